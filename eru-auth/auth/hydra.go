@@ -73,7 +73,7 @@ func (hydra HydraConfig) GetLoginChallenge(ctx context.Context) (loginChallenge
 	b := make([]byte, 32)
 	_, err = rand.Read(b)
 	if err != nil {
-		logs.WithContext(ctx).Error(fmt.Sprint("generate state failed: %v", err.Error()))
+		logs.WithContext(ctx).Error(fmt.Sprint("generate state failed: ", err.Error()))
 		return
 	}
 	state := base64.StdEncoding.EncodeToString(b)
@@ -85,7 +85,7 @@ func (hydra HydraConfig) GetLoginChallenge(ctx context.Context) (loginChallenge
 	}
 	outhConfig, ocErr := hydra.GetOauthConfig(ctx, hydraClientId)
 	if ocErr != nil {
-		logs.WithContext(ctx).Error(fmt.Sprint("generate state failed: %v", err.Error()))
+		logs.WithContext(ctx).Error(fmt.Sprint("generate state failed: ", err.Error()))
 		err = ocErr
 		return
 	}
@@ -493,7 +493,7 @@ func (hydraConfig HydraConfig) AcceptConsentRequest(ctx context.Context, identit
 				token, tokenErr := outhConfig.Exchange(ctx, code)
 				if tokenErr != nil {
 					err = tokenErr
-					logs.WithContext(ctx).Error(fmt.Sprint("unable to exchange code for token: %s\n", err.Error()))
+					logs.WithContext(ctx).Error(fmt.Sprint("unable to exchange code for token: ", err.Error()))
 					return
 				}
 				idt := token.Extra("id_token")
@@ -3,9 +3,9 @@ module github.com/eru-tech/eru/eru-crypto
 go 1.20
 
 require (
+	github.com/eru-tech/eru/eru-logs v0.0.0-00010101000000-000000000000
 	github.com/golang-jwt/jwt/v4 v4.4.1
 	github.com/lestrrat-go/jwx v1.2.23
-	github.com/eru-tech/eru/eru-logs v0.0.0-00010101000000-000000000000
 )
 
 require (
@@ -17,9 +17,10 @@ require (
 	github.com/lestrrat-go/iter v1.0.1 // indirect
 	github.com/lestrrat-go/option v1.0.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
+	go.uber.org/atomic v1.10.0 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	go.uber.org/zap v1.24.0 // indirect
 	golang.org/x/crypto v0.0.0-20220214200702-86341886e292 // indirect
 )
 
-replace (
-	github.com/eru-tech/eru/eru-logs => ../eru-logs
-)
\ No newline at end of file
+replace github.com/eru-tech/eru/eru-logs => ../eru-logs
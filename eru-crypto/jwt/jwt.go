@@ -14,7 +14,7 @@ func FetchKeyJWK(ctx context.Context, kid string, jwkurl string) (interface{}, e
 	// TODO Use jwk.AutoRefresh if you intend to keep reuse the JWKS over and over
 	set, err := jwk.Fetch(ctx, jwkurl)
 	if err != nil {
-		logs.WithContext(ctx).Error(fmt.Sprint("failed to parse JWK: %s", err))
+		logs.WithContext(ctx).Error(fmt.Sprint("failed to parse JWK: ", err))
 		return nil, errors.New("Failed to parse JWK")
 	}
 
@@ -345,7 +345,7 @@ func FileUploadHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 			err = r.ParseForm()
 		}
 		if err != nil {
-			logs.WithContext(r.Context()).Error(fmt.Sprint("Could not parse form: %s", err))
+			logs.WithContext(r.Context()).Error(fmt.Sprint("Could not parse form: ", err))
 			return
 		}
 	}
@@ -3,6 +3,7 @@ package module_model
 import (
 	"context"
 	"github.com/eru-tech/eru/eru-crypto/jwt"
+	utils "github.com/eru-tech/eru/eru-utils"
 )
 
 type StoreCompare struct {
@@ -37,6 +38,20 @@ type ListenerRule struct {
 	AuthorizerName        string
 	AuthorizerException   []PathStruct
 	AuthorizerExceptionIP []string
+	Stickiness            Stickiness
+	TargetOverride        TargetOverride
+	RateLimit             utils.RateLimit
+}
+
+type Stickiness struct {
+	Type            string
+	Key             string
+	DurationSeconds int
+}
+
+type TargetOverride struct {
+	HeaderKey  string
+	CookieName string
 }
 
 type MapStruct struct {
@@ -27,13 +27,21 @@ func RouteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 		host, url := extractHostUrl(r)
 		logs.WithContext(r.Context()).Info(host)
 		logs.WithContext(r.Context()).Info(url)
-		tg, authorizer, addHeaders, err := s.GetTargetGroupAuthorizer(r.Context(), r)
+		listenerRule, tg, authorizer, err := s.GetTargetGroupAuthorizer(r.Context(), r)
 		if err != nil {
 			server_handlers.FormatResponse(w, 400)
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": "suspicious activity"})
 			return
 		}
+		if module_store.ExposeListenerRule {
+			w.Header().Set(module_store.ListenerRuleHeader, listenerRule.RuleName)
+		}
+		// computing sticky cookie before request is rewritten for target host
+		stickyCookie := module_store.StickyCookie(listenerRule, r, tg)
 		logs.WithContext(r.Context()).Info(fmt.Sprint("authorizer.AuthorizerName = ", authorizer.AuthorizerName))
+		// claims header sent by the client is never passed on - only claims of the verified token are set below
+		r.Header.Del("claims")
+		var claims interface{}
 		if authorizer.AuthorizerName != "" {
 			token := r.Header.Get(authorizer.TokenHeaderKey)
 			if token == "" {
@@ -43,7 +51,7 @@ func RouteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 				logs.WithContext(r.Context()).Info(fmt.Sprint(http.StatusUnauthorized))
 				return
 			}
-			claims, err := authorizer.VerifyToken(r.Context(), r.Header.Get(authorizer.TokenHeaderKey))
+			claims, err = authorizer.VerifyToken(r.Context(), r.Header.Get(authorizer.TokenHeaderKey))
 			if err != nil {
 				server_handlers.FormatResponse(w, http.StatusUnauthorized)
 				_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
@@ -56,10 +64,21 @@ func RouteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 				_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 				return
 			}
-			r.Header.Add("claims", string(claimsBytes))
+			r.Header.Set("claims", string(claimsBytes))
+		}
+
+		// rate limit is checked after authorizer so that claim based limits are keyed on verified claims
+		if listenerRule.RateLimit.IsSet() {
+			rlResult := utils.CheckRateLimit(r.Context(), r, listenerRule.RuleName, listenerRule.RateLimit, claims)
+			utils.SetRateLimitHeaders(w.Header(), rlResult)
+			if !rlResult.Allowed {
+				server_handlers.FormatResponse(w, http.StatusTooManyRequests)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded"})
+				return
+			}
 		}
 
-		for _, v := range addHeaders {
+		for _, v := range listenerRule.AddHeaders {
 			headerValue := ""
 			if v.IsTemplate {
 				goTmpl := gotemplate.GoTemplate{v.Key, v.Value}
@@ -120,6 +139,9 @@ func RouteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 			//logs.WithContext(r.Context()).Info(fmt.Sprint(k, " - ", v))
 			w.Header()[k] = v
 		}
+		if stickyCookie != nil {
+			http.SetCookie(w, stickyCookie)
+		}
 		w.WriteHeader(response.StatusCode)
 		_, err = io.Copy(w, response.Body)
 		if err != nil {
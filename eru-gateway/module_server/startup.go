@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"github.com/eru-tech/eru/eru-gateway/module_store"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	utils "github.com/eru-tech/eru/eru-utils"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -46,5 +48,11 @@ func StartUp() (module_store.ModuleStoreI, error) {
 	} else {
 		logs.WithContext(context.Background()).Error(err.Error())
 	}
+	if err == nil {
+		err = utils.SetRateLimiter(context.Background())
+	}
+	if exposeListenerRule, parseErr := strconv.ParseBool(os.Getenv("EXPOSE_LISTENER_RULE")); parseErr == nil {
+		module_store.ExposeListenerRule = exposeListenerRule
+	}
 	return myStore, err
 }
@@ -0,0 +1,129 @@
+package module_store
+
+import (
+	"context"
+	"fmt"
+	"github.com/eru-tech/eru/eru-gateway/module_model"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const ListenerRuleHeader = "X-Eru-Listener-Rule"
+
+// ExposeListenerRule sets name of the matched listener rule in ListenerRuleHeader of the response - set from EXPOSE_LISTENER_RULE for debugging as rule names are internal
+var ExposeListenerRule = false
+
+// sortedListenerRules returns a copy of listener rules sorted on RuleRank so that store order is left untouched
+func sortedListenerRules(listenerRules []*module_model.ListenerRule) []*module_model.ListenerRule {
+	rules := make([]*module_model.ListenerRule, len(listenerRules))
+	copy(rules, listenerRules)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].RuleRank < rules[j].RuleRank
+	})
+	return rules
+}
+
+// matchListenerRule returns true only if request matches every dimension configured in the listener rule.
+// Within a dimension, any one of hosts, methods, paths or source ips is sufficient while all headers and params must match.
+func matchListenerRule(ctx context.Context, lr *module_model.ListenerRule, r *http.Request) bool {
+	if len(lr.Hosts) > 0 && !matchHost(lr.Hosts, r.Host) {
+		return false
+	}
+	if len(lr.Methods) > 0 && !matchMethod(lr.Methods, r.Method) {
+		return false
+	}
+	if len(lr.Paths) > 0 && !matchPath(lr.Paths, r.URL.Path) {
+		return false
+	}
+	for _, header := range lr.Headers {
+		if r.Header.Get(header.Key) != header.Value {
+			return false
+		}
+	}
+	if len(lr.Params) > 0 {
+		reqParams := r.URL.Query()
+		for _, param := range lr.Params {
+			if reqParams.Get(param.Key) != param.Value {
+				return false
+			}
+		}
+	}
+	if len(lr.SourceIP) > 0 && !matchSourceIP(ctx, lr.SourceIP, r.RemoteAddr) {
+		return false
+	}
+	logs.WithContext(ctx).Info(fmt.Sprint("listener rule matched = ", lr.RuleName))
+	return true
+}
+
+// matchHost supports exact hosts, "*" to match any host and "*.example.com" to match any subdomain of example.com
+func matchHost(hosts []string, reqHost string) bool {
+	reqHost = strings.ToLower(strings.Split(reqHost, ":")[0])
+	for _, host := range hosts {
+		host = strings.ToLower(host)
+		if host == "*" || host == reqHost {
+			return true
+		}
+		if strings.HasPrefix(host, "*.") && strings.HasSuffix(reqHost, host[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchMethod(methods []string, reqMethod string) bool {
+	for _, method := range methods {
+		if strings.EqualFold(method, reqMethod) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(paths []module_model.PathStruct, reqPath string) bool {
+	for _, path := range paths {
+		switch path.MatchType {
+		case MatchTypePrefix:
+			if strings.HasPrefix(reqPath, path.Path) {
+				return true
+			}
+		case MatchTypeExact:
+			if reqPath == path.Path {
+				return true
+			}
+		default:
+			//do nothing
+		}
+	}
+	return false
+}
+
+// matchSourceIP supports single ip addresses as well as CIDR ranges such as 10.0.0.0/8
+func matchSourceIP(ctx context.Context, sourceIPs []string, remoteAddr string) bool {
+	reqIPStr, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		reqIPStr = remoteAddr
+	}
+	reqIP := net.ParseIP(reqIPStr)
+	if reqIP == nil {
+		logs.WithContext(ctx).Info(fmt.Sprint("unable to parse remote address = ", remoteAddr))
+		return false
+	}
+	for _, sourceIP := range sourceIPs {
+		if strings.Contains(sourceIP, "/") {
+			_, ipNet, cidrErr := net.ParseCIDR(sourceIP)
+			if cidrErr != nil {
+				logs.WithContext(ctx).Error(fmt.Sprint("invalid CIDR in listener rule = ", sourceIP, " : ", cidrErr.Error()))
+				continue
+			}
+			if ipNet.Contains(reqIP) {
+				return true
+			}
+		} else if ip := net.ParseIP(sourceIP); ip != nil && ip.Equal(reqIP) {
+			return true
+		}
+	}
+	return false
+}
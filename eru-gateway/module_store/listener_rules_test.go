@@ -0,0 +1,86 @@
+package module_store
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-gateway/module_model"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logs.LogInit("eru-gateway-test")
+	os.Exit(m.Run())
+}
+
+func TestMatchListenerRule(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		lr     module_model.ListenerRule
+		method string
+		url    string
+		header map[string]string
+		remote string
+		want   bool
+	}{
+		{"empty rule matches any request", module_model.ListenerRule{}, "GET", "http://a.com/x", nil, "", true},
+		{"exact host", module_model.ListenerRule{Hosts: []string{"a.com"}}, "GET", "http://A.com:8080/x", nil, "", true},
+		{"other host", module_model.ListenerRule{Hosts: []string{"a.com"}}, "GET", "http://b.com/x", nil, "", false},
+		{"wildcard subdomain", module_model.ListenerRule{Hosts: []string{"*.a.com"}}, "GET", "http://api.a.com/x", nil, "", true},
+		{"wildcard does not match apex", module_model.ListenerRule{Hosts: []string{"*.a.com"}}, "GET", "http://a.com/x", nil, "", false},
+		{"method", module_model.ListenerRule{Methods: []string{"post"}}, "GET", "http://a.com/x", nil, "", false},
+		{"prefix path", module_model.ListenerRule{Paths: []module_model.PathStruct{{MatchType: MatchTypePrefix, Path: "/api"}}}, "GET", "http://a.com/api/v1", nil, "", true},
+		{"exact path", module_model.ListenerRule{Paths: []module_model.PathStruct{{MatchType: MatchTypeExact, Path: "/api"}}}, "GET", "http://a.com/api/v1", nil, "", false},
+		{"all headers must match", module_model.ListenerRule{Headers: []module_model.MapStruct{{Key: "X-A", Value: "1"}, {Key: "X-B", Value: "2"}}}, "GET", "http://a.com/", map[string]string{"X-A": "1"}, "", false},
+		{"params", module_model.ListenerRule{Params: []module_model.MapStruct{{Key: "v", Value: "2"}}}, "GET", "http://a.com/?v=2", nil, "", true},
+		{"source ip cidr", module_model.ListenerRule{SourceIP: []string{"10.0.0.0/8"}}, "GET", "http://a.com/", nil, "10.1.2.3:5000", true},
+		{"source ip outside cidr", module_model.ListenerRule{SourceIP: []string{"10.0.0.0/8", "192.168.1.1"}}, "GET", "http://a.com/", nil, "192.168.1.2:5000", false},
+		{"every dimension must match", module_model.ListenerRule{Hosts: []string{"a.com"}, Methods: []string{"GET"}}, "POST", "http://a.com/", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.url, nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if tt.remote != "" {
+				r.RemoteAddr = tt.remote
+			}
+			if got := matchListenerRule(ctx, &tt.lr, r); got != tt.want {
+				t.Errorf("matchListenerRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTargetGroupAuthorizerRankOrder(t *testing.T) {
+	ctx := context.Background()
+	th := []module_model.TargetHost{{Host: "t"}}
+	ms := ModuleStore{ListenerRules: []*module_model.ListenerRule{
+		{RuleRank: 3, RuleName: "catch-all", TargetHosts: th},
+		{RuleRank: 1, RuleName: "api", Paths: []module_model.PathStruct{{MatchType: MatchTypePrefix, Path: "/api"}}, TargetHosts: th},
+		{RuleRank: 2, RuleName: "host", Hosts: []string{"a.com"}, TargetHosts: th},
+	}}
+	tests := []struct {
+		url      string
+		wantRule string
+	}{
+		{"http://a.com/api/x", "api"},
+		{"http://a.com/x", "host"},
+		{"http://b.com/x", "catch-all"},
+	}
+	for _, tt := range tests {
+		lr, _, _, err := ms.GetTargetGroupAuthorizer(ctx, httptest.NewRequest("GET", tt.url, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lr.RuleName != tt.wantRule {
+			t.Errorf("%s matched rule %s, want %s", tt.url, lr.RuleName, tt.wantRule)
+		}
+	}
+	if ms.ListenerRules[0].RuleName != "catch-all" {
+		t.Errorf("order of listener rules in store is expected to be left untouched")
+	}
+}
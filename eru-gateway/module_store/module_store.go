@@ -10,7 +10,6 @@ import (
 	utils "github.com/eru-tech/eru/eru-utils"
 	"github.com/google/go-cmp/cmp"
 	"net/http"
-	"strings"
 )
 
 type StoreHolder struct {
@@ -23,7 +22,7 @@ type ModuleStoreI interface {
 	RemoveListenerRule(ctx context.Context, listenerRuleName string, realStore ModuleStoreI) error
 	GetListenerRules(ctx context.Context) []*module_model.ListenerRule
 	GetListenerRule(ctx context.Context, listenerRuleName string) (*module_model.ListenerRule, error)
-	GetTargetGroupAuthorizer(ctx context.Context, r *http.Request) (module_model.TargetHost, module_model.Authorizer, []module_model.MapStructCustom, error)
+	GetTargetGroupAuthorizer(ctx context.Context, r *http.Request) (*module_model.ListenerRule, module_model.TargetHost, module_model.Authorizer, error)
 	SaveAuthorizer(ctx context.Context, authorizer module_model.Authorizer, realStore ModuleStoreI, persist bool) error
 	RemoveAuthorizer(ctx context.Context, authorizerName string, realStore ModuleStoreI) error
 	GetAuthorizer(ctx context.Context, authorizerName string) (module_model.Authorizer, error)
@@ -48,125 +47,40 @@ type ModuleDbStore struct {
 	ModuleStore
 }
 
-func (ms *ModuleStore) GetTargetGroupAuthorizer(ctx context.Context, r *http.Request) (module_model.TargetHost, module_model.Authorizer, []module_model.MapStructCustom, error) {
+func (ms *ModuleStore) GetTargetGroupAuthorizer(ctx context.Context, r *http.Request) (*module_model.ListenerRule, module_model.TargetHost, module_model.Authorizer, error) {
 	logs.WithContext(ctx).Debug("GetTargetGroupAuthorizer - Start")
-	listenerRuleFound := false
-	if ms.ListenerRules != nil {
-		for _, v := range ms.ListenerRules {
-			//TODO to sort the array on RuleRank before looping
-			//check for hosts
-			for _, host := range v.Hosts {
-				if strings.Split(r.Host, ":")[0] == host {
-					logs.WithContext(ctx).Info(fmt.Sprint("host match = ", host))
-					listenerRuleFound = true
-					break
-				}
-			}
-
-			//check for Methods
-			for _, method := range v.Methods {
-				//resetting listenerRuleFound to false as Method array length > 1 - so it has to pass this match too
-				listenerRuleFound = false
-				if r.Method == method {
-					logs.WithContext(ctx).Info(fmt.Sprint("method match = ", method))
-					listenerRuleFound = true
-					break
-				}
-			}
-
-			//check for Paths
-			for _, path := range v.Paths {
-				//resetting listenerRuleFound to false as Path array length > 1 - so it has to pass this match too
-				listenerRuleFound = false
-				switch path.MatchType {
-				case MatchTypePrefix:
-					if strings.HasPrefix(r.URL.Path, path.Path) {
-						logs.WithContext(ctx).Info(fmt.Sprint("path match = ", path.Path))
-						listenerRuleFound = true
-						break
-					}
-				case MatchTypeExact:
-					if r.URL.Path == path.Path {
-						logs.WithContext(ctx).Info(fmt.Sprint("path match = ", path.Path))
-						listenerRuleFound = true
-						break
-					}
-				default:
-					//do nothing
-				}
-			}
-			//check for Headers
-			for _, header := range v.Headers {
-				//resetting listenerRuleFound to false as Headers array length > 1 - so it has to pass this match too
-				listenerRuleFound = false
-				if r.Header.Get(header.Key) == header.Value {
-					logs.WithContext(ctx).Info(fmt.Sprint("header match = ", header.Key, " = ", header.Value))
-					listenerRuleFound = true
-					break
-				}
-			}
-			//check for Params
-			for _, param := range v.Params {
-				//resetting listenerRuleFound to false as Headers array length > 1 - so it has to pass this match too
-				reqParams := r.URL.Query()
-				listenerRuleFound = false
-				if reqParams.Get(param.Key) == param.Value {
-					listenerRuleFound = true
-					logs.WithContext(ctx).Info(fmt.Sprint("param match = ", param.Key, " = ", param.Value))
-					r.URL.RawQuery = reqParams.Encode()
-					break
-				}
-				r.URL.RawQuery = reqParams.Encode()
-			}
-
-			//check for SourceIP
-			for _, sourceIP := range v.SourceIP {
-				//resetting listenerRuleFound to false as SourceIP array length > 1 - so it has to pass this match too
-				listenerRuleFound = false
-				if strings.Split(r.RemoteAddr, ":")[0] == sourceIP {
-					logs.WithContext(ctx).Info(fmt.Sprint("sourceIP match = ", sourceIP))
-					listenerRuleFound = true
-					break
-				}
-			}
-			logs.WithContext(ctx).Info(fmt.Sprint("listenerRuleFound = ", listenerRuleFound))
-			if listenerRuleFound {
-				pathExceptionFound := false
-				for _, pathException := range v.AuthorizerException {
-					switch pathException.MatchType {
-					case MatchTypePrefix:
-						if strings.HasPrefix(r.URL.Path, pathException.Path) {
-							logs.WithContext(ctx).Info(fmt.Sprint("pathException MatchTypePrefix = ", pathException.Path))
-							pathExceptionFound = true
-							r.Header.Set("is_public", "true")
-							break
-						}
-					case MatchTypeExact:
-						if r.URL.Path == pathException.Path {
-							logs.WithContext(ctx).Info(fmt.Sprint("pathException MatchTypeExact = ", pathException.Path))
-							pathExceptionFound = true
-							r.Header.Set("is_public", "true")
-							break
-						}
-					default:
-						//do nothing
-					}
-				}
-				if pathExceptionFound || v.AuthorizerName == "" {
-					return v.TargetHosts[0], module_model.Authorizer{}, v.AddHeaders, nil
-				} else {
-					authorizer, err := ms.GetAuthorizer(ctx, v.AuthorizerName)
-					if err != nil {
-						return module_model.TargetHost{}, module_model.Authorizer{}, nil, err
-					}
-					return v.TargetHosts[0], authorizer, v.AddHeaders, nil
-				}
+	for _, v := range sortedListenerRules(ms.ListenerRules) {
+		if !matchListenerRule(ctx, v, r) {
+			continue
+		}
+		if len(v.TargetHosts) == 0 {
+			err := errors.New(fmt.Sprint("No Target Host defined for listener rule ", v.RuleName))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, module_model.TargetHost{}, module_model.Authorizer{}, err
+		}
+		pathExceptionFound := false
+		for _, pathException := range v.AuthorizerException {
+			if matchPath([]module_model.PathStruct{pathException}, r.URL.Path) {
+				logs.WithContext(ctx).Info(fmt.Sprint("pathException ", pathException.MatchType, " = ", pathException.Path))
+				pathExceptionFound = true
+				r.Header.Set("is_public", "true")
+				break
 			}
 		}
+		targetHost := selectTargetHost(ctx, v, r)
+		logs.WithContext(ctx).Info(fmt.Sprint("target host selected = ", targetHostId(targetHost)))
+		if pathExceptionFound || v.AuthorizerName == "" {
+			return v, targetHost, module_model.Authorizer{}, nil
+		}
+		authorizer, err := ms.GetAuthorizer(ctx, v.AuthorizerName)
+		if err != nil {
+			return nil, module_model.TargetHost{}, module_model.Authorizer{}, err
+		}
+		return v, targetHost, authorizer, nil
 	}
 	err := errors.New(fmt.Sprint("Listener Rule not found for request host = ", r.Host, " and path = ", r.URL))
 	logs.WithContext(ctx).Error(err.Error())
-	return module_model.TargetHost{}, module_model.Authorizer{}, nil, err
+	return nil, module_model.TargetHost{}, module_model.Authorizer{}, err
 }
 
 func (ms *ModuleStore) GetListenerRule(ctx context.Context, listenerRuleName string) (*module_model.ListenerRule, error) {
@@ -0,0 +1,140 @@
+package module_store
+
+import (
+	"context"
+	"fmt"
+	"github.com/eru-tech/eru/eru-gateway/module_model"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const StickinessTypeCookie = "COOKIE"
+const StickinessTypeHeader = "HEADER"
+const defaultStickyCookieName = "eru_target"
+
+// targetHostId identifies a target host in sticky cookies and override headers - Name if set, else host and port
+func targetHostId(th module_model.TargetHost) string {
+	if th.Name != "" {
+		return th.Name
+	}
+	if th.Port != "" {
+		return fmt.Sprint(th.Host, ":", th.Port)
+	}
+	return th.Host
+}
+
+func findTargetHost(targetHosts []module_model.TargetHost, id string) (module_model.TargetHost, bool) {
+	if id == "" {
+		return module_model.TargetHost{}, false
+	}
+	for _, th := range targetHosts {
+		if targetHostId(th) == id {
+			return th, true
+		}
+	}
+	return module_model.TargetHost{}, false
+}
+
+// weightedTargetHosts returns only those target hosts which are allocated traffic.
+// If no allocation is configured, all target hosts share the traffic equally.
+func weightedTargetHosts(targetHosts []module_model.TargetHost) (weighted []module_model.TargetHost, totalAllocation int64) {
+	for _, th := range targetHosts {
+		if th.Allocation > 0 {
+			weighted = append(weighted, th)
+			totalAllocation = totalAllocation + th.Allocation
+		}
+	}
+	if totalAllocation == 0 {
+		for _, th := range targetHosts {
+			th.Allocation = 1
+			weighted = append(weighted, th)
+		}
+		totalAllocation = int64(len(targetHosts))
+	}
+	return
+}
+
+func pickTargetHost(weighted []module_model.TargetHost, pick int64) module_model.TargetHost {
+	for _, th := range weighted {
+		if pick < th.Allocation {
+			return th
+		}
+		pick = pick - th.Allocation
+	}
+	return weighted[len(weighted)-1]
+}
+
+func stickyCookieName(lr *module_model.ListenerRule) string {
+	if lr.Stickiness.Key != "" {
+		return lr.Stickiness.Key
+	}
+	return defaultStickyCookieName
+}
+
+// selectTargetHost picks a target host of the listener rule in below order
+// 1. target forced by override header or cookie
+// 2. target recorded in sticky cookie if it is still allocated traffic
+// 3. target derived from hash of sticky header value
+// 4. random target weighted on allocation
+func selectTargetHost(ctx context.Context, lr *module_model.ListenerRule, r *http.Request) module_model.TargetHost {
+	logs.WithContext(ctx).Debug("selectTargetHost - Start")
+	if lr.TargetOverride.HeaderKey != "" {
+		if th, ok := findTargetHost(lr.TargetHosts, r.Header.Get(lr.TargetOverride.HeaderKey)); ok {
+			logs.WithContext(ctx).Info(fmt.Sprint("target host forced by header = ", targetHostId(th)))
+			return th
+		}
+	}
+	if lr.TargetOverride.CookieName != "" {
+		if c, err := r.Cookie(lr.TargetOverride.CookieName); err == nil {
+			if th, ok := findTargetHost(lr.TargetHosts, c.Value); ok {
+				logs.WithContext(ctx).Info(fmt.Sprint("target host forced by cookie = ", targetHostId(th)))
+				return th
+			}
+		}
+	}
+
+	weighted, totalAllocation := weightedTargetHosts(lr.TargetHosts)
+	switch lr.Stickiness.Type {
+	case StickinessTypeCookie:
+		if c, err := r.Cookie(stickyCookieName(lr)); err == nil {
+			if th, ok := findTargetHost(weighted, c.Value); ok {
+				return th
+			}
+		}
+	case StickinessTypeHeader:
+		if hv := r.Header.Get(lr.Stickiness.Key); hv != "" {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(hv))
+			return pickTargetHost(weighted, int64(h.Sum32())%totalAllocation)
+		}
+	default:
+		//do nothing
+	}
+	return pickTargetHost(weighted, rand.Int63n(totalAllocation))
+}
+
+// StickyCookie returns the cookie to be set on response so that next request of the client is routed to the same target host.
+// It returns nil if cookie stickiness is not configured or the client already carries the cookie for this target host.
+func StickyCookie(lr *module_model.ListenerRule, r *http.Request, th module_model.TargetHost) *http.Cookie {
+	if lr.Stickiness.Type != StickinessTypeCookie {
+		return nil
+	}
+	cookieName := stickyCookieName(lr)
+	if c, err := r.Cookie(cookieName); err == nil && c.Value == targetHostId(th) {
+		return nil
+	}
+	cookie := &http.Cookie{
+		Name:     cookieName,
+		Value:    targetHostId(th),
+		Path:     "/",
+		HttpOnly: true,
+	}
+	if lr.Stickiness.DurationSeconds > 0 {
+		cookie.MaxAge = lr.Stickiness.DurationSeconds
+		cookie.Expires = time.Now().Add(time.Duration(lr.Stickiness.DurationSeconds) * time.Second)
+	}
+	return cookie
+}
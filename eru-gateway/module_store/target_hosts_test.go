@@ -0,0 +1,125 @@
+package module_store
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-gateway/module_model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSelectTargetHostAllocation(t *testing.T) {
+	ctx := context.Background()
+	a := module_model.TargetHost{Name: "a", Host: "a.com", Scheme: "http", Allocation: 75}
+	b := module_model.TargetHost{Name: "b", Host: "b.com", Scheme: "http", Allocation: 25}
+	c := module_model.TargetHost{Host: "c.com", Port: "8080", Scheme: "http"}
+	d := module_model.TargetHost{Host: "d.com", Scheme: "http"}
+	tests := []struct {
+		name      string
+		hosts     []module_model.TargetHost
+		wantShare map[string]float64 // expected share of picks of each target host id
+	}{
+		{"weighted on allocation", []module_model.TargetHost{a, b}, map[string]float64{"a": 0.75, "b": 0.25}},
+		{"host without allocation gets no traffic", []module_model.TargetHost{a, c}, map[string]float64{"a": 1}},
+		{"equal share without allocations", []module_model.TargetHost{c, d}, map[string]float64{"c.com:8080": 0.5, "d.com": 0.5}},
+		{"single host", []module_model.TargetHost{d}, map[string]float64{"d.com": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := &module_model.ListenerRule{TargetHosts: tt.hosts}
+			picks := make(map[string]int)
+			const n = 5000
+			for i := 0; i < n; i++ {
+				picks[targetHostId(selectTargetHost(ctx, lr, httptest.NewRequest("GET", "/", nil)))]++
+			}
+			for id := range picks {
+				if _, ok := tt.wantShare[id]; !ok {
+					t.Errorf("target host %s is not expected to be picked", id)
+				}
+			}
+			for id, share := range tt.wantShare {
+				got := float64(picks[id]) / n
+				if got < share-0.05 || got > share+0.05 {
+					t.Errorf("target host %s picked %.2f of requests, want %.2f", id, got, share)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectTargetHostStickinessAndOverride(t *testing.T) {
+	ctx := context.Background()
+	a := module_model.TargetHost{Name: "a", Host: "a.com", Scheme: "http", Allocation: 50}
+	b := module_model.TargetHost{Name: "b", Host: "b.com", Scheme: "http", Allocation: 50}
+	z := module_model.TargetHost{Name: "z", Host: "z.com", Scheme: "http"}
+	hosts := []module_model.TargetHost{a, b, z}
+	tests := []struct {
+		name       string
+		stickiness module_model.Stickiness
+		override   module_model.TargetOverride
+		header     map[string]string
+		cookies    map[string]string
+		want       string
+	}{
+		{"sticky cookie", module_model.Stickiness{Type: StickinessTypeCookie}, module_model.TargetOverride{}, nil, map[string]string{defaultStickyCookieName: "b"}, "b"},
+		{"sticky cookie with custom name", module_model.Stickiness{Type: StickinessTypeCookie, Key: "tgt"}, module_model.TargetOverride{}, nil, map[string]string{"tgt": "a"}, "a"},
+		{"override header", module_model.Stickiness{Type: StickinessTypeCookie}, module_model.TargetOverride{HeaderKey: "X-Target"}, map[string]string{"X-Target": "b"}, map[string]string{defaultStickyCookieName: "a"}, "b"},
+		// override can force a target host which is not allocated traffic
+		{"override cookie", module_model.Stickiness{}, module_model.TargetOverride{CookieName: "force"}, nil, map[string]string{"force": "z"}, "z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := &module_model.ListenerRule{TargetHosts: hosts, Stickiness: tt.stickiness, TargetOverride: tt.override}
+			for i := 0; i < 50; i++ {
+				r := httptest.NewRequest("GET", "/", nil)
+				for k, v := range tt.header {
+					r.Header.Set(k, v)
+				}
+				for k, v := range tt.cookies {
+					r.AddCookie(&http.Cookie{Name: k, Value: v})
+				}
+				if got := targetHostId(selectTargetHost(ctx, lr, r)); got != tt.want {
+					t.Fatalf("selectTargetHost() = %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+
+	// sticky cookie of target host which is no longer allocated traffic is ignored
+	lr := &module_model.ListenerRule{TargetHosts: hosts, Stickiness: module_model.Stickiness{Type: StickinessTypeCookie}}
+	for i := 0; i < 50; i++ {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: defaultStickyCookieName, Value: "z"})
+		if got := targetHostId(selectTargetHost(ctx, lr, r)); got == "z" {
+			t.Fatal("target host without allocation is not expected to be picked from sticky cookie")
+		}
+	}
+
+	// same sticky header value always goes to the same target host
+	lr = &module_model.ListenerRule{TargetHosts: hosts, Stickiness: module_model.Stickiness{Type: StickinessTypeHeader, Key: "X-User"}}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-User", "u1")
+	first := targetHostId(selectTargetHost(ctx, lr, r))
+	for i := 0; i < 50; i++ {
+		if got := targetHostId(selectTargetHost(ctx, lr, r)); got != first {
+			t.Fatalf("sticky header routed to %s and %s", first, got)
+		}
+	}
+}
+
+func TestStickyCookie(t *testing.T) {
+	a := module_model.TargetHost{Name: "a", Host: "a.com", Scheme: "http"}
+	lr := &module_model.ListenerRule{Stickiness: module_model.Stickiness{Type: StickinessTypeCookie, DurationSeconds: 60}}
+	c := StickyCookie(lr, httptest.NewRequest("GET", "/", nil), a)
+	if c == nil || c.Name != defaultStickyCookieName || c.Value != "a" || c.MaxAge != 60 {
+		t.Fatalf("unexpected sticky cookie %+v", c)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: defaultStickyCookieName, Value: "a"})
+	if c = StickyCookie(lr, r, a); c != nil {
+		t.Errorf("cookie is not expected to be set again for same target host")
+	}
+	if c = StickyCookie(&module_model.ListenerRule{}, httptest.NewRequest("GET", "/", nil), a); c != nil {
+		t.Errorf("cookie is not expected without cookie stickiness")
+	}
+}
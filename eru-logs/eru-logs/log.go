@@ -50,10 +50,34 @@ func LogInit(serviceName string) {
 	defer Logger.Sync()
 }
 
+// NewContext returns context with logger having the fields - string fields are also kept in context to be read by FieldValue
 func NewContext(ctx context.Context, fields ...zap.Field) context.Context {
+	ctxFields := make(map[string]string)
+	if parentFields, ok := ctx.Value("ctxfields").(map[string]string); ok {
+		for k, v := range parentFields {
+			ctxFields[k] = v
+		}
+	}
+	for _, f := range fields {
+		if f.Type == zapcore.StringType {
+			ctxFields[f.Key] = f.String
+		}
+	}
+	ctx = context.WithValue(ctx, "ctxfields", ctxFields)
 	return context.WithValue(ctx, "ctxlog", WithContext(ctx).With(fields...))
 }
 
+// FieldValue returns value of the string field added to context by NewContext e.g. request id - blank if field is not found
+func FieldValue(ctx context.Context, key string) string {
+	if ctx == nil {
+		return ""
+	}
+	if ctxFields, ok := ctx.Value("ctxfields").(map[string]string); ok {
+		return ctxFields[key]
+	}
+	return ""
+}
+
 func WithContext(ctx context.Context) *zap.Logger {
 	if ctx == nil {
 		return Logger
@@ -0,0 +1,262 @@
+package ds
+
+import (
+	"context"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type colRename struct {
+	from string
+	to   string
+}
+
+// colChange holds live and submitted definition of a column present in both
+// unspecified length and precision of submitted column are taken from live column so that they are not reported as changed
+type colChange struct {
+	live           module_model.TableColsMetaData
+	col            module_model.TableColsMetaData
+	typeChanged    bool
+	nullChanged    bool
+	defaultChanged bool
+}
+
+type constraintDef struct {
+	name string
+	cols []string
+}
+
+// tableDiff holds changes to be made to live table to match the submitted table
+type tableDiff struct {
+	renameCols []colRename
+	dropCols   []module_model.TableColsMetaData
+	addCols    []module_model.TableColsMetaData
+	changeCols []colChange
+	dropPk     *constraintDef
+	addPk      *constraintDef
+	dropUqs    []constraintDef
+	addUqs     []constraintDef
+	dropFks    []string
+	addFks     []module_model.TableColsMetaData
+}
+
+// diffTable compares submitted table with live table - colType returns the db data type of column and is used to detect type change.
+// a column is renamed if it is submitted with OldColName of a live column which is not submitted.
+func diffTable(ctx context.Context, tableName string, liveTableObj map[string]module_model.TableColsMetaData, tableObj map[string]module_model.TableColsMetaData, colType func(col module_model.TableColsMetaData) (string, error)) (td tableDiff, err error) {
+	logs.WithContext(ctx).Debug("diffTable - Start")
+	live := make(map[string]module_model.TableColsMetaData)
+	for k, v := range liveTableObj {
+		if v.ColName == "" {
+			v.ColName = k
+		}
+		live[v.ColName] = v
+	}
+	cols := make(map[string]module_model.TableColsMetaData)
+	for k, v := range tableObj {
+		if v.ColName == "" {
+			v.ColName = k
+		}
+		cols[v.ColName] = v
+	}
+
+	// matched holds live column of each submitted column present in live table, renamed or not
+	matched := make(map[string]module_model.TableColsMetaData)
+	renamedFrom := make(map[string]bool)
+	for _, c := range sortCols(cols) {
+		if l, ok := live[c.ColName]; ok {
+			matched[c.ColName] = l
+			continue
+		}
+		if l, ok := live[c.OldColName]; ok && c.OldColName != "" {
+			if _, stillThere := cols[c.OldColName]; !stillThere && !renamedFrom[c.OldColName] {
+				renamedFrom[c.OldColName] = true
+				td.renameCols = append(td.renameCols, colRename{from: c.OldColName, to: c.ColName})
+				matched[c.ColName] = l
+				continue
+			}
+		}
+		td.addCols = append(td.addCols, c)
+	}
+	for _, l := range sortCols(live) {
+		if _, ok := cols[l.ColName]; !ok && !renamedFrom[l.ColName] {
+			td.dropCols = append(td.dropCols, l)
+		}
+	}
+
+	for _, c := range sortCols(cols) {
+		l, ok := matched[c.ColName]
+		if !ok {
+			continue
+		}
+		if c.CharMaxLength <= 0 {
+			c.CharMaxLength = l.CharMaxLength
+		}
+		if c.NumericPrecision == "" {
+			c.NumericPrecision = l.NumericPrecision
+		}
+		if c.DatetimePrecision <= 0 {
+			c.DatetimePrecision = l.DatetimePrecision
+		}
+		cc := colChange{live: l, col: c}
+		newType, e := colType(c)
+		if e != nil {
+			return td, e
+		}
+		liveType, e := colType(l)
+		cc.typeChanged = e != nil || newType != liveType
+		cc.nullChanged = !c.PrimaryKey && c.IsNullable != l.IsNullable
+		cc.defaultChanged = !c.AutoIncrement && !l.AutoIncrement && c.DefaultValue != l.DefaultValue
+		if cc.typeChanged || cc.nullChanged || cc.defaultChanged {
+			td.changeCols = append(td.changeCols, cc)
+		}
+	}
+
+	// constraints of live table are compared with column names after rename
+	renamedTo := make(map[string]string)
+	for _, r := range td.renameCols {
+		renamedTo[r.from] = r.to
+	}
+	liveName := func(col string) string {
+		if to, ok := renamedTo[col]; ok {
+			return to
+		}
+		return col
+	}
+
+	livePk, newPk := &constraintDef{}, &constraintDef{}
+	liveUqs, newUqs := make(map[string][]string), make(map[string][]string)
+	for _, l := range sortCols(live) {
+		if l.PrimaryKey {
+			livePk.name = l.PkConstraintName
+			livePk.cols = append(livePk.cols, liveName(l.ColName))
+		} else if l.IsUnique && l.UqConstraintName != "" {
+			liveUqs[l.UqConstraintName] = append(liveUqs[l.UqConstraintName], liveName(l.ColName))
+		}
+	}
+	for _, c := range sortCols(cols) {
+		if c.PrimaryKey {
+			if c.PkConstraintName != "" {
+				newPk.name = c.PkConstraintName
+			}
+			newPk.cols = append(newPk.cols, c.ColName)
+		} else if c.IsUnique {
+			uqName := c.UqConstraintName
+			if uqName == "" {
+				uqName = fmt.Sprint("uq_", c.TblName, "_", c.ColName)
+			}
+			newUqs[uqName] = append(newUqs[uqName], c.ColName)
+		}
+	}
+	if newPk.name == "" {
+		newPk.name = livePk.name
+	}
+	if newPk.name == "" {
+		newPk.name = fmt.Sprint("pk_", strings.Replace(tableName, ".", "___", 1))
+	}
+	if !reflect.DeepEqual(livePk.cols, newPk.cols) {
+		if len(livePk.cols) > 0 {
+			td.dropPk = livePk
+		}
+		if len(newPk.cols) > 0 {
+			td.addPk = newPk
+		}
+	}
+	for _, k := range sortedKeys(liveUqs) {
+		if nc, ok := newUqs[k]; !ok || !reflect.DeepEqual(nc, liveUqs[k]) {
+			td.dropUqs = append(td.dropUqs, constraintDef{name: k, cols: liveUqs[k]})
+		}
+	}
+	for _, k := range sortedKeys(newUqs) {
+		if lc, ok := liveUqs[k]; !ok || !reflect.DeepEqual(lc, newUqs[k]) {
+			td.addUqs = append(td.addUqs, constraintDef{name: k, cols: newUqs[k]})
+		}
+	}
+
+	for _, c := range sortCols(cols) {
+		l, ok := matched[c.ColName]
+		if ok && l.FkTblSchema == c.FkTblSchema && l.FkTblName == c.FkTblName && l.FkColName == c.FkColName && strings.EqualFold(l.FkDeleteRule, c.FkDeleteRule) {
+			continue
+		}
+		if ok && l.FkConstraintName != "" {
+			td.dropFks = append(td.dropFks, l.FkConstraintName)
+		}
+		if c.FkTblName != "" {
+			if ok && c.FkConstraintName == "" {
+				c.FkConstraintName = l.FkConstraintName
+			}
+			td.addFks = append(td.addFks, c)
+		}
+	}
+	return td, nil
+}
+
+// sortCols returns columns in column position order - columns with same position are ordered by name
+func sortCols(cols map[string]module_model.TableColsMetaData) (sorted []module_model.TableColsMetaData) {
+	for _, c := range cols {
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].ColPosition == sorted[j].ColPosition {
+			return sorted[i].ColName < sorted[j].ColName
+		}
+		return sorted[i].ColPosition < sorted[j].ColPosition
+	})
+	return sorted
+}
+
+func sortedKeys(m map[string][]string) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// makeDefaultValue returns default value as sql - numbers, booleans, null and expressions with brackets e.g. now() are used as is and others are quoted
+func makeDefaultValue(val string) string {
+	if _, err := strconv.ParseFloat(val, 64); err == nil {
+		return val
+	}
+	switch strings.ToLower(val) {
+	case "true", "false", "null", "current_timestamp", "current_date":
+		return val
+	}
+	if strings.Contains(val, "(") || strings.HasPrefix(val, "'") {
+		return val
+	}
+	return fmt.Sprint("'", strings.Replace(val, "'", "''", -1), "'")
+}
+
+// makeFkName returns name of foreign key constraint of the column - same as name used while creating table
+func makeFkName(col module_model.TableColsMetaData) string {
+	if col.FkConstraintName != "" {
+		return col.FkConstraintName
+	}
+	return fmt.Sprint("fk_", col.TblName, col.ColName)
+}
+
+func makeFkReference(col module_model.TableColsMetaData) string {
+	fkTable := col.FkTblName
+	if col.FkTblSchema != "" {
+		fkTable = fmt.Sprint(col.FkTblSchema, ".", col.FkTblName)
+	}
+	ref := fmt.Sprint(" foreign key (", col.ColName, ") references ", fkTable, "(", col.FkColName, ")")
+	if col.FkDeleteRule != "" && !strings.EqualFold(col.FkDeleteRule, "NO ACTION") {
+		ref = fmt.Sprint(ref, " on delete ", strings.ToLower(col.FkDeleteRule))
+	}
+	return ref
+}
+
+// makeNumericPrecision returns precision and scale as p,s - blank is returned if precision is not set
+func makeNumericPrecision(precision string) string {
+	p := strings.TrimSpace(precision)
+	if p == "" || p == "0" || p == "0,0" {
+		return ""
+	}
+	return p
+}
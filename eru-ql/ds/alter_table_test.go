@@ -0,0 +1,112 @@
+package ds
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"reflect"
+	"testing"
+)
+
+// ordersTable returns live definition of orders table used by alter table tests
+func ordersTable() map[string]module_model.TableColsMetaData {
+	return map[string]module_model.TableColsMetaData{
+		"id":          {TblName: "orders", ColName: "id", OwnDataType: "Integer", ColPosition: 1, PrimaryKey: true, PkConstraintName: "pk_orders"},
+		"code":        {TblName: "orders", ColName: "code", OwnDataType: "Varchar", ColPosition: 2, CharMaxLength: 20, IsUnique: true, UqConstraintName: "uq_orders_code"},
+		"qty":         {TblName: "orders", ColName: "qty", OwnDataType: "Integer", ColPosition: 3, IsNullable: true},
+		"customer_id": {TblName: "orders", ColName: "customer_id", OwnDataType: "Integer", ColPosition: 4, IsNullable: true, FkTblSchema: "public", FkTblName: "customers", FkColName: "id", FkConstraintName: "fk_orders_customer"},
+	}
+}
+
+func TestMakeAlterTableSQL(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name         string
+		change       func(tableObj map[string]module_model.TableColsMetaData)
+		wantPostgres []string
+		wantMysql    []string
+	}{
+		{"no change", func(tableObj map[string]module_model.TableColsMetaData) {}, nil, nil},
+		{"rename column", func(tableObj map[string]module_model.TableColsMetaData) {
+			c := tableObj["qty"]
+			c.ColName, c.OldColName = "quantity", "qty"
+			delete(tableObj, "qty")
+			tableObj["quantity"] = c
+		}, []string{"alter table public.orders rename column qty to quantity"},
+			[]string{"alter table public.orders rename column qty to quantity"}},
+		{"add and drop column", func(tableObj map[string]module_model.TableColsMetaData) {
+			delete(tableObj, "qty")
+			tableObj["note"] = module_model.TableColsMetaData{TblName: "orders", ColName: "note", OwnDataType: "String", ColPosition: 5, DefaultValue: "none"}
+		}, []string{"alter table public.orders drop column qty", "alter table public.orders add column note text not null default 'none'"},
+			[]string{"alter table public.orders drop column qty", "alter table public.orders add column note text not null default 'none'"}},
+		{"type change", func(tableObj map[string]module_model.TableColsMetaData) {
+			c := tableObj["code"]
+			c.CharMaxLength = 40
+			tableObj["code"] = c
+		}, []string{"alter table public.orders alter column code type character varying(40) using code::character varying(40)"},
+			[]string{"alter table public.orders modify column code varchar(40) not null"}},
+		{"length taken from live column", func(tableObj map[string]module_model.TableColsMetaData) {
+			c := tableObj["code"]
+			c.CharMaxLength = 0
+			tableObj["code"] = c
+		}, nil, nil},
+		{"nullability and default change", func(tableObj map[string]module_model.TableColsMetaData) {
+			c := tableObj["qty"]
+			c.IsNullable, c.DefaultValue = false, "1"
+			tableObj["qty"] = c
+		}, []string{"alter table public.orders alter column qty set not null", "alter table public.orders alter column qty set default 1"},
+			[]string{"alter table public.orders modify column qty int not null default 1"}},
+		{"primary key change", func(tableObj map[string]module_model.TableColsMetaData) {
+			c := tableObj["code"]
+			c.PrimaryKey, c.IsUnique, c.UqConstraintName = true, false, ""
+			tableObj["code"] = c
+		}, []string{"alter table public.orders drop constraint if exists uq_orders_code", "alter table public.orders drop constraint if exists pk_orders",
+			"alter table public.orders add constraint pk_orders primary key (id , code)"},
+			[]string{"alter table public.orders drop index uq_orders_code", "alter table public.orders drop primary key", "alter table public.orders add primary key (id , code)"}},
+		{"unique change", func(tableObj map[string]module_model.TableColsMetaData) {
+			c := tableObj["code"]
+			c.IsUnique, c.UqConstraintName = false, ""
+			tableObj["code"] = c
+			c = tableObj["qty"]
+			c.IsUnique = true
+			tableObj["qty"] = c
+		}, []string{"alter table public.orders drop constraint if exists uq_orders_code", "alter table public.orders add constraint uq_orders_qty unique (qty)"},
+			[]string{"alter table public.orders drop index uq_orders_code", "alter table public.orders add constraint uq_orders_qty unique (qty)"}},
+		{"foreign key change", func(tableObj map[string]module_model.TableColsMetaData) {
+			c := tableObj["customer_id"]
+			c.FkDeleteRule = "CASCADE"
+			tableObj["customer_id"] = c
+		}, []string{"alter table public.orders drop constraint if exists fk_orders_customer",
+			"alter table public.orders add constraint fk_orders_customer foreign key (customer_id) references public.customers(id) on delete cascade"},
+			[]string{"alter table public.orders drop foreign key fk_orders_customer",
+				"alter table public.orders add constraint fk_orders_customer foreign key (customer_id) references public.customers(id) on delete cascade"}},
+		{"foreign key dropped", func(tableObj map[string]module_model.TableColsMetaData) {
+			c := tableObj["customer_id"]
+			c.FkTblSchema, c.FkTblName, c.FkColName, c.FkConstraintName = "", "", "", ""
+			tableObj["customer_id"] = c
+		}, []string{"alter table public.orders drop constraint if exists fk_orders_customer"},
+			[]string{"alter table public.orders drop foreign key fk_orders_customer"}},
+	}
+	makers := []struct {
+		name string
+		sr   SqlMakerI
+		want func(i int) []string
+	}{
+		{"postgres", new(PostgresSqlMaker), func(i int) []string { return tests[i].wantPostgres }},
+		{"mysql", new(MysqlSqlMaker), func(i int) []string { return tests[i].wantMysql }},
+	}
+	for _, m := range makers {
+		for i, tt := range tests {
+			t.Run(m.name+"/"+tt.name, func(t *testing.T) {
+				tableObj := ordersTable()
+				tt.change(tableObj)
+				got, err := m.sr.MakeAlterTableSQL(ctx, "public.orders", ordersTable(), tableObj)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := m.want(i); !reflect.DeepEqual(got, want) {
+					t.Errorf("got  %q\nwant %q", got, want)
+				}
+			})
+		}
+	}
+}
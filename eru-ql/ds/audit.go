@@ -0,0 +1,134 @@
+package ds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// AuditCols are the columns of audit table in the order their values are inserted
+var AuditCols = []string{"audit_id", "table_name", "operation", "primary_key", "before_image", "after_image", "user_id", "request_id", "changed_at"}
+
+// MakeAuditTableSQL returns query to create audit table if it is not present - images are saved as json text to keep it same for all dbs
+func MakeAuditTableSQL(ctx context.Context, sr SqlMakerI, tableName string) string {
+	return sr.MakeCreateTableIfNotExistsSQL(ctx, tableName, fmt.Sprint("audit_id varchar(36) primary key, table_name varchar(500), operation varchar(20), primary_key varchar(1000), ",
+		"before_image text, after_image text, user_id varchar(500), request_id varchar(100), changed_at ", sr.GetTimestampDataType(ctx)))
+}
+
+// fetchAuditBefore returns rows matched by before query of the audited mutation keyed on their match columns
+func (sqr *SqlMaker) fetchAuditBefore(ctx context.Context, datasource *module_model.DataSource, tableName string) (before map[string]map[string]interface{}, err error) {
+	logs.WithContext(ctx).Debug("fetchAuditBefore - Start")
+	before = make(map[string]map[string]interface{})
+	if sqr.audit.BeforeQuery == "" {
+		return before, nil
+	}
+	rows, err := sqr.tx.QueryxContext(ctx, sqr.audit.BeforeQuery, sqr.audit.BeforeArgs...)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err = rows.MapScan(row); err != nil {
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+		row = auditImage(row, datasource.SchemaTables[tableName])
+		before[module_model.AuditPrimaryKey(sqr.audit.MatchCols, row)] = row
+	}
+	return before, rows.Err()
+}
+
+// saveAuditRecords makes audit records of rows returned by the audited mutation and saves them in audit table within the mutation transaction.
+// records are only added to the mutation audit if they are to be posted to sink url - which is done after the transaction is committed.
+func (sqr *SqlMaker) saveAuditRecords(ctx context.Context, datasource *module_model.DataSource, myself SqlMakerI, tableName string, before map[string]map[string]interface{}, res []map[string]interface{}) (err error) {
+	logs.WithContext(ctx).Debug("saveAuditRecords - Start")
+	operation := sqr.QueryType
+	if operation == "insertselect" {
+		operation = module_model.QUERY_TYPE_INSERT
+	}
+	changedAt := time.Now().UTC()
+	var records []module_model.AuditRecord
+	for _, r := range res {
+		image := auditImage(r, datasource.SchemaTables[tableName])
+		rec := module_model.AuditRecord{AuditId: uuid.New().String(), TableName: tableName, Operation: operation, UserId: sqr.audit.UserId, RequestId: sqr.audit.RequestId, ChangedAt: changedAt}
+		if operation == module_model.QUERY_TYPE_DELETE {
+			rec.Before = image
+		} else {
+			rec.After = image
+			if b, ok := before[module_model.AuditPrimaryKey(sqr.audit.MatchCols, image)]; ok {
+				rec.Before = b
+			}
+		}
+		// upsert which has not found an existing row is an insert
+		if operation == module_model.QUERY_TYPE_UPSERT && rec.Before == nil {
+			rec.Operation = module_model.QUERY_TYPE_INSERT
+		}
+		rec.PrimaryKey = module_model.AuditPrimaryKey(sqr.audit.PrimaryKeys, image)
+		records = append(records, rec)
+	}
+	if sqr.audit.Config.SinkUrl != "" {
+		sqr.audit.Records = append(sqr.audit.Records, records...)
+		return nil
+	}
+	query := fmt.Sprint("insert into ", sqr.audit.Config.GetAuditTable(), " (", strings.Join(AuditCols, " , "), ") values ", myself.GetPreparedQueryPlaceholder(ctx, 1, len(AuditCols), false))
+	for _, rec := range records {
+		_, err = sqr.tx.ExecContext(ctx, query, rec.AuditId, rec.TableName, rec.Operation, rec.PrimaryKey, auditImageJson(rec.Before), auditImageJson(rec.After), rec.UserId, rec.RequestId, rec.ChangedAt)
+		if err != nil {
+			err = errors.New(fmt.Sprint("audit of ", tableName, " failed : ", err.Error()))
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// auditImage returns copy of the row having only columns of the table - nested records returned by insert are not part of the image
+func auditImage(row map[string]interface{}, tableCols map[string]module_model.TableColsMetaData) map[string]interface{} {
+	image := make(map[string]interface{})
+	for k, v := range row {
+		if _, ok := tableCols[k]; !ok && tableCols != nil {
+			continue
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		image[k] = v
+	}
+	return image
+}
+
+// auditImageJson returns json of the row image to be saved in audit table - nil if there is no image
+func auditImageJson(image map[string]interface{}) interface{} {
+	if image == nil {
+		return nil
+	}
+	imageBytes, _ := json.Marshal(image)
+	return string(imageBytes)
+}
+
+// trimAuditReturn removes columns not asked by the mutation from the returned rows as audited mutation returns all columns
+func trimAuditReturn(res []map[string]interface{}, tableCols map[string]module_model.TableColsMetaData, returnFields []string) []map[string]interface{} {
+	if returnFields == nil {
+		return res
+	}
+	asked := make(map[string]bool)
+	for _, f := range returnFields {
+		asked[strings.TrimSpace(f)] = true
+	}
+	for _, r := range res {
+		for k := range r {
+			if _, ok := tableCols[k]; (ok || tableCols == nil) && !asked[k] {
+				delete(r, k)
+			}
+		}
+	}
+	return res
+}
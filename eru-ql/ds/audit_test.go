@@ -0,0 +1,68 @@
+package ds
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqliteMutationAudit(t *testing.T) {
+	ctx := context.Background()
+	sr := GetSqlMaker("sqlite")
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db")},
+		SchemaTables: map[string]map[string]module_model.TableColsMetaData{"customers": {
+			"id":   {ColName: "id", OwnDataType: "Integer", PrimaryKey: true},
+			"name": {ColName: "name", OwnDataType: "String"},
+		}}}
+	if err := sr.CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+	for _, q := range []string{"create table customers (id integer primary key, name text)", "insert into customers values (1, 'a'), (2, 'b')", MakeAuditTableSQL(ctx, sr, module_model.AUDIT_TABLE)} {
+		if _, err := datasource.Con.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	audit := &module_model.MutationAudit{Config: module_model.AuditConfig{Enabled: true}, PrimaryKeys: []string{"id"}, MatchCols: []string{"id"},
+		BeforeQuery: "select * from customers where id = ?", BeforeArgs: []interface{}{1}, ReturnFields: []string{"name"}, UserId: "u1", RequestId: "r1"}
+	mrm := module_model.MutationResultMaker{MainTableName: "customers", QueryType: "update", TxnFlag: true, Audit: audit,
+		MutationRecords: []module_model.MutationRecord{{DBQuery: "update customers set name = ? where id = ? RETURNING * ", Values: []interface{}{"c"}, WhereValues: []interface{}{1}}}}
+	res, err := sr.ExecuteMutationQuery(ctx, datasource, sr, mrm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || len(res[0]) != 1 || res[0]["name"] != "c" {
+		t.Errorf("returned rows are expected to have only asked fields : %v", res)
+	}
+
+	mrm = module_model.MutationResultMaker{MainTableName: "customers", QueryType: "delete", TxnFlag: true, Audit: &module_model.MutationAudit{Config: audit.Config, PrimaryKeys: []string{"id"}},
+		DBQuery: "delete from customers where id = ? RETURNING * ", DBQueryArgs: []interface{}{2}}
+	if _, err = sr.ExecuteMutationQuery(ctx, datasource, sr, mrm); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []struct {
+		Operation  string  `db:"operation"`
+		PrimaryKey string  `db:"primary_key"`
+		Before     *string `db:"before_image"`
+		After      *string `db:"after_image"`
+		UserId     string  `db:"user_id"`
+		RequestId  string  `db:"request_id"`
+	}
+	if err = datasource.Con.Select(&rows, "select operation, primary_key, before_image, after_image, user_id, request_id from eru_audit order by changed_at"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 audit records, got %d", len(rows))
+	}
+	upd := rows[0]
+	if upd.Operation != "update" || upd.PrimaryKey != `{"id":"1"}` || upd.Before == nil || *upd.Before != `{"id":1,"name":"a"}` || upd.After == nil || *upd.After != `{"id":1,"name":"c"}` || upd.UserId != "u1" || upd.RequestId != "r1" {
+		t.Errorf("unexpected audit record of update : %+v", upd)
+	}
+	del := rows[1]
+	if del.Operation != "delete" || del.PrimaryKey != `{"id":"2"}` || del.Before == nil || *del.Before != `{"id":2,"name":"b"}` || del.After != nil {
+		t.Errorf("unexpected audit record of delete : %+v", del)
+	}
+}
@@ -2,6 +2,7 @@ package ds
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -68,7 +69,9 @@ type SqlMaker struct {
 	tx                  *sqlx.Tx
 	IsNested            bool
 	DBQuery             string
+	DBQueryArgs         []interface{}
 	PreparedQuery       bool
+	audit               *module_model.MutationAudit
 }
 
 type GraphqlResult struct {
@@ -76,8 +79,11 @@ type GraphqlResult struct {
 	idx  int
 }
 
+// defaultMutationTimeOut is the timeout of mutation queries if neither datasource nor my query has a timeout
+const defaultMutationTimeOut = 100 * time.Second
+
 var DefaultDriverConfig = module_model.DriverConfig{10, 2, time.Hour}
-var DefaultOtherConfig = module_model.OtherDbConfig{1000, 60, false}
+var DefaultOtherConfig = module_model.OtherDbConfig{RowLimit: module_model.DEFAULT_ROW_LIMIT, QueryTimeOut: 60}
 var emptyCustomRule = security_rule.CustomRule{}
 var DefaultDbSecurityRules = module_model.SecurityRules{security_rule.SecurityRule{"Allow", emptyCustomRule}, security_rule.SecurityRule{"Deny", emptyCustomRule}, security_rule.SecurityRule{"Allow", emptyCustomRule}, security_rule.SecurityRule{"Allow", emptyCustomRule}, security_rule.SecurityRule{"Allow", emptyCustomRule}, security_rule.SecurityRule{"Deny", emptyCustomRule}, security_rule.SecurityRule{"Allow", emptyCustomRule}, security_rule.SecurityRule{"Allow", emptyCustomRule}}
 
@@ -94,16 +100,27 @@ type SqlMakerI interface {
 	CreateConn(ctx context.Context, dataSource *module_model.DataSource) error
 	ExecuteQuery(ctx context.Context, datasource *module_model.DataSource, qrm module_model.QueryResultMaker) (res map[string]interface{}, err error)
 	ExecuteMutationQuery(ctx context.Context, datasource *module_model.DataSource, myself SqlMakerI, mrm module_model.MutationResultMaker) (res []map[string]interface{}, err error)
-	ExecutePreparedQuery(ctx context.Context, query string, datasource *module_model.DataSource) (res map[string]interface{}, err error)
-	ExecuteQueryForCsv(ctx context.Context, query string, datasource *module_model.DataSource, aliasName string) (res map[string]interface{}, err error)
+	ExecutePreparedQuery(ctx context.Context, query string, datasource *module_model.DataSource, args ...interface{}) (res map[string]interface{}, err error)
+	ExecuteQueryForCsv(ctx context.Context, query string, datasource *module_model.DataSource, aliasName string, args ...interface{}) (res map[string]interface{}, err error)
+	ExecuteQueryStream(ctx context.Context, query string, datasource *module_model.DataSource, asCsv bool, onRow StreamRowFunc, args ...interface{}) (cols []string, rowCount int, err error)
 	RollbackQuery(ctx context.Context) (err error)
-	GetTableList(ctx context.Context, query string, datasource *module_model.DataSource, myself SqlMakerI) (err error)
+	GetTableList(ctx context.Context, query string, datasource *module_model.DataSource, myself SqlMakerI, args ...interface{}) (err error)
 	GetTableMetaDataSQL(ctx context.Context) string
+	// GetSingleTableMetaDataSQL returns query for metadata of one table - schema and table name are bound to it in that order
+	GetSingleTableMetaDataSQL(ctx context.Context) string
 	MakeCreateTableSQL(ctx context.Context, tableName string, tableObj map[string]module_model.TableColsMetaData) (string, error)
 	MakeDropTableSQL(ctx context.Context, tableName string) (string, error)
+	MakeAlterTableSQL(ctx context.Context, tableName string, liveTableObj map[string]module_model.TableColsMetaData, tableObj map[string]module_model.TableColsMetaData) ([]string, error)
+	MakeUpsertSQL(ctx context.Context, tableName string, cols []string, colsPlaceholder string, conflictCols []string, updateCols []string, returnFields string) (string, error)
+	MakeReturningClause(ctx context.Context, queryType string, returnFields string) (outputClause string, returningClause string)
 	getDataTypeMapping(ctx context.Context, dataType string) string
 	GetSqlResult(ctx context.Context) map[string]interface{}
 	GetPreparedQueryPlaceholder(ctx context.Context, rowCount int, colCount int, single bool) string
+	GetQueryCost(ctx context.Context, datasource *module_model.DataSource, query string, args ...interface{}) (cost float64, err error)
+	BulkInsert(ctx context.Context, tx *sqlx.Tx, myself SqlMakerI, tableName string, cols []string, rows [][]interface{}) (err error)
+	MakeSavepointSQL(ctx context.Context, name string) (savepoint string, rollbackTo string)
+	MakeCreateTableIfNotExistsSQL(ctx context.Context, tableName string, colDefs string) string
+	GetTimestampDataType(ctx context.Context) string
 	//CreateConn() error
 }
 
@@ -112,7 +129,28 @@ func (sqr *SqlMaker) GetReturnAlias(ctx context.Context) string {
 }
 
 func (sqr *SqlMaker) GetPreparedQueryPlaceholder(ctx context.Context, rowCount int, colCount int, single bool) string {
-	return strings.Repeat(" ? ", colCount*rowCount)
+	return makePreparedQueryPlaceholder(rowCount, colCount, single, func(n int) string {
+		return "?"
+	})
+}
+
+// makePreparedQueryPlaceholder returns placeholder of colCount(th) value if single is true
+// else placeholders of all values grouped by row e.g. (p1 , p2) , (p3 , p4)
+func makePreparedQueryPlaceholder(rowCount int, colCount int, single bool, placeholder func(n int) string) string {
+	if single {
+		return placeholder(colCount)
+	}
+	var rowArray []string
+	startNo := 0
+	for r := 1; r <= rowCount; r++ {
+		var colArray []string
+		for c := 1; c <= colCount; c++ {
+			startNo++
+			colArray = append(colArray, placeholder(startNo))
+		}
+		rowArray = append(rowArray, fmt.Sprint("(", strings.Join(colArray, " , "), ")"))
+	}
+	return strings.Join(rowArray, " , ")
 }
 
 func (sqr *SqlMaker) GetBaseSqlMaker(ctx context.Context) *SqlMaker {
@@ -122,6 +160,20 @@ func (sqr *SqlMaker) GetTableMetaDataSQL(ctx context.Context) string {
 	return ""
 }
 
+func (sqr *SqlMaker) GetSingleTableMetaDataSQL(ctx context.Context) string {
+	return ""
+}
+
+// MakeCreateTableIfNotExistsSQL returns query to create table with the column definitions if it is not present e.g. tables used by eru to track changes
+func (sqr *SqlMaker) MakeCreateTableIfNotExistsSQL(ctx context.Context, tableName string, colDefs string) string {
+	return fmt.Sprint("create table if not exists ", tableName, " (", colDefs, ")")
+}
+
+// GetTimestampDataType returns data type of date time columns of tables used by eru
+func (sqr *SqlMaker) GetTimestampDataType(ctx context.Context) string {
+	return "timestamp"
+}
+
 func (sqr *SqlMaker) MakeCreateTableSQL(ctx context.Context, tableName string, tableObj map[string]module_model.TableColsMetaData) (string, error) {
 	return "", nil
 }
@@ -129,6 +181,20 @@ func (sqr *SqlMaker) MakeDropTableSQL(ctx context.Context, tableName string) (st
 	return "", nil
 }
 
+func (sqr *SqlMaker) MakeAlterTableSQL(ctx context.Context, tableName string, liveTableObj map[string]module_model.TableColsMetaData, tableObj map[string]module_model.TableColsMetaData) ([]string, error) {
+	return nil, errors.New("MakeAlterTableSQL not implemented")
+}
+
+func (sqr *SqlMaker) MakeUpsertSQL(ctx context.Context, tableName string, cols []string, colsPlaceholder string, conflictCols []string, updateCols []string, returnFields string) (string, error) {
+	return "", errors.New("MakeUpsertSQL not implemented")
+}
+
+// MakeReturningClause returns clauses to return affected rows of insert, update and delete queries.
+// outputClause is placed before values, where or select of the query and returningClause at the end of the query.
+func (sqr *SqlMaker) MakeReturningClause(ctx context.Context, queryType string, returnFields string) (outputClause string, returningClause string) {
+	return "", fmt.Sprint(" RETURNING ", returnFields)
+}
+
 func (sqr *SqlMaker) GetSqlResult(ctx context.Context) map[string]interface{} {
 	return sqr.result
 }
@@ -425,11 +491,9 @@ func (sqr *SqlMaker) ProcessGraphQL(sel ast.Selection, vars map[string]interface
 }
 */
 
-func (sqr *SqlMaker) ExecuteQueryForCsv(ctx context.Context, query string, datasource *module_model.DataSource, aliasName string) (res map[string]interface{}, err error) {
+func (sqr *SqlMaker) ExecuteQueryForCsv(ctx context.Context, query string, datasource *module_model.DataSource, aliasName string, args ...interface{}) (res map[string]interface{}, err error) {
 	logs.WithContext(ctx).Debug("ExecuteQueryForCsv - Start")
-	//ctx, cancel := context.WithTimeout(context.Background(), 100000*time.Millisecond) //TODO: to get context as argument
-	//defer cancel()
-	rows, e := datasource.Con.Queryx(query)
+	rows, e := datasource.Con.QueryxContext(ctx, query, args...)
 	if e != nil {
 		return nil, e
 	}
@@ -443,7 +507,12 @@ func (sqr *SqlMaker) ExecuteQueryForCsv(ctx context.Context, query string, datas
 	sqr.result = make(map[string]interface{})
 	var innerResult [][]interface{}
 	firstRow := true
+	rowNo := 0
 	for rows.Next() {
+		rowNo = rowNo + 1
+		if ee = checkMaxRows(ctx, datasource, rowNo); ee != nil {
+			return nil, ee
+		}
 		var innerResultRow []interface{}
 		var innerResultLabel []interface{}
 		ee = rows.MapScan(mapping)
@@ -459,45 +528,11 @@ func (sqr *SqlMaker) ExecuteQueryForCsv(ctx context.Context, query string, datas
 				}
 				innerResultLabel = append(innerResultLabel, colHeader)
 			}
-			if mapping[colType.Name()] != nil {
-				if reflect.TypeOf(mapping[colType.Name()]).String() == "[]uint8" && colType.DatabaseTypeName() == "NUMERIC" {
-					f := 0.0
-					f, err = strconv.ParseFloat(string(mapping[colType.Name()].([]byte)), 64)
-					mapping[colType.Name()] = strconv.FormatFloat(f, 'f', -1, 64)
-					if err != nil {
-						logs.WithContext(ctx).Error(err.Error())
-						return nil, err
-					}
-				} else if reflect.TypeOf(mapping[colType.Name()]).String() == "float64" {
-					f := 0.0
-					f = mapping[colType.Name()].(float64)
-					mapping[colType.Name()] = strconv.FormatFloat(f, 'E', -1, 64)
-				} else if reflect.TypeOf(mapping[colType.Name()]).String() == "bool" {
-					mapping[colType.Name()] = strconv.FormatBool(mapping[colType.Name()].(bool))
-				} else if reflect.TypeOf(mapping[colType.Name()]).String() == "time.Time" {
-					if colType.DatabaseTypeName() == "DATE" {
-						mapping[colType.Name()] = mapping[colType.Name()].(time.Time).Format("02-Jan-2006")
-					} else {
-						mapping[colType.Name()] = mapping[colType.Name()].(time.Time).String()
-					}
-				} else if strings.HasPrefix(reflect.TypeOf(mapping[colType.Name()]).String(), "int") {
-					//if reflect.TypeOf(mapping[colType.Name()]).String() == "int64" {
-					n := mapping[colType.Name()].(int64)
-					mapping[colType.Name()] = fmt.Sprintf("%d", n)
-					//}
-				} else if (colType.DatabaseTypeName() == "JSONB" || colType.DatabaseTypeName() == "JSON") || colType.DatabaseTypeName() == "BPCHAR" {
-					mapping[colType.Name()] = string(mapping[colType.Name()].([]byte))
-				}
-			}
-
-			if val, ok := mapping[colType.Name()].(string); ok {
-				innerResultRow = append(innerResultRow, val)
-			} else if mapping[colType.Name()] == nil {
-				innerResultRow = append(innerResultRow, "")
-			} else {
-				err = errors.New(fmt.Sprint("value of ", colType.Name(), " is not a string"))
-				return nil, err
+			val, e := csvValue(ctx, colType, mapping[colType.Name()])
+			if e != nil {
+				return nil, e
 			}
+			innerResultRow = append(innerResultRow, val)
 		}
 		if firstRow {
 			innerResult = append(innerResult, innerResultLabel)
@@ -505,6 +540,9 @@ func (sqr *SqlMaker) ExecuteQueryForCsv(ctx context.Context, query string, datas
 		}
 		innerResult = append(innerResult, innerResultRow)
 	}
+	if ee = rows.Err(); ee != nil {
+		return nil, ee
+	}
 	if len(innerResult) == 0 {
 		innerResult = append(innerResult, []interface{}{})
 	}
@@ -512,12 +550,79 @@ func (sqr *SqlMaker) ExecuteQueryForCsv(ctx context.Context, query string, datas
 	return sqr.result, nil
 }
 
-func (sqr *SqlMaker) ExecutePreparedQuery(ctx context.Context, query string, datasource *module_model.DataSource) (res map[string]interface{}, err error) {
+// csvValue returns value of the column as string for csv and excel output - null is returned as blank
+func csvValue(ctx context.Context, colType *sql.ColumnType, v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	switch tv := v.(type) {
+	case []byte:
+		if colType.DatabaseTypeName() == "NUMERIC" {
+			f, err := strconv.ParseFloat(string(tv), 64)
+			if err != nil {
+				logs.WithContext(ctx).Error(err.Error())
+				return "", err
+			}
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+	case float64:
+		return strconv.FormatFloat(tv, 'E', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(tv), nil
+	case time.Time:
+		if colType.DatabaseTypeName() == "DATE" {
+			return tv.Format("02-Jan-2006"), nil
+		}
+		return tv.String(), nil
+	case int, int8, int16, int32, int64:
+		return fmt.Sprintf("%d", tv), nil
+	}
+	if colType.DatabaseTypeName() == "JSONB" || colType.DatabaseTypeName() == "JSON" || colType.DatabaseTypeName() == "BPCHAR" {
+		return string(jsonBytes(v)), nil
+	}
+	if val, ok := v.(string); ok {
+		return val, nil
+	}
+	return "", errors.New(fmt.Sprint("value of ", colType.Name(), " is not a string"))
+}
+
+// jsonValue returns value of the column for json output - numeric values are returned as float and json values are unmarshalled
+func jsonValue(ctx context.Context, colType *sql.ColumnType, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if colType.DatabaseTypeName() == "NUMERIC" {
+		if b, ok := v.([]byte); ok {
+			f, err := strconv.ParseFloat(string(b), 64)
+			if err != nil {
+				logs.WithContext(ctx).Error(err.Error())
+				return nil, err
+			}
+			return f, nil
+		}
+	} else if colType.DatabaseTypeName() == "JSONB" || colType.DatabaseTypeName() == "JSON" {
+		var jv interface{}
+		if err := json.Unmarshal(jsonBytes(v), &jv); err != nil {
+			return nil, err
+		}
+		return &jv, nil
+	}
+	return v, nil
+}
+
+// jsonBytes returns json column value as bytes - drivers like sqlite return text columns as string
+func jsonBytes(v interface{}) []byte {
+	if s, ok := v.(string); ok {
+		return []byte(s)
+	}
+	b, _ := v.([]byte)
+	return b
+}
+
+func (sqr *SqlMaker) ExecutePreparedQuery(ctx context.Context, query string, datasource *module_model.DataSource, args ...interface{}) (res map[string]interface{}, err error) {
 	logs.WithContext(ctx).Debug("ExecutePreparedQuery - Start")
 	logs.WithContext(ctx).Info(query)
-	//ctx, cancel := context.WithTimeout(context.Background(), 100000*time.Millisecond) //TODO: to get context as argument
-	//defer cancel()
-	rows, e := datasource.Con.Queryx(query)
+	rows, e := datasource.Con.QueryxContext(ctx, query, args...)
 	if e != nil {
 		return nil, e
 	}
@@ -530,40 +635,30 @@ func (sqr *SqlMaker) ExecutePreparedQuery(ctx context.Context, query string, dat
 	}
 	sqr.result = make(map[string]interface{})
 	var innerResult []map[string]interface{}
+	rowNo := 0
 	for rows.Next() {
+		rowNo = rowNo + 1
+		if ee = checkMaxRows(ctx, datasource, rowNo); ee != nil {
+			return nil, ee
+		}
 		innerResultRow := make(map[string]interface{})
 		ee = rows.MapScan(mapping)
 		if ee != nil {
 			return nil, ee
 		}
 		for _, colType := range colsType {
-			if colType.DatabaseTypeName() == "NUMERIC" && mapping[colType.Name()] != nil {
-				f := 0.0
-				if reflect.TypeOf(mapping[colType.Name()]).String() == "[]uint8" {
-					f, err = strconv.ParseFloat(string(mapping[colType.Name()].([]byte)), 64)
-					mapping[colType.Name()] = f
-				} else if reflect.TypeOf(mapping[colType.Name()]).String() == "float64" {
-					f = mapping[colType.Name()].(float64)
-					mapping[colType.Name()] = f
-				}
-				if err != nil {
-					logs.WithContext(ctx).Error(err.Error())
-					return nil, err
-				}
-			} else if (colType.DatabaseTypeName() == "JSONB" || colType.DatabaseTypeName() == "JSON") && mapping[colType.Name()] != nil {
-				bytesToUnmarshal := mapping[colType.Name()].([]byte)
-				var v interface{}
-				err = json.Unmarshal(bytesToUnmarshal, &v)
-				if err != nil {
-					return nil, err
-				}
-				mapping[colType.Name()] = &v
+			mapping[colType.Name()], err = jsonValue(ctx, colType, mapping[colType.Name()])
+			if err != nil {
+				return nil, err
 			}
 			innerResultRow[colType.Name()] = mapping[colType.Name()]
 			//innerResult[colType.Name()+"_DT"] = reflect.TypeOf(r).Kind().String()
 		}
 		innerResult = append(innerResult, innerResultRow)
 	}
+	if ee = rows.Err(); ee != nil {
+		return nil, ee
+	}
 	if len(innerResult) == 0 {
 		innerResult = append(innerResult, make(map[string]interface{}))
 	}
@@ -588,10 +683,16 @@ func (sqr *SqlMaker) ExecuteMutationQuery(ctx context.Context, datasource *modul
 	sqr.TxnFlag = mrm.TxnFlag
 	sqr.QueryType = mrm.QueryType
 	sqr.DBQuery = mrm.DBQuery
+	sqr.DBQueryArgs = mrm.DBQueryArgs
 	sqr.PreparedQuery = mrm.PreparedQuery
+	sqr.audit = mrm.Audit
 	var errMsgs []string
-	ctx, cancel := context.WithTimeout(context.Background(), 100000*time.Millisecond) //TODO: to get context as argument
-	defer cancel()
+	// mutations without a statement timeout set by caller are not allowed to run forever
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultMutationTimeOut)
+		defer cancel()
+	}
 	if sqr.openTxn || (sqr.TxnFlag && !sqr.SingleTxn) {
 		logs.WithContext(ctx).Debug("datasource.Con.MustBegin() called in ExecuteMutationQuery")
 		sqr.tx = datasource.Con.MustBegin() //begin txn only once for all queries OR begin txn outside for loop to insert all docs as single txn
@@ -619,6 +720,22 @@ func (sqr *SqlMaker) ExecuteMutationQuery(ctx context.Context, datasource *modul
 	return res, nil
 }
 
+// checkMaxRows returns error if query has returned more rows than max rows allowed for the datasource
+func checkMaxRows(ctx context.Context, datasource *module_model.DataSource, rowNo int) (err error) {
+	maxRows := datasource.DbConfig.OtherDbConfig.MaxRows
+	if maxRows > 0 && rowNo > maxRows {
+		err = errors.New(fmt.Sprint("query returned more than ", maxRows, " rows - max rows allowed for datasource ", datasource.DbAlias))
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	return err
+}
+
+// GetQueryCost returns cost of the query estimated by the db - dbs which cannot estimate it return an error
+func (sqr *SqlMaker) GetQueryCost(ctx context.Context, datasource *module_model.DataSource, query string, args ...interface{}) (cost float64, err error) {
+	logs.WithContext(ctx).Debug("GetQueryCost - Start")
+	return 0, errors.New(fmt.Sprint("query cost is not supported for ", datasource.DbName))
+}
+
 func (sqr *SqlMaker) RollbackQuery(ctx context.Context) (err error) {
 	logs.WithContext(ctx).Debug("RollbackQuery - Start")
 	if sqr.tx != nil {
@@ -638,8 +755,10 @@ func (sqr *SqlMaker) iterateDocsForMutation(ctx context.Context, docs []module_m
 	if !sqr.IsNested {
 		if sqr.QueryType == "insertselect" || sqr.QueryType == "delete" || sqr.PreparedQuery {
 			query = sqr.DBQuery
+			finalValues = sqr.DBQueryArgs
 		} else if sqr.QueryType == "update" {
-			finalValues = docs[0].Values
+			// where clause placeholders are numbered after the updated columns
+			finalValues = append(append(finalValues, docs[0].Values...), docs[0].WhereValues...)
 			query = sqr.MutationRecords[0].DBQuery
 			for i, _ := range sqr.MutationRecords[0].UpdatedCols {
 				query = strings.Replace(query, fmt.Sprint("$UpdateColPlaceholder", i), myself.GetPreparedQueryPlaceholder(ctx, 1, i, true), 1)
@@ -664,7 +783,7 @@ func (sqr *SqlMaker) iterateDocsForMutation(ctx context.Context, docs []module_m
 				errMsgs = append(errMsgs, err.Error())
 				return res, errors.New(strings.Join(errMsgs, " , "))
 			}
-			if sqr.QueryType == "insert" && len(resDocs) > 0 {
+			if (sqr.QueryType == "insert" || sqr.QueryType == "upsert") && len(resDocs) > 0 {
 				resDoc := resDocs[0]
 				var childError bool
 				for ck, cv := range v.ChildRecords {
@@ -754,6 +873,16 @@ func (sqr *SqlMaker) executeMutationQueriesinDB(ctx context.Context, query strin
 		}
 		//return nil, errors.New(strings.Join(errMsgs, " , "))
 	}
+	// before images of rows changed by audited mutation are fetched in the same transaction
+	auditMutation := sqr.audit != nil && !isNested && tableName == sqr.MainTableName
+	var auditBefore map[string]map[string]interface{}
+	if !errFound && auditMutation {
+		auditBefore, err = sqr.fetchAuditBefore(ctx, datasource, tableName)
+		if err != nil {
+			sqr.tx.Rollback()
+			return nil, errors.New(fmt.Sprint("DB error for Document No ", docNo, " : ", err.Error()))
+		}
+	}
 	if !errFound {
 		/*if sqr.QueryType == "insert" {
 			resDoc := make(map[string]interface{})
@@ -812,7 +941,7 @@ func (sqr *SqlMaker) executeMutationQueriesinDB(ctx context.Context, query strin
 			for _, colType := range colsType {
 				if colType.DatabaseTypeName() == "JSONB" || colType.DatabaseTypeName() == "JSON" {
 					var tmpv interface{}
-					json.Unmarshal(resDoc[colType.Name()].([]byte), &tmpv)
+					json.Unmarshal(jsonBytes(resDoc[colType.Name()]), &tmpv)
 					resDoc[colType.Name()] = tmpv
 				} else if colType.DatabaseTypeName() == "NUMERIC" && resDoc[colType.Name()] != nil {
 					f, err := strconv.ParseFloat(string(resDoc[colType.Name()].([]byte)), 64)
@@ -825,6 +954,14 @@ func (sqr *SqlMaker) executeMutationQueriesinDB(ctx context.Context, query strin
 			}
 			res = append(res, resDoc)
 		}
+		if auditMutation {
+			err = sqr.saveAuditRecords(ctx, datasource, myself, tableName, auditBefore, res)
+			if err != nil {
+				sqr.tx.Rollback()
+				return nil, errors.New(fmt.Sprint("DB error for Document No ", docNo, " : ", err.Error()))
+			}
+			res = trimAuditReturn(res, datasource.SchemaTables[tableName], sqr.audit.ReturnFields)
+		}
 	}
 	if !sqr.TxnFlag && !isNested {
 		logs.WithContext(ctx).Info("sqr.tx.Commit() called")
@@ -846,7 +983,7 @@ func (sqr *SqlMaker) ExecuteQuery(ctx context.Context, datasource *module_model.
 	sqr.MainAliasName = qrm.MainAliasName
 	sqr.MainTableName = qrm.MainTableName
 
-	rows, e := datasource.Con.Queryx(qrm.SQLQuery)
+	rows, e := datasource.Con.QueryxContext(ctx, qrm.SQLQuery, qrm.SQLArgs...)
 	if e != nil {
 		return nil, e
 	}
@@ -861,6 +998,9 @@ func (sqr *SqlMaker) ExecuteQuery(ctx context.Context, datasource *module_model.
 	sqr.result = make(map[string]interface{})
 	for rows.Next() {
 		rowNo = rowNo + 1
+		if e = checkMaxRows(ctx, datasource, rowNo); e != nil {
+			return nil, e
+		}
 		resultRowHolderNew := make([][]map[string]interface{}, sqr.queryLevel+1)
 
 		e = rows.MapScan(mapping)
@@ -922,7 +1062,7 @@ func (sqr *SqlMaker) ExecuteQuery(ctx context.Context, datasource *module_model.
 					return nil, err
 				}
 			} else if (colType.DatabaseTypeName() == "JSONB" || colType.DatabaseTypeName() == "JSON") && mapping[colType.Name()] != nil {
-				bytesToUnmarshal := mapping[colType.Name()].([]byte)
+				bytesToUnmarshal := jsonBytes(mapping[colType.Name()])
 				var v interface{}
 				err = json.Unmarshal(bytesToUnmarshal, &v)
 				if err != nil {
@@ -964,6 +1104,9 @@ func (sqr *SqlMaker) ExecuteQuery(ctx context.Context, datasource *module_model.
 		}
 
 	}
+	if e = rows.Err(); e != nil {
+		return nil, e
+	}
 	return sqr.result, nil
 }
 
@@ -1374,10 +1517,10 @@ func (sqr *SqlMaker) AddLimitSkipClause(ctx context.Context, query string, limit
 	return newQuery
 }
 
-func (sqr *SqlMaker) GetTableList(ctx context.Context, query string, datasource *module_model.DataSource, myself SqlMakerI) (err error) {
+func (sqr *SqlMaker) GetTableList(ctx context.Context, query string, datasource *module_model.DataSource, myself SqlMakerI, args ...interface{}) (err error) {
 	logs.WithContext(ctx).Debug("GetTableList - Start")
 	tableList := make(map[string]map[string]module_model.TableColsMetaData)
-	rows, e := datasource.Con.Queryx(query)
+	rows, e := datasource.Con.Queryx(query, args...)
 	if e != nil {
 		logs.WithContext(ctx).Error(e.Error())
 		return e
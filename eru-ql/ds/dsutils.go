@@ -1,5 +1,13 @@
 package ds
 
+import (
+	"context"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/jmoiron/sqlx"
+)
+
 func GetSqlMaker(dbName string) SqlMakerI {
 	switch dbName {
 	case "mysql":
@@ -8,6 +16,10 @@ func GetSqlMaker(dbName string) SqlMakerI {
 		return new(PostgresSqlMaker)
 	case "mssql":
 		return new(MssqlSqlMaker)
+	case "sqlite":
+		return new(SqliteSqlMaker)
+	case "mongo":
+		return new(MongoSqlMaker)
 	default:
 		return nil
 		//do nothing
@@ -17,7 +29,7 @@ func GetSqlMaker(dbName string) SqlMakerI {
 
 func GetDbType(dbName string) string {
 	switch dbName {
-	case "postgres", "mysql", "mssql":
+	case "postgres", "mysql", "mssql", "sqlite":
 		return "sql"
 	case "mongo":
 		return "mongo"
@@ -26,3 +38,37 @@ func GetDbType(dbName string) string {
 	}
 	return ""
 }
+
+// CreateReplicaConns returns read replicas of the datasource with connections made by CreateConn of the sql maker
+// replica which fails to connect is kept as unhealthy and connection is tried again on its next health check
+func CreateReplicaConns(ctx context.Context, sqlMaker SqlMakerI, datasource *module_model.DataSource) *module_model.Replicas {
+	logs.WithContext(ctx).Debug("CreateReplicaConns - Start")
+	if len(datasource.DbConfig.ReadReplicas) == 0 {
+		return nil
+	}
+	if GetDbType(datasource.DbName) != "sql" {
+		logs.WithContext(ctx).Warn(fmt.Sprint("read replicas are ignored for ", datasource.DbName, " datasource ", datasource.DbAlias))
+		return nil
+	}
+	replicas := &module_model.Replicas{}
+	for _, rr := range datasource.DbConfig.ReadReplicas {
+		replicaDs := *datasource
+		replicaDs.DbConfig.Host = rr.Host
+		replicaDs.DbConfig.Port = rr.Port
+		if rr.User != "" {
+			replicaDs.DbConfig.User = rr.User
+			replicaDs.DbConfig.Password = rr.Password
+		}
+		replicaDs.DbConfig.ReadReplicas = nil
+		rc := module_model.NewReplicaCon(rr.Host, func(ctx context.Context) (*sqlx.DB, error) {
+			replicaDs.Con = nil
+			if err := sqlMaker.CreateConn(ctx, &replicaDs); err != nil {
+				return nil, err
+			}
+			return replicaDs.Con, nil
+		})
+		rc.HealthyCon(ctx, false)
+		replicas.Cons = append(replicas.Cons, rc)
+	}
+	return replicas
+}
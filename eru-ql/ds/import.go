@@ -0,0 +1,190 @@
+package ds
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/jmoiron/sqlx"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxBulkInsertParams keeps multi row insert within placeholder limit of all dbs - mssql allows 2100 parameters and 1000 rows per insert
+const (
+	maxBulkInsertParams = 2000
+	maxBulkInsertRows   = 1000
+	importSavepoint     = "eru_import"
+)
+
+var importDateTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ImportRow is a row of imported file with values in order of import columns
+type ImportRow struct {
+	RowNo  int
+	Values []interface{}
+}
+
+// CoerceImportValue converts value read from the file to the data type of the table column - blank value is imported as null
+func CoerceImportValue(col module_model.TableColsMetaData, value string) (interface{}, error) {
+	v := strings.TrimSpace(value)
+	switch col.OwnDataType {
+	case "Varchar", "Char", "String":
+		if value == "" && col.IsNullable {
+			return nil, nil
+		}
+		if col.CharMaxLength > 0 && utf8.RuneCountInString(value) > col.CharMaxLength {
+			return nil, errors.New(fmt.Sprint("value is longer than ", col.CharMaxLength, " characters"))
+		}
+		return value, nil
+	}
+	if v == "" {
+		return nil, nil
+	}
+	switch col.OwnDataType {
+	case "SmallInteger", "Integer", "BigInteger", "TinyInteger", "MediumInteger":
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			// excel keeps whole numbers as decimals e.g. 12.0
+			f, fErr := strconv.ParseFloat(v, 64)
+			if fErr != nil || f != float64(int64(f)) {
+				return nil, errors.New(fmt.Sprint("'", v, "' is not an integer"))
+			}
+			i = int64(f)
+		}
+		return i, nil
+	case "Decimal", "Float", "Double", "Numeric", "Real":
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("'", v, "' is not a number"))
+		}
+		return f, nil
+	case "Boolean":
+		switch strings.ToLower(v) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New(fmt.Sprint("'", v, "' is not a boolean"))
+		}
+		return b, nil
+	case "Date", "DateTime", "DateTimeWithZone":
+		for _, layout := range importDateTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return nil, errors.New(fmt.Sprint("'", v, "' is not a date in yyyy-mm-dd hh:mm:ss format"))
+	case "JSON":
+		if !json.Valid([]byte(v)) {
+			return nil, errors.New(fmt.Sprint("'", v, "' is not a valid json"))
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// ImportRows inserts rows into the table in batches within a single transaction.
+// each batch is inserted within a savepoint and rows of a failed batch are inserted one by one to report the rows which failed.
+// transaction is rolled back if stopOnError is true and any row fails.
+func ImportRows(ctx context.Context, datasource *module_model.DataSource, myself SqlMakerI, tableName string, cols []string, rows []ImportRow, batchSize int, stopOnError bool) (imported int, rowErrors []module_model.BulkImportRowError, err error) {
+	logs.WithContext(ctx).Debug("ImportRows - Start")
+	if batchSize <= 0 {
+		batchSize = module_model.DEFAULT_IMPORT_BATCH_SIZE
+	}
+	tx, err := datasource.Con.BeginTxx(ctx, nil)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return 0, nil, err
+	}
+	savepoint, rollbackTo := myself.MakeSavepointSQL(ctx, importSavepoint)
+	insertSaved := func(batch []ImportRow) (insertErr error) {
+		if _, insertErr = tx.ExecContext(ctx, savepoint); insertErr != nil {
+			return insertErr
+		}
+		values := make([][]interface{}, len(batch))
+		for i, r := range batch {
+			values[i] = r.Values
+		}
+		if insertErr = myself.BulkInsert(ctx, tx, myself, tableName, cols, values); insertErr != nil {
+			if _, rbErr := tx.ExecContext(ctx, rollbackTo); rbErr != nil {
+				logs.WithContext(ctx).Error(rbErr.Error())
+				return rbErr
+			}
+		}
+		return insertErr
+	}
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batchErr := insertSaved(rows[start:end])
+		if batchErr == nil {
+			imported += end - start
+			continue
+		}
+		logs.WithContext(ctx).Warn(fmt.Sprint("import batch of rows ", rows[start].RowNo, " to ", rows[end-1].RowNo, " failed - inserting rows one by one : ", batchErr.Error()))
+		for _, r := range rows[start:end] {
+			if rowErr := insertSaved([]ImportRow{r}); rowErr != nil {
+				rowErrors = append(rowErrors, module_model.BulkImportRowError{RowNo: r.RowNo, Error: rowErr.Error()})
+			} else {
+				imported++
+			}
+			if ctx.Err() != nil {
+				_ = tx.Rollback()
+				return 0, rowErrors, ctx.Err()
+			}
+		}
+	}
+	if stopOnError && len(rowErrors) > 0 {
+		logs.WithContext(ctx).Info("tx.Rollback() called as rows failed to import")
+		return 0, rowErrors, tx.Rollback()
+	}
+	if err = tx.Commit(); err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return 0, rowErrors, err
+	}
+	return imported, rowErrors, nil
+}
+
+// BulkInsert inserts rows into the table using multi row insert queries
+func (sqr *SqlMaker) BulkInsert(ctx context.Context, tx *sqlx.Tx, myself SqlMakerI, tableName string, cols []string, rows [][]interface{}) (err error) {
+	logs.WithContext(ctx).Debug("BulkInsert - Start")
+	rowsPerQuery := maxBulkInsertParams / len(cols)
+	if rowsPerQuery > maxBulkInsertRows {
+		rowsPerQuery = maxBulkInsertRows
+	}
+	if rowsPerQuery == 0 {
+		rowsPerQuery = 1
+	}
+	for start := 0; start < len(rows); start += rowsPerQuery {
+		end := start + rowsPerQuery
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var args []interface{}
+		for _, r := range rows[start:end] {
+			args = append(args, r...)
+		}
+		query := fmt.Sprint("insert into ", tableName, " (", strings.Join(cols, " , "), ") values ", myself.GetPreparedQueryPlaceholder(ctx, end-start, len(cols), false))
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// MakeSavepointSQL returns queries to set a savepoint in transaction and to rollback to it
+func (sqr *SqlMaker) MakeSavepointSQL(ctx context.Context, name string) (savepoint string, rollbackTo string) {
+	return fmt.Sprint("savepoint ", name), fmt.Sprint("rollback to savepoint ", name)
+}
@@ -0,0 +1,77 @@
+package ds
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"path/filepath"
+	"testing"
+)
+
+func TestCoerceImportValue(t *testing.T) {
+	tests := []struct {
+		col     module_model.TableColsMetaData
+		value   string
+		want    interface{}
+		wantErr bool
+	}{
+		{module_model.TableColsMetaData{OwnDataType: "Integer"}, " 12 ", int64(12), false},
+		{module_model.TableColsMetaData{OwnDataType: "Integer"}, "12.0", int64(12), false},
+		{module_model.TableColsMetaData{OwnDataType: "Integer"}, "12.5", nil, true},
+		{module_model.TableColsMetaData{OwnDataType: "Integer"}, "", nil, false},
+		{module_model.TableColsMetaData{OwnDataType: "Decimal"}, "1.25", 1.25, false},
+		{module_model.TableColsMetaData{OwnDataType: "Boolean"}, "Yes", true, false},
+		{module_model.TableColsMetaData{OwnDataType: "Boolean"}, "maybe", nil, true},
+		{module_model.TableColsMetaData{OwnDataType: "Varchar", CharMaxLength: 3}, "abcd", nil, true},
+		{module_model.TableColsMetaData{OwnDataType: "Varchar", IsNullable: true}, "", nil, false},
+		{module_model.TableColsMetaData{OwnDataType: "Varchar"}, "", "", false},
+		{module_model.TableColsMetaData{OwnDataType: "JSON"}, "{a}", nil, true},
+		{module_model.TableColsMetaData{OwnDataType: "Date"}, "01/02/2023", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := CoerceImportValue(tt.col, tt.value)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("CoerceImportValue(%s, %q) = %v, %v", tt.col.OwnDataType, tt.value, got, err)
+		}
+	}
+	if got, err := CoerceImportValue(module_model.TableColsMetaData{OwnDataType: "DateTime"}, "2023-02-01 10:30:00"); err != nil || got == nil {
+		t.Errorf("datetime is expected to be parsed : %v, %v", got, err)
+	}
+}
+
+func TestSqliteImportRows(t *testing.T) {
+	ctx := context.Background()
+	sr := GetSqlMaker("sqlite")
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db")}}
+	if err := sr.CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+	if _, err := datasource.Con.Exec("create table customers (id integer primary key, name text not null)"); err != nil {
+		t.Fatal(err)
+	}
+	rows := []ImportRow{{RowNo: 2, Values: []interface{}{1, "a"}}, {RowNo: 3, Values: []interface{}{1, "b"}}, {RowNo: 4, Values: []interface{}{2, nil}}, {RowNo: 5, Values: []interface{}{3, "c"}}}
+	cols := []string{"id", "name"}
+
+	imported, rowErrors, err := ImportRows(ctx, datasource, sr, "customers", cols, rows, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 0 || len(rowErrors) != 2 {
+		t.Fatalf("import with stop on error is expected to import nothing : imported %d, errors %v", imported, rowErrors)
+	}
+	var count int
+	if err = datasource.Con.Get(&count, "select count(*) from customers"); err != nil || count != 0 {
+		t.Fatalf("no row is expected in table : %d, %v", count, err)
+	}
+
+	imported, rowErrors, err = ImportRows(ctx, datasource, sr, "customers", cols, rows, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 2 || len(rowErrors) != 2 || rowErrors[0].RowNo != 3 || rowErrors[1].RowNo != 4 {
+		t.Fatalf("rows 3 and 4 are expected to fail : imported %d, errors %v", imported, rowErrors)
+	}
+	if err = datasource.Con.Get(&count, "select count(*) from customers"); err != nil || count != 2 {
+		t.Fatalf("2 rows are expected in table : %d, %v", count, err)
+	}
+}
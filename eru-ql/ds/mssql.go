@@ -6,6 +6,10 @@ import (
 	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
 	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/microsoft/go-mssqldb"
+	"net"
+	"net/url"
 	"strings"
 )
 
@@ -13,38 +17,231 @@ type MssqlSqlMaker struct {
 	SqlMaker
 }
 
-func (pr *MssqlSqlMaker) GetTableMetaDataSQL(ctx context.Context) string {
+func (mr *MssqlSqlMaker) GetPreparedQueryPlaceholder(ctx context.Context, rowCount int, colCount int, single bool) string {
+	logs.WithContext(ctx).Debug("GetPreparedQueryPlaceholder - Start")
+	return makePreparedQueryPlaceholder(rowCount, colCount, single, func(n int) string {
+		return fmt.Sprint("@p", n)
+	})
+}
+
+func (mr *MssqlSqlMaker) GetTableMetaDataSQL(ctx context.Context) string {
+	logs.WithContext(ctx).Debug("GetTableMetaDataSQL - Start")
 	return mssqlTableMetaDataSQL
 }
+
+func (mr *MssqlSqlMaker) GetSingleTableMetaDataSQL(ctx context.Context) string {
+	logs.WithContext(ctx).Debug("GetSingleTableMetaDataSQL - Start")
+	return mssqlSingleTableMetaDataSQL
+}
+
+// CreateConn opens connection pool to DefaultDB of the sql server as per DriverConfig and checks it with a ping
 func (mr *MssqlSqlMaker) CreateConn(ctx context.Context, dataSource *module_model.DataSource) error {
-	return errors.New("CreateConn not implemented")
+	logs.WithContext(ctx).Debug("CreateConn - Start")
+	db, err := sqlx.Open("sqlserver", makeMssqlConnString(dataSource.DbConfig))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		dataSource.ConStatus = false
+		return err
+	}
+	dc := dataSource.DbConfig.DriverConfig
+	if dc.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(dc.MaxOpenConns)
+	}
+	if dc.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(dc.MaxIdleConns)
+	}
+	if dc.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(dc.ConnMaxLifetime)
+	}
+	err = db.PingContext(ctx)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		dataSource.ConStatus = false
+		_ = db.Close()
+		return err
+	}
+	logs.WithContext(ctx).Info("ping success - setting con as true")
+	dataSource.Con = db
+	dataSource.ConStatus = true
+	return nil
+}
+
+// makeMssqlConnString returns sqlserver url of the db - user and password are escaped as they can have characters like @ and /
+func makeMssqlConnString(dbConfig module_model.DbConfig) string {
+	u := &url.URL{
+		Scheme: "sqlserver",
+		User:   url.UserPassword(dbConfig.User, dbConfig.Password),
+		Host:   net.JoinHostPort(dbConfig.Host, dbConfig.Port),
+	}
+	q := url.Values{}
+	q.Set("database", dbConfig.DefaultDB)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// MakeCreateTableSQL returns create table query - auto increment columns are made identity columns
+func (mr *MssqlSqlMaker) MakeCreateTableSQL(ctx context.Context, tableName string, tableObj map[string]module_model.TableColsMetaData) (string, error) {
+	logs.WithContext(ctx).Debug("MakeCreateTableSQL - Start")
+	var cols []string
+	var pkCols []string
+	var fks []string
+	uqCon := make(map[string][]string)
+	tableCols := make(map[string]module_model.TableColsMetaData)
+	for k, v := range tableObj {
+		if v.ColName == "" {
+			v.ColName = k
+		}
+		tableCols[k] = v
+	}
+	for _, v := range sortCols(tableCols) {
+		colDef := ""
+		if v.AutoIncrement {
+			dt := "int"
+			if v.OwnDataType == "BigInteger" {
+				dt = "bigint"
+			}
+			colDef = fmt.Sprint(v.ColName, " ", dt, " identity(1,1) not null")
+		} else {
+			dt, err := mr.makeColType(ctx, v)
+			if err != nil {
+				logs.WithContext(ctx).Error(err.Error())
+				return "", err
+			}
+			colDef = fmt.Sprint(v.ColName, " ", dt)
+			if !v.IsNullable || v.PrimaryKey {
+				colDef = fmt.Sprint(colDef, " not null")
+			}
+			if v.DefaultValue != "" {
+				colDef = fmt.Sprint(colDef, " default ", makeDefaultValue(v.DefaultValue))
+			}
+		}
+		cols = append(cols, colDef)
+		if v.PrimaryKey {
+			pkCols = append(pkCols, v.ColName)
+		} else if v.IsUnique {
+			uqName := v.UqConstraintName
+			if uqName == "" {
+				uqName = fmt.Sprint("uq_", v.TblName, "_", v.ColName)
+			}
+			uqCon[uqName] = append(uqCon[uqName], v.ColName)
+		}
+		if v.FkTblName != "" {
+			fks = append(fks, fmt.Sprint("constraint ", makeFkName(v), makeFkReference(v)))
+		}
+	}
+	if len(pkCols) > 0 {
+		cols = append(cols, fmt.Sprint("constraint pk_", strings.Replace(tableName, ".", "___", 1), " primary key (", strings.Join(pkCols, " , "), ")"))
+	}
+	for _, k := range sortedKeys(uqCon) {
+		cols = append(cols, fmt.Sprint("constraint ", k, " unique (", strings.Join(uqCon[k], " , "), ")"))
+	}
+	cols = append(cols, fks...)
+	return fmt.Sprint("create table ", tableName, " (", strings.Join(cols, " , "), " )"), nil
+}
+
+func (mr *MssqlSqlMaker) MakeDropTableSQL(ctx context.Context, tableName string) (string, error) {
+	logs.WithContext(ctx).Debug("MakeDropTableSQL - Start")
+	return fmt.Sprint("drop table ", tableName), nil
+}
+
+// makeColType returns sql server data type of the column with its length or precision - text and json columns are nvarchar(max)
+func (mr *MssqlSqlMaker) makeColType(ctx context.Context, col module_model.TableColsMetaData) (string, error) {
+	dt := mssqlErutoDBDataTypeMapping[col.OwnDataType]
+	if dt == "" {
+		return "", errors.New(fmt.Sprint("Unsupported Datatype : ", col.OwnDataType))
+	}
+	switch dt {
+	case "decimal":
+		if p := makeNumericPrecision(col.NumericPrecision); p != "" {
+			dt = fmt.Sprint(dt, "(", p, ")")
+		}
+	case "nvarchar", "nchar":
+		if col.CharMaxLength > 0 && col.CharMaxLength <= 4000 {
+			dt = fmt.Sprint(dt, "(", col.CharMaxLength, ")")
+		} else if dt == "nvarchar" {
+			dt = "nvarchar(max)"
+		}
+	case "datetime2", "datetimeoffset", "time":
+		if col.DatetimePrecision > 0 && col.DatetimePrecision <= 7 {
+			dt = fmt.Sprint(dt, "(", col.DatetimePrecision, ")")
+		}
+	}
+	return dt, nil
 }
+
+// MakeReturningClause returns output clause as sql server returns affected rows with output clause placed before values, where or select of the query.
+// deleted rows are returned for delete and inserted rows, which hold the new values, for insert and update.
+func (mr *MssqlSqlMaker) MakeReturningClause(ctx context.Context, queryType string, returnFields string) (outputClause string, returningClause string) {
+	logs.WithContext(ctx).Debug("MakeReturningClause - Start")
+	prefix := "inserted."
+	if queryType == "delete" {
+		prefix = "deleted."
+	}
+	return fmt.Sprint(" output ", makeOutputCols(prefix, returnFields), " "), ""
+}
+
+func makeOutputCols(prefix string, returnFields string) string {
+	var outputCols []string
+	for _, f := range strings.Split(returnFields, ",") {
+		outputCols = append(outputCols, fmt.Sprint(prefix, strings.TrimSpace(f)))
+	}
+	return strings.Join(outputCols, " , ")
+}
+
+func (mr *MssqlSqlMaker) MakeUpsertSQL(ctx context.Context, tableName string, cols []string, colsPlaceholder string, conflictCols []string, updateCols []string, returnFields string) (string, error) {
+	logs.WithContext(ctx).Debug("MakeUpsertSQL - Start")
+	var onCols []string
+	for _, c := range conflictCols {
+		onCols = append(onCols, fmt.Sprint("tgt.", c, " = src.", c))
+	}
+	var srcCols []string
+	for _, c := range cols {
+		srcCols = append(srcCols, fmt.Sprint("src.", c))
+	}
+	query := fmt.Sprint("merge into ", tableName, " as tgt using (values ", colsPlaceholder, ") as src (", strings.Join(cols, ","), ")",
+		" on (", strings.Join(onCols, " and "), ")")
+	if len(updateCols) > 0 {
+		var setCols []string
+		for _, c := range updateCols {
+			setCols = append(setCols, fmt.Sprint("tgt.", c, " = src.", c))
+		}
+		query = fmt.Sprint(query, " when matched then update set ", strings.Join(setCols, " , "))
+	}
+	query = fmt.Sprint(query, " when not matched then insert (", strings.Join(cols, ","), ") values (", strings.Join(srcCols, ","), ")")
+	if returnFields != "" {
+		query = fmt.Sprint(query, " output ", makeOutputCols("inserted.", returnFields))
+	}
+	// merge statement must be terminated by a semicolon
+	return fmt.Sprint(query, ";"), nil
+}
+
 func (mr *MssqlSqlMaker) CheckMe(ctx context.Context) {
 	logs.WithContext(ctx).Info("I am MssqlSqlMaker changed  removed")
 	mr.ChildChange = "changed by MysqlRead"
 }
 
+// AddLimitSkipClause pages the query with offset fetch - sql server needs order by for offset fetch, so rows are ordered by nothing if query is not sorted
 func (mr *MssqlSqlMaker) AddLimitSkipClause(ctx context.Context, query string, limit int, skip int, globalLimit int) (newQuery string) {
 	logs.WithContext(ctx).Debug("AddLimitSkipClause - Start")
-	if limit == 0 {
+	if limit <= 0 {
 		limit = globalLimit
 	}
+	orderClause := ""
+	if !hasOrderBy(query) {
+		orderClause = " order by (select null)"
+	}
+	return fmt.Sprint(query, orderClause, " offset ", skip, " rows fetch next ", limit, " rows only")
+}
 
-	if skip == 0 {
-		if mr.DistinctResults {
-			newQuery = strings.Replace(query, "distinct ", fmt.Sprint("distinct top ", limit, " "), 1)
-		} else {
-			newQuery = strings.Replace(query, "select ", fmt.Sprint("select top ", limit), 1)
-		}
-
-	} else {
-		orderClause := ""
-		if mr.SortClause == "" {
-			orderClause = " order by (select null) "
-		}
-		newQuery = fmt.Sprint(query, orderClause, " offset ", skip, " rows fetch next ", limit, " rows only")
+// hasOrderBy returns true if the outer query has an order by clause - order by of sub queries is followed by closing bracket of the sub query
+func hasOrderBy(query string) bool {
+	lowerQuery := strings.ToLower(query)
+	idx := strings.LastIndex(lowerQuery, "order by")
+	if idx < 0 {
+		return false
 	}
-	return newQuery
+	rest := lowerQuery[idx:]
+	return strings.Count(rest, ")") <= strings.Count(rest, "(")
 }
 
 func (mr *MssqlSqlMaker) getDataTypeMapping(ctx context.Context, dataType string) string {
@@ -56,5 +253,108 @@ func (mr *MssqlSqlMaker) getDataTypeMapping(ctx context.Context, dataType string
 	}
 }
 
-var mssqlTableMetaDataSQL = ""
-var mssqlDataTypeMapping = map[string]string{}
+// column defaults are stored wrapped in brackets e.g. ((0)) and ('abc') - outer brackets and quotes are removed as in postgres
+const mssqlColumnsMetaDataSQL = `select c.table_schema tblschema,
+	c.table_name tblname,
+	c.column_name colname,
+	c.data_type datatype,
+	'' owndatatype,
+	case when pk.constraint_name is not null then 'true' else 'false' end primarykey,
+	case when uq.constraint_name is not null then 'true' else 'false' end isunique,
+	coalesce(pk.constraint_name,'') pkconstraintname,
+	coalesce(uq.constraint_name,'') uqconstraintname,
+	case when c.is_nullable = 'YES' then 'true' else 'false' end isnullable,
+	c.ordinal_position colposition,
+	replace(case when d.def like '(%)' then substring(d.def, 2, len(d.def) - 2) else coalesce(d.def,'') end, '''', '') defaultvalue,
+	case when columnproperty(object_id(quotename(c.table_schema) + '.' + quotename(c.table_name)), c.column_name, 'IsIdentity') = 1 then 'true' else 'false' end autoincrement,
+	coalesce(c.character_maximum_length,-1) charmaxlength,
+	concat(coalesce(c.numeric_precision,0),',',coalesce(c.numeric_scale,0)) numericprecision,
+	coalesce(c.numeric_scale,0) numericscale,
+	coalesce(c.datetime_precision,0) datetimeprecision,
+	coalesce(fk.constraint_name,'') fkconstraintname,
+	coalesce(fk.delete_rule,'') fkdeleterule,
+	coalesce(fk.foreign_table_schema,'') fktblschema,
+	coalesce(fk.foreign_table_name,'') fktblname,
+	coalesce(fk.foreign_column_name,'') fkcolname
+from information_schema.columns c
+cross apply (select case when c.column_default like '(%)' then substring(c.column_default, 2, len(c.column_default) - 2) else c.column_default end def) d
+left join (select tc.constraint_name, tc.table_schema, tc.table_name, kcu.column_name from information_schema.table_constraints tc
+	inner join information_schema.key_column_usage kcu on tc.constraint_name = kcu.constraint_name and tc.table_schema = kcu.table_schema and tc.table_name = kcu.table_name
+	where tc.constraint_type = 'PRIMARY KEY') pk on pk.table_schema = c.table_schema and pk.table_name = c.table_name and pk.column_name = c.column_name
+left join (select tc.constraint_name, tc.table_schema, tc.table_name, kcu.column_name from information_schema.table_constraints tc
+	inner join information_schema.key_column_usage kcu on tc.constraint_name = kcu.constraint_name and tc.table_schema = kcu.table_schema and tc.table_name = kcu.table_name
+	where tc.constraint_type = 'UNIQUE') uq on uq.table_schema = c.table_schema and uq.table_name = c.table_name and uq.column_name = c.column_name
+left join (select f.name constraint_name, schema_name(tp.schema_id) table_schema, tp.name table_name, cp.name column_name,
+		schema_name(tr.schema_id) foreign_table_schema, tr.name foreign_table_name, cr.name foreign_column_name,
+		replace(f.delete_referential_action_desc, '_', ' ') delete_rule
+	from sys.foreign_keys f
+	inner join sys.foreign_key_columns fkc on fkc.constraint_object_id = f.object_id
+	inner join sys.tables tp on tp.object_id = fkc.parent_object_id
+	inner join sys.columns cp on cp.object_id = fkc.parent_object_id and cp.column_id = fkc.parent_column_id
+	inner join sys.tables tr on tr.object_id = fkc.referenced_object_id
+	inner join sys.columns cr on cr.object_id = fkc.referenced_object_id and cr.column_id = fkc.referenced_column_id) fk
+	on fk.table_schema = c.table_schema and fk.table_name = c.table_name and fk.column_name = c.column_name
+where c.table_schema not in ('information_schema','sys')`
+
+const mssqlTableMetaDataSQL = mssqlColumnsMetaDataSQL + `
+order by c.table_schema, c.table_name, c.ordinal_position`
+
+const mssqlSingleTableMetaDataSQL = mssqlColumnsMetaDataSQL + `
+and c.table_schema = @p1 and c.table_name = @p2
+order by c.table_schema, c.table_name, c.ordinal_position`
+
+var mssqlDataTypeMapping = map[string]string{
+	"tinyint":        "SmallInteger",
+	"smallint":       "SmallInteger",
+	"int":            "Integer",
+	"bigint":         "BigInteger",
+	"decimal":        "Decimal",
+	"numeric":        "Decimal",
+	"money":          "Decimal",
+	"real":           "Float",
+	"float":          "Float",
+	"varchar":        "Varchar",
+	"nvarchar":       "Varchar",
+	"char":           "Char",
+	"nchar":          "Char",
+	"text":           "String",
+	"ntext":          "String",
+	"datetime":       "DateTime",
+	"datetime2":      "DateTime",
+	"smalldatetime":  "DateTime",
+	"datetimeoffset": "DateTimeWithZone",
+	"date":           "Date",
+	"time":           "Time",
+	"bit":            "Boolean"}
+
+var mssqlErutoDBDataTypeMapping = map[string]string{
+	"SmallInteger":     "smallint",
+	"Integer":          "int",
+	"BigInteger":       "bigint",
+	"Decimal":          "decimal",
+	"Float":            "float",
+	"Varchar":          "nvarchar",
+	"Char":             "nchar",
+	"String":           "nvarchar(max)",
+	"DateTime":         "datetime2",
+	"DateTimeWithZone": "datetimeoffset",
+	"Date":             "date",
+	"Time":             "time",
+	"TimeWithZone":     "datetimeoffset",
+	"Boolean":          "bit",
+	"JSON":             "nvarchar(max)",
+}
+
+// MakeCreateTableIfNotExistsSQL checks for the table with object_id as mssql has no create table if not exists
+func (mr *MssqlSqlMaker) MakeCreateTableIfNotExistsSQL(ctx context.Context, tableName string, colDefs string) string {
+	return fmt.Sprint("if object_id('", tableName, "', 'U') is null create table ", tableName, " (", colDefs, ")")
+}
+
+// GetTimestampDataType returns datetime2 as timestamp is a row version in mssql
+func (mr *MssqlSqlMaker) GetTimestampDataType(ctx context.Context) string {
+	return "datetime2"
+}
+
+func (mr *MssqlSqlMaker) MakeSavepointSQL(ctx context.Context, name string) (savepoint string, rollbackTo string) {
+	return fmt.Sprint("save transaction ", name), fmt.Sprint("rollback transaction ", name)
+}
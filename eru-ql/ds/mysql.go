@@ -2,11 +2,15 @@ package ds
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
 	"github.com/eru-tech/eru/eru-ql/module_model"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"strconv"
+	"strings"
 )
 
 type MysqlSqlMaker struct {
@@ -17,6 +21,137 @@ func (mr *MysqlSqlMaker) GetTableMetaDataSQL(ctx context.Context) string {
 	return mysqlTableMetaDataSQL
 }
 
+func (mr *MysqlSqlMaker) GetSingleTableMetaDataSQL(ctx context.Context) string {
+	return mysqlSingleTableMetaDataSQL
+}
+
+// MakeUpsertSQL updates the row on duplicate key only if it matches the conflict columns - mysql raises duplicate key on any
+// unique key, so a row conflicting on some other unique key is left as is. Returning fields are not supported by mysql.
+func (mr *MysqlSqlMaker) MakeUpsertSQL(ctx context.Context, tableName string, cols []string, colsPlaceholder string, conflictCols []string, updateCols []string, returnFields string) (string, error) {
+	logs.WithContext(ctx).Debug("MakeUpsertSQL - Start")
+	if returnFields != "" {
+		err := errors.New("returning fields of upsert is not supported for mysql")
+		logs.WithContext(ctx).Error(err.Error())
+		return "", err
+	}
+	if len(conflictCols) == 0 {
+		err := errors.New(fmt.Sprint("conflict columns not found for upsert on table ", tableName))
+		logs.WithContext(ctx).Error(err.Error())
+		return "", err
+	}
+	var matchCols []string
+	for _, c := range conflictCols {
+		matchCols = append(matchCols, fmt.Sprint(c, " <=> values(", c, ")"))
+	}
+	isConflict := strings.Join(matchCols, " and ")
+	var setCols []string
+	for _, c := range updateCols {
+		setCols = append(setCols, fmt.Sprint(c, " = if(", isConflict, ", values(", c, "), ", c, ")"))
+	}
+	if len(setCols) == 0 {
+		// no-op update to keep existing row as is
+		setCols = append(setCols, fmt.Sprint(conflictCols[0], " = ", conflictCols[0]))
+	}
+	return fmt.Sprint("insert into ", tableName, " (", strings.Join(cols, ","), ") values ", colsPlaceholder,
+		" on duplicate key update ", strings.Join(setCols, " , ")), nil
+}
+
+// MakeAlterTableSQL returns queries to change live table to the submitted table.
+// mysql needs complete definition of column to change any of type, nullability or default - so changed columns are modified with their submitted definition.
+func (mr *MysqlSqlMaker) MakeAlterTableSQL(ctx context.Context, tableName string, liveTableObj map[string]module_model.TableColsMetaData, tableObj map[string]module_model.TableColsMetaData) (queries []string, err error) {
+	logs.WithContext(ctx).Debug("MakeAlterTableSQL - Start")
+	td, err := diffTable(ctx, tableName, liveTableObj, tableObj, func(col module_model.TableColsMetaData) (string, error) {
+		return mr.makeColType(ctx, col)
+	})
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	alter := fmt.Sprint("alter table ", tableName, " ")
+	for _, fk := range td.dropFks {
+		queries = append(queries, fmt.Sprint(alter, "drop foreign key ", fk))
+	}
+	for _, uq := range td.dropUqs {
+		queries = append(queries, fmt.Sprint(alter, "drop index ", uq.name))
+	}
+	if td.dropPk != nil {
+		queries = append(queries, fmt.Sprint(alter, "drop primary key"))
+	}
+	for _, r := range td.renameCols {
+		queries = append(queries, fmt.Sprint(alter, "rename column ", r.from, " to ", r.to))
+	}
+	for _, c := range td.dropCols {
+		queries = append(queries, fmt.Sprint(alter, "drop column ", c.ColName))
+	}
+	for _, c := range td.addCols {
+		colDef, e := mr.makeColDefinition(ctx, c)
+		if e != nil {
+			return nil, e
+		}
+		queries = append(queries, fmt.Sprint(alter, "add column ", colDef))
+	}
+	for _, cc := range td.changeCols {
+		colDef, e := mr.makeColDefinition(ctx, cc.col)
+		if e != nil {
+			return nil, e
+		}
+		queries = append(queries, fmt.Sprint(alter, "modify column ", colDef))
+	}
+	if td.addPk != nil {
+		queries = append(queries, fmt.Sprint(alter, "add primary key (", strings.Join(td.addPk.cols, " , "), ")"))
+	}
+	for _, uq := range td.addUqs {
+		queries = append(queries, fmt.Sprint(alter, "add constraint ", uq.name, " unique (", strings.Join(uq.cols, " , "), ")"))
+	}
+	for _, c := range td.addFks {
+		queries = append(queries, fmt.Sprint(alter, "add constraint ", makeFkName(c), makeFkReference(c)))
+	}
+	return queries, nil
+}
+
+// makeColDefinition returns column name with its type, nullability and default as used in add and modify column
+func (mr *MysqlSqlMaker) makeColDefinition(ctx context.Context, col module_model.TableColsMetaData) (string, error) {
+	dt, err := mr.makeColType(ctx, col)
+	if err != nil {
+		return "", err
+	}
+	colDef := fmt.Sprint(col.ColName, " ", dt)
+	if !col.IsNullable || col.PrimaryKey {
+		colDef = fmt.Sprint(colDef, " not null")
+	}
+	if col.AutoIncrement {
+		colDef = fmt.Sprint(colDef, " auto_increment")
+	} else if col.DefaultValue != "" {
+		colDef = fmt.Sprint(colDef, " default ", makeDefaultValue(col.DefaultValue))
+	}
+	return colDef, nil
+}
+
+// makeColType returns mysql data type of the column with its length or precision
+func (mr *MysqlSqlMaker) makeColType(ctx context.Context, col module_model.TableColsMetaData) (string, error) {
+	dt := mysqlErutoDBDataTypeMapping[col.OwnDataType]
+	if dt == "" {
+		return "", errors.New(fmt.Sprint("Unsupported Datatype : ", col.OwnDataType))
+	}
+	switch dt {
+	case "decimal":
+		if p := makeNumericPrecision(col.NumericPrecision); p != "" {
+			dt = fmt.Sprint(dt, "(", p, ")")
+		}
+	case "varchar", "char":
+		if col.CharMaxLength > 0 {
+			dt = fmt.Sprint(dt, "(", col.CharMaxLength, ")")
+		} else if dt == "varchar" {
+			dt = "varchar(255)"
+		}
+	case "datetime", "timestamp", "time":
+		if col.DatetimePrecision > 0 {
+			dt = fmt.Sprint(dt, "(", col.DatetimePrecision, ")")
+		}
+	}
+	return dt, nil
+}
+
 func (mr *MysqlSqlMaker) CreateConn(ctx context.Context, dataSource *module_model.DataSource) error {
 	logs.WithContext(ctx).Debug("CreateConn - Start")
 	connString := fmt.Sprint(dataSource.DbConfig.User, ":", dataSource.DbConfig.Password, "@tcp(", dataSource.DbConfig.Host, ":", dataSource.DbConfig.Port, ")/", dataSource.DbConfig.DefaultSchema)
@@ -69,6 +204,12 @@ func (mr *MysqlSqlMaker) executeQuery(ctx context.Context, query string, datasou
 	}
 	return nil, nil
 }
+
+// GetTimestampDataType returns datetime as first timestamp column of a mysql table may be updated automatically on every update of the row
+func (mr *MysqlSqlMaker) GetTimestampDataType(ctx context.Context) string {
+	return "datetime"
+}
+
 func (mr *MysqlSqlMaker) getDataTypeMapping(ctx context.Context, dataType string) string {
 	logs.WithContext(ctx).Debug("getDataTypeMapping - Start")
 	if mysqlDataTypeMapping[dataType] == "" {
@@ -78,6 +219,102 @@ func (mr *MysqlSqlMaker) getDataTypeMapping(ctx context.Context, dataType string
 	}
 }
 
-var mysqlTableMetaDataSQL = ""
+// column aliases are in lower case as mysql returns them as is and they are mapped to lower case field names of TableColsMetaData
+const mysqlColumnsMetaDataSQL = `select c.table_schema tblschema,
+	c.table_name tblname,
+	c.column_name colname,
+	c.data_type datatype,
+	'' owndatatype,
+	case when c.column_key = 'PRI' then 'true' else 'false' end primarykey,
+	case when uq.constraint_name is not null then 'true' else 'false' end isunique,
+	case when c.column_key = 'PRI' then 'PRIMARY' else '' end pkconstraintname,
+	coalesce(uq.constraint_name,'') uqconstraintname,
+	case when c.is_nullable = 'YES' then 'true' else 'false' end isnullable,
+	c.ordinal_position colposition,
+	coalesce(c.column_default,'') defaultvalue,
+	case when c.extra like '%auto_increment%' then 'true' else 'false' end autoincrement,
+	coalesce(c.character_maximum_length,-1) charmaxlength,
+	concat(coalesce(c.numeric_precision,0),',',coalesce(c.numeric_scale,0)) numericprecision,
+	coalesce(c.numeric_scale,0) numericscale,
+	coalesce(c.datetime_precision,0) datetimeprecision,
+	coalesce(fk.constraint_name,'') fkconstraintname,
+	coalesce(rc.delete_rule,'') fkdeleterule,
+	coalesce(fk.referenced_table_schema,'') fktblschema,
+	coalesce(fk.referenced_table_name,'') fktblname,
+	coalesce(fk.referenced_column_name,'') fkcolname
+from information_schema.columns c
+left join (select tc.constraint_name, tc.table_schema, tc.table_name, kcu.column_name from information_schema.table_constraints tc
+	inner join information_schema.key_column_usage kcu on tc.constraint_name = kcu.constraint_name and tc.table_schema = kcu.table_schema and tc.table_name = kcu.table_name
+	where tc.constraint_type = 'UNIQUE') uq on uq.table_schema = c.table_schema and uq.table_name = c.table_name and uq.column_name = c.column_name
+left join information_schema.key_column_usage fk on fk.table_schema = c.table_schema and fk.table_name = c.table_name and fk.column_name = c.column_name and fk.referenced_table_name is not null
+left join information_schema.referential_constraints rc on rc.constraint_schema = fk.constraint_schema and rc.constraint_name = fk.constraint_name
+where c.table_schema not in ('information_schema','mysql','performance_schema','sys')`
+
+const mysqlTableMetaDataSQL = mysqlColumnsMetaDataSQL + `
+order by c.table_schema, c.table_name, c.ordinal_position`
+
+const mysqlSingleTableMetaDataSQL = mysqlColumnsMetaDataSQL + `
+and c.table_schema = ? and c.table_name = ?
+order by c.table_schema, c.table_name, c.ordinal_position`
+
+var mysqlDataTypeMapping = map[string]string{
+	"smallint":  "SmallInteger",
+	"int":       "Integer",
+	"bigint":    "BigInteger",
+	"decimal":   "Decimal",
+	"float":     "Float",
+	"double":    "Float",
+	"varchar":   "Varchar",
+	"char":      "Char",
+	"text":      "String",
+	"datetime":  "DateTime",
+	"timestamp": "DateTimeWithZone",
+	"date":      "Date",
+	"time":      "Time",
+	"tinyint":   "Boolean",
+	"json":      "JSON"}
 
-var mysqlDataTypeMapping = map[string]string{}
+var mysqlErutoDBDataTypeMapping = map[string]string{
+	"SmallInteger":     "smallint",
+	"Integer":          "int",
+	"BigInteger":       "bigint",
+	"Decimal":          "decimal",
+	"Float":            "double",
+	"Varchar":          "varchar",
+	"Char":             "char",
+	"String":           "text",
+	"DateTime":         "datetime",
+	"DateTimeWithZone": "timestamp",
+	"Date":             "date",
+	"Time":             "time",
+	"TimeWithZone":     "time",
+	"Boolean":          "tinyint(1)",
+	"JSON":             "json",
+}
+
+// GetQueryCost returns cost of the query block estimated by explain of mysql
+func (mr *MysqlSqlMaker) GetQueryCost(ctx context.Context, datasource *module_model.DataSource, query string, args ...interface{}) (cost float64, err error) {
+	logs.WithContext(ctx).Debug("GetQueryCost - Start")
+	var plan interface{}
+	err = datasource.Con.QueryRowxContext(ctx, fmt.Sprint("explain format=json ", query), args...).Scan(&plan)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return 0, err
+	}
+	var explain struct {
+		QueryBlock struct {
+			CostInfo struct {
+				QueryCost string `json:"query_cost"`
+			} `json:"cost_info"`
+		} `json:"query_block"`
+	}
+	err = json.Unmarshal(jsonBytes(plan), &explain)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return 0, err
+	}
+	if explain.QueryBlock.CostInfo.QueryCost == "" {
+		return 0, errors.New("query cost not returned by explain")
+	}
+	return strconv.ParseFloat(explain.QueryBlock.CostInfo.QueryCost, 64)
+}
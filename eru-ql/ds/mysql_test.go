@@ -0,0 +1,34 @@
+package ds
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMysqlMakeUpsertSQL(t *testing.T) {
+	mr := new(MysqlSqlMaker)
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		updateCols []string
+		returning  string
+		want       string
+		wantErr    bool
+	}{
+		{"update on conflict of conflict columns only", []string{"code", "qty"}, "",
+			"insert into orders (id,code,qty) values (?,?,?) on duplicate key update code = if(id <=> values(id), values(code), code) , qty = if(id <=> values(id), values(qty), qty)", false},
+		{"no columns to update", nil, "", "insert into orders (id,code,qty) values (?,?,?) on duplicate key update id = id", false},
+		{"returning is not supported", []string{"code"}, "id", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mr.MakeUpsertSQL(ctx, "orders", []string{"id", "code", "qty"}, "(?,?,?)", []string{"id"}, tt.updateCols, tt.returning)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,13 @@ package ds
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
 	"github.com/eru-tech/eru/eru-ql/module_model"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"strings"
 )
 
@@ -17,20 +18,9 @@ type PostgresSqlMaker struct {
 
 func (pr *PostgresSqlMaker) GetPreparedQueryPlaceholder(ctx context.Context, rowCount int, colCount int, single bool) string {
 	logs.WithContext(ctx).Debug("GetPreparedQueryPlaceholder - Start")
-	var rowArray []string
-	startNo := 0
-	if single {
-		return fmt.Sprint("$", colCount)
-	}
-	for r := 1; r <= rowCount; r++ {
-		var colArray []string
-		for c := 1; c <= colCount; c++ {
-			startNo++
-			colArray = append(colArray, fmt.Sprint("$", startNo))
-		}
-		rowArray = append(rowArray, fmt.Sprint("(", strings.Join(colArray, " , "), ")"))
-	}
-	return strings.Join(rowArray, " , ")
+	return makePreparedQueryPlaceholder(rowCount, colCount, single, func(n int) string {
+		return fmt.Sprint("$", n)
+	})
 }
 
 func (pr *PostgresSqlMaker) GetTableMetaDataSQL(ctx context.Context) string {
@@ -38,6 +28,11 @@ func (pr *PostgresSqlMaker) GetTableMetaDataSQL(ctx context.Context) string {
 	return postgresTableMetaDataSQL
 }
 
+func (pr *PostgresSqlMaker) GetSingleTableMetaDataSQL(ctx context.Context) string {
+	logs.WithContext(ctx).Debug("GetSingleTableMetaDataSQL - Start")
+	return postgresSingleTableMetaDataSQL
+}
+
 func (pr *PostgresSqlMaker) MakeCreateTableSQL(ctx context.Context, tableName string, tableObj map[string]module_model.TableColsMetaData) (string, error) {
 	logs.WithContext(ctx).Debug("MakeCreateTableSQL - Start")
 	var cols []string
@@ -108,6 +103,136 @@ func (pr *PostgresSqlMaker) MakeDropTableSQL(ctx context.Context, tableName stri
 	return fmt.Sprint("drop table ", tableName), nil
 }
 
+// MakeAlterTableSQL returns queries to change live table to the submitted table - constraints are dropped before columns and added after them
+func (pr *PostgresSqlMaker) MakeAlterTableSQL(ctx context.Context, tableName string, liveTableObj map[string]module_model.TableColsMetaData, tableObj map[string]module_model.TableColsMetaData) (queries []string, err error) {
+	logs.WithContext(ctx).Debug("MakeAlterTableSQL - Start")
+	td, err := diffTable(ctx, tableName, liveTableObj, tableObj, func(col module_model.TableColsMetaData) (string, error) {
+		return pr.makeColType(ctx, col)
+	})
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	alter := fmt.Sprint("alter table ", tableName, " ")
+	for _, fk := range td.dropFks {
+		queries = append(queries, fmt.Sprint(alter, "drop constraint if exists ", fk))
+	}
+	for _, uq := range td.dropUqs {
+		queries = append(queries, fmt.Sprint(alter, "drop constraint if exists ", uq.name))
+	}
+	if td.dropPk != nil {
+		queries = append(queries, fmt.Sprint(alter, "drop constraint if exists ", td.dropPk.name))
+	}
+	for _, r := range td.renameCols {
+		queries = append(queries, fmt.Sprint(alter, "rename column ", r.from, " to ", r.to))
+	}
+	for _, c := range td.dropCols {
+		queries = append(queries, fmt.Sprint(alter, "drop column ", c.ColName))
+	}
+	for _, c := range td.addCols {
+		dt := ""
+		if c.AutoIncrement {
+			dt = "serial"
+			if c.OwnDataType == "BigInteger" {
+				dt = "bigserial"
+			}
+		} else {
+			dt, err = pr.makeColType(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+		}
+		q := fmt.Sprint(alter, "add column ", c.ColName, " ", dt)
+		if !c.IsNullable && !c.PrimaryKey {
+			q = fmt.Sprint(q, " not null")
+		}
+		if c.DefaultValue != "" && !c.AutoIncrement {
+			q = fmt.Sprint(q, " default ", makeDefaultValue(c.DefaultValue))
+		}
+		queries = append(queries, q)
+	}
+	for _, cc := range td.changeCols {
+		alterCol := fmt.Sprint(alter, "alter column ", cc.col.ColName, " ")
+		if cc.typeChanged {
+			dt, e := pr.makeColType(ctx, cc.col)
+			if e != nil {
+				return nil, e
+			}
+			queries = append(queries, fmt.Sprint(alterCol, "type ", dt, " using ", cc.col.ColName, "::", dt))
+		}
+		if cc.nullChanged {
+			if cc.col.IsNullable {
+				queries = append(queries, fmt.Sprint(alterCol, "drop not null"))
+			} else {
+				queries = append(queries, fmt.Sprint(alterCol, "set not null"))
+			}
+		}
+		if cc.defaultChanged {
+			if cc.col.DefaultValue == "" {
+				queries = append(queries, fmt.Sprint(alterCol, "drop default"))
+			} else {
+				queries = append(queries, fmt.Sprint(alterCol, "set default ", makeDefaultValue(cc.col.DefaultValue)))
+			}
+		}
+	}
+	if td.addPk != nil {
+		queries = append(queries, fmt.Sprint(alter, "add constraint ", td.addPk.name, " primary key (", strings.Join(td.addPk.cols, " , "), ")"))
+	}
+	for _, uq := range td.addUqs {
+		queries = append(queries, fmt.Sprint(alter, "add constraint ", uq.name, " unique (", strings.Join(uq.cols, " , "), ")"))
+	}
+	for _, c := range td.addFks {
+		queries = append(queries, fmt.Sprint(alter, "add constraint ", makeFkName(c), makeFkReference(c)))
+	}
+	return queries, nil
+}
+
+// makeColType returns postgres data type of the column with its length or precision
+func (pr *PostgresSqlMaker) makeColType(ctx context.Context, col module_model.TableColsMetaData) (string, error) {
+	dt := pr.getErutoDBDataTypeMapping(ctx, col.OwnDataType)
+	if dt == "NotSupported" {
+		return "", errors.New(fmt.Sprint("Unsupported Datatype : ", col.OwnDataType))
+	}
+	switch dt {
+	case "numeric":
+		if p := makeNumericPrecision(col.NumericPrecision); p != "" {
+			dt = fmt.Sprint(dt, "(", p, ")")
+		}
+	case "character", "character varying":
+		if col.CharMaxLength > 0 {
+			dt = fmt.Sprint(dt, "(", col.CharMaxLength, ")")
+		}
+	case "timestamp without time zone", "timestamp with time zone", "time with time zone":
+		if col.DatetimePrecision > 0 {
+			dt = strings.Replace(dt, " ", fmt.Sprint("(", col.DatetimePrecision, ") "), 1)
+		}
+	}
+	return dt, nil
+}
+
+func (pr *PostgresSqlMaker) MakeUpsertSQL(ctx context.Context, tableName string, cols []string, colsPlaceholder string, conflictCols []string, updateCols []string, returnFields string) (string, error) {
+	logs.WithContext(ctx).Debug("MakeUpsertSQL - Start")
+	return makeOnConflictUpsertSQL(tableName, cols, colsPlaceholder, conflictCols, updateCols, returnFields), nil
+}
+
+// makeOnConflictUpsertSQL returns insert query with on conflict clause as supported by postgres and sqlite
+func makeOnConflictUpsertSQL(tableName string, cols []string, colsPlaceholder string, conflictCols []string, updateCols []string, returnFields string) string {
+	conflictAction := " do nothing"
+	if len(updateCols) > 0 {
+		var setCols []string
+		for _, c := range updateCols {
+			setCols = append(setCols, fmt.Sprint(c, " = excluded.", c))
+		}
+		conflictAction = fmt.Sprint(" do update set ", strings.Join(setCols, " , "))
+	}
+	query := fmt.Sprint("insert into ", tableName, " (", strings.Join(cols, ","), ") values ", colsPlaceholder,
+		" on conflict (", strings.Join(conflictCols, ","), ")", conflictAction)
+	if returnFields != "" {
+		query = fmt.Sprint(query, " RETURNING ", returnFields)
+	}
+	return query
+}
+
 func (pr *PostgresSqlMaker) CreateConn(ctx context.Context, dataSource *module_model.DataSource) error {
 	logs.WithContext(ctx).Debug("CreateConn - Start")
 	connString := fmt.Sprint("postgres://", dataSource.DbConfig.User, ":", dataSource.DbConfig.Password, "@", dataSource.DbConfig.Host, ":", dataSource.DbConfig.Port, "/", dataSource.DbConfig.DefaultSchema, "?sslmode=disable")
@@ -166,7 +291,7 @@ func (pr *PostgresSqlMaker) getErutoDBDataTypeMapping(ctx context.Context, dataT
 	}
 }
 
-const postgresTableMetaDataSQL = `select CAST(c.table_schema as VARCHAR) TblSchema,
+const postgresColumnsMetaDataSQL = `select CAST(c.table_schema as VARCHAR) TblSchema,
        CAST(c.table_name as VARCHAR) TblName,
        CAST(c.column_name as VARCHAR) ColName,
        CAST(c.data_type as VARCHAR) DataType,
@@ -209,7 +334,13 @@ LEFT JOIN (SELECT tc.table_schema, tc.constraint_name, tc.table_name, kcu.column
             	ON rc.constraint_name = tc.constraint_name AND rc.constraint_schema = tc.table_schema
 			WHERE tc.constraint_type = 'FOREIGN KEY' ) fk
 ON fk.table_name = c.table_name AND fk.column_name = c.column_name AND fk.table_schema = c.table_schema
-WHERE  c.table_schema not in ('information_schema','pg_catalog')
+WHERE  c.table_schema not in ('information_schema','pg_catalog')`
+
+const postgresTableMetaDataSQL = postgresColumnsMetaDataSQL + `
+ORDER BY c.ordinal_position`
+
+const postgresSingleTableMetaDataSQL = postgresColumnsMetaDataSQL + `
+AND c.table_schema = $1 AND c.table_name = $2
 ORDER BY c.ordinal_position`
 
 //erudevsh
@@ -239,8 +370,8 @@ var postgresErutoDBDataTypeMapping = map[string]string{
 	"BigInteger":       "bigint",
 	"Decimal":          "numeric",
 	"Float":            "double precision",
-	"Varchar":          "character",
-	"Char":             "character varying",
+	"Varchar":          "character varying",
+	"Char":             "character",
 	"String":           "text",
 	"DateTime":         "timestamp without time zone",
 	"DateTimeWithZone": "timestamp with time zone",
@@ -250,3 +381,55 @@ var postgresErutoDBDataTypeMapping = map[string]string{
 	"Boolean":          "boolean",
 	"JSON":             "jsonb",
 }
+
+// GetQueryCost returns total cost of the query plan estimated by explain of postgres
+func (pr *PostgresSqlMaker) GetQueryCost(ctx context.Context, datasource *module_model.DataSource, query string, args ...interface{}) (cost float64, err error) {
+	logs.WithContext(ctx).Debug("GetQueryCost - Start")
+	var plan interface{}
+	err = datasource.Con.QueryRowxContext(ctx, fmt.Sprint("explain (format json) ", query), args...).Scan(&plan)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return 0, err
+	}
+	var plans []struct {
+		Plan struct {
+			TotalCost float64 `json:"Total Cost"`
+		}
+	}
+	err = json.Unmarshal(jsonBytes(plan), &plans)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return 0, err
+	}
+	if len(plans) == 0 {
+		return 0, errors.New("query plan not returned by explain")
+	}
+	return plans[0].Plan.TotalCost, nil
+}
+
+// BulkInsert loads rows into the table using copy protocol which is much faster than multi row inserts for large files
+func (pr *PostgresSqlMaker) BulkInsert(ctx context.Context, tx *sqlx.Tx, myself SqlMakerI, tableName string, cols []string, rows [][]interface{}) (err error) {
+	logs.WithContext(ctx).Debug("BulkInsert - Start")
+	copyQuery := pq.CopyIn(tableName, cols...)
+	if tableParts := strings.SplitN(tableName, ".", 2); len(tableParts) == 2 {
+		copyQuery = pq.CopyInSchema(tableParts[0], tableParts[1], cols...)
+	}
+	stmt, err := tx.PrepareContext(ctx, copyQuery)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range rows {
+		if _, err = stmt.ExecContext(ctx, r...); err != nil {
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+	}
+	// copy is flushed to the db by exec without arguments
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package ds
+
+import (
+	"context"
+	"errors"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/jmoiron/sqlx"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadReplicaRouting(t *testing.T) {
+	ctx := context.Background()
+	sr := GetSqlMaker("sqlite")
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db"),
+		ReadReplicas: []module_model.ReadReplica{{Host: "r1"}, {Host: "r2"}}}}
+	if datasource.ReadDataSource(ctx) != datasource {
+		t.Fatal("datasource without replicas is expected to be used for reads")
+	}
+	if err := sr.CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+	datasource.Replicas = CreateReplicaConns(ctx, sr, datasource)
+	if datasource.Replicas == nil || len(datasource.Replicas.Cons) != 2 {
+		t.Fatalf("expected 2 replicas, got %+v", datasource.Replicas)
+	}
+	r1 := datasource.Replicas.Cons[0].HealthyCon(ctx, false)
+	r2 := datasource.Replicas.Cons[1].HealthyCon(ctx, false)
+	if r1 == nil || r2 == nil || r1 == datasource.Con || r2 == datasource.Con {
+		t.Fatal("replicas are expected to have their own healthy connections")
+	}
+
+	first := datasource.ReadDataSource(ctx).Con
+	second := datasource.ReadDataSource(ctx).Con
+	if first == second || (first != r1 && first != r2) || (second != r1 && second != r2) {
+		t.Errorf("reads are not routed to replicas in round robin")
+	}
+
+	_ = r1.Close()
+	if datasource.RecheckReplica(ctx, r1) {
+		t.Fatal("closed replica is expected to be unhealthy")
+	}
+	for i := 0; i < 3; i++ {
+		if con := datasource.ReadDataSource(ctx).Con; con != r2 {
+			t.Errorf("read %d is expected to be routed to healthy replica", i)
+		}
+	}
+	_ = r2.Close()
+	datasource.RecheckReplica(ctx, r2)
+	if datasource.ReadDataSource(ctx).Con != datasource.Con {
+		t.Error("reads are expected to be routed to primary when no replica is healthy")
+	}
+}
+
+func TestReplicaHealthCheckIsNotBlocking(t *testing.T) {
+	ctx := context.Background()
+	connecting := make(chan struct{})
+	release := make(chan struct{})
+	rc := module_model.NewReplicaCon("r1", func(ctx context.Context) (*sqlx.DB, error) {
+		close(connecting)
+		<-release
+		return nil, errors.New("replica is down")
+	})
+	done := make(chan struct{})
+	go func() {
+		rc.HealthyCon(ctx, false)
+		close(done)
+	}()
+	<-connecting
+	// check in progress is not waited for and replica is unhealthy till it is checked
+	returned := make(chan *sqlx.DB)
+	go func() { returned <- rc.HealthyCon(ctx, false) }()
+	select {
+	case con := <-returned:
+		if con != nil {
+			t.Error("replica is not expected to be healthy before it is checked")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("HealthyCon is blocked by connect of another check")
+	}
+	close(release)
+	<-done
+}
+
+func TestReplicaClose(t *testing.T) {
+	ctx := context.Background()
+	connects := 0
+	rc := module_model.NewReplicaCon("r1", func(ctx context.Context) (*sqlx.DB, error) {
+		connects++
+		return sqlx.Open("sqlite", filepath.Join(t.TempDir(), "eru.db"))
+	})
+	con := rc.HealthyCon(ctx, false)
+	if con == nil {
+		t.Fatal("replica is expected to be healthy")
+	}
+	replicas := &module_model.Replicas{Cons: []*module_model.ReplicaCon{rc}}
+	replicas.Close(ctx)
+	if err := con.PingContext(ctx); err == nil {
+		t.Error("connection pool of replica is expected to be closed")
+	}
+	if rc.HealthyCon(ctx, true) != nil || connects != 1 {
+		t.Error("closed replica is not expected to be connected again")
+	}
+	// nil replicas of datasource without replicas can be closed
+	var none *module_model.Replicas
+	none.Close(ctx)
+}
@@ -0,0 +1,281 @@
+package ds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+	"strings"
+)
+
+// SQLITE_SCHEMA is the schema name of tables of sqlite db - sqlite has no schemas and refers to the tables of the opened file as main
+const SQLITE_SCHEMA = "main"
+
+type SqliteSqlMaker struct {
+	SqlMaker
+}
+
+func (sr *SqliteSqlMaker) GetTableMetaDataSQL(ctx context.Context) string {
+	logs.WithContext(ctx).Debug("GetTableMetaDataSQL - Start")
+	return sqliteTableMetaDataSQL
+}
+
+func (sr *SqliteSqlMaker) GetSingleTableMetaDataSQL(ctx context.Context) string {
+	logs.WithContext(ctx).Debug("GetSingleTableMetaDataSQL - Start")
+	return sqliteSingleTableMetaDataSQL
+}
+
+// CreateConn opens the sqlite db file set as DefaultDB, host, port and user are not used.
+// foreign keys are enforced as sqlite does not enforce them by default.
+func (sr *SqliteSqlMaker) CreateConn(ctx context.Context, dataSource *module_model.DataSource) error {
+	logs.WithContext(ctx).Debug("CreateConn - Start")
+	db, err := sqlx.Open("sqlite", makeSqliteConnString(dataSource.DbConfig))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		dataSource.ConStatus = false
+		return err
+	}
+	dc := dataSource.DbConfig.DriverConfig
+	if dataSource.DbConfig.DefaultDB == ":memory:" {
+		// every connection to in memory db opens a new empty db
+		db.SetMaxOpenConns(1)
+	} else if dc.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(dc.MaxOpenConns)
+	}
+	if dc.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(dc.MaxIdleConns)
+	}
+	if dc.ConnMaxLifetime > 0 && dataSource.DbConfig.DefaultDB != ":memory:" {
+		db.SetConnMaxLifetime(dc.ConnMaxLifetime)
+	}
+	err = db.PingContext(ctx)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		dataSource.ConStatus = false
+		_ = db.Close()
+		return err
+	}
+	logs.WithContext(ctx).Info("ping success - setting con as true")
+	dataSource.Con = db
+	dataSource.ConStatus = true
+	return nil
+}
+
+func makeSqliteConnString(dbConfig module_model.DbConfig) string {
+	return fmt.Sprint(dbConfig.DefaultDB, "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+}
+
+func (sr *SqliteSqlMaker) CheckMe(ctx context.Context) {
+	logs.WithContext(ctx).Info("I am SqliteSqlMaker")
+	sr.ChildChange = "changed by SqliteSqlMaker"
+}
+
+func (sr *SqliteSqlMaker) AddLimitSkipClause(ctx context.Context, query string, limit int, skip int, globalLimit int) (newQuery string) {
+	logs.WithContext(ctx).Debug("AddLimitSkipClause - Start")
+	if limit > 0 {
+		newQuery = fmt.Sprint(query, " limit ", limit)
+	} else {
+		newQuery = fmt.Sprint(query, " limit ", globalLimit)
+	}
+	if skip > 0 {
+		newQuery = fmt.Sprint(newQuery, " offset ", skip)
+	}
+	return newQuery
+}
+
+// MakeUpsertSQL uses on conflict clause as in postgres - sqlite supports it with returning clause from version 3.35
+func (sr *SqliteSqlMaker) MakeUpsertSQL(ctx context.Context, tableName string, cols []string, colsPlaceholder string, conflictCols []string, updateCols []string, returnFields string) (string, error) {
+	logs.WithContext(ctx).Debug("MakeUpsertSQL - Start")
+	return makeOnConflictUpsertSQL(tableName, cols, colsPlaceholder, conflictCols, updateCols, returnFields), nil
+}
+
+// MakeCreateTableSQL returns create table query - single integer primary key is made autoincrement as sqlite uses it as rowid
+func (sr *SqliteSqlMaker) MakeCreateTableSQL(ctx context.Context, tableName string, tableObj map[string]module_model.TableColsMetaData) (string, error) {
+	logs.WithContext(ctx).Debug("MakeCreateTableSQL - Start")
+	var cols []string
+	var pkCols []string
+	var fks []string
+	uqCon := make(map[string][]string)
+	tableCols := make(map[string]module_model.TableColsMetaData)
+	for k, v := range tableObj {
+		if v.ColName == "" {
+			v.ColName = k
+		}
+		tableCols[k] = v
+		if v.PrimaryKey {
+			pkCols = append(pkCols, v.ColName)
+		}
+	}
+	for _, v := range sortCols(tableCols) {
+		if v.PrimaryKey && len(pkCols) == 1 && (v.AutoIncrement || v.OwnDataType == "" || v.OwnDataType == "Integer" || v.OwnDataType == "BigInteger") {
+			cols = append(cols, fmt.Sprint(v.ColName, " integer primary key autoincrement"))
+			pkCols = nil
+			continue
+		}
+		dt, err := sr.makeColType(ctx, v)
+		if err != nil {
+			logs.WithContext(ctx).Error(err.Error())
+			return "", err
+		}
+		colDef := fmt.Sprint(v.ColName, " ", dt)
+		if !v.IsNullable || v.PrimaryKey {
+			colDef = fmt.Sprint(colDef, " not null")
+		}
+		if v.DefaultValue != "" {
+			colDef = fmt.Sprint(colDef, " default ", makeSqliteDefaultValue(v.DefaultValue))
+		}
+		cols = append(cols, colDef)
+		if v.IsUnique && !v.PrimaryKey {
+			uqName := v.UqConstraintName
+			if uqName == "" {
+				uqName = fmt.Sprint("uq_", v.TblName, "_", v.ColName)
+			}
+			uqCon[uqName] = append(uqCon[uqName], v.ColName)
+		}
+		if v.FkTblName != "" {
+			// tables of other schemas cannot be referred in sqlite
+			v.FkTblSchema = ""
+			fks = append(fks, fmt.Sprint("constraint ", makeFkName(v), makeFkReference(v)))
+		}
+	}
+	if len(pkCols) > 0 {
+		cols = append(cols, fmt.Sprint("constraint pk_", strings.Replace(tableName, ".", "___", 1), " primary key (", strings.Join(pkCols, " , "), ")"))
+	}
+	for _, k := range sortedKeys(uqCon) {
+		cols = append(cols, fmt.Sprint("constraint ", k, " unique (", strings.Join(uqCon[k], " , "), ")"))
+	}
+	cols = append(cols, fks...)
+	return fmt.Sprint("create table ", tableName, " (", strings.Join(cols, " , "), " )"), nil
+}
+
+// makeSqliteDefaultValue returns default value as sql - sqlite needs expressions used as default to be in brackets
+func makeSqliteDefaultValue(val string) string {
+	dv := makeDefaultValue(val)
+	if strings.Contains(dv, "(") && !strings.HasPrefix(dv, "(") && !strings.HasPrefix(dv, "'") {
+		return fmt.Sprint("(", dv, ")")
+	}
+	return dv
+}
+
+func (sr *SqliteSqlMaker) MakeDropTableSQL(ctx context.Context, tableName string) (string, error) {
+	logs.WithContext(ctx).Debug("MakeDropTableSQL - Start")
+	return fmt.Sprint("drop table ", tableName), nil
+}
+
+// makeColType returns sqlite data type of the column with its length or precision - sqlite does not enforce them but keeps them in table metadata
+func (sr *SqliteSqlMaker) makeColType(ctx context.Context, col module_model.TableColsMetaData) (string, error) {
+	dt := sqliteErutoDBDataTypeMapping[col.OwnDataType]
+	if dt == "" {
+		return "", errors.New(fmt.Sprint("Unsupported Datatype : ", col.OwnDataType))
+	}
+	switch dt {
+	case "decimal":
+		if p := makeNumericPrecision(col.NumericPrecision); p != "" {
+			dt = fmt.Sprint(dt, "(", p, ")")
+		}
+	case "varchar", "char":
+		if col.CharMaxLength > 0 {
+			dt = fmt.Sprint(dt, "(", col.CharMaxLength, ")")
+		}
+	}
+	return dt, nil
+}
+
+func (sr *SqliteSqlMaker) getDataTypeMapping(ctx context.Context, dataType string) string {
+	logs.WithContext(ctx).Debug("getDataTypeMapping - Start")
+	if sqliteDataTypeMapping[dataType] == "" {
+		return "NotSupported"
+	} else {
+		return sqliteDataTypeMapping[dataType]
+	}
+}
+
+// sqlite keeps declared type of the column as is e.g. varchar(20) - it is split into data type and its length or precision.
+// integer primary key is an alias of rowid and hence auto increment. constraints other than primary key and unique have no name in sqlite.
+const sqliteColumnsMetaDataSQL = `select 'main' tblschema,
+	c.tblname,
+	c.colname,
+	c.datatype,
+	'' owndatatype,
+	case when c.pk > 0 then 'true' else 'false' end primarykey,
+	case when uq.name is not null then 'true' else 'false' end isunique,
+	case when c.pk > 0 then 'pk_main___' || c.tblname else '' end pkconstraintname,
+	coalesce(uq.name,'') uqconstraintname,
+	case when c.notnullflag = 0 and c.pk = 0 then 'true' else 'false' end isnullable,
+	c.cid + 1 colposition,
+	replace(coalesce(c.dflt_value,''), '''', '') defaultvalue,
+	case when c.pk = 1 and c.datatype = 'integer' and c.pkcount = 1 then 'true' else 'false' end "autoincrement",
+	case when c.datatype in ('varchar','char','nvarchar','nchar','character') and c.typeargs <> '' then cast(c.typeargs as integer) else -1 end charmaxlength,
+	case when c.datatype in ('decimal','numeric') and c.typeargs <> '' then case when instr(c.typeargs, ',') > 0 then c.typeargs else c.typeargs || ',0' end else '0,0' end numericprecision,
+	case when c.datatype in ('decimal','numeric') and instr(c.typeargs, ',') > 0 then cast(substr(c.typeargs, instr(c.typeargs, ',') + 1) as integer) else 0 end numericscale,
+	0 datetimeprecision,
+	'' fkconstraintname,
+	coalesce(fk.on_delete,'') fkdeleterule,
+	case when fk."table" is not null then 'main' else '' end fktblschema,
+	coalesce(fk."table",'') fktblname,
+	coalesce(fk."to",'') fkcolname
+from (select m.name tblname, p.name colname, p.cid, p."notnull" notnullflag, p.dflt_value, p.pk,
+		case when instr(p.type, '(') > 0 then lower(trim(substr(p.type, 1, instr(p.type, '(') - 1))) else lower(trim(p.type)) end datatype,
+		case when instr(p.type, '(') > 0 then replace(substr(p.type, instr(p.type, '(') + 1, instr(p.type, ')') - instr(p.type, '(') - 1), ' ', '') else '' end typeargs,
+		(select count(*) from pragma_table_info(m.name) pp where pp.pk > 0) pkcount
+	from sqlite_master m
+	inner join pragma_table_info(m.name) p
+	where m.type = 'table' and m.name not like 'sqlite_%') c
+left join (select m.name tblname, il.name, ii.name colname from sqlite_master m
+	inner join pragma_index_list(m.name) il
+	inner join pragma_index_info(il.name) ii
+	where m.type = 'table' and il."unique" = 1 and il.origin = 'u') uq on uq.tblname = c.tblname and uq.colname = c.colname
+left join (select m.name tblname, f."from", f."table", f."to", f.on_delete from sqlite_master m
+	inner join pragma_foreign_key_list(m.name) f
+	where m.type = 'table') fk on fk.tblname = c.tblname and fk."from" = c.colname`
+
+const sqliteTableMetaDataSQL = sqliteColumnsMetaDataSQL + `
+order by c.tblname, c.cid`
+
+const sqliteSingleTableMetaDataSQL = sqliteColumnsMetaDataSQL + `
+where 'main' = ? and c.tblname = ?
+order by c.tblname, c.cid`
+
+var sqliteDataTypeMapping = map[string]string{
+	"smallint":  "SmallInteger",
+	"integer":   "Integer",
+	"int":       "Integer",
+	"bigint":    "BigInteger",
+	"decimal":   "Decimal",
+	"numeric":   "Decimal",
+	"real":      "Float",
+	"double":    "Float",
+	"float":     "Float",
+	"varchar":   "Varchar",
+	"nvarchar":  "Varchar",
+	"char":      "Char",
+	"nchar":     "Char",
+	"character": "Char",
+	"text":      "String",
+	"datetime":  "DateTime",
+	"timestamp": "DateTime",
+	"date":      "Date",
+	"time":      "Time",
+	"boolean":   "Boolean",
+	"json":      "JSON"}
+
+var sqliteErutoDBDataTypeMapping = map[string]string{
+	"SmallInteger":     "smallint",
+	"Integer":          "integer",
+	"BigInteger":       "bigint",
+	"Decimal":          "decimal",
+	"Float":            "real",
+	"Varchar":          "varchar",
+	"Char":             "char",
+	"String":           "text",
+	"DateTime":         "datetime",
+	"DateTimeWithZone": "datetime",
+	"Date":             "date",
+	"Time":             "time",
+	"TimeWithZone":     "time",
+	"Boolean":          "boolean",
+	"JSON":             "json",
+}
@@ -0,0 +1,113 @@
+package ds
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSqliteCreateTableAndMetaData(t *testing.T) {
+	ctx := context.Background()
+	sr := GetSqlMaker("sqlite")
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db")}}
+	if err := sr.CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+
+	customers := map[string]module_model.TableColsMetaData{
+		"id":   {TblName: "customers", ColName: "id", OwnDataType: "Integer", PrimaryKey: true, ColPosition: 1},
+		"name": {TblName: "customers", ColName: "name", OwnDataType: "Varchar", CharMaxLength: 50, IsUnique: true, ColPosition: 2},
+		"meta": {TblName: "customers", ColName: "meta", OwnDataType: "JSON", IsNullable: true, ColPosition: 3},
+		"amt":  {TblName: "customers", ColName: "amt", OwnDataType: "Decimal", NumericPrecision: "10,2", DefaultValue: "0", ColPosition: 4},
+	}
+	orders := map[string]module_model.TableColsMetaData{
+		"id":  {TblName: "orders", ColName: "id", OwnDataType: "Integer", PrimaryKey: true, ColPosition: 1},
+		"cid": {TblName: "orders", ColName: "cid", OwnDataType: "Integer", FkTblSchema: "main", FkTblName: "customers", FkColName: "id", FkDeleteRule: "CASCADE", ColPosition: 2},
+	}
+	query, err := sr.MakeCreateTableSQL(ctx, "main.customers", customers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "create table main.customers (id integer primary key autoincrement , name varchar(50) not null , meta json , amt decimal(10,2) not null default 0 , constraint uq_customers_name unique (name) )"
+	if query != want {
+		t.Errorf("got  %q\nwant %q", query, want)
+	}
+	if _, err = datasource.Con.Exec(query); err != nil {
+		t.Fatal(err)
+	}
+	query, err = sr.MakeCreateTableSQL(ctx, "main.orders", orders)
+	if err == nil {
+		_, err = datasource.Con.Exec(query)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = sr.GetTableList(ctx, sr.GetTableMetaDataSQL(ctx), datasource, sr); err != nil {
+		t.Fatal(err)
+	}
+	id := datasource.OtherTables["main.customers"]["id"]
+	if !id.PrimaryKey || !id.AutoIncrement || id.OwnDataType != "Integer" {
+		t.Errorf("unexpected metadata of id : %+v", id)
+	}
+	name := datasource.OtherTables["main.customers"]["name"]
+	if !name.IsUnique || name.IsNullable || name.CharMaxLength != 50 || name.OwnDataType != "Varchar" {
+		t.Errorf("unexpected metadata of name : %+v", name)
+	}
+	amt := datasource.OtherTables["main.customers"]["amt"]
+	if amt.NumericPrecision != "10,2" || amt.DefaultValue != "0" || amt.OwnDataType != "Decimal" {
+		t.Errorf("unexpected metadata of amt : %+v", amt)
+	}
+	cid := datasource.OtherTables["main.orders"]["cid"]
+	if cid.FkTblName != "customers" || cid.FkColName != "id" || cid.FkDeleteRule != "CASCADE" {
+		t.Errorf("unexpected metadata of cid : %+v", cid)
+	}
+	if err = sr.GetTableList(ctx, sr.GetSingleTableMetaDataSQL(ctx), datasource, sr, "main", "orders"); err != nil {
+		t.Fatal(err)
+	}
+	if len(datasource.OtherTables) != 1 || len(datasource.OtherTables["main.orders"]) != 2 {
+		t.Errorf("unexpected metadata of single table : %+v", datasource.OtherTables)
+	}
+
+	res, err := sr.ExecutePreparedQuery(ctx, "insert into main.customers (name, meta) values (?, ?) RETURNING id, meta", datasource, "a", `{"x":1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := res["Results"].([]map[string]interface{})
+	meta, ok := rows[0]["meta"].(*interface{})
+	if len(rows) != 1 || !ok || (*meta).(map[string]interface{})["x"] != 1.0 {
+		t.Errorf("unexpected result : %+v", rows)
+	}
+}
+
+func TestSqliteQueryLimits(t *testing.T) {
+	ctx := context.Background()
+	sr := GetSqlMaker("sqlite")
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db"),
+		OtherDbConfig: module_model.OtherDbConfig{MaxRows: 5}}}
+	if err := sr.CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+
+	series := "with recursive s(n) as (select 1 union all select n + 1 from s where n < ?) select n from s"
+	if _, err := sr.ExecutePreparedQuery(ctx, series, datasource, 5); err != nil {
+		t.Errorf("query returning max rows failed : %v", err)
+	}
+	if _, err := sr.ExecutePreparedQuery(ctx, series, datasource, 6); err == nil {
+		t.Error("query returning more than max rows is expected to fail")
+	}
+	if _, err := sr.ExecuteQueryForCsv(ctx, series, datasource, "Results", 6); err == nil {
+		t.Error("csv query returning more than max rows is expected to fail")
+	}
+
+	datasource.DbConfig.OtherDbConfig.MaxRows = 0
+	qctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := sr.ExecutePreparedQuery(qctx, "with recursive s(n) as (select 1 union all select n + 1 from s) select count(*) from s", datasource); err == nil {
+		t.Error("query is expected to be cancelled on timeout")
+	}
+}
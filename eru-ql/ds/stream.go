@@ -0,0 +1,72 @@
+package ds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"strings"
+)
+
+// StreamRowFunc receives values of a row of streamed query in order of its columns
+type StreamRowFunc func(cols []string, values []interface{}) error
+
+// ExecuteQueryStream passes rows of the query to onRow as they are read from the cursor without holding the result in memory.
+// values are converted to strings as in csv output if asCsv is true, else as in json output.
+func (sqr *SqlMaker) ExecuteQueryStream(ctx context.Context, query string, datasource *module_model.DataSource, asCsv bool, onRow StreamRowFunc, args ...interface{}) (cols []string, rowCount int, err error) {
+	logs.WithContext(ctx).Debug("ExecuteQueryStream - Start")
+	rows, err := datasource.Con.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	colsType, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, colType := range colsType {
+		// graphql queries alias columns as L<level>~~<sublevel>**<column>
+		colHeader := colType.Name()
+		if colHeaderArray := strings.Split(colHeader, "**"); len(colHeaderArray) > 1 {
+			colHeader = colHeaderArray[1]
+		}
+		cols = append(cols, colHeader)
+	}
+	mapping := make(map[string]interface{})
+	for rows.Next() {
+		rowCount = rowCount + 1
+		if err = checkMaxRows(ctx, datasource, rowCount); err != nil {
+			return cols, rowCount - 1, err
+		}
+		if err = rows.MapScan(mapping); err != nil {
+			return cols, rowCount - 1, err
+		}
+		values := make([]interface{}, len(colsType))
+		for i, colType := range colsType {
+			v := mapping[colType.Name()]
+			if asCsv {
+				values[i], err = csvValue(ctx, colType, v)
+			} else if values[i], err = jsonValue(ctx, colType, v); err == nil {
+				// text returned as bytes by drivers like mysql would otherwise be written as base64
+				if b, ok := values[i].([]byte); ok {
+					values[i] = string(b)
+				}
+			}
+			if err != nil {
+				return cols, rowCount - 1, err
+			}
+		}
+		if err = onRow(cols, values); err != nil {
+			return cols, rowCount, err
+		}
+	}
+	return cols, rowCount, rows.Err()
+}
+
+func (mr *MongoSqlMaker) ExecuteQueryStream(ctx context.Context, query string, datasource *module_model.DataSource, asCsv bool, onRow StreamRowFunc, args ...interface{}) (cols []string, rowCount int, err error) {
+	err = errors.New(fmt.Sprint("streamed output is not supported for ", datasource.DbName, " datasource ", datasource.DbAlias))
+	logs.WithContext(ctx).Error(err.Error())
+	return nil, 0, err
+}
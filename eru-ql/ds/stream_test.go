@@ -0,0 +1,65 @@
+package ds
+
+import (
+	"context"
+	"errors"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSqliteExecuteQueryStream(t *testing.T) {
+	ctx := context.Background()
+	sr := GetSqlMaker("sqlite")
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db")}}
+	if err := sr.CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+	for _, q := range []string{"create table customers (id integer primary key, name text, active boolean)", "insert into customers values (1, 'a', true), (2, null, false), (3, 'c', true)"} {
+		if _, err := datasource.Con.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query := `select id "L0~~0**cid", name, active from customers where id < ? order by id`
+
+	var csvRows [][]interface{}
+	cols, rowCount, err := sr.ExecuteQueryStream(ctx, query, datasource, true, func(cols []string, values []interface{}) error {
+		csvRows = append(csvRows, values)
+		return nil
+	}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cols, []string{"cid", "name", "active"}) || rowCount != 2 {
+		t.Errorf("unexpected columns %v or row count %d", cols, rowCount)
+	}
+	if !reflect.DeepEqual(csvRows, [][]interface{}{{"1", "a", "1"}, {"2", "", "0"}}) {
+		t.Errorf("unexpected csv rows : %v", csvRows)
+	}
+
+	var jsonRows [][]interface{}
+	if _, _, err = sr.ExecuteQueryStream(ctx, query, datasource, false, func(cols []string, values []interface{}) error {
+		jsonRows = append(jsonRows, values)
+		return nil
+	}, 3); err != nil {
+		t.Fatal(err)
+	}
+	if len(jsonRows) != 2 || jsonRows[1][0] != int64(2) || jsonRows[1][1] != nil {
+		t.Errorf("unexpected json rows : %v", jsonRows)
+	}
+
+	// error of a row stops the stream
+	stopErr := errors.New("stop")
+	if _, rowCount, err = sr.ExecuteQueryStream(ctx, query, datasource, true, func(cols []string, values []interface{}) error {
+		return stopErr
+	}, 3); err != stopErr || rowCount != 1 {
+		t.Errorf("stream is expected to stop at first row : %d, %v", rowCount, err)
+	}
+
+	datasource.DbConfig.OtherDbConfig.MaxRows = 1
+	if _, _, err = sr.ExecuteQueryStream(ctx, query, datasource, true, func(cols []string, values []interface{}) error { return nil }, 3); err == nil {
+		t.Errorf("max rows of datasource is expected to stop the stream")
+	}
+}
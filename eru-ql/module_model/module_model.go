@@ -2,6 +2,9 @@ package module_model
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
@@ -10,6 +13,12 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/jmoiron/sqlx"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,6 +33,26 @@ const (
 	QUERY_TYPE_UPDATE = "update"
 	QUERY_TYPE_DELETE = "delete"
 	QUERY_TYPE_SELECT = "select"
+	QUERY_TYPE_UPSERT = "upsert"
+
+	MASKING_TYPE_NONE    = "none"
+	MASKING_TYPE_FULL    = "full"
+	MASKING_TYPE_PARTIAL = "partial"
+	MASKING_TYPE_HASH    = "hash"
+	MASKING_TEXT         = "****"
+	MASKING_CHAR         = "*"
+
+	MIGRATION_TABLE = "eru_schema_migrations"
+	AUDIT_TABLE     = "eru_audit"
+
+	DEFAULT_IMPORT_BATCH_SIZE = 500
+	DEFAULT_IMPORT_MAX_BYTES  = 50 << 20 // 50 MB
+
+	OUTPUT_TYPE_CSV_STREAM = "csvstream"
+	OUTPUT_TYPE_NDJSON     = "ndjson"
+
+	DEFAULT_CACHE_TTL         = 60
+	DEFAULT_CACHE_MAX_ENTRIES = 1000
 )
 
 type ModuleProjectI interface {
@@ -46,8 +75,9 @@ type Project struct {
 	ProjectSettings ProjectSettings
 }
 type ProjectSettings struct {
-	AesKey    string
-	ClaimsKey string
+	AesKey          string
+	ClaimsKey       string
+	ValidateGraphQL bool //graphql queries are validated against schema generated from SchemaTables of datasources
 }
 
 /*
@@ -74,6 +104,9 @@ type MyQuery struct {
 	ReadWrite    string
 	Cols         string
 	SecurityRule security_rule.SecurityRule
+	TimeOut      int // in seconds - overrides query timeout of the datasource
+	Params       []MyQueryParam
+	Cache        CacheConfig
 }
 
 type DataSource struct {
@@ -85,10 +118,32 @@ type DataSource struct {
 	OtherTables                map[string]map[string]TableColsMetaData `json:"-"` //tableName is the key
 	SchemaTablesSecurity       map[string]SecurityRules
 	SchemaTablesTransformation map[string]TransformRules
+	SchemaTablesAudit          map[string]AuditConfig
+	SchemaTablesCache          map[string]CacheConfig
 	TableJoins                 map[string]*TableJoins
-	Con                        *sqlx.DB `json:"-"`
+	Con                        *sqlx.DB      `json:"-"`
+	MongoCon                   *mongo.Client `json:"-"`
+	Replicas                   *Replicas     `json:"-"`
 	ConStatus                  bool
 	DbSecurityRules            SecurityRules
+	Migrations                 []Migration
+}
+
+// Migration is a numbered schema change of the datasource - DownQueries revert the change made by UpQueries.
+// applied migrations are tracked in MIGRATION_TABLE of the datasource db so that same migrations can be applied to db of another project.
+type Migration struct {
+	Version     int
+	Name        string
+	TableName   string
+	UpQueries   []string
+	DownQueries []string
+	CreatedDate time.Time
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied     bool
+	AppliedDate *time.Time `json:",omitempty"`
 }
 
 type TableJoins struct {
@@ -125,14 +180,81 @@ type TableColsMetaData struct {
 	FkTblName         string
 	FkColName         string
 	ColumnMasking     ColumnMasking
+	OldColName        string `json:",omitempty"` //set to rename the column while altering table
 }
 
+// ColumnMasking hides value of the column in query results.
+// MaskingRule is the replacement text for full masking, count of leading and trailing characters to keep as "first,last" for partial masking (e.g. "0,4" keeps last 4)
+// and salt for hash masking. Raw value is returned if CustomRule is set and evaluates to true for the token e.g. for admins.
 type ColumnMasking struct {
 	MaskingType string
 	MaskingRule string
 	CustomRule  security_rule.CustomRule
 }
 
+// IsSet returns true if masking is configured for the column
+func (cm ColumnMasking) IsSet() bool {
+	mt := strings.ToLower(cm.MaskingType)
+	return mt != "" && mt != MASKING_TYPE_NONE
+}
+
+// Applies returns true if value is to be masked for the token in vars
+func (cm ColumnMasking) Applies(ctx context.Context, vars map[string]interface{}) (bool, error) {
+	logs.WithContext(ctx).Debug("Applies - Start")
+	if !cm.IsSet() {
+		return false, nil
+	}
+	if cm.CustomRule.IsEmpty() {
+		return true, nil
+	}
+	showRaw, err := cm.CustomRule.Evaluate(ctx, vars)
+	if err != nil {
+		return true, err
+	}
+	return !showRaw, nil
+}
+
+// Mask returns masked value - null stays null
+func (cm ColumnMasking) Mask(ctx context.Context, val interface{}) interface{} {
+	if val == nil {
+		return nil
+	}
+	str := ""
+	if b, ok := val.([]byte); ok {
+		str = string(b)
+	} else {
+		str = fmt.Sprint(val)
+	}
+	switch strings.ToLower(cm.MaskingType) {
+	case MASKING_TYPE_PARTIAL:
+		first, last := 0, 4
+		if cm.MaskingRule != "" {
+			keep := strings.Split(cm.MaskingRule, ",")
+			first, _ = strconv.Atoi(strings.TrimSpace(keep[0]))
+			last = 0
+			if len(keep) > 1 {
+				last, _ = strconv.Atoi(strings.TrimSpace(keep[1]))
+			}
+		}
+		runes := []rune(str)
+		if first < 0 || last < 0 || first+last >= len(runes) {
+			return strings.Repeat(MASKING_CHAR, len(runes))
+		}
+		return fmt.Sprint(string(runes[:first]), strings.Repeat(MASKING_CHAR, len(runes)-first-last), string(runes[len(runes)-last:]))
+	case MASKING_TYPE_HASH:
+		h := sha256.Sum256([]byte(fmt.Sprint(cm.MaskingRule, str)))
+		return hex.EncodeToString(h[:])
+	case MASKING_TYPE_FULL:
+		if cm.MaskingRule != "" {
+			return cm.MaskingRule
+		}
+		return MASKING_TEXT
+	default:
+		logs.WithContext(ctx).Warn(fmt.Sprint("unknown masking type ", cm.MaskingType, " - masking full value"))
+		return MASKING_TEXT
+	}
+}
+
 /*
 	type CustomRule struct {
 		AND []CustomRuleDetails `json:",omitempty"`
@@ -182,6 +304,153 @@ type TransformRuleDetail struct {
 	RuleRank           int
 }
 
+// AuditConfig enables audit trail of mutations on a table - before and after images of changed rows are saved in audit table of the datasource
+// or posted to sink url if it is set
+type AuditConfig struct {
+	Enabled    bool
+	Operations []string // insert, update, upsert and delete - all operations are audited if blank
+	AuditTable string   // AUDIT_TABLE is used if blank
+	SinkUrl    string
+	UserIdKey  string // attribute of token object holding id of the user - sub is used if blank
+}
+
+// Audits returns true if mutations of the query type are audited
+func (ac AuditConfig) Audits(queryType string) bool {
+	if !ac.Enabled {
+		return false
+	}
+	if queryType == "insertselect" {
+		queryType = QUERY_TYPE_INSERT
+	}
+	if len(ac.Operations) == 0 {
+		return queryType == QUERY_TYPE_INSERT || queryType == QUERY_TYPE_UPDATE || queryType == QUERY_TYPE_UPSERT || queryType == QUERY_TYPE_DELETE
+	}
+	for _, op := range ac.Operations {
+		if strings.ToLower(op) == queryType {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAuditTable returns table in which audit records are saved
+func (ac AuditConfig) GetAuditTable() string {
+	if ac.AuditTable == "" {
+		return AUDIT_TABLE
+	}
+	return ac.AuditTable
+}
+
+// GetUserIdKey returns attribute of token object holding id of the user
+func (ac AuditConfig) GetUserIdKey() string {
+	if ac.UserIdKey == "" {
+		return "sub"
+	}
+	return ac.UserIdKey
+}
+
+// CacheConfig enables caching of results of my query or of select queries on a table.
+// results are cached per query, variables and token of the request and are invalidated when a mutation changes any table of the query.
+type CacheConfig struct {
+	Enabled     bool
+	TTL         int      // in seconds - DEFAULT_CACHE_TTL is used if not set
+	MaxEntries  int      // DEFAULT_CACHE_MAX_ENTRIES is used if not set - least recently used result is evicted when cache is full
+	TokenClaims []string // claims of token which decide rows and values returned to the user - whole token is part of cache key if blank
+}
+
+// GetTTL returns duration for which results are cached
+func (cc CacheConfig) GetTTL() time.Duration {
+	if cc.TTL <= 0 {
+		return time.Duration(DEFAULT_CACHE_TTL) * time.Second
+	}
+	return time.Duration(cc.TTL) * time.Second
+}
+
+// GetMaxEntries returns number of results which are cached
+func (cc CacheConfig) GetMaxEntries() int {
+	if cc.MaxEntries <= 0 {
+		return DEFAULT_CACHE_MAX_ENTRIES
+	}
+	return cc.MaxEntries
+}
+
+// AuditPrimaryKey returns json of primary key values of the row as strings - keys of json are sorted so that same row always gets same value
+func AuditPrimaryKey(pkCols []string, row map[string]interface{}) string {
+	pk := make(map[string]string)
+	for _, c := range pkCols {
+		switch v := row[c].(type) {
+		case nil:
+			pk[c] = ""
+		case []byte:
+			pk[c] = string(v)
+		default:
+			pk[c] = fmt.Sprint(v)
+		}
+	}
+	pkBytes, _ := json.Marshal(pk)
+	return string(pkBytes)
+}
+
+// AuditRecord is change of a row made by a mutation - PrimaryKey is json of primary key values of the row as strings
+type AuditRecord struct {
+	AuditId    string                 `json:"audit_id"`
+	TableName  string                 `json:"table_name"`
+	Operation  string                 `json:"operation"`
+	PrimaryKey string                 `json:"primary_key"`
+	Before     map[string]interface{} `json:"before"`
+	After      map[string]interface{} `json:"after"`
+	UserId     string                 `json:"user_id"`
+	RequestId  string                 `json:"request_id"`
+	ChangedAt  time.Time              `json:"changed_at"`
+}
+
+// BulkImport is request to load rows of a csv or excel file into a table - file is either uploaded or referred from eru-files storage
+type BulkImport struct {
+	FileType    string            `json:"file_type"`     // csv or xlsx - taken from extension of file name if blank
+	Sheet       string            `json:"sheet"`         // sheet of excel file - required if file has more than one sheet
+	Delimiter   string            `json:"delimiter"`     // delimiter of csv file - comma is used if blank
+	ColumnMap   map[string]string `json:"column_map"`    // table column of the file column header - headers matching table columns are mapped if blank
+	BatchSize   int               `json:"batch_size"`    // DEFAULT_IMPORT_BATCH_SIZE is used if not set
+	StopOnError bool              `json:"stop_on_error"` // no row is imported if any row fails
+	FileRef     FileRef           `json:"file_ref"`
+}
+
+// FileRef refers to a file in storage of eru-files
+type FileRef struct {
+	StorageName string `json:"storage_name"`
+	FolderPath  string `json:"folder_path"`
+	FileName    string `json:"file_name"`
+}
+
+// BulkImportResult reports rows imported from the file - RowNo of errors is the row number in the file with header as row 1
+type BulkImportResult struct {
+	TotalRows    int                  `json:"total_rows"`
+	ImportedRows int                  `json:"imported_rows"`
+	FailedRows   int                  `json:"failed_rows"`
+	Errors       []BulkImportRowError `json:"errors"`
+}
+
+type BulkImportRowError struct {
+	RowNo  int    `json:"row_no"`
+	Column string `json:"column,omitempty"`
+	Error  string `json:"error"`
+}
+
+// MutationAudit carries audit config of the mutated table to SqlMaker - audit records of the mutation are added to Records.
+// BeforeQuery fetches rows before they are changed and MatchCols pair them with rows returned by the mutation.
+// ReturnFields are the fields asked by the mutation as all fields are returned by audited mutations - nil if all fields are asked.
+type MutationAudit struct {
+	Config       AuditConfig
+	PrimaryKeys  []string
+	MatchCols    []string
+	BeforeQuery  string
+	BeforeArgs   []interface{}
+	ReturnFields []string
+	UserId       string
+	RequestId    string
+	Records      []AuditRecord
+}
+
 type DbConfig struct {
 	Host          string       `eru:"required"`
 	Port          string       `eru:"required"`
@@ -191,6 +460,15 @@ type DbConfig struct {
 	DefaultSchema string       `eru:"required"`
 	DriverConfig  DriverConfig `eru:"required"`
 	OtherDbConfig OtherDbConfig
+	ReadReplicas  []ReadReplica
+}
+
+// ReadReplica is a read only copy of the datasource db - user and password of the datasource are used if not set
+type ReadReplica struct {
+	Host     string `eru:"required"`
+	Port     string `eru:"required"`
+	User     string
+	Password string
 }
 
 type DriverConfig struct {
@@ -199,10 +477,32 @@ type DriverConfig struct {
 	ConnMaxLifetime time.Duration `eru:"required"`
 }
 
+// OtherDbConfig holds limits of queries executed on the datasource - zero value of a limit means it is not applied
+// RowLimit is the number of rows returned by graphql queries which do not ask for a limit and QueryTimeOut is in seconds
+// MaxDepth is the levels of tables nested below the main table and MaxJoins is the count of tables joined in a graphql query
+// MaxCost is compared with cost of the query estimated by the db with explain - it is checked only for postgres and mysql
 type OtherDbConfig struct {
 	RowLimit       int
 	QueryTimeOut   int
 	AllowDropTable bool
+	MaxRows        int
+	MaxDepth       int
+	MaxJoins       int
+	MaxCost        float64
+}
+
+const DEFAULT_ROW_LIMIT = 1000
+
+// DefaultLimit returns the limit applied to queries which do not ask for one - it never exceeds max rows
+func (odc OtherDbConfig) DefaultLimit() int {
+	limit := odc.RowLimit
+	if limit <= 0 {
+		limit = DEFAULT_ROW_LIMIT
+	}
+	if odc.MaxRows > 0 && limit > odc.MaxRows {
+		limit = odc.MaxRows
+	}
+	return limit
 }
 
 type QueryResultMaker struct {
@@ -212,6 +512,7 @@ type QueryResultMaker struct {
 	MainAliasName string
 	Tables        [][]Tables
 	SQLQuery      string
+	SQLArgs       []interface{}
 }
 
 type MutationResultMaker struct {
@@ -226,7 +527,9 @@ type MutationResultMaker struct {
 	TxnFlag         bool
 	IsNested        bool
 	DBQuery         string
+	DBQueryArgs     []interface{}
 	PreparedQuery   bool
+	Audit           *MutationAudit
 }
 
 type MutationRecord struct {
@@ -239,6 +542,7 @@ type MutationRecord struct {
 	ChildRecords    map[string][]MutationRecord
 	TableJoins      map[string]TableJoins
 	DBQuery         string
+	WhereValues     []interface{}
 }
 
 type MutationReturn struct {
@@ -249,6 +553,157 @@ type MutationReturn struct {
 	ReturnFields     string
 }
 
+// Replicas holds connections of read replicas of the datasource - read queries are routed to healthy replicas in round robin
+type Replicas struct {
+	Cons []*ReplicaCon
+	next uint32
+}
+
+// ReplicaCon is connection of a read replica - health of the replica is checked with a ping once every REPLICA_HEALTH_CHECK_INTERVAL
+// and connection is made again if it could not be made earlier
+type ReplicaCon struct {
+	Host      string
+	con       *sqlx.DB
+	connect   func(ctx context.Context) (*sqlx.DB, error)
+	healthy   bool
+	checkedAt time.Time
+	checking  bool
+	closed    bool
+	mu        sync.Mutex
+}
+
+const REPLICA_HEALTH_CHECK_INTERVAL = 30 * time.Second
+
+func NewReplicaCon(host string, connect func(ctx context.Context) (*sqlx.DB, error)) *ReplicaCon {
+	return &ReplicaCon{Host: host, connect: connect}
+}
+
+// HealthyCon returns connection of the replica if it is healthy as of last check and nil otherwise
+// replica is checked again if last check is older than REPLICA_HEALTH_CHECK_INTERVAL or if force is true.
+// connect and ping are done without holding the lock so that reads routed to the replica are not blocked by a slow replica,
+// check which is due is skipped if another check is in progress and last known health is returned.
+func (rc *ReplicaCon) HealthyCon(ctx context.Context, force bool) *sqlx.DB {
+	rc.mu.Lock()
+	if rc.closed {
+		rc.mu.Unlock()
+		return nil
+	}
+	due := rc.checkedAt.IsZero() || time.Since(rc.checkedAt) >= REPLICA_HEALTH_CHECK_INTERVAL
+	if !force && (!due || rc.checking) {
+		defer rc.mu.Unlock()
+		if !rc.healthy {
+			return nil
+		}
+		return rc.con
+	}
+	rc.checking = true
+	con := rc.con
+	rc.mu.Unlock()
+
+	var err error
+	newCon := false
+	if con == nil {
+		con, err = rc.connect(ctx)
+		newCon = err == nil && con != nil
+	}
+	if err == nil && con != nil {
+		err = con.PingContext(ctx)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.checking = false
+	if newCon {
+		if rc.closed || rc.con != nil {
+			// replica is closed or connected by another check in the meantime
+			_ = con.Close()
+			con = rc.con
+		} else {
+			rc.con = con
+		}
+	}
+	if rc.closed {
+		return nil
+	}
+	healthy := err == nil && con != nil
+	if err != nil && (rc.healthy || rc.checkedAt.IsZero()) {
+		logs.WithContext(ctx).Error(fmt.Sprint("read replica ", rc.Host, " is unhealthy : ", err.Error()))
+	} else if healthy && !rc.healthy {
+		logs.WithContext(ctx).Info(fmt.Sprint("read replica ", rc.Host, " is healthy"))
+	}
+	rc.healthy = healthy
+	rc.checkedAt = time.Now()
+	if !rc.healthy {
+		return nil
+	}
+	return rc.con
+}
+
+// Close closes connection pool of the replica - replica is not connected again once closed
+func (rc *ReplicaCon) Close(ctx context.Context) {
+	rc.mu.Lock()
+	con := rc.con
+	rc.con = nil
+	rc.healthy = false
+	rc.closed = true
+	rc.mu.Unlock()
+	if con != nil {
+		if err := con.Close(); err != nil {
+			logs.WithContext(ctx).Error(fmt.Sprint("error while closing read replica ", rc.Host, " : ", err.Error()))
+		}
+	}
+}
+
+// Close closes connection pools of all replicas - it is called when replicas are replaced or datasource is removed
+func (r *Replicas) Close(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	for _, rc := range r.Cons {
+		rc.Close(ctx)
+	}
+}
+
+func (rc *ReplicaCon) hasCon(con *sqlx.DB) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.con != nil && rc.con == con
+}
+
+// ReadDataSource returns copy of the datasource with connection of next healthy read replica in round robin
+// datasource itself is returned if it has no read replica or none of them is healthy
+func (ds *DataSource) ReadDataSource(ctx context.Context) *DataSource {
+	logs.WithContext(ctx).Debug("ReadDataSource - Start")
+	if ds.Replicas == nil || len(ds.Replicas.Cons) == 0 {
+		return ds
+	}
+	n := len(ds.Replicas.Cons)
+	start := int(atomic.AddUint32(&ds.Replicas.next, 1) % uint32(n))
+	for i := 0; i < n; i++ {
+		if con := ds.Replicas.Cons[(start+i)%n].HealthyCon(ctx, false); con != nil {
+			readDs := *ds
+			readDs.Con = con
+			return &readDs
+		}
+	}
+	logs.WithContext(ctx).Warn(fmt.Sprint("no healthy read replica found for ", ds.DbAlias, " - read query is routed to primary"))
+	return ds
+}
+
+// RecheckReplica checks health of the replica of the connection immediately - it is used when a query fails on the replica
+func (ds *DataSource) RecheckReplica(ctx context.Context, con *sqlx.DB) (healthy bool) {
+	logs.WithContext(ctx).Debug("RecheckReplica - Start")
+	if ds.Replicas == nil {
+		return false
+	}
+	for _, rc := range ds.Replicas.Cons {
+		if rc.hasCon(con) {
+			return rc.HealthyCon(ctx, true) != nil
+		}
+	}
+	return false
+}
+
 // tables used in query
 type Tables struct {
 	Name     string
@@ -338,6 +793,55 @@ func (tj *TableJoins) GetOnClause(ctx context.Context) (res map[string]interface
 	return res
 }
 
+// GetConflictCols returns columns of the primary key or unique constraint of the table in column order - primary key is used if constraint name is blank
+func (ds *DataSource) GetConflictCols(ctx context.Context, tableName string, constraintName string) (cols []string, err error) {
+	logs.WithContext(ctx).Debug("GetConflictCols - Start")
+	tableCols, ok := ds.SchemaTables[tableName]
+	if !ok {
+		err = errors.New(fmt.Sprint(tableName, " table not found"))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	var conflictCols []TableColsMetaData
+	for _, c := range tableCols {
+		if (constraintName == "" && c.PrimaryKey) || (constraintName != "" && (c.PkConstraintName == constraintName || c.UqConstraintName == constraintName)) {
+			conflictCols = append(conflictCols, c)
+		}
+	}
+	if len(conflictCols) == 0 {
+		if constraintName == "" {
+			err = errors.New(fmt.Sprint("primary key not found for table ", tableName))
+		} else {
+			err = errors.New(fmt.Sprint("constraint ", constraintName, " not found for table ", tableName))
+		}
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	sort.Slice(conflictCols, func(i, j int) bool {
+		return conflictCols[i].ColPosition < conflictCols[j].ColPosition
+	})
+	for _, c := range conflictCols {
+		cols = append(cols, c.ColName)
+	}
+	return cols, nil
+}
+
+// GetColumnMasking returns masking of the column - table name without schema is looked up in default schema
+func (ds *DataSource) GetColumnMasking(tableName string, colName string) (cm ColumnMasking, ok bool) {
+	tableCols, tok := ds.SchemaTables[tableName]
+	if !tok && !strings.Contains(tableName, ".") {
+		tableCols, tok = ds.SchemaTables[fmt.Sprint(ds.DbConfig.DefaultSchema, ".", tableName)]
+	}
+	if !tok {
+		return
+	}
+	col, cok := tableCols[colName]
+	if !cok || !col.ColumnMasking.IsSet() {
+		return
+	}
+	return col.ColumnMasking, true
+}
+
 func (ds *DataSource) CreateTable(ctx context.Context, tableName string, tableObj map[string]TableColsMetaData) (err error) {
 	logs.WithContext(ctx).Debug("CreateTable - Start")
 	return
@@ -385,7 +889,7 @@ func (prj *Project) CompareProject(ctx context.Context, compareProject Project)
 		for _, cd := range compareProject.DataSources {
 			if md.DbAlias == cd.DbAlias {
 				dsFound = true
-				if !cmp.Equal(md, cd, cmpopts.IgnoreFields(DataSource{}, "Con"), cmpopts.IgnoreFields(TableColsMetaData{}, "ColPosition"), cmp.Reporter(&diffR)) {
+				if !cmp.Equal(md, cd, cmpopts.IgnoreFields(DataSource{}, "Con", "MongoCon", "Replicas"), cmpopts.IgnoreFields(TableColsMetaData{}, "ColPosition"), cmp.Reporter(&diffR)) {
 					if storeCompare.MismatchDataSources == nil {
 						storeCompare.MismatchDataSources = make(map[string]interface{})
 					}
@@ -0,0 +1,224 @@
+package module_model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	PARAM_TYPE_STRING   = "string"
+	PARAM_TYPE_INTEGER  = "integer"
+	PARAM_TYPE_NUMBER   = "number"
+	PARAM_TYPE_BOOLEAN  = "boolean"
+	PARAM_TYPE_DATE     = "date"
+	PARAM_TYPE_DATETIME = "datetime"
+	PARAM_TYPE_ARRAY    = "array"
+	PARAM_TYPE_OBJECT   = "object"
+)
+
+var paramTypes = []string{PARAM_TYPE_STRING, PARAM_TYPE_INTEGER, PARAM_TYPE_NUMBER, PARAM_TYPE_BOOLEAN, PARAM_TYPE_DATE, PARAM_TYPE_DATETIME, PARAM_TYPE_ARRAY, PARAM_TYPE_OBJECT}
+
+var paramDateTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05"}
+
+// MyQueryParam declares a parameter of my query - values posted to execute my query are validated against it.
+// Min and Max are limits of the value for integer and number, of the length for string and of the number of items for array.
+type MyQueryParam struct {
+	Name          string        `json:"name"`
+	Type          string        `json:"type"` // value of any type is allowed if blank
+	Description   string        `json:"description"`
+	Required      bool          `json:"required"`
+	Default       interface{}   `json:"default"` // set as value of the parameter if it is not posted
+	AllowedValues []interface{} `json:"allowed_values"`
+	Regex         string        `json:"regex"` // only for string, date and datetime
+	Min           *float64      `json:"min"`
+	Max           *float64      `json:"max"`
+}
+
+type MyQueryParamError struct {
+	Param string `json:"param"`
+	Error string `json:"error"`
+}
+
+// MyQueryParamsError is returned when posted values do not conform to parameters of my query
+type MyQueryParamsError struct {
+	Errors []MyQueryParamError
+}
+
+func (e *MyQueryParamsError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, pe := range e.Errors {
+		msgs[i] = fmt.Sprint(pe.Param, " : ", pe.Error)
+	}
+	return strings.Join(msgs, " ; ")
+}
+
+// ValidateParamSpec returns error if parameters of my query are not declared correctly
+func ValidateParamSpec(params []MyQueryParam) error {
+	var errs []MyQueryParamError
+	names := make(map[string]bool)
+	for _, p := range params {
+		var e string
+		if p.Name == "" {
+			e = "name of parameter is mandatory"
+		} else if names[p.Name] {
+			e = "parameter is declared more than once"
+		} else if p.Type != "" && !paramTypeFound(p.Type) {
+			e = fmt.Sprint("type should be one of ", strings.Join(paramTypes, ", "))
+		} else if _, err := regexp.Compile(p.Regex); err != nil {
+			e = fmt.Sprint("invalid regex : ", err.Error())
+		} else if p.Min != nil && p.Max != nil && *p.Min > *p.Max {
+			e = "min is more than max"
+		} else if p.Default != nil {
+			if err := p.validate(p.Default); err != nil {
+				e = fmt.Sprint("invalid default : ", err.Error())
+			}
+		}
+		names[p.Name] = true
+		if e != "" {
+			errs = append(errs, MyQueryParamError{Param: p.Name, Error: e})
+		}
+	}
+	if len(errs) > 0 {
+		return &MyQueryParamsError{Errors: errs}
+	}
+	return nil
+}
+
+// ValidateParams validates values posted to execute my query against its parameters and sets default of parameters not posted.
+// values posted for undeclared parameters are left as they are.
+func (mq *MyQuery) ValidateParams(vars map[string]interface{}) error {
+	if vars == nil {
+		return errors.New("values of my query should be posted as a json object")
+	}
+	var errs []MyQueryParamError
+	for _, p := range mq.Params {
+		v, ok := vars[p.Name]
+		if !ok || v == nil {
+			if p.Default != nil {
+				vars[p.Name] = p.Default
+			} else if p.Required {
+				errs = append(errs, MyQueryParamError{Param: p.Name, Error: "parameter is required"})
+			}
+			continue
+		}
+		if err := p.validate(v); err != nil {
+			errs = append(errs, MyQueryParamError{Param: p.Name, Error: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return &MyQueryParamsError{Errors: errs}
+	}
+	return nil
+}
+
+// validate returns error if the value does not conform to type and limits of the parameter
+func (p MyQueryParam) validate(v interface{}) error {
+	switch p.Type {
+	case PARAM_TYPE_STRING, PARAM_TYPE_DATE, PARAM_TYPE_DATETIME:
+		s, ok := v.(string)
+		if !ok {
+			return errors.New(fmt.Sprint("value should be a ", p.Type))
+		}
+		if p.Type == PARAM_TYPE_DATE {
+			if _, err := time.Parse("2006-01-02", s); err != nil {
+				return errors.New("value should be a date in yyyy-mm-dd format")
+			}
+		} else if p.Type == PARAM_TYPE_DATETIME && !isParamDateTime(s) {
+			return errors.New("value should be a datetime in yyyy-mm-dd hh:mm:ss format")
+		}
+		if p.Type == PARAM_TYPE_STRING {
+			if err := p.checkLimits(float64(utf8.RuneCountInString(s)), "length"); err != nil {
+				return err
+			}
+		}
+		if p.Regex != "" {
+			re, err := regexp.Compile(p.Regex)
+			if err != nil {
+				return err
+			}
+			if !re.MatchString(s) {
+				return errors.New(fmt.Sprint("value does not match pattern ", p.Regex))
+			}
+		}
+	case PARAM_TYPE_INTEGER, PARAM_TYPE_NUMBER:
+		f, ok := v.(float64)
+		if !ok {
+			return errors.New(fmt.Sprint("value should be a ", p.Type))
+		}
+		if p.Type == PARAM_TYPE_INTEGER && f != math.Trunc(f) {
+			return errors.New("value should be an integer")
+		}
+		if err := p.checkLimits(f, "value"); err != nil {
+			return err
+		}
+	case PARAM_TYPE_BOOLEAN:
+		if _, ok := v.(bool); !ok {
+			return errors.New("value should be a boolean")
+		}
+	case PARAM_TYPE_ARRAY:
+		a, ok := v.([]interface{})
+		if !ok {
+			return errors.New("value should be an array")
+		}
+		if err := p.checkLimits(float64(len(a)), "number of items"); err != nil {
+			return err
+		}
+		// each item of array is to be one of the allowed values
+		for _, item := range a {
+			if err := p.checkAllowed(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case PARAM_TYPE_OBJECT:
+		if _, ok := v.(map[string]interface{}); !ok {
+			return errors.New("value should be an object")
+		}
+	}
+	return p.checkAllowed(v)
+}
+
+func (p MyQueryParam) checkLimits(f float64, what string) error {
+	if p.Min != nil && f < *p.Min {
+		return errors.New(fmt.Sprint(what, " should not be less than ", *p.Min))
+	}
+	if p.Max != nil && f > *p.Max {
+		return errors.New(fmt.Sprint(what, " should not be more than ", *p.Max))
+	}
+	return nil
+}
+
+func (p MyQueryParam) checkAllowed(v interface{}) error {
+	if len(p.AllowedValues) == 0 {
+		return nil
+	}
+	for _, av := range p.AllowedValues {
+		if fmt.Sprint(av) == fmt.Sprint(v) {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprint("value ", v, " is not one of allowed values ", p.AllowedValues))
+}
+
+func paramTypeFound(paramType string) bool {
+	for _, t := range paramTypes {
+		if t == paramType {
+			return true
+		}
+	}
+	return false
+}
+
+func isParamDateTime(s string) bool {
+	for _, layout := range paramDateTimeLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package module_model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMyQueryValidateParams(t *testing.T) {
+	one, ten := 1.0, 10.0
+	mq := MyQuery{Params: []MyQueryParam{
+		{Name: "id", Type: PARAM_TYPE_INTEGER, Required: true, Min: &one, Max: &ten},
+		{Name: "status", Type: PARAM_TYPE_STRING, Default: "open", AllowedValues: []interface{}{"open", "closed"}},
+		{Name: "code", Type: PARAM_TYPE_STRING, Regex: "^[A-Z]{3}$"},
+		{Name: "from", Type: PARAM_TYPE_DATE},
+		{Name: "tags", Type: PARAM_TYPE_ARRAY, Max: &one, AllowedValues: []interface{}{"a", "b"}},
+	}}
+
+	vars := map[string]interface{}{"id": 5.0, "code": "ABC", "from": "2023-01-31", "tags": []interface{}{"a"}, "other": true}
+	if err := mq.ValidateParams(vars); err != nil {
+		t.Fatalf("valid values failed : %v", err)
+	}
+	if vars["status"] != "open" {
+		t.Errorf("default is expected to be set : %v", vars["status"])
+	}
+
+	vars = map[string]interface{}{"status": "pending", "code": "abc", "from": "31-01-2023", "tags": []interface{}{"a", "b"}}
+	err := mq.ValidateParams(vars)
+	var paramsErr *MyQueryParamsError
+	if !errors.As(err, &paramsErr) {
+		t.Fatalf("expected MyQueryParamsError, got %v", err)
+	}
+	failed := make(map[string]bool)
+	for _, pe := range paramsErr.Errors {
+		failed[pe.Param] = true
+	}
+	for _, p := range []string{"id", "status", "code", "from", "tags"} {
+		if !failed[p] {
+			t.Errorf("%s is expected to fail : %v", p, err)
+		}
+	}
+
+	for _, v := range []interface{}{5.5, 11.0, "5"} {
+		if err = mq.ValidateParams(map[string]interface{}{"id": v}); err == nil {
+			t.Errorf("id %v is expected to fail", v)
+		}
+	}
+
+	// null posted as values
+	if err = mq.ValidateParams(nil); err == nil {
+		t.Error("nil values are expected to fail")
+	}
+}
+
+func TestValidateParamSpec(t *testing.T) {
+	one, ten := 1.0, 10.0
+	valid := []MyQueryParam{{Name: "id", Type: PARAM_TYPE_INTEGER, Min: &one, Max: &ten, Default: 2.0}, {Name: "name"}}
+	if err := ValidateParamSpec(valid); err != nil {
+		t.Errorf("valid spec failed : %v", err)
+	}
+	for _, params := range [][]MyQueryParam{
+		{{Name: ""}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Type: "uuid"}},
+		{{Name: "a", Regex: "["}},
+		{{Name: "a", Min: &ten, Max: &one}},
+		{{Name: "a", Type: PARAM_TYPE_INTEGER, Default: "x"}},
+	} {
+		if err := ValidateParamSpec(params); err == nil {
+			t.Errorf("spec %+v is expected to fail", params)
+		}
+	}
+}
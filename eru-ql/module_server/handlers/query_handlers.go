@@ -17,6 +17,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	//"../server"
 )
 
@@ -37,7 +38,7 @@ func ProjectMyQuerySaveHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 				logs.WithContext(r.Context()).Error(err.Error())
 				return
 			}
-			err = s.SaveMyQuery(r.Context(), projectID, queryName, queryType, "", gqd.Query, gqd.Variables, s, "", gqd.SecurityRule)
+			err = s.SaveMyQuery(r.Context(), projectID, queryName, queryType, "", gqd.Query, gqd.Variables, s, "", gqd.SecurityRule, gqd.TimeOut, gqd.Params, gqd.Cache)
 		} else if queryType == "sql" {
 			var sqd ql.SQLData
 			if err := json.NewDecoder(r.Body).Decode(&sqd); err != nil {
@@ -47,15 +48,21 @@ func ProjectMyQuerySaveHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 				return
 			}
 
-			err = s.SaveMyQuery(r.Context(), projectID, queryName, queryType, sqd.DBAlias, sqd.Query, sqd.Variables, s, sqd.Cols, sqd.SecurityRule)
+			err = s.SaveMyQuery(r.Context(), projectID, queryName, queryType, sqd.DBAlias, sqd.Query, sqd.Variables, s, sqd.Cols, sqd.SecurityRule, sqd.TimeOut, sqd.Params, sqd.Cache)
 		} else {
 			err = errors.New("Incorrect query type")
 		}
-		if err != nil {
+		var paramsErr *module_model.MyQueryParamsError
+		if errors.As(err, &paramsErr) {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "errors": paramsErr.Errors})
+		} else if err != nil {
 			server_handlers.FormatResponse(w, 400)
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
 
 		} else {
+			// results cached with earlier version of the query are no longer valid
+			ql.ClearCache(r.Context(), ql.MyQueryCacheScope(projectID, queryName))
 			server_handlers.FormatResponse(w, 200)
 			_ = json.NewEncoder(w).Encode(map[string]string{"message": fmt.Sprint("Query ", queryName, " saved successfully")})
 		}
@@ -75,6 +82,7 @@ func ProjectMyQueryRemoveHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 			server_handlers.FormatResponse(w, 400)
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
 		} else {
+			ql.ClearCache(r.Context(), ql.MyQueryCacheScope(projectID, queryName))
 			server_handlers.FormatResponse(w, 200)
 			_ = json.NewEncoder(w).Encode(map[string]string{"message": fmt.Sprint("Query ", queryName, " removed successfully")})
 		}
@@ -160,6 +168,14 @@ func ProjectMyQueryExecuteHandler(s module_store.ModuleStoreI) http.HandlerFunc
 			logs.WithContext(r.Context()).Error(err.Error())
 			return
 		}
+		// null body decodes into nil map
+		if postBody == nil {
+			err := errors.New("values of my query should be posted as a json object")
+			server_handlers.FormatResponse(w, 400)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			logs.WithContext(r.Context()).Error(err.Error())
+			return
+		}
 
 		datasources, err := s.GetDataSources(r.Context(), projectID)
 		if err != nil {
@@ -187,6 +203,18 @@ func ProjectMyQueryExecuteHandler(s module_store.ModuleStoreI) http.HandlerFunc
 				logs.WithContext(r.Context()).Error(err.Error())
 				return
 			}
+			// posted values are validated against declared parameters of my query before it is executed
+			if err = myQuery.ValidateParams(postBody); err != nil {
+				logs.WithContext(r.Context()).Error(err.Error())
+				server_handlers.FormatResponse(w, 400)
+				var paramsErr *module_model.MyQueryParamsError
+				if errors.As(err, &paramsErr) {
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "errors": paramsErr.Errors})
+				} else {
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+				}
+				return
+			}
 			isPublic := false
 			isPublic, err = strconv.ParseBool(r.Header.Get("is_public"))
 			if err != nil {
@@ -194,7 +222,16 @@ func ProjectMyQueryExecuteHandler(s module_store.ModuleStoreI) http.HandlerFunc
 			}
 
 			qlInterface.SetQLData(r.Context(), myQuery, postBody, true, tokenObj, isPublic, outputType)
-			res, _, err = qlInterface.Execute(r.Context(), projectID, datasources, s, outputType)
+			var sw *ql.StreamWriter
+			if ql.IsStreamOutput(outputType) {
+				sw = ql.NewStreamWriter(w, outputType)
+				qlInterface.SetStreamWriter(sw)
+			}
+			res, err = ql.ExecuteMyQuery(r.Context(), projectID, myQuery, qlInterface, datasources, s, outputType, postBody, tokenObj, isPublic, sw != nil)
+			// streamed rows are already written to the response
+			if sw != nil && sw.Finish(r.Context(), err) {
+				return
+			}
 			/*
 				if err != nil {
 					server_handlers.FormatResponse(w, 400)
@@ -347,18 +384,49 @@ func GraphqlExecuteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 			logs.WithContext(r.Context()).Error(err.Error())
 			return
 		}
+		// timeout of my query is set only when it is saved - ad hoc queries are executed with timeout of the datasource
+		gqd.TimeOut = 0
 
 		if gqd.Variables == nil {
 			gqd.Variables = make(map[string]interface{})
 		}
 		gqd.Variables[module_model.RULEPREFIX_TOKEN] = tokenObj
 		gqd.FinalVariables = gqd.Variables
-		gqd.ExecuteFlag = true
 
+		// introspection queries are answered in standard graphql response format for graphql clients
+		if gqd.IsIntrospection(r.Context()) {
+			result := gqd.Introspect(r.Context(), projectID, datasources, s)
+			if result.HasErrors() {
+				server_handlers.FormatResponse(w, 400)
+			} else {
+				server_handlers.FormatResponse(w, 200)
+			}
+			_ = json.NewEncoder(w).Encode(result)
+			return
+		}
+		gqd.ExecuteFlag = true
+		// output type in path overrides output type in body
+		if outputType != "" {
+			gqd.OutputType = outputType
+		}
+		var sw *ql.StreamWriter
+		if ql.IsStreamOutput(gqd.OutputType) {
+			sw = ql.NewStreamWriter(w, gqd.OutputType)
+			gqd.SetStreamWriter(sw)
+		}
 		res, queryObjs, err := gqd.Execute(r.Context(), projectID, datasources, s, outputType)
 		_ = queryObjs
+		// streamed rows are already written to the response
+		if sw != nil && sw.Finish(r.Context(), err) {
+			return
+		}
 		if err != nil {
 			server_handlers.FormatResponse(w, 400)
+			var validationErr *ql.GraphQLValidationError
+			if errors.As(err, &validationErr) {
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "errors": validationErr.Errors})
+				return
+			}
 			if res == nil {
 				_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
 				return
@@ -371,6 +439,48 @@ func GraphqlExecuteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 	}
 }
 
+func GraphqlSchemaHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("GraphqlSchemaHandler - Start")
+		vars := mux.Vars(r)
+		projectID := vars["project"]
+		projectSettings, err := s.GetProjectSettingsObject(r.Context(), projectID)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		tokenObj := make(map[string]interface{})
+		tokenStr := r.Header.Get(projectSettings.ClaimsKey)
+		if tokenStr != "" {
+			err = json.Unmarshal([]byte(tokenStr), &tokenObj)
+			if err != nil {
+				logs.WithContext(r.Context()).Error(fmt.Sprint("error while unmarshalling token claim : ", err.Error()))
+				server_handlers.FormatResponse(w, 400)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+				return
+			}
+		}
+		datasources, err := s.GetDataSources(r.Context(), projectID)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		// schema has only those tables which the caller can select
+		ruleVars := map[string]interface{}{module_model.RULEPREFIX_TOKEN: tokenObj}
+		sdl, err := ql.GetGraphQLSchemaSDL(r.Context(), ql.SelectableDataSources(r.Context(), projectID, datasources, s, ruleVars))
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"sdl": sdl})
+		return
+	}
+}
+
 func SqlExecuteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logs.WithContext(r.Context()).Debug("SqlExecuteHandler - Start")
@@ -415,6 +525,8 @@ func SqlExecuteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 			logs.WithContext(r.Context()).Error(err.Error())
 			return
 		}
+		// timeout of my query is set only when it is saved - ad hoc queries are executed with timeout of the datasource
+		sqd.TimeOut = 0
 
 		if sqd.Variables == nil {
 			sqd.Variables = make(map[string]interface{})
@@ -422,8 +534,21 @@ func SqlExecuteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 		sqd.Variables[module_model.RULEPREFIX_TOKEN] = tokenObj
 		sqd.FinalVariables = sqd.Variables
 		sqd.ExecuteFlag = true
+		// output type in path overrides output type in body
+		if outputType != "" {
+			sqd.OutputType = outputType
+		}
+		var sw *ql.StreamWriter
+		if ql.IsStreamOutput(sqd.OutputType) {
+			sw = ql.NewStreamWriter(w, sqd.OutputType)
+			sqd.SetStreamWriter(sw)
+		}
 		res, queryObjs, err := sqd.Execute(r.Context(), projectID, datasources, s, outputType)
 		_ = queryObjs
+		// streamed rows are already written to the response
+		if sw != nil && sw.Finish(r.Context(), err) {
+			return
+		}
 		if err != nil {
 			server_handlers.FormatResponse(w, 400)
 			if res == nil {
@@ -467,3 +592,74 @@ func EchoHandler(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(res)
 
 }
+
+// ImportHandler imports csv or excel file into the table - file is either uploaded as multipart form field "file" with import options as form field "options"
+// or referred in file_ref of json body to be downloaded from eru-files
+func ImportHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ImportHandler - Start")
+		vars := mux.Vars(r)
+		projectID := vars["project"]
+		dbAlias := vars["dbalias"]
+		tableName := strings.Replace(vars["tablename"], "___", ".", -1)
+
+		projectSettings, err := s.GetProjectSettingsObject(r.Context(), projectID)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		tokenObj := make(map[string]interface{})
+		tokenStr := r.Header.Get(projectSettings.ClaimsKey)
+		if tokenStr != "" {
+			err = json.Unmarshal([]byte(tokenStr), &tokenObj)
+			if err != nil {
+				logs.WithContext(r.Context()).Error(fmt.Sprint("error while unmarshalling token claim : ", err.Error()))
+				server_handlers.FormatResponse(w, 400)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+				return
+			}
+		}
+
+		var bi module_model.BulkImport
+		var fileName string
+		var fileBytes []byte
+		r.Body = http.MaxBytesReader(w, r.Body, module_store.ImportMaxBytes)
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			file, header, fErr := r.FormFile("file")
+			if fErr == nil {
+				defer file.Close()
+				fileName = header.Filename
+				fileBytes, fErr = io.ReadAll(file)
+			}
+			if fErr == nil && r.FormValue("options") != "" {
+				fErr = json.Unmarshal([]byte(r.FormValue("options")), &bi)
+			}
+			err = fErr
+		} else if err = json.NewDecoder(r.Body).Decode(&bi); err == nil {
+			fileName = bi.FileRef.FileName
+			fileBytes, err = ql.FetchImportFile(r.Context(), projectID, bi.FileRef)
+		}
+		if err != nil {
+			logs.WithContext(r.Context()).Error(err.Error())
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				server_handlers.FormatResponse(w, http.StatusRequestEntityTooLarge)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": fmt.Sprint("file is larger than ", module_store.ImportMaxBytes, " bytes")})
+				return
+			}
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		result, err := ql.ImportFile(r.Context(), projectID, dbAlias, tableName, fileName, fileBytes, bi, tokenObj, s)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/eru-tech/eru/eru-ql/ds"
 	"github.com/eru-tech/eru/eru-ql/module_model"
 	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/eru-tech/eru/eru-ql/ql"
 	server_handlers "github.com/eru-tech/eru/eru-server/server/handlers"
 	"github.com/eru-tech/eru/eru-utils"
 	"github.com/gorilla/mux"
@@ -90,6 +91,7 @@ func ProjectDataSourceSaveHandler(s module_store.ModuleStoreI) http.HandlerFunc
 			}
 		}
 		err := s.SaveDataSource(r.Context(), projectId, &datasource, s)
+		ql.InvalidateGraphQLSchema(r.Context(), projectId)
 		if err != nil {
 			server_handlers.FormatResponse(w, 400)
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
@@ -109,6 +111,7 @@ func ProjectDataSourceRemoveHandler(s module_store.ModuleStoreI) http.HandlerFun
 		dbAlias := vars["dbalias"]
 
 		err := s.RemoveDataSource(r.Context(), projectId, dbAlias, s)
+		ql.InvalidateGraphQLSchema(r.Context(), projectId)
 		if err != nil {
 			server_handlers.FormatResponse(w, 400)
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
@@ -157,6 +160,30 @@ func ProjectDataSourceSchemaHandler(s module_store.ModuleStoreI) http.HandlerFun
 	}
 }
 
+func ProjectDataSourceSchemaSdlHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceSchemaSdlHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		datasource, err := s.GetDataSource(r.Context(), projectId, dbAlias)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		sdl, err := ql.GetGraphQLSchemaSDL(r.Context(), map[string]*module_model.DataSource{dbAlias: datasource})
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"sdl": sdl})
+		return
+	}
+}
+
 func ProjectDataSourceSchemaAddTableHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logs.WithContext(r.Context()).Debug("ProjectDataSourceSchemaAddTableHandler - Start")
@@ -166,6 +193,7 @@ func ProjectDataSourceSchemaAddTableHandler(s module_store.ModuleStoreI) http.Ha
 		tableName := vars["tablename"]
 
 		res, err := s.AddSchemaTable(r.Context(), projectId, dbAlias, tableName, s)
+		ql.InvalidateGraphQLSchema(r.Context(), projectId)
 		if err != nil {
 			server_handlers.FormatResponse(w, 400)
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
@@ -186,6 +214,7 @@ func ProjectDataSourceSchemaRemoveTableHandler(s module_store.ModuleStoreI) http
 		tableName := vars["tablename"]
 
 		res, err := s.RemoveSchemaTable(r.Context(), projectId, dbAlias, tableName, s)
+		ql.InvalidateGraphQLSchema(r.Context(), projectId)
 		if err != nil {
 			server_handlers.FormatResponse(w, 400)
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
@@ -212,6 +241,7 @@ func ProjectDataSourceSchemaAddJoinHandler(s module_store.ModuleStoreI) http.Han
 		}
 
 		res, err := s.AddSchemaJoin(r.Context(), projectId, dbAlias, &tj, s)
+		ql.InvalidateGraphQLSchema(r.Context(), projectId)
 		if err != nil {
 			logs.WithContext(r.Context()).Error(err.Error())
 			server_handlers.FormatResponse(w, 400)
@@ -238,6 +268,7 @@ func ProjectDataSourceSchemaRemoveJoinHandler(s module_store.ModuleStoreI) http.
 		}
 
 		res, err := s.RemoveSchemaJoin(r.Context(), projectId, dbAlias, &tj, s)
+		ql.InvalidateGraphQLSchema(r.Context(), projectId)
 		if err != nil {
 			server_handlers.FormatResponse(w, 400)
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
@@ -276,13 +307,60 @@ func ProjectDataSourceSchemaSaveTableHandler(s module_store.ModuleStoreI) http.H
 			}
 		}
 		err := s.SaveSchemaTable(r.Context(), projectId, dbAlias, tableName, tableObj, s)
+		ql.InvalidateGraphQLSchema(r.Context(), projectId)
+		if err != nil {
+			logs.WithContext(r.Context()).Error(err.Error())
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+		} else {
+			server_handlers.FormatResponse(w, 200)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"msg": fmt.Sprint("Table ", tableName, " saved successfully")})
+		}
+		return
+	}
+}
+
+// ProjectDataSourceSchemaSaveTableDryRunHandler returns queries which save table will execute without executing them
+func ProjectDataSourceSchemaSaveTableDryRunHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceSchemaSaveTableDryRunHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		tableName := vars["tablename"]
+		tableName = strings.Replace(tableName, "___", ".", 1)
+
+		tableFromReq := json.NewDecoder(r.Body)
+		tableFromReq.DisallowUnknownFields()
+
+		var tableObj map[string]module_model.TableColsMetaData
+
+		if err := tableFromReq.Decode(&tableObj); err != nil {
+			server_handlers.FormatResponse(w, 400)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		} else {
+			err := eru_utils.ValidateStruct(r.Context(), tableObj, "")
+			if err != nil {
+				server_handlers.FormatResponse(w, 400)
+				json.NewEncoder(w).Encode(map[string]interface{}{"error": fmt.Sprint("missing field in object : ", err.Error())})
+				return
+			}
+		}
+		queries, downQueries, err := s.MakeSchemaTableSQL(r.Context(), projectId, dbAlias, tableName, tableObj)
 		if err != nil {
 			logs.WithContext(r.Context()).Error(err.Error())
 			server_handlers.FormatResponse(w, 400)
 			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
 		} else {
+			if queries == nil {
+				queries = []string{}
+			}
+			if downQueries == nil {
+				downQueries = []string{}
+			}
 			server_handlers.FormatResponse(w, 200)
-			_ = json.NewEncoder(w).Encode(map[string]interface{}{"msg": fmt.Sprint("Table ", tableName, " created successfully")})
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"queries": queries, "down_queries": downQueries})
 		}
 		return
 	}
@@ -380,6 +458,7 @@ func ProjectDataSourceSchemaDropTableHandler(s module_store.ModuleStoreI) http.H
 		tableName = strings.Replace(tableName, "___", ".", 1)
 
 		err := s.DropSchemaTable(r.Context(), projectId, dbAlias, tableName, s)
+		ql.InvalidateGraphQLSchema(r.Context(), projectId)
 		if err != nil {
 			logs.WithContext(r.Context()).Error(err.Error())
 			server_handlers.FormatResponse(w, 400)
@@ -391,3 +470,149 @@ func ProjectDataSourceSchemaDropTableHandler(s module_store.ModuleStoreI) http.H
 		return
 	}
 }
+
+func ProjectDataSourceSchemaAuditTableHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceSchemaAuditTableHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		tableName := vars["tablename"]
+		tableName = strings.Replace(tableName, "___", ".", 1)
+
+		auditConfigFromReq := json.NewDecoder(r.Body)
+		auditConfigFromReq.DisallowUnknownFields()
+
+		var auditConfig module_model.AuditConfig
+
+		if err := auditConfigFromReq.Decode(&auditConfig); err != nil {
+			logs.WithContext(r.Context()).Error(err.Error())
+			server_handlers.FormatResponse(w, 400)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		err := s.SaveTableAudit(r.Context(), projectId, dbAlias, tableName, auditConfig, s)
+
+		if err != nil {
+			logs.WithContext(r.Context()).Error(err.Error())
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+		} else {
+			server_handlers.FormatResponse(w, 200)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"msg": fmt.Sprint("Table Audit for ", tableName, " set successfully")})
+		}
+		return
+	}
+}
+
+func ProjectDataSourceSchemaAuditConfigHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceSchemaAuditConfigHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		tableName := strings.Replace(vars["tablename"], "___", ".", 1)
+		auditConfig, err := s.GetTableAudit(r.Context(), projectId, dbAlias, tableName)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(auditConfig)
+	}
+}
+
+// ProjectDataSourceSchemaAuditHistoryHandler returns change history of a row - primary key values of the row are passed as query params
+func ProjectDataSourceSchemaAuditHistoryHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceSchemaAuditHistoryHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		tableName := strings.Replace(vars["tablename"], "___", ".", 1)
+		pkValues := make(map[string]string)
+		for k, v := range r.URL.Query() {
+			if len(v) > 0 {
+				pkValues[k] = v[0]
+			}
+		}
+		records, err := s.GetAuditHistory(r.Context(), projectId, dbAlias, tableName, pkValues)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"history": records})
+	}
+}
+
+func ProjectDataSourceSchemaCacheTableHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceSchemaCacheTableHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		tableName := strings.Replace(vars["tablename"], "___", ".", 1)
+
+		cacheConfigFromReq := json.NewDecoder(r.Body)
+		cacheConfigFromReq.DisallowUnknownFields()
+
+		var cacheConfig module_model.CacheConfig
+
+		if err := cacheConfigFromReq.Decode(&cacheConfig); err != nil {
+			logs.WithContext(r.Context()).Error(err.Error())
+			server_handlers.FormatResponse(w, 400)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		err := s.SaveTableCache(r.Context(), projectId, dbAlias, tableName, cacheConfig, s)
+
+		if err != nil {
+			logs.WithContext(r.Context()).Error(err.Error())
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+		} else {
+			// results cached with earlier config are not to be returned once token claims of the key are changed
+			ql.ClearCache(r.Context(), ql.TableCacheScope(projectId, dbAlias, tableName))
+			server_handlers.FormatResponse(w, 200)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"msg": fmt.Sprint("Table Cache for ", tableName, " set successfully")})
+		}
+		return
+	}
+}
+
+func ProjectDataSourceSchemaCacheConfigHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceSchemaCacheConfigHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		tableName := strings.Replace(vars["tablename"], "___", ".", 1)
+		cacheConfig, err := s.GetTableCache(r.Context(), projectId, dbAlias, tableName)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(cacheConfig)
+	}
+}
+
+// ProjectCacheStatsHandler returns hits, misses and size of result cache of my queries and tables of the project since the server started
+func ProjectCacheStatsHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectCacheStatsHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		if _, err := s.GetProjectConfig(r.Context(), projectId); err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"stats": ql.GetCacheStats(r.Context(), projectId)})
+	}
+}
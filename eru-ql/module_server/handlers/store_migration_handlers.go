@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	server_handlers "github.com/eru-tech/eru/eru-server/server/handlers"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+func ProjectDataSourceMigrationListHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceMigrationListHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		migrations, err := s.GetMigrations(r.Context(), projectId, dbAlias)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"migrations": migrations})
+	}
+}
+
+// ProjectDataSourceMigrationApplyHandler applies pending migrations up to the version in path - version 0 applies all pending migrations
+func ProjectDataSourceMigrationApplyHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceMigrationApplyHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		version, err := strconv.Atoi(vars["version"])
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": fmt.Sprint("invalid version ", vars["version"])})
+			return
+		}
+		versions, err := s.ApplyMigrations(r.Context(), projectId, dbAlias, version, s)
+		if versions == nil {
+			versions = []int{}
+		}
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "applied": versions})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"applied": versions})
+	}
+}
+
+// ProjectDataSourceMigrationRollbackHandler rolls back applied migrations after the version in path - version 0 rolls back all migrations
+func ProjectDataSourceMigrationRollbackHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceMigrationRollbackHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+		version, err := strconv.Atoi(vars["version"])
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": fmt.Sprint("invalid version ", vars["version"])})
+			return
+		}
+		versions, err := s.RollbackMigrations(r.Context(), projectId, dbAlias, version, s)
+		if versions == nil {
+			versions = []int{}
+		}
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error(), "rolledback": versions})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"rolledback": versions})
+	}
+}
+
+// ProjectDataSourceMigrationImportHandler imports migrations exported from another project, they are applied with apply handler
+func ProjectDataSourceMigrationImportHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("ProjectDataSourceMigrationImportHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		dbAlias := vars["dbalias"]
+
+		migrationsFromReq := json.NewDecoder(r.Body)
+		migrationsFromReq.DisallowUnknownFields()
+
+		var migrations []module_model.Migration
+		if err := migrationsFromReq.Decode(&migrations); err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		err := s.ImportMigrations(r.Context(), projectId, dbAlias, migrations, s)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"msg": fmt.Sprint(len(migrations), " migrations imported successfully")})
+	}
+}
@@ -17,7 +17,11 @@ func AddModuleRoutes(serverRouter *mux.Router, sh *module_store.StoreHolder) {
 
 	//store routes specific to files
 	serverRouter.Methods(http.MethodPost).Path("/graphql/{project}/execute").HandlerFunc(module_handlers.GraphqlExecuteHandler(sh.Store))
+	serverRouter.Methods(http.MethodPost).Path("/graphql/{project}/execute/{outputtype}").HandlerFunc(module_handlers.GraphqlExecuteHandler(sh.Store))
+	serverRouter.Methods(http.MethodGet).Path("/graphql/{project}/schema").HandlerFunc(module_handlers.GraphqlSchemaHandler(sh.Store))
 	serverRouter.Methods(http.MethodPost).Path("/sql/{project}/execute").HandlerFunc(module_handlers.SqlExecuteHandler(sh.Store))
+	serverRouter.Methods(http.MethodPost).Path("/sql/{project}/execute/{outputtype}").HandlerFunc(module_handlers.SqlExecuteHandler(sh.Store))
+	serverRouter.Methods(http.MethodPost).Path("/import/{project}/{dbalias}/{tablename}").HandlerFunc(module_handlers.ImportHandler(sh.Store))
 
 	storeRouter := serverRouter.PathPrefix("/store").Subrouter()
 	storeRouter.Methods(http.MethodPost).Path("/{project}/compare").HandlerFunc(module_handlers.StoreCompareHandler(sh.Store))
@@ -45,12 +49,24 @@ func AddModuleRoutes(serverRouter *mux.Router, sh *module_store.StoreHolder) {
 	storeRouter.Methods(http.MethodGet).Path("/{project}/datasource/list").HandlerFunc(module_handlers.ProjectDataSourceListHandler(sh.Store))
 	storeRouter.Methods(http.MethodGet).Path("/{project}/datasource/config/{dbalias}").HandlerFunc(module_handlers.ProjectDataSourceConfigHandler(sh.Store))
 	storeRouter.Methods(http.MethodGet).Path("/{project}/datasource/schema/{dbalias}").HandlerFunc(module_handlers.ProjectDataSourceSchemaHandler(sh.Store))
+	storeRouter.Methods(http.MethodGet).Path("/{project}/datasource/schema/{dbalias}/sdl").HandlerFunc(module_handlers.ProjectDataSourceSchemaSdlHandler(sh.Store))
 	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/schema/{dbalias}/addtable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaAddTableHandler(sh.Store))
 	storeRouter.Methods(http.MethodDelete).Path("/{project}/datasource/schema/{dbalias}/removetable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaRemoveTableHandler(sh.Store))
 	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/schema/{dbalias}/addjoin").HandlerFunc(module_handlers.ProjectDataSourceSchemaAddJoinHandler(sh.Store))
 	storeRouter.Methods(http.MethodDelete).Path("/{project}/datasource/schema/{dbalias}/removejoin").HandlerFunc(module_handlers.ProjectDataSourceSchemaRemoveJoinHandler(sh.Store))
 	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/schema/{dbalias}/savetable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaSaveTableHandler(sh.Store))
+	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/schema/{dbalias}/savetable/{tablename}/dryrun").HandlerFunc(module_handlers.ProjectDataSourceSchemaSaveTableDryRunHandler(sh.Store))
+	storeRouter.Methods(http.MethodGet).Path("/{project}/datasource/migrations/{dbalias}").HandlerFunc(module_handlers.ProjectDataSourceMigrationListHandler(sh.Store))
+	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/migrations/{dbalias}/apply/{version}").HandlerFunc(module_handlers.ProjectDataSourceMigrationApplyHandler(sh.Store))
+	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/migrations/{dbalias}/rollback/{version}").HandlerFunc(module_handlers.ProjectDataSourceMigrationRollbackHandler(sh.Store))
+	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/migrations/{dbalias}/import").HandlerFunc(module_handlers.ProjectDataSourceMigrationImportHandler(sh.Store))
 	storeRouter.Methods(http.MethodDelete).Path("/{project}/datasource/schema/{dbalias}/droptable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaDropTableHandler(sh.Store))
 	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/schema/{dbalias}/securetable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaSecureTableHandler(sh.Store))
 	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/schema/{dbalias}/transformtable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaTransformTableHandler(sh.Store))
+	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/schema/{dbalias}/audittable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaAuditTableHandler(sh.Store))
+	storeRouter.Methods(http.MethodGet).Path("/{project}/datasource/schema/{dbalias}/audittable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaAuditConfigHandler(sh.Store))
+	storeRouter.Methods(http.MethodGet).Path("/{project}/datasource/schema/{dbalias}/audithistory/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaAuditHistoryHandler(sh.Store))
+	storeRouter.Methods(http.MethodPost).Path("/{project}/datasource/schema/{dbalias}/cachetable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaCacheTableHandler(sh.Store))
+	storeRouter.Methods(http.MethodGet).Path("/{project}/datasource/schema/{dbalias}/cachetable/{tablename}").HandlerFunc(module_handlers.ProjectDataSourceSchemaCacheConfigHandler(sh.Store))
+	storeRouter.Methods(http.MethodGet).Path("/{project}/cache/stats").HandlerFunc(module_handlers.ProjectCacheStatsHandler(sh.Store))
 }
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
 	"github.com/eru-tech/eru/eru-ql/module_store"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +17,20 @@ const StoreTableName = "eruql_config"
 
 func StartUp() (module_store.ModuleStoreI, error) {
 	logs.WithContext(context.Background()).Debug("StartUp - Start")
+	erufilesbaseurl := os.Getenv("ERUFILES_BASEURL")
+	if erufilesbaseurl == "" {
+		erufilesbaseurl = "http://localhost:8082"
+		logs.WithContext(context.Background()).Info("'erufilesbaseurl' environment variable not found - setting default value as http://localhost:8082")
+	}
+	module_store.Erufilesbaseurl = erufilesbaseurl
+	if importMaxBytes := os.Getenv("IMPORT_MAX_BYTES"); importMaxBytes != "" {
+		maxBytes, err := strconv.ParseInt(importMaxBytes, 10, 64)
+		if err != nil || maxBytes <= 0 {
+			logs.WithContext(context.Background()).Error(fmt.Sprint("invalid IMPORT_MAX_BYTES environment variable '", importMaxBytes, "' - setting default value as ", module_model.DEFAULT_IMPORT_MAX_BYTES))
+		} else {
+			module_store.ImportMaxBytes = maxBytes
+		}
+	}
 	storeType := strings.ToUpper(os.Getenv("STORE_TYPE"))
 	if storeType == "" {
 		storeType = "STANDALONE"
@@ -0,0 +1,161 @@
+package module_store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"strings"
+	"time"
+)
+
+// SaveTableAudit saves audit config of the table - audit table is created in datasource db if audit records are not posted to sink url
+func (ms *ModuleStore) SaveTableAudit(ctx context.Context, projectId string, dbAlias string, tableName string, auditConfig module_model.AuditConfig, realStore ModuleStoreI) (err error) {
+	logs.WithContext(ctx).Debug("SaveTableAudit - Start")
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		return err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	if _, ok := db.SchemaTables[tableName]; !ok {
+		err = errors.New(fmt.Sprint("Table ", tableName, " not found"))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	if auditConfig.Enabled {
+		// mysql has no returning clause to fetch rows changed by the mutation
+		if db.DbType != "sql" || db.DbName == "mysql" {
+			err = errors.New(fmt.Sprint("audit is not supported for ", db.DbName, " datasource ", dbAlias))
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+		if _, err = db.GetConflictCols(ctx, tableName, ""); err != nil {
+			err = errors.New(fmt.Sprint("audit of table ", tableName, " needs a primary key : ", err.Error()))
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+		if auditConfig.SinkUrl == "" {
+			if err = createAuditTable(ctx, db, auditConfig.GetAuditTable()); err != nil {
+				return err
+			}
+		}
+	}
+	if db.SchemaTablesAudit == nil {
+		db.SchemaTablesAudit = make(map[string]module_model.AuditConfig)
+	}
+	db.SchemaTablesAudit[tableName] = auditConfig
+	return realStore.SaveStore(ctx, "", realStore)
+}
+
+// GetTableAudit returns audit config of the table - audit is disabled if config is not saved for the table
+func (ms *ModuleStore) GetTableAudit(ctx context.Context, projectId string, dbAlias string, tableName string) (auditConfig module_model.AuditConfig, err error) {
+	logs.WithContext(ctx).Debug("GetTableAudit - Start")
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		return auditConfig, err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	if _, ok := db.SchemaTables[tableName]; !ok {
+		err = errors.New(fmt.Sprint("Table ", tableName, " not found"))
+		logs.WithContext(ctx).Error(err.Error())
+		return auditConfig, err
+	}
+	return db.SchemaTablesAudit[tableName], nil
+}
+
+// GetAuditHistory returns changes of the row having the primary key values in the order they are made - values are matched as strings
+func (ms *ModuleStore) GetAuditHistory(ctx context.Context, projectId string, dbAlias string, tableName string, pkValues map[string]string) (records []module_model.AuditRecord, err error) {
+	logs.WithContext(ctx).Debug("GetAuditHistory - Start")
+	auditConfig, err := ms.GetTableAudit(ctx, projectId, dbAlias, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if auditConfig.SinkUrl != "" {
+		err = errors.New(fmt.Sprint("audit records of table ", tableName, " are posted to sink url"))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	if db.Con == nil {
+		err = errors.New(fmt.Sprint("Datasource ", dbAlias, " is not connected"))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	pkCols, err := db.GetConflictCols(ctx, tableName, "")
+	if err != nil {
+		return nil, err
+	}
+	pk := make(map[string]interface{})
+	for _, c := range pkCols {
+		v, ok := pkValues[c]
+		if !ok {
+			err = errors.New(fmt.Sprint("value of primary key column ", c, " not found"))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+		pk[c] = v
+	}
+	sr := ds.GetSqlMaker(db.DbName)
+	query := fmt.Sprint("select ", strings.Join(ds.AuditCols, " , "), " from ", auditConfig.GetAuditTable(),
+		" where table_name = ", sr.GetPreparedQueryPlaceholder(ctx, 1, 1, true), " and primary_key = ", sr.GetPreparedQueryPlaceholder(ctx, 1, 2, true), " order by changed_at")
+	rows, err := db.Con.QueryxContext(ctx, query, tableName, module_model.AuditPrimaryKey(pkCols, pk))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	records = []module_model.AuditRecord{}
+	for rows.Next() {
+		var rec module_model.AuditRecord
+		var before, after, userId, requestId *string
+		if err = rows.Scan(&rec.AuditId, &rec.TableName, &rec.Operation, &rec.PrimaryKey, &before, &after, &userId, &requestId, &rec.ChangedAt); err != nil {
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+		if rec.Before, err = auditImage(ctx, before); err != nil {
+			return nil, err
+		}
+		if rec.After, err = auditImage(ctx, after); err != nil {
+			return nil, err
+		}
+		if userId != nil {
+			rec.UserId = *userId
+		}
+		if requestId != nil {
+			rec.RequestId = *requestId
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// createAuditTable creates audit table in datasource db if it is not present
+func createAuditTable(ctx context.Context, db *module_model.DataSource, auditTable string) (err error) {
+	logs.WithContext(ctx).Debug("createAuditTable - Start")
+	if db.Con == nil {
+		err = errors.New(fmt.Sprint("Datasource ", db.DbAlias, " is not connected"))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	cctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if _, err = db.Con.ExecContext(cctx, ds.MakeAuditTableSQL(ctx, ds.GetSqlMaker(db.DbName), auditTable)); err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	return err
+}
+
+// auditImage returns row image saved as json in audit table - nil if there is no image
+func auditImage(ctx context.Context, imageJson *string) (image map[string]interface{}, err error) {
+	if imageJson == nil || *imageJson == "" {
+		return nil, nil
+	}
+	if err = json.Unmarshal([]byte(*imageJson), &image); err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	return image, nil
+}
@@ -0,0 +1,50 @@
+package module_store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+)
+
+// SaveTableCache saves result cache config of select queries on the table
+func (ms *ModuleStore) SaveTableCache(ctx context.Context, projectId string, dbAlias string, tableName string, cacheConfig module_model.CacheConfig, realStore ModuleStoreI) (err error) {
+	logs.WithContext(ctx).Debug("SaveTableCache - Start")
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		return err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	if _, ok := db.SchemaTables[tableName]; !ok {
+		err = errors.New(fmt.Sprint("Table ", tableName, " not found"))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	if cacheConfig.Enabled && db.DbType != "sql" {
+		err = errors.New(fmt.Sprint("cache is not supported for ", db.DbName, " datasource ", dbAlias))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	if db.SchemaTablesCache == nil {
+		db.SchemaTablesCache = make(map[string]module_model.CacheConfig)
+	}
+	db.SchemaTablesCache[tableName] = cacheConfig
+	return realStore.SaveStore(ctx, "", realStore)
+}
+
+// GetTableCache returns result cache config of the table - results are not cached if config is not saved for the table
+func (ms *ModuleStore) GetTableCache(ctx context.Context, projectId string, dbAlias string, tableName string) (cacheConfig module_model.CacheConfig, err error) {
+	logs.WithContext(ctx).Debug("GetTableCache - Start")
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		return cacheConfig, err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	if _, ok := db.SchemaTables[tableName]; !ok {
+		err = errors.New(fmt.Sprint("Table ", tableName, " not found"))
+		logs.WithContext(ctx).Error(err.Error())
+		return cacheConfig, err
+	}
+	return db.SchemaTablesCache[tableName], nil
+}
@@ -0,0 +1,268 @@
+package module_store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// addAndApplyMigration records the queries as next migration of the datasource and applies it.
+// change is refused if migrations are pending, as they would otherwise be applied along with it, and migration is removed if it fails.
+func (ms *ModuleStore) addAndApplyMigration(ctx context.Context, projectId string, dbAlias string, name string, tableName string, upQueries []string, downQueries []string, realStore ModuleStoreI) (err error) {
+	logs.WithContext(ctx).Debug("addAndApplyMigration - Start")
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	applied, err := getAppliedMigrations(ctx, db)
+	if err != nil {
+		return err
+	}
+	version := 1
+	for _, m := range db.Migrations {
+		if _, ok := applied[m.Version]; !ok {
+			err = errors.New(fmt.Sprint("migration ", m.Version, " of datasource ", dbAlias, " is pending - apply or remove it before changing schema"))
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+		if m.Version >= version {
+			version = m.Version + 1
+		}
+	}
+	db.Migrations = append(db.Migrations, module_model.Migration{Version: version, Name: name, TableName: tableName, UpQueries: upQueries, DownQueries: downQueries, CreatedDate: time.Now()})
+	_, err = ms.ApplyMigrations(ctx, projectId, dbAlias, version, realStore)
+	if err != nil {
+		db.Migrations = removeMigration(db.Migrations, version)
+		return err
+	}
+	return realStore.SaveStore(ctx, "", realStore)
+}
+
+// removeMigration returns migrations other than the version
+func removeMigration(migrations []module_model.Migration, version int) []module_model.Migration {
+	var remaining []module_model.Migration
+	for _, m := range migrations {
+		if m.Version != version {
+			remaining = append(remaining, m)
+		}
+	}
+	return remaining
+}
+
+// GetMigrations returns migrations of the datasource in version order along with their status in datasource db
+func (ms *ModuleStore) GetMigrations(ctx context.Context, projectId string, dbAlias string) (migrations []module_model.MigrationStatus, err error) {
+	logs.WithContext(ctx).Debug("GetMigrations - Start")
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		return nil, err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	applied, err := getAppliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	migrations = []module_model.MigrationStatus{}
+	for _, m := range sortMigrations(db.Migrations) {
+		status := module_model.MigrationStatus{Migration: m}
+		if appliedDate, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedDate = &appliedDate
+		}
+		migrations = append(migrations, status)
+	}
+	return migrations, nil
+}
+
+// ApplyMigrations applies pending migrations up to the version, all pending migrations are applied if version is 0.
+// each migration is applied in its own transaction and returned versions are of migrations applied before an error, if any.
+func (ms *ModuleStore) ApplyMigrations(ctx context.Context, projectId string, dbAlias string, version int, realStore ModuleStoreI) (versions []int, err error) {
+	logs.WithContext(ctx).Debug("ApplyMigrations - Start")
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		return nil, err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	applied, err := getAppliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	sr := ds.GetSqlMaker(db.DbName)
+	insertQuery := fmt.Sprint("insert into ", module_model.MIGRATION_TABLE, " (version, name, applied_date) values ", sr.GetPreparedQueryPlaceholder(ctx, 1, 3, false))
+	for _, m := range sortMigrations(db.Migrations) {
+		if version > 0 && m.Version > version {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		logs.WithContext(ctx).Info(fmt.Sprint("applying migration ", m.Version, " : ", m.Name))
+		err = executeMigrationQueries(ctx, db, m.UpQueries, insertQuery, m.Version, m.Name, time.Now())
+		if err != nil {
+			err = errors.New(fmt.Sprint("migration ", m.Version, " failed : ", err.Error()))
+			logs.WithContext(ctx).Error(err.Error())
+			return versions, err
+		}
+		versions = append(versions, m.Version)
+	}
+	if len(versions) > 0 {
+		_, err = realStore.UpdateSchemaTables(ctx, projectId, dbAlias, realStore)
+	}
+	return versions, err
+}
+
+// RollbackMigrations reverts applied migrations after the version in reverse order, all migrations are reverted if version is 0
+func (ms *ModuleStore) RollbackMigrations(ctx context.Context, projectId string, dbAlias string, version int, realStore ModuleStoreI) (versions []int, err error) {
+	logs.WithContext(ctx).Debug("RollbackMigrations - Start")
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		return nil, err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	applied, err := getAppliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	sr := ds.GetSqlMaker(db.DbName)
+	deleteQuery := fmt.Sprint("delete from ", module_model.MIGRATION_TABLE, " where version = ", sr.GetPreparedQueryPlaceholder(ctx, 1, 1, true))
+	migrations := sortMigrations(db.Migrations)
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= version {
+			break
+		}
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if len(m.DownQueries) == 0 {
+			err = errors.New(fmt.Sprint("migration ", m.Version, " cannot be rolled back as it has no down queries"))
+			logs.WithContext(ctx).Error(err.Error())
+			return versions, err
+		}
+		logs.WithContext(ctx).Info(fmt.Sprint("rolling back migration ", m.Version, " : ", m.Name))
+		err = executeMigrationQueries(ctx, db, m.DownQueries, deleteQuery, m.Version)
+		if err != nil {
+			err = errors.New(fmt.Sprint("rollback of migration ", m.Version, " failed : ", err.Error()))
+			logs.WithContext(ctx).Error(err.Error())
+			return versions, err
+		}
+		versions = append(versions, m.Version)
+	}
+	if len(versions) > 0 {
+		_, err = realStore.UpdateSchemaTables(ctx, projectId, dbAlias, realStore)
+	}
+	return versions, err
+}
+
+// ImportMigrations adds migrations of another project, e.g. to promote schema changes from dev to prod, which can then be applied.
+// migration with a version already present is skipped if it is the same, including its down queries, else import is rejected.
+func (ms *ModuleStore) ImportMigrations(ctx context.Context, projectId string, dbAlias string, migrations []module_model.Migration, realStore ModuleStoreI) (err error) {
+	logs.WithContext(ctx).Debug("ImportMigrations - Start")
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		return err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	existing := make(map[int]module_model.Migration)
+	for _, m := range db.Migrations {
+		existing[m.Version] = m
+	}
+	var newMigrations []module_model.Migration
+	for _, m := range migrations {
+		if m.Version <= 0 || len(m.UpQueries) == 0 {
+			err = errors.New(fmt.Sprint("migration ", m.Version, " needs a positive version and up queries"))
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+		if em, ok := existing[m.Version]; ok {
+			if em.Name != m.Name || em.TableName != m.TableName || !reflect.DeepEqual(em.UpQueries, m.UpQueries) || !reflect.DeepEqual(em.DownQueries, m.DownQueries) {
+				err = errors.New(fmt.Sprint("migration ", m.Version, " already exists with different name, table or queries"))
+				logs.WithContext(ctx).Error(err.Error())
+				return err
+			}
+			continue
+		}
+		existing[m.Version] = m
+		newMigrations = append(newMigrations, m)
+	}
+	db.Migrations = sortMigrations(append(db.Migrations, newMigrations...))
+	return realStore.SaveStore(ctx, "", realStore)
+}
+
+func sortMigrations(migrations []module_model.Migration) []module_model.Migration {
+	sorted := append([]module_model.Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return sorted
+}
+
+// getAppliedMigrations returns versions of migrations applied to datasource db with their applied date - tracking table is created if not present
+func getAppliedMigrations(ctx context.Context, db *module_model.DataSource) (applied map[int]time.Time, err error) {
+	logs.WithContext(ctx).Debug("getAppliedMigrations - Start")
+	if db.Con == nil {
+		err = errors.New(fmt.Sprint("Datasource ", db.DbAlias, " is not connected"))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	sr := ds.GetSqlMaker(db.DbName)
+	if sr == nil {
+		err = errors.New(fmt.Sprint("Unsupported database ", db.DbName))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	_, err = db.Con.ExecContext(ctx, migrationTableSQL(ctx, sr))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	rows, err := db.Con.QueryxContext(ctx, fmt.Sprint("select version, applied_date from ", module_model.MIGRATION_TABLE))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+	applied = make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedDate time.Time
+		if err = rows.Scan(&version, &appliedDate); err != nil {
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+		applied[version] = appliedDate
+	}
+	return applied, rows.Err()
+}
+
+// migrationTableSQL returns query to create table which tracks applied migrations if it is not present
+func migrationTableSQL(ctx context.Context, sr ds.SqlMakerI) string {
+	return sr.MakeCreateTableIfNotExistsSQL(ctx, module_model.MIGRATION_TABLE, fmt.Sprint("version integer primary key, name varchar(500), applied_date ", sr.GetTimestampDataType(ctx)))
+}
+
+// executeMigrationQueries executes queries of a migration and the query to track it in a single transaction - mysql commits each ddl on its own
+func executeMigrationQueries(ctx context.Context, db *module_model.DataSource, queries []string, trackQuery string, trackArgs ...interface{}) (err error) {
+	logs.WithContext(ctx).Debug("executeMigrationQueries - Start")
+	tx, err := db.Con.BeginTxx(ctx, nil)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	for _, q := range append(queries, trackQuery) {
+		args := trackArgs
+		if q != trackQuery {
+			args = nil
+		}
+		logs.WithContext(ctx).Info(q)
+		if _, err = tx.ExecContext(ctx, q, args...); err != nil {
+			logs.WithContext(ctx).Error(err.Error())
+			if rbErr := tx.Rollback(); rbErr != nil {
+				logs.WithContext(ctx).Error(rbErr.Error())
+			}
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,141 @@
+package module_store
+
+import (
+	"context"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-store/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logs.LogInit("eru-ql-test")
+	os.Exit(m.Run())
+}
+
+// testModuleStore keeps the store in memory
+type testModuleStore struct {
+	ModuleFileStore
+}
+
+func (ts *testModuleStore) SaveStore(ctx context.Context, fp string, ms store.StoreI) error {
+	return nil
+}
+
+func newMigrationTestStore(t *testing.T) (*testModuleStore, *module_model.DataSource) {
+	ctx := context.Background()
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db"), DefaultSchema: "main"}}
+	if err := ds.GetSqlMaker("sqlite").CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { datasource.Con.Close() })
+	datasource.SchemaTables = make(map[string]map[string]module_model.TableColsMetaData)
+	ts := &testModuleStore{}
+	ts.Projects = map[string]*module_model.Project{"p": {ProjectId: "p", DataSources: map[string]*module_model.DataSource{"db": datasource}}}
+	return ts, datasource
+}
+
+func TestAddAndApplyMigration(t *testing.T) {
+	ctx := context.Background()
+	ts, datasource := newMigrationTestStore(t)
+	if err := ts.addAndApplyMigration(ctx, "p", "db", "create a", "a", []string{"create table a (id integer primary key)"}, []string{"drop table a"}, ts); err != nil {
+		t.Fatal(err)
+	}
+
+	// failed migration is removed and migrations before it are kept
+	if err := ts.addAndApplyMigration(ctx, "p", "db", "create b", "b", []string{"create table b (id nosuchtype primary key"}, nil, ts); err == nil {
+		t.Fatal("invalid migration is expected to fail")
+	}
+	if len(datasource.Migrations) != 1 || datasource.Migrations[0].Version != 1 {
+		t.Fatalf("unexpected migrations after failure : %+v", datasource.Migrations)
+	}
+
+	// pending imported migration is not applied as a side effect of a schema change
+	if err := ts.ImportMigrations(ctx, "p", "db", []module_model.Migration{{Version: 2, Name: "create c", TableName: "c", UpQueries: []string{"create table c (id integer)"}}}, ts); err != nil {
+		t.Fatal(err)
+	}
+	err := ts.addAndApplyMigration(ctx, "p", "db", "create d", "d", []string{"create table d (id integer)"}, nil, ts)
+	if err == nil || !strings.Contains(err.Error(), "pending") {
+		t.Fatalf("schema change is expected to be refused while migrations are pending : %v", err)
+	}
+	statuses, err := ts.GetMigrations(ctx, "p", "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || !statuses[0].Applied || statuses[1].Applied {
+		t.Fatalf("unexpected migration status : %+v", statuses)
+	}
+}
+
+func TestApplyAndRollbackMigrations(t *testing.T) {
+	ctx := context.Background()
+	ts, datasource := newMigrationTestStore(t)
+	datasource.Migrations = []module_model.Migration{
+		{Version: 2, Name: "add b", UpQueries: []string{"alter table a add column b integer"}, DownQueries: []string{"alter table a drop column b"}},
+		{Version: 1, Name: "create a", UpQueries: []string{"create table a (id integer primary key)"}, DownQueries: []string{"drop table a"}},
+	}
+	versions, err := ts.ApplyMigrations(ctx, "p", "db", 0, ts)
+	if err != nil || len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Fatalf("migrations are expected to be applied in version order : %v %v", versions, err)
+	}
+	if _, err = datasource.Con.Exec("insert into a (id, b) values (1, 2)"); err != nil {
+		t.Fatal(err)
+	}
+	versions, err = ts.RollbackMigrations(ctx, "p", "db", 1, ts)
+	if err != nil || len(versions) != 1 || versions[0] != 2 {
+		t.Fatalf("only migrations after version are expected to be rolled back : %v %v", versions, err)
+	}
+	if _, err = datasource.Con.Exec("insert into a (id, b) values (2, 2)"); err == nil {
+		t.Fatal("column b is expected to be dropped")
+	}
+}
+
+func TestImportMigrations(t *testing.T) {
+	ctx := context.Background()
+	existing := module_model.Migration{Version: 1, Name: "create a", TableName: "a", UpQueries: []string{"create table a (id integer)"}, DownQueries: []string{"drop table a"}}
+	tests := []struct {
+		name    string
+		import_ module_model.Migration
+		wantErr bool
+	}{
+		{"same migration is skipped", existing, false},
+		{"different up queries", module_model.Migration{Version: 1, Name: "create a", TableName: "a", UpQueries: []string{"create table a (id bigint)"}, DownQueries: []string{"drop table a"}}, true},
+		{"different down queries", module_model.Migration{Version: 1, Name: "create a", TableName: "a", UpQueries: existing.UpQueries}, true},
+		{"different name", module_model.Migration{Version: 1, Name: "create x", TableName: "a", UpQueries: existing.UpQueries, DownQueries: existing.DownQueries}, true},
+		{"new version", module_model.Migration{Version: 2, Name: "create b", TableName: "b", UpQueries: []string{"create table b (id integer)"}}, false},
+		{"no up queries", module_model.Migration{Version: 3, Name: "empty"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, datasource := newMigrationTestStore(t)
+			datasource.Migrations = []module_model.Migration{existing}
+			err := ts.ImportMigrations(ctx, "p", "db", []module_model.Migration{tt.import_}, ts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMigrationTableSQL(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		dbName string
+		want   string
+	}{
+		{"postgres", "create table if not exists eru_schema_migrations (version integer primary key, name varchar(500), applied_date timestamp)"},
+		{"mysql", "create table if not exists eru_schema_migrations (version integer primary key, name varchar(500), applied_date datetime)"},
+		{"mssql", "if object_id('eru_schema_migrations', 'U') is null create table eru_schema_migrations (version integer primary key, name varchar(500), applied_date datetime2)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dbName, func(t *testing.T) {
+			if got := migrationTableSQL(ctx, ds.GetSqlMaker(tt.dbName)); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
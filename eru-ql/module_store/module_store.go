@@ -14,6 +14,11 @@ import (
 	"strings"
 )
 
+var Erufilesbaseurl = "http://localhost:8082"
+
+// ImportMaxBytes is the size limit of file imported into a table
+var ImportMaxBytes int64 = module_model.DEFAULT_IMPORT_MAX_BYTES
+
 const (
 	Q_SELECT = "SELECT"
 	Q_WITH   = "WITH"
@@ -41,13 +46,23 @@ type ModuleStoreI interface {
 	UpdateSchemaTables(ctx context.Context, projectId string, dbAlias string, realStore ModuleStoreI) (datasource *module_model.DataSource, err error)
 	AddSchemaTable(ctx context.Context, projectId string, dbAlias string, tableName string, realStore ModuleStoreI) (tables map[string]interface{}, err error)
 	SaveSchemaTable(ctx context.Context, projectId string, dbAlias string, tableName string, tableObj map[string]module_model.TableColsMetaData, realStore ModuleStoreI) (err error)
+	MakeSchemaTableSQL(ctx context.Context, projectId string, dbAlias string, tableName string, tableObj map[string]module_model.TableColsMetaData) (queries []string, downQueries []string, err error)
+	GetMigrations(ctx context.Context, projectId string, dbAlias string) (migrations []module_model.MigrationStatus, err error)
+	ApplyMigrations(ctx context.Context, projectId string, dbAlias string, version int, realStore ModuleStoreI) (versions []int, err error)
+	RollbackMigrations(ctx context.Context, projectId string, dbAlias string, version int, realStore ModuleStoreI) (versions []int, err error)
+	ImportMigrations(ctx context.Context, projectId string, dbAlias string, migrations []module_model.Migration, realStore ModuleStoreI) (err error)
 	SaveTableSecurity(ctx context.Context, projectId string, dbAlias string, tableName string, securityRules module_model.SecurityRules, realStore ModuleStoreI) (err error)
 	SaveTableTransformation(ctx context.Context, projectId string, dbAlias string, tableName string, transformRules module_model.TransformRules, realStore ModuleStoreI) (err error)
 	GetTableTransformation(ctx context.Context, projectId string, dbAlias string, tableName string) (transformRules module_model.TransformRules, err error)
+	SaveTableAudit(ctx context.Context, projectId string, dbAlias string, tableName string, auditConfig module_model.AuditConfig, realStore ModuleStoreI) (err error)
+	GetTableAudit(ctx context.Context, projectId string, dbAlias string, tableName string) (auditConfig module_model.AuditConfig, err error)
+	GetAuditHistory(ctx context.Context, projectId string, dbAlias string, tableName string, pkValues map[string]string) (records []module_model.AuditRecord, err error)
+	SaveTableCache(ctx context.Context, projectId string, dbAlias string, tableName string, cacheConfig module_model.CacheConfig, realStore ModuleStoreI) (err error)
+	GetTableCache(ctx context.Context, projectId string, dbAlias string, tableName string) (cacheConfig module_model.CacheConfig, err error)
 	GetTableSecurityRule(ctx context.Context, projectId string, dbAlias string, tableName string) (transformRules module_model.SecurityRules, err error)
 	DropSchemaTable(ctx context.Context, projectId string, dbAlias string, tableName string, realStore ModuleStoreI) (err error)
 	RemoveSchemaTable(ctx context.Context, projectId string, dbAlias string, tableName string, realStore ModuleStoreI) (tables map[string]interface{}, err error)
-	SaveMyQuery(ctx context.Context, projectId string, queryName string, queryType string, dbAlias string, query string, vars map[string]interface{}, realStore ModuleStoreI, cols string, securityRule security_rule.SecurityRule) error
+	SaveMyQuery(ctx context.Context, projectId string, queryName string, queryType string, dbAlias string, query string, vars map[string]interface{}, realStore ModuleStoreI, cols string, securityRule security_rule.SecurityRule, timeOut int, params []module_model.MyQueryParam, cache module_model.CacheConfig) error
 	RemoveMyQuery(ctx context.Context, projectId string, queryName string, realStore ModuleStoreI) error
 	GetMyQuery(ctx context.Context, projectId string, queryName string) (myquery module_model.MyQuery, err error)
 	GetMyQueries(ctx context.Context, projectId string, queryType string) (myqueries map[string]module_model.MyQuery, err error)
@@ -161,7 +176,11 @@ func (ms *ModuleStore) SetDataSourceConnections(ctx context.Context, realStore M
 				}
 				//setting DB connection object in actual store
 				datasource.Con = datasourceClone.Con
+				datasource.MongoCon = datasourceClone.MongoCon
 				datasource.ConStatus = datasourceClone.ConStatus
+				oldReplicas := datasource.Replicas
+				datasource.Replicas = ds.CreateReplicaConns(ctx, i, datasourceClone)
+				oldReplicas.Close(ctx)
 
 			} else {
 				err = errors.New(fmt.Sprint(datasource.DbName, " not found"))
@@ -217,12 +236,16 @@ func (ms *ModuleStore) SaveDataSource(ctx context.Context, projectId string, dat
 		ms.Projects[projectId].DataSources = make(map[string]*module_model.DataSource)
 	}
 
+	oldDatasource := ms.Projects[projectId].DataSources[datasource.DbAlias]
 	if ms.Projects[projectId].DataSources[datasource.DbAlias] != nil {
 		datasource.SchemaTables = ms.Projects[projectId].DataSources[datasource.DbAlias].SchemaTables
 		datasource.SchemaTablesSecurity = ms.Projects[projectId].DataSources[datasource.DbAlias].SchemaTablesSecurity
 		datasource.TableJoins = ms.Projects[projectId].DataSources[datasource.DbAlias].TableJoins
 		datasource.DbSecurityRules = ms.Projects[projectId].DataSources[datasource.DbAlias].DbSecurityRules
 		datasource.SchemaTablesTransformation = ms.Projects[projectId].DataSources[datasource.DbAlias].SchemaTablesTransformation
+		datasource.SchemaTablesAudit = ms.Projects[projectId].DataSources[datasource.DbAlias].SchemaTablesAudit
+		datasource.SchemaTablesCache = ms.Projects[projectId].DataSources[datasource.DbAlias].SchemaTablesCache
+		datasource.Migrations = ms.Projects[projectId].DataSources[datasource.DbAlias].Migrations
 	}
 	ms.Projects[projectId].DataSources[datasource.DbAlias] = datasource
 
@@ -240,7 +263,13 @@ func (ms *ModuleStore) SaveDataSource(ctx context.Context, projectId string, dat
 	}
 	//setting DB connection object in actual store
 	datasource.Con = datasourceClone.Con
+	datasource.MongoCon = datasourceClone.MongoCon
 	datasource.ConStatus = datasourceClone.ConStatus
+	datasource.Replicas = ds.CreateReplicaConns(ctx, sqlMaker, datasourceClone)
+	// replicas of the replaced datasource are no longer used
+	if oldDatasource != nil && oldDatasource != datasource {
+		oldDatasource.Replicas.Close(ctx)
+	}
 	return realStore.SaveStore(ctx, "", realStore)
 }
 
@@ -250,6 +279,7 @@ func (ms *ModuleStore) RemoveDataSource(ctx context.Context, projectId string, d
 	if err != nil {
 		return err
 	}
+	ms.Projects[projectId].DataSources[dbAlias].Replicas.Close(ctx)
 	delete(ms.Projects[projectId].DataSources, dbAlias)
 	return realStore.SaveStore(ctx, "", realStore)
 }
@@ -438,12 +468,16 @@ func (ms *ModuleStore) RemoveSchemaJoin(ctx context.Context, projectId string, d
 	}
 }
 
-func (ms *ModuleStore) SaveMyQuery(ctx context.Context, projectId string, queryName string, queryType string, dbAlias string, query string, vars map[string]interface{}, realStore ModuleStoreI, cols string, securityRule security_rule.SecurityRule) error {
+func (ms *ModuleStore) SaveMyQuery(ctx context.Context, projectId string, queryName string, queryType string, dbAlias string, query string, vars map[string]interface{}, realStore ModuleStoreI, cols string, securityRule security_rule.SecurityRule, timeOut int, params []module_model.MyQueryParam, cache module_model.CacheConfig) error {
 	logs.WithContext(ctx).Debug("SaveMyQuery - Start")
 	if _, ok := ms.Projects[projectId]; ok {
+		if err := module_model.ValidateParamSpec(params); err != nil {
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
 		readWrite := ""
 		queryFirstWord := strings.ToUpper(strings.Split(query, " ")[0])
-		if queryFirstWord == Q_SELECT || queryFirstWord == Q_WITH {
+		if queryType == "sql" && IsSqlSelect(query) {
 			readWrite = Q_SELECT
 		} else if queryFirstWord == Q_INSERT {
 			readWrite = Q_INSERT
@@ -452,7 +486,13 @@ func (ms *ModuleStore) SaveMyQuery(ctx context.Context, projectId string, queryN
 		} else if queryFirstWord == Q_DELETE {
 			readWrite = Q_DELETE
 		}
-		myquery := module_model.MyQuery{queryName, query, vars, queryType, dbAlias, readWrite, cols, securityRule}
+		// results of only select queries are cached as other queries change data
+		if cache.Enabled && queryType == "sql" && readWrite != Q_SELECT {
+			err := errors.New(fmt.Sprint("cache is supported only for select queries - query ", queryName, " is not a select query"))
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+		myquery := module_model.MyQuery{queryName, query, vars, queryType, dbAlias, readWrite, cols, securityRule, timeOut, params, cache}
 		if ms.Projects[projectId].MyQueries == nil {
 			ms.Projects[projectId].MyQueries = make(map[string]*module_model.MyQuery)
 		}
@@ -540,54 +580,113 @@ func (ms *ModuleStore) GetMyQueries(ctx context.Context, projectId string, query
 	}
 }
 
+// SaveSchemaTable creates or alters the table - change is recorded as a migration of the datasource and applied
 func (ms *ModuleStore) SaveSchemaTable(ctx context.Context, projectId string, dbAlias string, tableName string, tableObj map[string]module_model.TableColsMetaData, realStore ModuleStoreI) (err error) {
 	logs.WithContext(ctx).Debug("SaveSchemaTable - Start")
-	tableExists := false
-	if prj, ok := ms.Projects[projectId]; ok {
-		if db, ok := prj.DataSources[dbAlias]; ok {
-			if _, ok := db.SchemaTables[tableName]; ok {
-				tableExists = true
-				logs.WithContext(ctx).Info("table exists in Schema table - to alter")
-			} else if _, ok := db.OtherTables[tableName]; ok {
-				tableExists = true
-				logs.WithContext(ctx).Info("table exists in Other table - to alter")
-			}
-			if tableExists {
-				//alter table
-				err = errors.New("Alter table not implemented as yet")
-				if err != nil {
-					logs.WithContext(ctx).Error(err.Error())
-				}
-			} else {
-				//create table
-				sr := ds.GetSqlMaker(db.DbName)
-				query, err := sr.MakeCreateTableSQL(ctx, tableName, tableObj)
-				if err != nil {
-					logs.WithContext(ctx).Error(err.Error())
-					return err
-				}
-				res, err := sr.ExecutePreparedQuery(ctx, query, db)
-				_ = res
-				if err != nil {
-					return err
-				}
-				//TODO to change store
-			}
-		} else {
-			err = errors.New(fmt.Sprint("Datasource ", dbAlias, " not found"))
-			if err != nil {
-				logs.WithContext(ctx).Error(err.Error())
-			}
-			return err
+	queries, downQueries, tableExists, err := ms.makeSchemaTableSQL(ctx, projectId, dbAlias, tableName, tableObj)
+	if err != nil {
+		return err
+	}
+	if len(queries) == 0 {
+		logs.WithContext(ctx).Info(fmt.Sprint("no changes found for table ", tableName))
+		return nil
+	}
+	name := fmt.Sprint("create ", tableName)
+	if tableExists {
+		name = fmt.Sprint("alter ", tableName)
+	}
+	return ms.addAndApplyMigration(ctx, projectId, dbAlias, name, tableName, queries, downQueries, realStore)
+}
+
+// MakeSchemaTableSQL returns queries to create the table or, if it exists, to alter it to match tableObj along with the queries to revert them
+func (ms *ModuleStore) MakeSchemaTableSQL(ctx context.Context, projectId string, dbAlias string, tableName string, tableObj map[string]module_model.TableColsMetaData) (queries []string, downQueries []string, err error) {
+	logs.WithContext(ctx).Debug("MakeSchemaTableSQL - Start")
+	queries, downQueries, _, err = ms.makeSchemaTableSQL(ctx, projectId, dbAlias, tableName, tableObj)
+	return
+}
+
+// makeSchemaTableSQL compares existing table with its metadata fetched again from db as metadata in store can be stale.
+// queries to revert an alter are made by altering submitted table back to existing table.
+func (ms *ModuleStore) makeSchemaTableSQL(ctx context.Context, projectId string, dbAlias string, tableName string, tableObj map[string]module_model.TableColsMetaData) (queries []string, downQueries []string, tableExists bool, err error) {
+	logs.WithContext(ctx).Debug("makeSchemaTableSQL - Start")
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	sr := ds.GetSqlMaker(db.DbName)
+	if sr == nil {
+		err = errors.New(fmt.Sprint("Unsupported database ", db.DbName))
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	liveTableObj, tableExists := db.SchemaTables[tableName]
+	if !tableExists {
+		liveTableObj, tableExists = db.OtherTables[tableName]
+	}
+	if metaDataSQL := sr.GetSingleTableMetaDataSQL(ctx); db.Con != nil && metaDataSQL != "" {
+		// only the table being saved is fetched again
+		schemaName, tblName := db.DbConfig.DefaultSchema, tableName
+		if i := strings.Index(tableName, "."); i >= 0 {
+			schemaName, tblName = tableName[:i], tableName[i+1:]
 		}
-	} else {
-		err = errors.New(fmt.Sprint("Project ", projectId, " not found"))
-		if err != nil {
-			logs.WithContext(ctx).Error(err.Error())
+		liveDs := &module_model.DataSource{Con: db.Con}
+		if e := sr.GetTableList(ctx, metaDataSQL, liveDs, sr, schemaName, tblName); e != nil {
+			logs.WithContext(ctx).Warn(fmt.Sprint("failed to fetch table metadata from db - using metadata in store : ", e.Error()))
+		} else if t, ok := liveDs.OtherTables[fmt.Sprint(schemaName, ".", tblName)]; ok {
+			liveTableObj = t
+			tableExists = true
 		}
-		return err
 	}
-	return err
+	if tableExists {
+		logs.WithContext(ctx).Info("table exists - to alter")
+		queries, err = sr.MakeAlterTableSQL(ctx, tableName, liveTableObj, tableObj)
+		if err != nil || len(queries) == 0 {
+			return
+		}
+		downFrom, downTo := revertTableObjs(liveTableObj, tableObj)
+		downQueries, err = sr.MakeAlterTableSQL(ctx, tableName, downFrom, downTo)
+		return
+	}
+	query, err := sr.MakeCreateTableSQL(ctx, tableName, tableObj)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	if query == "" {
+		err = errors.New(fmt.Sprint("Create table not implemented for ", db.DbName))
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	dropQuery, err := sr.MakeDropTableSQL(ctx, tableName)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	return []string{query}, []string{dropQuery}, false, nil
+}
+
+// revertTableObjs returns submitted table, as the table to alter, and existing table, as the target, to make queries which revert an alter.
+// renamed columns are renamed back by setting OldColName on existing columns.
+func revertTableObjs(liveTableObj map[string]module_model.TableColsMetaData, tableObj map[string]module_model.TableColsMetaData) (from map[string]module_model.TableColsMetaData, to map[string]module_model.TableColsMetaData) {
+	from = make(map[string]module_model.TableColsMetaData)
+	to = make(map[string]module_model.TableColsMetaData)
+	for k, v := range liveTableObj {
+		v.OldColName = ""
+		to[k] = v
+	}
+	for k, v := range tableObj {
+		if v.OldColName != "" {
+			if l, ok := to[v.OldColName]; ok {
+				l.OldColName = k
+				to[v.OldColName] = l
+			}
+		}
+		v.OldColName = ""
+		from[k] = v
+	}
+	return
 }
 
 func (ms *ModuleStore) SaveTableSecurity(ctx context.Context, projectId string, dbAlias string, tableName string, securityRules module_model.SecurityRules, realStore ModuleStoreI) (err error) {
@@ -720,48 +819,43 @@ func (ms *ModuleStore) GetTableSecurityRule(ctx context.Context, projectId strin
 	return
 }
 
+// DropSchemaTable drops the table - drop is recorded as a migration of the datasource which recreates the table on rollback
 func (ms *ModuleStore) DropSchemaTable(ctx context.Context, projectId string, dbAlias string, tableName string, realStore ModuleStoreI) (err error) {
 	logs.WithContext(ctx).Debug("DropSchemaTable - Start")
-	tableExists := false
-	if prj, ok := ms.Projects[projectId]; ok {
-		if db, ok := prj.DataSources[dbAlias]; ok {
-			if _, ok := db.SchemaTables[tableName]; ok {
-				tableExists = true
-				delete(db.SchemaTables, tableName)
-				logs.WithContext(ctx).Info("table exists in Schema table - to alter")
-			} else if _, ok := db.OtherTables[tableName]; ok {
-				tableExists = true
-				delete(db.OtherTables, tableName)
-				logs.WithContext(ctx).Info("table exists in Other table - to alter")
-			}
-			if tableExists {
-				//drop table
-				sr := ds.GetSqlMaker(db.DbName)
-				query, err := sr.MakeDropTableSQL(ctx, tableName)
-				if err != nil {
-					logs.WithContext(ctx).Error(err.Error())
-					return err
-				}
-				res, err := sr.ExecutePreparedQuery(ctx, query, db)
-				if err != nil {
-					logs.WithContext(ctx).Error(err.Error())
-					return err
-				}
-				_ = res
-				//TODO to change store
-			} else {
-				err = errors.New(fmt.Sprint("Table ", tableName, " does not exists"))
-				logs.WithContext(ctx).Error(err.Error())
-			}
-		} else {
-			err = errors.New(fmt.Sprint("Datasource ", dbAlias, " not found"))
-			logs.WithContext(ctx).Error(err.Error())
-			return err
-		}
-	} else {
-		err = errors.New(fmt.Sprint("Project ", projectId, " not found"))
+	err = ms.checkProjectDataSourceExists(ctx, projectId, dbAlias)
+	if err != nil {
+		return err
+	}
+	db := ms.Projects[projectId].DataSources[dbAlias]
+	tableObj, tableExists := db.SchemaTables[tableName]
+	if !tableExists {
+		tableObj, tableExists = db.OtherTables[tableName]
+	}
+	if !tableExists {
+		err = errors.New(fmt.Sprint("Table ", tableName, " does not exists"))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	sr := ds.GetSqlMaker(db.DbName)
+	query, err := sr.MakeDropTableSQL(ctx, tableName)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	createQuery, err := sr.MakeCreateTableSQL(ctx, tableName, tableObj)
+	if err != nil {
 		logs.WithContext(ctx).Error(err.Error())
 		return err
 	}
-	return err
+	var downQueries []string
+	if createQuery != "" {
+		downQueries = append(downQueries, createQuery)
+	}
+	err = ms.addAndApplyMigration(ctx, projectId, dbAlias, fmt.Sprint("drop ", tableName), tableName, []string{query}, downQueries, realStore)
+	if err != nil {
+		return err
+	}
+	delete(db.SchemaTables, tableName)
+	delete(db.OtherTables, tableName)
+	return realStore.SaveStore(ctx, "", realStore)
 }
@@ -0,0 +1,130 @@
+package module_store
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-security-rule/security_rule"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSaveDataSourceClosesReplacedReplicas(t *testing.T) {
+	ctx := context.Background()
+	ts := &testModuleStore{}
+	ts.Projects = map[string]*module_model.Project{"p": {ProjectId: "p"}}
+	dbConfig := module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db"), ReadReplicas: []module_model.ReadReplica{{Host: "r1"}}}
+	if err := ts.SaveDataSource(ctx, "p", &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: dbConfig}, ts); err != nil {
+		t.Fatal(err)
+	}
+	old := ts.Projects["p"].DataSources["db"]
+	oldCon := old.Replicas.Cons[0].HealthyCon(ctx, false)
+	if oldCon == nil {
+		t.Fatal("replica is expected to be healthy")
+	}
+	if err := ts.SaveDataSource(ctx, "p", &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: dbConfig}, ts); err != nil {
+		t.Fatal(err)
+	}
+	if err := oldCon.PingContext(ctx); err == nil {
+		t.Error("replica of replaced datasource is expected to be closed")
+	}
+	current := ts.Projects["p"].DataSources["db"]
+	defer current.Con.Close()
+	defer old.Con.Close()
+	newCon := current.Replicas.Cons[0].HealthyCon(ctx, false)
+	if newCon == nil || newCon == oldCon {
+		t.Fatal("saved datasource is expected to have its own healthy replica")
+	}
+	if err := ts.RemoveDataSource(ctx, "p", "db", ts); err != nil {
+		t.Fatal(err)
+	}
+	if err := newCon.PingContext(ctx); err == nil {
+		t.Error("replica of removed datasource is expected to be closed")
+	}
+}
+
+func TestRevertTableObjs(t *testing.T) {
+	ctx := context.Background()
+	live := map[string]module_model.TableColsMetaData{
+		"id":  {TblName: "orders", ColName: "id", OwnDataType: "Integer", ColPosition: 1, PrimaryKey: true, PkConstraintName: "pk_orders"},
+		"qty": {TblName: "orders", ColName: "qty", OwnDataType: "Integer", ColPosition: 2, IsNullable: true},
+		"tag": {TblName: "orders", ColName: "tag", OwnDataType: "Varchar", ColPosition: 3, CharMaxLength: 10, IsNullable: true},
+	}
+	tableObj := map[string]module_model.TableColsMetaData{
+		"id":       {TblName: "orders", ColName: "id", OwnDataType: "Integer", ColPosition: 1, PrimaryKey: true, PkConstraintName: "pk_orders"},
+		"quantity": {TblName: "orders", ColName: "quantity", OldColName: "qty", OwnDataType: "BigInteger", ColPosition: 2},
+		"note":     {TblName: "orders", ColName: "note", OwnDataType: "String", ColPosition: 3, IsNullable: true},
+	}
+	from, to := revertTableObjs(live, tableObj)
+	tests := []struct {
+		dbName string
+		want   []string
+	}{
+		{"postgres", []string{"alter table public.orders rename column quantity to qty", "alter table public.orders drop column note",
+			"alter table public.orders add column tag character varying(10)", "alter table public.orders alter column qty type integer using qty::integer",
+			"alter table public.orders alter column qty drop not null"}},
+		{"mysql", []string{"alter table public.orders rename column quantity to qty", "alter table public.orders drop column note",
+			"alter table public.orders add column tag varchar(10)", "alter table public.orders modify column qty int"}},
+	}
+	for _, tt := range tests {
+		down, err := ds.GetSqlMaker(tt.dbName).MakeAlterTableSQL(ctx, "public.orders", from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(down, tt.want) {
+			t.Errorf("%s : down queries\ngot  %q\nwant %q", tt.dbName, down, tt.want)
+		}
+	}
+}
+
+func TestIsSqlSelect(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"select * from t", true},
+		{"  SELECT * from t;", true},
+		{"-- totals\nselect * from t", true},
+		{"select * from t where name = 'a;b' or note = 'delete'", true},
+		{"with c as (select * from t) select * from c", true},
+		{"with c as (select * from t where status = 'update') select * from c", true},
+		{"with d as (delete from t returning *) select * from d", false},
+		{"WITH c AS (SELECT 1) UPDATE t SET n = 1", false},
+		{"select * from t; delete from t", false},
+		{"select 1; select 2", false},
+		{"delete from t", false},
+		{"insert into t select * from s", false},
+	}
+	for _, tt := range tests {
+		if got := IsSqlSelect(tt.query); got != tt.want {
+			t.Errorf("module_store.IsSqlSelect(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSaveMyQueryReadWrite(t *testing.T) {
+	ctx := context.Background()
+	ts := &testModuleStore{}
+	ts.Projects = map[string]*module_model.Project{"p": {ProjectId: "p"}}
+	tests := []struct {
+		query     string
+		queryType string
+		want      string
+	}{
+		{"select * from t", "sql", Q_SELECT},
+		{"with c as (select * from t) select * from c", "sql", Q_SELECT},
+		{"with d as (delete from t returning *) select * from d", "sql", ""},
+		{"select * from t; delete from t", "sql", ""},
+		{"update t set n = 1", "sql", Q_UPDATE},
+		{"select { t { id } }", "graphql", ""},
+	}
+	for _, tt := range tests {
+		if err := ts.SaveMyQuery(ctx, "p", "q", tt.queryType, "db", tt.query, nil, ts, "", security_rule.SecurityRule{}, 0, nil, module_model.CacheConfig{}); err != nil {
+			t.Fatal(err)
+		}
+		if got := ts.Projects["p"].MyQueries["q"].ReadWrite; got != tt.want {
+			t.Errorf("read write of %q = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
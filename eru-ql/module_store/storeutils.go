@@ -5,8 +5,29 @@ import (
 	"errors"
 	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"regexp"
+	"strings"
 )
 
+// sqlLiteralRegex matches string literals and comments of a sql query - words within them are not part of the query
+var sqlLiteralRegex = regexp.MustCompile(`'(?:[^']|'')*'|--[^\n]*|(?s:/\*.*?\*/)`)
+
+var sqlWriteWordRegex = regexp.MustCompile(`(?i)\b(insert|update|delete|merge|truncate)\b`)
+
+// IsSqlSelect returns true if the sql query only reads data.
+// query with more than one statement or with query of a with clause which changes data is not a read.
+func IsSqlSelect(query string) bool {
+	query = sqlLiteralRegex.ReplaceAllString(query, " ")
+	if statements := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";")); strings.Contains(statements, ";") {
+		return false
+	}
+	queryFirstWord := strings.ToUpper(strings.Fields(query + " ")[0])
+	if queryFirstWord == Q_SELECT {
+		return true
+	}
+	return queryFirstWord == Q_WITH && !sqlWriteWordRegex.MatchString(query)
+}
+
 func (ms *ModuleStore) checkProjectExists(ctx context.Context, projectId string) error {
 	logs.WithContext(ctx).Debug("checkProjectExists - Start")
 	_, ok := ms.Projects[projectId]
@@ -0,0 +1,202 @@
+package ql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	server_handlers "github.com/eru-tech/eru/eru-server/server/handlers"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const auditSinkTimeOut = 10 * time.Second
+
+// auditPosts tracks audit records being posted to sink
+var auditPosts sync.WaitGroup
+
+// initAudit sets mutation audit of the sql object if its query type is audited for the main table.
+// audited mutations return all columns of changed rows - fields asked by the mutation are remembered to trim the returned rows.
+func (sqlObj *SQLObjectM) initAudit(ctx context.Context, datasource *module_model.DataSource, vars map[string]interface{}) (err error) {
+	logs.WithContext(ctx).Debug("initAudit - Start")
+	sqlObj.audit = nil
+	if sqlObj.PreparedQuery {
+		return sqlObj.checkPreparedQueryAudit(ctx, datasource)
+	}
+	ac, ok := datasource.SchemaTablesAudit[sqlObj.MainTableName]
+	if !ok || !ac.Audits(sqlObj.QueryType) {
+		return nil
+	}
+	if datasource.DbName == "mysql" {
+		err = errors.New(fmt.Sprint("audit of table ", sqlObj.MainTableName, " is not supported for mysql datasource as it cannot return changed rows"))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	pkCols, err := datasource.GetConflictCols(ctx, sqlObj.MainTableName, "")
+	if err != nil {
+		err = errors.New(fmt.Sprint("audit of table ", sqlObj.MainTableName, " needs a primary key : ", err.Error()))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	audit := &module_model.MutationAudit{Config: ac, PrimaryKeys: pkCols, MatchCols: pkCols}
+	audit.RequestId = logs.FieldValue(ctx, server_handlers.RequestIdKey)
+	if token, tok := vars[module_model.RULEPREFIX_TOKEN].(map[string]interface{}); tok {
+		if userId, uok := token[ac.GetUserIdKey()]; uok && userId != nil {
+			audit.UserId = fmt.Sprint(userId)
+		}
+	}
+	sqlObj.audit = audit
+	return nil
+}
+
+// checkPreparedQueryAudit refuses mutation with my query if the query refers an audited table.
+// rows changed by query of a my query cannot be traced to the table, hence such mutations would escape the audit.
+func (sqlObj *SQLObjectM) checkPreparedQueryAudit(ctx context.Context, datasource *module_model.DataSource) (err error) {
+	for _, tableName := range getSqlTableNames(sqlObj.QueryObject[sqlObj.MainTableName].Query, datasource) {
+		if datasource.SchemaTablesAudit[tableName].Enabled {
+			err = errors.New(fmt.Sprint("mutation of audited table ", tableName, " with query ", sqlObj.MainTableName, " is not supported"))
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// returning returns returning fields of the mutation on the table - audited mutations return all fields of the main table
+func (sqlObj *SQLObjectM) returning(tableName string) (returnDoc bool, returnFields string) {
+	if sqlObj.audit != nil && tableName == sqlObj.MainTableName {
+		return true, " * "
+	}
+	return sqlObj.MutationReturn.ReturnDoc, sqlObj.MutationReturn.ReturnFields
+}
+
+// setAuditReturnFields sets fields asked by the audited mutation - returned rows are not trimmed if all fields are asked
+func (sqlObj *SQLObjectM) setAuditReturnFields() {
+	if sqlObj.audit == nil {
+		return
+	}
+	sqlObj.audit.ReturnFields = nil
+	if sqlObj.MutationReturn.ReturnDoc && strings.TrimSpace(sqlObj.MutationReturn.ReturnFields) != "*" {
+		sqlObj.audit.ReturnFields = strings.Split(sqlObj.MutationReturn.ReturnFields, ",")
+	}
+}
+
+// makeAuditBeforeQuery makes query to fetch rows changed by audited update and upsert before they are changed.
+// update fetches rows matching its where clause and upsert fetches rows matching conflict columns of its documents.
+func (sqlObj *SQLObjectM) makeAuditBeforeQuery(ctx context.Context) (err error) {
+	logs.WithContext(ctx).Debug("makeAuditBeforeQuery - Start")
+	if sqlObj.audit == nil {
+		return nil
+	}
+	qa := &queryArgs{sqlMaker: sqlObj.sqlMaker}
+	switch sqlObj.QueryType {
+	case module_model.QUERY_TYPE_UPDATE:
+		strWhereClause, e := processWhereClause(ctx, sqlObj.WhereClause, "", sqlObj.MainTableName, false, qa)
+		if e != "" {
+			err = errors.New(e)
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+		if strWhereClause != "" {
+			strWhereClause = fmt.Sprint(" where ", strWhereClause)
+		}
+		sqlObj.audit.BeforeQuery = fmt.Sprint("select * from ", sqlObj.MainTableName, strWhereClause)
+	case module_model.QUERY_TYPE_UPSERT:
+		conflictCols, e := sqlObj.datasource.GetConflictCols(ctx, sqlObj.MainTableName, sqlObj.ConflictTarget)
+		if e != nil {
+			return e
+		}
+		sqlObj.audit.MatchCols = conflictCols
+		var conditions []string
+		for _, doc := range sqlObj.auditDocs() {
+			var colConditions []string
+			for _, c := range conflictCols {
+				v, ok := doc[c]
+				if !ok || v == nil {
+					break
+				}
+				colConditions = append(colConditions, fmt.Sprint(c, " = ", qa.bind(ctx, v)))
+			}
+			if len(colConditions) == len(conflictCols) {
+				conditions = append(conditions, fmt.Sprint("(", strings.Join(colConditions, " and "), ")"))
+			}
+		}
+		if len(conditions) > 0 {
+			sqlObj.audit.BeforeQuery = fmt.Sprint("select * from ", sqlObj.MainTableName, " where ", strings.Join(conditions, " or "))
+		}
+	}
+	sqlObj.audit.BeforeArgs = qa.values
+	return nil
+}
+
+// auditDocs returns columns and values of the mutation records of main table as documents
+func (sqlObj *SQLObjectM) auditDocs() (docs []map[string]interface{}) {
+	if len(sqlObj.MutationRecords) == 0 {
+		return nil
+	}
+	for _, mr := range sqlObj.MutationRecords {
+		cols, values := strings.Split(mr.Cols, ","), mr.Values
+		if !sqlObj.NestedDoc {
+			// non nested records are inserted with values in order of columns of first record
+			cols, values = strings.Split(sqlObj.MutationRecords[0].NonNestedCols, ","), mr.NonNestedValues
+		}
+		doc := make(map[string]interface{})
+		for i, c := range cols {
+			if i < len(values) {
+				doc[c] = values[i]
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// postAuditRecords posts audit records of committed mutations to sink url of their audit config.
+// records are posted in background so that the request is not held by the sink.
+// mutations are already committed hence failure to post is only logged.
+func postAuditRecords(ctx context.Context, audits []*module_model.MutationAudit) {
+	logs.WithContext(ctx).Debug("postAuditRecords - Start")
+	for _, audit := range audits {
+		if audit == nil || audit.Config.SinkUrl == "" || len(audit.Records) == 0 {
+			continue
+		}
+		auditPosts.Add(1)
+		go func(audit *module_model.MutationAudit) {
+			defer auditPosts.Done()
+			if err := postAuditSink(ctx, audit.Config.SinkUrl, audit.Records); err != nil {
+				logs.WithContext(ctx).Error(fmt.Sprint("audit records of ", audit.Records[0].TableName, " not posted to sink : ", err.Error()))
+			}
+		}(audit)
+	}
+}
+
+func postAuditSink(ctx context.Context, sinkUrl string, records []module_model.AuditRecord) (err error) {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), auditSinkTimeOut)
+	defer cancel()
+	req, err := http.NewRequestWithContext(sctx, http.MethodPost, sinkUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if requestId := logs.FieldValue(ctx, server_handlers.RequestIdKey); requestId != "" {
+		req.Header.Set(server_handlers.RequestIdKey, requestId)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return errors.New(fmt.Sprint("sink returned status ", resp.StatusCode))
+	}
+	return nil
+}
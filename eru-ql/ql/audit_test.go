@@ -0,0 +1,78 @@
+package ql
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestInitAudit(t *testing.T) {
+	ctx := context.Background()
+	newDataSource := func(dbName string) *module_model.DataSource {
+		return &module_model.DataSource{DbAlias: "db", DbName: dbName, SchemaTables: map[string]map[string]module_model.TableColsMetaData{
+			"public.orders": {"id": {ColName: "id", PrimaryKey: true}},
+			"public.notes":  {"id": {ColName: "id", PrimaryKey: true}},
+		}, SchemaTablesAudit: map[string]module_model.AuditConfig{"public.orders": {Enabled: true}}}
+	}
+	tests := []struct {
+		name      string
+		dbName    string
+		sqlObj    SQLObjectM
+		wantAudit bool
+		wantErr   bool
+	}{
+		{"audited table", "postgres", SQLObjectM{MainTableName: "public.orders", QueryType: "update"}, true, false},
+		{"table not audited", "postgres", SQLObjectM{MainTableName: "public.notes", QueryType: "update"}, false, false},
+		{"mysql", "mysql", SQLObjectM{MainTableName: "public.orders", QueryType: "update"}, false, true},
+		{"my query on audited table", "postgres", SQLObjectM{MainTableName: "close_orders", QueryType: "update", PreparedQuery: true,
+			QueryObject: map[string]QueryObject{"close_orders": {Query: "update orders set status = 'closed'"}}}, false, true},
+		{"my query on table not audited", "postgres", SQLObjectM{MainTableName: "clear_notes", QueryType: "delete", PreparedQuery: true,
+			QueryObject: map[string]QueryObject{"clear_notes": {Query: "delete from notes"}}}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlObj := tt.sqlObj
+			err := sqlObj.initAudit(ctx, newDataSource(tt.dbName), map[string]interface{}{module_model.RULEPREFIX_TOKEN: map[string]interface{}{"sub": "u1"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (sqlObj.audit != nil) != tt.wantAudit {
+				t.Fatalf("audit = %+v, wantAudit %v", sqlObj.audit, tt.wantAudit)
+			}
+			if tt.wantAudit && sqlObj.audit.UserId != "u1" {
+				t.Errorf("user id = %q, want u1", sqlObj.audit.UserId)
+			}
+		})
+	}
+}
+
+func TestPostAuditRecordsInBackground(t *testing.T) {
+	release := make(chan struct{})
+	posted := make(chan struct{}, 1)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		posted <- struct{}{}
+	}))
+	defer sink.Close()
+	audit := &module_model.MutationAudit{Config: module_model.AuditConfig{Enabled: true, SinkUrl: sink.URL}, Records: []module_model.AuditRecord{{TableName: "public.orders"}}}
+	returned := make(chan struct{})
+	go func() {
+		postAuditRecords(context.Background(), []*module_model.MutationAudit{audit, nil})
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("postAuditRecords is blocked by the sink")
+	}
+	close(release)
+	auditPosts.Wait()
+	select {
+	case <-posted:
+	default:
+		t.Error("audit records are expected to be posted to sink")
+	}
+}
@@ -0,0 +1,413 @@
+package ql
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/eru-tech/eru/eru-security-rule/security_rule"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cacheScopeMyQuery = "myquery"
+	cacheScopeTable   = "table"
+)
+
+// CacheStats are counters of result cache of a my query or of a table since the server started
+type CacheStats struct {
+	Scope         string `json:"scope"`
+	Hits          int64  `json:"hits"`
+	Misses        int64  `json:"misses"`
+	Evictions     int64  `json:"evictions"`
+	Invalidations int64  `json:"invalidations"`
+	Entries       int    `json:"entries"`
+	MaxEntries    int    `json:"max_entries"`
+	TTL           int    `json:"ttl"` // in seconds
+}
+
+// cacheScope holds cached results of a my query or of select queries on a table with the least recently used result at the back
+type cacheScope struct {
+	projectId string
+	config    module_model.CacheConfig
+	entries   map[string]*list.Element
+	lru       *list.List
+	stats     CacheStats
+}
+
+type cacheEntry struct {
+	key       string
+	res       []map[string]interface{}
+	tables    []string // tables of the query as projectId:dbAlias:tableName - entry is invalidated if any of them is mutated
+	expiresAt time.Time
+}
+
+// resultCache is in memory of the server - each server of a cluster caches results on its own.
+// generation is incremented on every invalidation so that result read before a mutation is not cached after it.
+var resultCache = struct {
+	sync.Mutex
+	scopes     map[string]*cacheScope
+	generation uint64
+}{scopes: make(map[string]*cacheScope)}
+
+func MyQueryCacheScope(projectId string, queryName string) string {
+	return fmt.Sprint(cacheScopeMyQuery, ":", projectId, ":", queryName)
+}
+
+func TableCacheScope(projectId string, dbAlias string, tableName string) string {
+	return fmt.Sprint(cacheScopeTable, ":", projectId, ":", dbAlias, ":", tableName)
+}
+
+func cacheTableKey(projectId string, dbAlias string, tableName string) string {
+	return strings.ToLower(fmt.Sprint(projectId, ":", dbAlias, ":", tableName))
+}
+
+// makeCacheKey returns hash of the query, its variables and claims of the token which decide rows and values returned to the user.
+// with token claims of cache config, claims referred by security and masking rules of the tables of the query are also part of the key.
+func makeCacheKey(ctx context.Context, cc module_model.CacheConfig, query string, args interface{}, tokenObj interface{}, ruleClaims []string, isPublic bool, outputType string) (key string, err error) {
+	logs.WithContext(ctx).Debug("makeCacheKey - Start")
+	var claims interface{} = tokenObj
+	if len(cc.TokenClaims) > 0 {
+		tokenClaims := make(map[string]interface{})
+		for _, c := range append(append([]string{}, cc.TokenClaims...), ruleClaims...) {
+			tokenClaims[c] = getClaim(tokenObj, c)
+		}
+		claims = tokenClaims
+	}
+	keyBytes, err := json.Marshal([]interface{}{query, args, claims, isPublic, outputType})
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return "", err
+	}
+	hash := sha256.Sum256(keyBytes)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// getRuleClaims returns claims of token referred by select security rule and column masking of the tables - tables are projectId:dbAlias:tableName
+func getRuleClaims(projectId string, datasources map[string]*module_model.DataSource, tables []string) (claims []string) {
+	queried := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		queried[t] = true
+	}
+	for dbAlias, datasource := range datasources {
+		if datasource == nil {
+			continue
+		}
+		for tableName, cols := range datasource.SchemaTables {
+			if !queried[cacheTableKey(projectId, dbAlias, tableName)] {
+				continue
+			}
+			sr := datasource.SchemaTablesSecurity[tableName].Select
+			claims = append(claims, ruleClaims(sr.CustomRule.AND)...)
+			claims = append(claims, ruleClaims(sr.CustomRule.OR)...)
+			for _, col := range cols {
+				if col.ColumnMasking.IsSet() {
+					claims = append(claims, ruleClaims(col.ColumnMasking.CustomRule.AND)...)
+					claims = append(claims, ruleClaims(col.ColumnMasking.CustomRule.OR)...)
+				}
+			}
+		}
+	}
+	sort.Strings(claims)
+	return claims
+}
+
+// ruleClaims returns claims of token referred by variables of the rule conditions
+func ruleClaims(rules []security_rule.CustomRuleDetails) (claims []string) {
+	for _, r := range rules {
+		claims = append(claims, ruleClaims(r.AND)...)
+		claims = append(claims, ruleClaims(r.OR)...)
+		for _, v := range []string{r.Variable1, r.Variable2} {
+			if security_rule.IsVariable(v) {
+				claims = append(claims, strings.SplitN(v, ".", 2)[1])
+			}
+		}
+	}
+	return claims
+}
+
+// getClaim returns value of the claim from the token - nested claims are separated by dot
+func getClaim(tokenObj interface{}, claim string) interface{} {
+	v := tokenObj
+	for _, c := range strings.Split(claim, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[c]
+	}
+	return v
+}
+
+// cacheGeneration returns generation to be passed to putCachedResult for the result about to be read
+func cacheGeneration() uint64 {
+	resultCache.Lock()
+	defer resultCache.Unlock()
+	return resultCache.generation
+}
+
+// getCachedResult returns cached result of the key - cached result is shared and is not to be modified
+func getCachedResult(ctx context.Context, projectId string, scope string, cc module_model.CacheConfig, key string) (res []map[string]interface{}, found bool) {
+	logs.WithContext(ctx).Debug("getCachedResult - Start")
+	resultCache.Lock()
+	defer resultCache.Unlock()
+	cs := getCacheScope(projectId, scope, cc)
+	if e, ok := cs.entries[key]; ok {
+		ce := e.Value.(*cacheEntry)
+		if time.Now().Before(ce.expiresAt) {
+			cs.lru.MoveToFront(e)
+			cs.stats.Hits++
+			return ce.res, true
+		}
+		cs.removeEntry(e)
+	}
+	cs.stats.Misses++
+	return nil, false
+}
+
+// putCachedResult caches the result unless a table was mutated after generation was read
+func putCachedResult(ctx context.Context, projectId string, scope string, cc module_model.CacheConfig, key string, tables []string, res []map[string]interface{}, generation uint64) {
+	logs.WithContext(ctx).Debug("putCachedResult - Start")
+	resultCache.Lock()
+	defer resultCache.Unlock()
+	if resultCache.generation != generation {
+		return
+	}
+	cs := getCacheScope(projectId, scope, cc)
+	if e, ok := cs.entries[key]; ok {
+		cs.removeEntry(e)
+	}
+	for cs.lru.Len() >= cc.GetMaxEntries() {
+		cs.removeEntry(cs.lru.Back())
+		cs.stats.Evictions++
+	}
+	ce := &cacheEntry{key: key, res: res, tables: tables, expiresAt: time.Now().Add(cc.GetTTL())}
+	cs.entries[key] = cs.lru.PushFront(ce)
+}
+
+// getCacheScope returns cache of the scope creating it if not found - caller is to hold the lock of resultCache
+func getCacheScope(projectId string, scope string, cc module_model.CacheConfig) *cacheScope {
+	cs, ok := resultCache.scopes[scope]
+	if !ok {
+		cs = &cacheScope{projectId: projectId, entries: make(map[string]*list.Element), lru: list.New()}
+		cs.stats.Scope = scope
+		resultCache.scopes[scope] = cs
+	}
+	cs.config = cc
+	return cs
+}
+
+func (cs *cacheScope) removeEntry(e *list.Element) {
+	cs.lru.Remove(e)
+	delete(cs.entries, e.Value.(*cacheEntry).key)
+}
+
+// ClearCache removes all cached results of the scope - called when config of my query or table is changed
+func ClearCache(ctx context.Context, scope string) {
+	logs.WithContext(ctx).Debug("ClearCache - Start")
+	resultCache.Lock()
+	defer resultCache.Unlock()
+	resultCache.generation++
+	if cs, ok := resultCache.scopes[scope]; ok {
+		cs.stats.Invalidations += int64(cs.lru.Len())
+		cs.entries = make(map[string]*list.Element)
+		cs.lru.Init()
+	}
+}
+
+// InvalidateCacheTables removes cached results of all queries which read any of the tables
+func InvalidateCacheTables(ctx context.Context, projectId string, dbAlias string, tableNames ...string) {
+	logs.WithContext(ctx).Debug("InvalidateCacheTables - Start")
+	if len(tableNames) == 0 {
+		return
+	}
+	mutated := make(map[string]bool, len(tableNames))
+	for _, t := range tableNames {
+		mutated[cacheTableKey(projectId, dbAlias, t)] = true
+	}
+	resultCache.Lock()
+	defer resultCache.Unlock()
+	resultCache.generation++
+	for _, cs := range resultCache.scopes {
+		if cs.projectId != projectId {
+			continue
+		}
+		for e := cs.lru.Front(); e != nil; {
+			next := e.Next()
+			for _, t := range e.Value.(*cacheEntry).tables {
+				if mutated[t] {
+					cs.removeEntry(e)
+					cs.stats.Invalidations++
+					break
+				}
+			}
+			e = next
+		}
+	}
+}
+
+// GetCacheStats returns stats of result cache of all my queries and tables of the project
+func GetCacheStats(ctx context.Context, projectId string) (stats []CacheStats) {
+	logs.WithContext(ctx).Debug("GetCacheStats - Start")
+	resultCache.Lock()
+	defer resultCache.Unlock()
+	stats = []CacheStats{}
+	for _, cs := range resultCache.scopes {
+		if cs.projectId != projectId {
+			continue
+		}
+		s := cs.stats
+		s.Entries = cs.lru.Len()
+		s.MaxEntries = cs.config.GetMaxEntries()
+		s.TTL = int(cs.config.GetTTL().Seconds())
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Scope < stats[j].Scope })
+	return stats
+}
+
+// getSqlTables returns tables of the datasource found in the sql query as projectId:dbAlias:tableName
+func getSqlTables(projectId string, query string, datasource *module_model.DataSource) (tables []string) {
+	for _, tableName := range getSqlTableNames(query, datasource) {
+		tables = append(tables, cacheTableKey(projectId, datasource.DbAlias, tableName))
+	}
+	return tables
+}
+
+// getSqlTableNames returns tables of the datasource found in the sql query - same as tables matched for column masking
+func getSqlTableNames(query string, datasource *module_model.DataSource) (tableNames []string) {
+	words := sqlWords(query)
+	for tableName := range datasource.SchemaTables {
+		tn := tableName
+		if strings.Contains(tn, ".") {
+			tn = strings.SplitN(tn, ".", 2)[1]
+		}
+		if words[strings.ToLower(tn)] {
+			tableNames = append(tableNames, tableName)
+		}
+	}
+	return tableNames
+}
+
+// ExecuteMyQuery executes my query with values already set by SetQLData.
+// result is returned from cache if cache is enabled for my query and same query was executed with same values and token claims.
+func ExecuteMyQuery(ctx context.Context, projectId string, mq module_model.MyQuery, qlInterface QL, datasources map[string]*module_model.DataSource, s module_store.ModuleStoreI, outputType string, vars map[string]interface{}, tokenObj map[string]interface{}, isPublic bool, streamed bool) (res []map[string]interface{}, err error) {
+	logs.WithContext(ctx).Debug("ExecuteMyQuery - Start")
+	if !mq.Cache.Enabled || streamed {
+		res, _, err = qlInterface.Execute(ctx, projectId, datasources, s, outputType)
+		return res, err
+	}
+	tables, readOnly := getMyQueryTables(ctx, projectId, mq, datasources, s)
+	if !readOnly {
+		res, _, err = qlInterface.Execute(ctx, projectId, datasources, s, outputType)
+		return res, err
+	}
+	scope := MyQueryCacheScope(projectId, mq.QueryName)
+	key, keyErr := makeCacheKey(ctx, mq.Cache, mq.Query, vars, tokenObj, getRuleClaims(projectId, datasources, tables), isPublic, outputType)
+	if keyErr == nil {
+		if cached, ok := getCachedResult(ctx, projectId, scope, mq.Cache, key); ok {
+			return cached, nil
+		}
+	}
+	generation := cacheGeneration()
+	res, _, err = qlInterface.Execute(ctx, projectId, datasources, s, outputType)
+	if err == nil && keyErr == nil {
+		putCachedResult(ctx, projectId, scope, mq.Cache, key, tables, res, generation)
+	}
+	return res, err
+}
+
+// getMyQueryTables returns tables read by my query and false if my query changes data
+func getMyQueryTables(ctx context.Context, projectId string, mq module_model.MyQuery, datasources map[string]*module_model.DataSource, s module_store.ModuleStoreI) (tables []string, readOnly bool) {
+	logs.WithContext(ctx).Debug("getMyQueryTables - Start")
+	if mq.QueryType == "sql" {
+		datasource := datasources[mq.DBAlias]
+		if datasource == nil || !module_store.IsSqlSelect(mq.Query) {
+			return nil, false
+		}
+		return getSqlTables(projectId, mq.Query, datasource), true
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(mq.Query)})})
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, false
+	}
+	for _, docDef := range doc.Definitions {
+		op, ok := docDef.(*ast.OperationDefinition)
+		if !ok || op.Operation != "query" {
+			return nil, false
+		}
+		for _, v := range op.SelectionSet.Selections {
+			field, ok := v.(*ast.Field)
+			if !ok || len(field.Directives) == 0 {
+				continue
+			}
+			datasource := datasources[field.Directives[0].Name.Value]
+			if datasource == nil {
+				continue
+			}
+			tableName := strings.Replace(field.Name.Value, "___", ".", -1)
+			if _, ok := datasource.SchemaTables[tableName]; !ok {
+				// query on another my query reads tables of that my query
+				if wq, wqErr := s.GetMyQuery(ctx, projectId, tableName); wqErr == nil && wq.QueryType == "sql" {
+					tables = append(tables, getSqlTables(projectId, wq.Query, datasource)...)
+				}
+			}
+			tables = append(tables, getFieldTables(projectId, field, datasource)...)
+		}
+	}
+	return tables, true
+}
+
+// getFieldTables returns tables of the datasource queried by the field and its nested fields
+func getFieldTables(projectId string, field *ast.Field, datasource *module_model.DataSource) (tables []string) {
+	tableName := strings.Replace(field.Name.Value, "___", ".", -1)
+	if _, ok := datasource.SchemaTables[tableName]; ok {
+		tables = append(tables, cacheTableKey(projectId, datasource.DbAlias, tableName))
+	}
+	if field.SelectionSet == nil {
+		return tables
+	}
+	for _, v := range field.SelectionSet.Selections {
+		if f, ok := v.(*ast.Field); ok {
+			tables = append(tables, getFieldTables(projectId, f, datasource)...)
+		}
+	}
+	return tables
+}
+
+// getQueryTables returns tables read by select query of a table
+func (sqlObj *SQLObjectQ) getQueryTables(projectId string, datasource *module_model.DataSource) (tables []string) {
+	tables = append(tables, cacheTableKey(projectId, datasource.DbAlias, sqlObj.MainTableName))
+	for _, level := range sqlObj.tables {
+		for _, t := range level {
+			tables = append(tables, cacheTableKey(projectId, datasource.DbAlias, strings.Replace(t.Name, "___", ".", -1)))
+		}
+	}
+	if sqlObj.WithQuery != "" {
+		tables = append(tables, getSqlTables(projectId, sqlObj.WithQuery, datasource)...)
+	}
+	return tables
+}
+
+// getMutationTables returns tables changed by the mutation records and their nested child records
+func getMutationTables(mainTableName string, records []module_model.MutationRecord) (tables []string) {
+	tables = append(tables, mainTableName)
+	for _, mr := range records {
+		for childTableName, childRecords := range mr.ChildRecords {
+			tables = append(tables, getMutationTables(strings.Replace(childTableName, "___", ".", -1), childRecords)...)
+		}
+	}
+	return tables
+}
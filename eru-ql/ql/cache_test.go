@@ -0,0 +1,212 @@
+package ql
+
+import (
+	"context"
+	"fmt"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/eru-tech/eru/eru-security-rule/security_rule"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMakeCacheKeyClaims(t *testing.T) {
+	ctx := context.Background()
+	datasource := &module_model.DataSource{DbAlias: "db", SchemaTables: map[string]map[string]module_model.TableColsMetaData{
+		"public.orders": {"ssn": {ColName: "ssn", ColumnMasking: module_model.ColumnMasking{MaskingType: "full", CustomRule: security_rule.CustomRule{OR: []security_rule.CustomRuleDetails{
+			{Variable1: "token.role", Variable2: "admin", Operator: "eq", DataType: "string"}}}}}},
+		"public.notes": {"id": {ColName: "id"}},
+	}, SchemaTablesSecurity: map[string]module_model.SecurityRules{
+		"public.orders": {Select: security_rule.SecurityRule{RuleType: module_model.RULETYPE_CUSTOM, CustomRule: security_rule.CustomRule{AND: []security_rule.CustomRuleDetails{
+			{AND: []security_rule.CustomRuleDetails{{Variable1: "region", Variable2: "token.org.region", Operator: "eq", DataType: "string"}}}}}}},
+		"public.notes": {Select: security_rule.SecurityRule{RuleType: module_model.RULETYPE_CUSTOM, CustomRule: security_rule.CustomRule{AND: []security_rule.CustomRuleDetails{
+			{Variable1: "owner", Variable2: "token.sub", Operator: "eq", DataType: "string"}}}}},
+	}}
+	datasources := map[string]*module_model.DataSource{"db": datasource}
+	claims := getRuleClaims("p", datasources, []string{cacheTableKey("p", "db", "public.orders")})
+	if want := []string{"org.region", "role"}; !reflect.DeepEqual(claims, want) {
+		t.Fatalf("claims = %v, want %v", claims, want)
+	}
+
+	cc := module_model.CacheConfig{Enabled: true, TokenClaims: []string{"tenant"}}
+	key := func(token map[string]interface{}) string {
+		k, err := makeCacheKey(ctx, cc, "select * from orders", nil, token, claims, false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	base := key(map[string]interface{}{"tenant": "t1", "role": "clerk", "org": map[string]interface{}{"region": "east"}, "sub": "u1"})
+	tests := []struct {
+		name    string
+		token   map[string]interface{}
+		sameKey bool
+	}{
+		{"claim not referred", map[string]interface{}{"tenant": "t1", "role": "clerk", "org": map[string]interface{}{"region": "east"}, "sub": "u2"}, true},
+		{"claim of cache config", map[string]interface{}{"tenant": "t2", "role": "clerk", "org": map[string]interface{}{"region": "east"}, "sub": "u1"}, false},
+		{"claim of masking rule", map[string]interface{}{"tenant": "t1", "role": "admin", "org": map[string]interface{}{"region": "east"}, "sub": "u1"}, false},
+		{"claim of security rule", map[string]interface{}{"tenant": "t1", "role": "clerk", "org": map[string]interface{}{"region": "west"}, "sub": "u1"}, false},
+	}
+	for _, tt := range tests {
+		if got := key(tt.token) == base; got != tt.sameKey {
+			t.Errorf("%s : same key = %v, want %v", tt.name, got, tt.sameKey)
+		}
+	}
+}
+
+// testCacheScope returns a scope of the test which is not shared with other tests
+func testCacheScope(t *testing.T) string {
+	return TableCacheScope("cache-test", "db", t.Name())
+}
+
+func TestResultCache(t *testing.T) {
+	ctx := context.Background()
+	cc := module_model.CacheConfig{Enabled: true, MaxEntries: 2}
+	scope := testCacheScope(t)
+	table := cacheTableKey("cache-test", "db", "public.orders")
+	put := func(key string) {
+		putCachedResult(ctx, "cache-test", scope, cc, key, []string{table}, []map[string]interface{}{{"key": key}}, cacheGeneration())
+	}
+	stats := func() CacheStats {
+		for _, s := range GetCacheStats(ctx, "cache-test") {
+			if s.Scope == scope {
+				return s
+			}
+		}
+		return CacheStats{}
+	}
+
+	if _, found := getCachedResult(ctx, "cache-test", scope, cc, "a"); found {
+		t.Fatal("result is not expected before it is cached")
+	}
+	put("a")
+	if res, found := getCachedResult(ctx, "cache-test", scope, cc, "a"); !found || res[0]["key"] != "a" {
+		t.Fatalf("cached result is expected : %v", res)
+	}
+
+	// least recently used result is evicted when cache is full
+	put("b")
+	getCachedResult(ctx, "cache-test", scope, cc, "a")
+	put("c")
+	if _, found := getCachedResult(ctx, "cache-test", scope, cc, "b"); found {
+		t.Error("least recently used result is expected to be evicted")
+	}
+	if _, found := getCachedResult(ctx, "cache-test", scope, cc, "a"); !found {
+		t.Error("recently used result is not expected to be evicted")
+	}
+
+	// expired result is not returned
+	resultCache.Lock()
+	resultCache.scopes[scope].entries["c"].Value.(*cacheEntry).expiresAt = time.Now().Add(-time.Second)
+	resultCache.Unlock()
+	if _, found := getCachedResult(ctx, "cache-test", scope, cc, "c"); found {
+		t.Error("expired result is not expected to be returned")
+	}
+
+	// result read before a mutation is not cached after the mutation
+	generation := cacheGeneration()
+	InvalidateCacheTables(ctx, "cache-test", "db", "public.orders")
+	putCachedResult(ctx, "cache-test", scope, cc, "d", []string{table}, []map[string]interface{}{{"key": "d"}}, generation)
+	if _, found := getCachedResult(ctx, "cache-test", scope, cc, "d"); found {
+		t.Error("result read before mutation is not expected to be cached")
+	}
+	if _, found := getCachedResult(ctx, "cache-test", scope, cc, "a"); found {
+		t.Error("result of mutated table is expected to be invalidated")
+	}
+
+	want := CacheStats{Scope: scope, Hits: 3, Misses: 5, Evictions: 1, Invalidations: 1, MaxEntries: 2, TTL: module_model.DEFAULT_CACHE_TTL}
+	if got := stats(); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestTableCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbType: "sql", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db"), DefaultSchema: "main"}}
+	if err := ds.GetSqlMaker("sqlite").CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+	if _, err := datasource.Con.Exec("create table items (id integer primary key, name varchar(10)); insert into items (id, name) values (1, 'a')"); err != nil {
+		t.Fatal(err)
+	}
+	datasource.SchemaTables = map[string]map[string]module_model.TableColsMetaData{"main.items": {
+		"id": {TblName: "items", ColName: "id", OwnDataType: "Integer", PrimaryKey: true}, "name": {TblName: "items", ColName: "name", OwnDataType: "Varchar"},
+	}}
+	datasource.SchemaTablesCache = map[string]module_model.CacheConfig{"main.items": {Enabled: true}}
+	datasources := map[string]*module_model.DataSource{"db": datasource}
+	s := &module_store.ModuleFileStore{}
+	s.Projects = map[string]*module_model.Project{"cache-test-e2e": {ProjectId: "cache-test-e2e", DataSources: datasources}}
+	defer ClearCache(ctx, TableCacheScope("cache-test-e2e", "db", "main.items"))
+
+	execute := func(query string) []map[string]interface{} {
+		gqd := GraphQLData{}
+		gqd.Query = query
+		gqd.FinalVariables = map[string]interface{}{module_model.RULEPREFIX_TOKEN: map[string]interface{}{}}
+		gqd.ExecuteFlag = true
+		res, _, err := gqd.Execute(ctx, "cache-test-e2e", datasources, s, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	count := func() int {
+		res := execute(`{ main___items @db { id } }`)
+		return len(res[0]["main___items"].([]interface{}))
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("rows = %d, want 1", n)
+	}
+	// rows added outside eru are not seen till cached result expires or is invalidated
+	if _, err := datasource.Con.Exec("insert into items (id, name) values (2, 'b')"); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("cached result is expected : rows = %d", n)
+	}
+	execute(`mutation { insert_main___items(docs: {id: 3, name: "c"}) @db { returning { id } } }`)
+	if n := count(); n != 3 {
+		t.Fatalf("cached result is expected to be invalidated by mutation : rows = %d", n)
+	}
+	if _, err := ImportFile(ctx, "cache-test-e2e", "db", "main.items", "items.csv", []byte("id,name\n4,d\n"), module_model.BulkImport{}, nil, s); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(); n != 4 {
+		t.Fatalf("cached result is expected to be invalidated by import : rows = %d", n)
+	}
+}
+
+func TestResultCacheConcurrentInvalidation(t *testing.T) {
+	ctx := context.Background()
+	cc := module_model.CacheConfig{Enabled: true, MaxEntries: 10}
+	scope := testCacheScope(t)
+	table := cacheTableKey("cache-test", "db", "public.items")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprint("k", i%5)
+			generation := cacheGeneration()
+			if _, found := getCachedResult(ctx, "cache-test", scope, cc, key); !found {
+				putCachedResult(ctx, "cache-test", scope, cc, key, []string{table}, []map[string]interface{}{{"key": key}}, generation)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			InvalidateCacheTables(ctx, "cache-test", "db", "public.items")
+		}()
+	}
+	wg.Wait()
+	// no result read before the last invalidation is left in cache
+	InvalidateCacheTables(ctx, "cache-test", "db", "public.items")
+	for i := 0; i < 5; i++ {
+		if _, found := getCachedResult(ctx, "cache-test", scope, cc, fmt.Sprint("k", i)); found {
+			t.Errorf("result k%d is expected to be invalidated", i)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package ql
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-utils"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	cursorColPrefix = "__cursor"
+	PageInfoKey     = "pageInfo"
+	countColAlias   = "total_count"
+)
+
+type sortCol struct {
+	col     string
+	desc    bool
+	notNull bool
+}
+
+// primaryKeyCols returns primary key columns of the table in column order - table name without schema is looked up in default schema
+func primaryKeyCols(datasource *module_model.DataSource, tableName string) (pkCols []string) {
+	tableCols, ok := datasource.SchemaTables[tableName]
+	if !ok && !strings.Contains(tableName, ".") {
+		tableCols = datasource.SchemaTables[fmt.Sprint(datasource.DbConfig.DefaultSchema, ".", tableName)]
+	}
+	var pks []module_model.TableColsMetaData
+	for colName, c := range tableCols {
+		if c.PrimaryKey {
+			if c.ColName == "" {
+				c.ColName = colName
+			}
+			pks = append(pks, c)
+		}
+	}
+	sort.Slice(pks, func(i, j int) bool {
+		return pks[i].ColPosition < pks[j].ColPosition
+	})
+	for _, c := range pks {
+		pkCols = append(pkCols, c.ColName)
+	}
+	return
+}
+
+// getSortCols returns columns of sort clause in sort order followed by columns which make the order unique -
+// primary key of the main table, or group by columns for aggregate queries, so that no row is repeated or skipped across pages.
+// sort by column position is not supported for cursor pagination as cursor holds values of the sort columns.
+func (sqlObj *SQLObjectQ) getSortCols(ctx context.Context, val interface{}) (sortCols []sortCol, err error) {
+	logs.WithContext(ctx).Debug("getSortCols - Start")
+	var sortStrs []interface{}
+	switch reflect.TypeOf(val).Kind() {
+	case reflect.Slice:
+		s := reflect.ValueOf(val)
+		for i := 0; i < s.Len(); i++ {
+			sortStrs = append(sortStrs, s.Index(i).Interface())
+		}
+	default:
+		sortStrs = append(sortStrs, val)
+	}
+	var uniqueCols []string
+	if sqlObj.HasAggregate {
+		uniqueCols = sqlObj.Columns.GroupClause
+	} else {
+		if len(sqlObj.pkCols) == 0 {
+			err = errors.New(fmt.Sprint("cursor pagination needs primary key of table ", sqlObj.MainTableName))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+		for _, pk := range sqlObj.pkCols {
+			uniqueCols = append(uniqueCols, fmt.Sprint(sqlObj.MainTableName, ".", pk))
+		}
+	}
+	isUniqueCol := make(map[string]bool)
+	for _, uc := range uniqueCols {
+		isUniqueCol[uc] = true
+	}
+	sorted := make(map[string]bool)
+	for _, ss := range sortStrs {
+		str, ok := ss.(string)
+		if !ok {
+			err = errors.New(fmt.Sprint("sort by column position ", ss, " is not supported with cursor pagination"))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+		sc := sortCol{}
+		if strings.HasPrefix(str, "-") {
+			sc.desc = true
+			str = strings.Replace(str, "-", "", 1)
+		}
+		str = eru_utils.ReplaceUnderscoresWithDots(str)
+		if !strings.Contains(str, ".") {
+			str = fmt.Sprint(sqlObj.MainTableName, ".", str)
+		}
+		if err = checkSqlIdentifier(ctx, str); err != nil {
+			return nil, err
+		}
+		sc.col = str
+		sc.notNull = !sqlObj.HasAggregate && isUniqueCol[str]
+		sortCols = append(sortCols, sc)
+		sorted[str] = true
+	}
+	for _, uc := range uniqueCols {
+		if !sorted[uc] {
+			sortCols = append(sortCols, sortCol{col: uc, notNull: !sqlObj.HasAggregate})
+			sorted[uc] = true
+		}
+	}
+	return sortCols, nil
+}
+
+// makeCursorClause returns condition which selects rows after the cursor row in sort order, or before it if before is true
+// e.g. for sort a, -b after cursor (1, 2) it returns ( ( a > 1 or a is null ) or ( a = 1 and b < 2 ) ).
+// null is taken as larger than any value, same as the order of makeCursorSortClause.
+func makeCursorClause(ctx context.Context, sortCols []sortCol, values []interface{}, before bool, qa *queryArgs) string {
+	logs.WithContext(ctx).Debug("makeCursorClause - Start")
+	var orClauses []string
+	for i, sc := range sortCols {
+		var andClauses []string
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				andClauses = append(andClauses, fmt.Sprint(sortCols[j].col, " is null"))
+			} else {
+				andClauses = append(andClauses, fmt.Sprint(sortCols[j].col, " = ", qa.bind(ctx, values[j])))
+			}
+		}
+		if sc.desc != before {
+			// values less than cursor value - null is less than nothing but every value is less than null
+			if values[i] == nil {
+				andClauses = append(andClauses, fmt.Sprint(sc.col, " is not null"))
+			} else {
+				andClauses = append(andClauses, fmt.Sprint(sc.col, " < ", qa.bind(ctx, values[i])))
+			}
+		} else {
+			// values more than cursor value - nothing is more than null and null is more than every value
+			if values[i] == nil {
+				andClauses = append(andClauses, "1 = 0")
+			} else if sc.notNull {
+				andClauses = append(andClauses, fmt.Sprint(sc.col, " > ", qa.bind(ctx, values[i])))
+			} else {
+				andClauses = append(andClauses, fmt.Sprint("( ", sc.col, " > ", qa.bind(ctx, values[i]), " or ", sc.col, " is null )"))
+			}
+		}
+		orClauses = append(orClauses, fmt.Sprint("( ", strings.Join(andClauses, " and "), " )"))
+	}
+	return fmt.Sprint("( ", strings.Join(orClauses, " or "), " )")
+}
+
+// makeCursorSortClause returns order by clause of sort columns - order is reversed to fetch the page before the cursor
+// nulls are sorted last in ascending order on every db, as default placement of nulls differs across dbs
+func makeCursorSortClause(sortCols []sortCol, before bool) string {
+	var temp []string
+	for _, sc := range sortCols {
+		dir := ""
+		if sc.desc != before {
+			dir = " desc"
+		}
+		if !sc.notNull {
+			temp = append(temp, fmt.Sprint("case when ", sc.col, " is null then 1 else 0 end", dir))
+		}
+		temp = append(temp, fmt.Sprint(sc.col, dir))
+	}
+	return fmt.Sprint(" order by ", strings.Join(temp, " , "))
+}
+
+func encodeCursor(ctx context.Context, values []interface{}) (string, error) {
+	logs.WithContext(ctx).Debug("encodeCursor - Start")
+	for i, v := range values {
+		if b, ok := v.([]byte); ok {
+			values[i] = string(b)
+		}
+	}
+	cursorBytes, err := json.Marshal(values)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorBytes), nil
+}
+
+// decodeCursor returns values of sort columns held in cursor - numbers are kept as json.Number to avoid losing precision of big integers
+func decodeCursor(ctx context.Context, cursor string, colCount int) (values []interface{}, err error) {
+	logs.WithContext(ctx).Debug("decodeCursor - Start")
+	cursorBytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		d := json.NewDecoder(bytes.NewReader(cursorBytes))
+		d.UseNumber()
+		err = d.Decode(&values)
+	}
+	if err == nil && len(values) != colCount {
+		err = errors.New(fmt.Sprint("cursor has ", len(values), " values but sort clause has ", colCount, " columns"))
+	}
+	if err != nil {
+		err = errors.New(fmt.Sprint("invalid cursor : ", err.Error()))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	return values, nil
+}
+
+// setPageInfo adds cursors of first and last record of the page, and total count if asked, to the result.
+// cursor columns fetched with the query are removed from the records.
+func (sqlObj *SQLObjectQ) setPageInfo(ctx context.Context, result map[string]interface{}, totalCount interface{}) (err error) {
+	logs.WithContext(ctx).Debug("setPageInfo - Start")
+	if result == nil {
+		return nil
+	}
+	pageInfo := make(map[string]interface{})
+	pageInfo["startCursor"] = ""
+	pageInfo["endCursor"] = ""
+	if sqlObj.WithCount {
+		pageInfo["totalCount"] = totalCount
+	}
+	man := strings.Replace(sqlObj.MainAliasName, ".", "___", 1)
+	records, _ := result[man].([]interface{})
+	if sqlObj.Before != nil {
+		// page before the cursor is fetched in reverse order
+		for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+			records[i], records[j] = records[j], records[i]
+		}
+	}
+	var cursors []string
+	for _, r := range records {
+		record, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var values []interface{}
+		for i := 0; i < sqlObj.cursorColCount; i++ {
+			cn := fmt.Sprint(cursorColPrefix, i)
+			values = append(values, record[cn])
+			delete(record, cn)
+		}
+		if len(values) > 0 {
+			cursor, e := encodeCursor(ctx, values)
+			if e != nil {
+				return e
+			}
+			cursors = append(cursors, cursor)
+		}
+	}
+	if len(cursors) > 0 {
+		pageInfo["startCursor"] = cursors[0]
+		pageInfo["endCursor"] = cursors[len(cursors)-1]
+	}
+	result[PageInfoKey] = pageInfo
+	return nil
+}
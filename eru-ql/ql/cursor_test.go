@@ -0,0 +1,117 @@
+package ql
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetSortColsTiebreaker(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		sort      interface{}
+		pkCols    []string
+		aggregate bool
+		groupCols []string
+		want      []sortCol
+		wantErr   bool
+	}{
+		{"primary key appended", "-name", []string{"id"}, false, nil, []sortCol{{"t.name", true, false}, {"t.id", false, true}}, false},
+		{"primary key already sorted", []interface{}{"-id", "name"}, []string{"id"}, false, nil, []sortCol{{"t.id", true, true}, {"t.name", false, false}}, false},
+		{"composite primary key", "name", []string{"a", "b"}, false, nil, []sortCol{{"t.name", false, false}, {"t.a", false, true}, {"t.b", false, true}}, false},
+		{"group columns of aggregate", "-total", nil, true, []string{"t.city"}, []sortCol{{"t.total", true, false}, {"t.city", false, false}}, false},
+		{"no primary key", "name", nil, false, nil, nil, true},
+		{"sort by position", []interface{}{1}, []string{"id"}, false, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlObj := SQLObjectQ{MainTableName: "t", pkCols: tt.pkCols, HasAggregate: tt.aggregate}
+			sqlObj.Columns.GroupClause = tt.groupCols
+			got, err := sqlObj.getSortCols(ctx, tt.sort)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sort cols = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeCursorClause(t *testing.T) {
+	ctx := context.Background()
+	sortCols := []sortCol{{"t.name", false, false}, {"t.id", false, true}}
+	tests := []struct {
+		name     string
+		values   []interface{}
+		before   bool
+		wantSql  string
+		wantArgs []interface{}
+	}{
+		{"after value", []interface{}{"a", 1}, false, "( ( ( t.name > $1 or t.name is null ) ) or ( t.name = $2 and t.id > $3 ) )", []interface{}{"a", "a", 1}},
+		{"after null", []interface{}{nil, 1}, false, "( ( 1 = 0 ) or ( t.name is null and t.id > $1 ) )", []interface{}{1}},
+		{"before value", []interface{}{"a", 1}, true, "( ( t.name < $1 ) or ( t.name = $2 and t.id < $3 ) )", []interface{}{"a", "a", 1}},
+		{"before null", []interface{}{nil, 1}, true, "( ( t.name is not null ) or ( t.name is null and t.id < $1 ) )", []interface{}{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qa := &queryArgs{sqlMaker: new(ds.PostgresSqlMaker)}
+			got := makeCursorClause(ctx, sortCols, tt.values, tt.before, qa)
+			if strings.Join(strings.Fields(got), " ") != tt.wantSql {
+				t.Errorf("cursor clause = %s, want %s", got, tt.wantSql)
+			}
+			if !reflect.DeepEqual(qa.values, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", qa.values, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestMakeCursorSortClause(t *testing.T) {
+	sortCols := []sortCol{{"t.name", true, false}, {"t.id", false, true}}
+	want := " order by case when t.name is null then 1 else 0 end desc , t.name desc , t.id"
+	if got := makeCursorSortClause(sortCols, false); got != want {
+		t.Errorf("sort clause = %s, want %s", got, want)
+	}
+	want = " order by case when t.name is null then 1 else 0 end , t.name , t.id desc"
+	if got := makeCursorSortClause(sortCols, true); got != want {
+		t.Errorf("reversed sort clause = %s, want %s", got, want)
+	}
+}
+
+func TestMakeQueryCursor(t *testing.T) {
+	ctx := context.Background()
+	cursor, _ := encodeCursor(ctx, []interface{}{"a", 1})
+	sqlObj := SQLObjectQ{MainTableName: "t", SortClause: "name", After: cursor, ReturnCursor: true, pkCols: []string{"id"}}
+	sqlObj.Columns.ColNames = []string{"t.name"}
+	if err := sqlObj.MakeQuery(ctx, new(ds.PostgresSqlMaker), false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sqlObj.DBQuery, "t.id > $3") || !strings.Contains(sqlObj.DBQuery, "end , t.name , t.id") || sqlObj.cursorColCount != 2 {
+		t.Errorf("unexpected cursor query %s", sqlObj.DBQuery)
+	}
+
+	// cursor columns added to select change the distinct rows
+	sqlObj = SQLObjectQ{MainTableName: "t", SortClause: "name", After: "", DistinctResults: true, pkCols: []string{"id"}}
+	sqlObj.Columns.ColNames = []string{"t.name"}
+	if err := sqlObj.MakeQuery(ctx, new(ds.PostgresSqlMaker), false); err == nil {
+		t.Error("cursor pagination with distinct results is expected to fail")
+	}
+}
+
+func TestPrimaryKeyCols(t *testing.T) {
+	datasource := &module_model.DataSource{SchemaTables: map[string]map[string]module_model.TableColsMetaData{
+		"public.t": {"b": {ColName: "b", PrimaryKey: true, ColPosition: 2}, "a": {ColName: "a", PrimaryKey: true, ColPosition: 1}, "c": {ColName: "c", ColPosition: 3}},
+	}}
+	datasource.DbConfig.DefaultSchema = "public"
+	if got := primaryKeyCols(datasource, "t"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("primary key cols = %v", got)
+	}
+	if got := primaryKeyCols(datasource, "x"); got != nil {
+		t.Errorf("primary key cols of unknown table = %v", got)
+	}
+}
@@ -79,14 +79,31 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 	logs.WithContext(ctx).Debug("Execute of GraphQl - Start")
 	singleTxn := false
 	errFound := false
+	var pendingAudits []*module_model.MutationAudit // audits of mutations of single txn posted to sink after commit
+	mutatedTables := make(map[string][]string)      // dbAlias is the key - cached results of these tables are invalidated after mutations are committed
+	defer func() {
+		for dbAlias, tables := range mutatedTables {
+			InvalidateCacheTables(ctx, projectId, dbAlias, tables...)
+		}
+	}()
 	doc, err := gqd.parseGraphQL(ctx)
 	if err != nil {
 		logs.WithContext(ctx).Error(err.Error())
 		return nil, nil, err
 	}
+	err = gqd.validateGraphQL(ctx, projectId, datasources, s, doc)
+	if err != nil {
+		return nil, nil, err
+	}
 	//doc := ast.Node(d).(*ast.Document)
 	for _, docDef := range doc.Definitions {
 		op := ast.Node(docDef).(*ast.OperationDefinition)
+		// rows of only one query can be streamed as they are written to the response as they are read
+		if gqd.stream != nil && (op.Operation != "query" || len(op.SelectionSet.Selections) != 1 || len(doc.Definitions) != 1) {
+			err = errors.New("streamed output is supported only for a single query")
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, nil, err
+		}
 		graphQLs := make([]ds.SqlMakerI, len(op.SelectionSet.Selections))
 		//graphQLs := make([]ds.SqlMakerI, len(op.SelectionSet.Selections))
 		var result map[string]interface{}
@@ -98,6 +115,7 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 		}
 		var returnAliasStrings []string
 		var mainAliasNames []string
+		var queryErr error // first error of the queries - queries after it are not executed
 		breakForLoop := false
 		for i, v := range op.SelectionSet.Selections {
 			if breakForLoop {
@@ -128,8 +146,24 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 				graphQLs[i] = ds.GetSqlMaker(datasource.DbName)
 			}
 
+			mongoMaker, isMongo := graphQLs[i].(*ds.MongoSqlMaker)
 			switch op.Operation {
 			case "query":
+				if isMongo {
+					if gqd.stream != nil {
+						return nil, nil, errors.New(fmt.Sprint("streamed output is not supported for ", datasource.DbName, " datasource ", dbAlias))
+					}
+					var mainAliasName string
+					result, queryObj, mainAliasName, err = gqd.executeMongoQuery(ctx, v.(*ast.Field), projectId, dbAlias, datasource, mongoMaker, s)
+					mainAliasNames = append(mainAliasNames, mainAliasName)
+					if err != nil {
+						errFound = true
+					}
+					if result != nil {
+						res = append(res, result)
+					}
+					break
+				}
 				sqlObj := SQLObjectQ{}
 				sqlObj.ProjectId = projectId
 				sqlObj.FinalVariables = gqd.QLData.FinalVariables
@@ -141,11 +175,7 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 					sqlObj.MainAliasName = field.Alias.Value
 				}
 
-				if sqlObj.OverwriteDoc == nil {
-					sqlObj.OverwriteDoc = make(map[string]map[string]interface{})
-				}
-
-				sqlObj.OverwriteDoc[sqlObj.MainTableName], err = gqd.setOverwriteDoc(ctx, projectId, dbAlias, sqlObj.MainTableName, s, op.Operation, module_model.QUERY_TYPE_SELECT)
+				sqlObj.TransformRule, err = gqd.getTransformRule(ctx, projectId, dbAlias, sqlObj.MainTableName, s, op.Operation, module_model.QUERY_TYPE_SELECT)
 				if err != nil {
 					errMsg = err.Error()
 					errFound = true
@@ -153,6 +183,14 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 
 				err = gqd.getSqlForQuery(ctx, projectId, datasources, sqlObj.MainTableName, s, nil, gqd.IsPublic)
 				sqlObj.WithQuery = gqd.QueryObject[sqlObj.MainTableName].Query
+				sqlObj.WithQueryArgs = gqd.QueryObject[sqlObj.MainTableName].Args
+				var maskErr error
+				if sqlObj.WithQuery != "" && gqd.ExecuteFlag {
+					// columns of my query are masked with masking of same named columns of tables used in it
+					var withQueryMasks map[string]module_model.ColumnMasking
+					withQueryMasks, maskErr = getSqlColumnMasks(ctx, sqlObj.WithQuery, datasource, gqd.FinalVariables)
+					sqlObj.masks = columnMasks{strings.Replace(sqlObj.MainTableName, ".", "___", 1): withQueryMasks}
+				}
 
 				if err != nil {
 					logs.WithContext(ctx).Error(err.Error())
@@ -167,12 +205,53 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 					errFound = true
 				}
 
+				// cursors are returned only with json output as csv and excel have no place for page info
+				sqlObj.ReturnCursor = gqd.ExecuteFlag && gqd.OutputType != eru_writes.OutputTypeCsv && gqd.OutputType != eru_writes.OutputTypeExcel && gqd.stream == nil
 				err = sqlObj.ProcessGraphQL(ctx, v, datasource, graphQLs[i], gqd.FinalVariables, s, gqd.ExecuteFlag) //TODO to handle if err recd.
+				if err == nil && maskErr != nil {
+					// query is not executed as its masked columns cannot be masked in result
+					err = maskErr
+				}
+				if err == nil && gqd.ExecuteFlag {
+					// query which nests or joins more tables or asks for more rows than allowed is not executed
+					err = sqlObj.checkQueryGuards(ctx, datasource)
+				}
+				if err != nil {
+					errMsg = err.Error()
+					errFound = true
+				}
 
 				queryObj.Query = sqlObj.DBQuery
+				queryObj.Args = sqlObj.DBQueryArgs
 				queryObj.Cols = strings.Join(sqlObj.Columns.ColNames, " , ")
 				mainAliasNames = append(mainAliasNames, sqlObj.MainAliasName)
-				if gqd.ExecuteFlag {
+				if gqd.ExecuteFlag && gqd.stream != nil {
+					queryObjs = append(queryObjs, queryObj)
+					if errFound {
+						return nil, queryObjs, errors.New(errMsg)
+					}
+					onRow := gqd.stream.rowFunc(ctx, sqlObj.masks.flatten(), sqlObj.TransformRule, gqd.FinalVariables)
+					err = executeStream(ctx, graphQLs[i], datasource, sqlObj.DBQuery, sqlObj.DBQueryArgs, !singleTxn, gqd.TimeOut, gqd.stream, onRow)
+					return nil, queryObjs, err
+				}
+				// results of select queries on a table are cached only outside single transaction as it may read its own uncommitted changes
+				var cacheKey string
+				var cacheGen uint64
+				var cached []map[string]interface{}
+				cacheHit := false
+				cacheConfig := datasource.SchemaTablesCache[sqlObj.MainTableName]
+				cacheScope := TableCacheScope(projectId, dbAlias, sqlObj.MainTableName)
+				if gqd.ExecuteFlag && cacheConfig.Enabled && !singleTxn && !errFound {
+					ruleClaims := getRuleClaims(projectId, map[string]*module_model.DataSource{dbAlias: datasource}, sqlObj.getQueryTables(projectId, datasource))
+					cacheKey, err = makeCacheKey(ctx, cacheConfig, sqlObj.DBQuery, sqlObj.DBQueryArgs, gqd.FinalVariables[module_model.RULEPREFIX_TOKEN], ruleClaims, gqd.IsPublic, gqd.OutputType)
+					if err == nil {
+						cacheGen = cacheGeneration()
+						cached, cacheHit = getCachedResult(ctx, projectId, cacheScope, cacheConfig, cacheKey)
+					}
+				}
+				if cacheHit {
+					result = cached[0]
+				} else if gqd.ExecuteFlag && !errFound {
 					qrm := module_model.QueryResultMaker{}
 					qrm.MainTableName = sqlObj.MainTableName
 					qrm.MainAliasName = sqlObj.MainAliasName
@@ -180,36 +259,91 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 					qrm.QueryLevel = sqlObj.queryLevel
 					qrm.QuerySubLevel = sqlObj.querySubLevel
 					qrm.SQLQuery = sqlObj.DBQuery
+					qrm.SQLArgs = sqlObj.DBQueryArgs
 
+					// queries are routed to read replicas unless they are part of single transaction
 					if gqd.OutputType == eru_writes.OutputTypeCsv || gqd.OutputType == eru_writes.OutputTypeExcel {
-						result, err = graphQLs[i].ExecuteQueryForCsv(ctx, qrm.SQLQuery, datasource, mainAliasNames[i])
+						result, err = executeRead(ctx, graphQLs[i], datasource, qrm.SQLQuery, qrm.SQLArgs, !singleTxn, gqd.TimeOut, func(ctx context.Context, d *module_model.DataSource) (map[string]interface{}, error) {
+							return graphQLs[i].ExecuteQueryForCsv(ctx, qrm.SQLQuery, d, mainAliasNames[i], qrm.SQLArgs...)
+						})
 						if err != nil {
 							logs.WithContext(ctx).Error(err.Error())
 						}
 					} else {
-						result, err = graphQLs[i].ExecuteQuery(ctx, datasource, qrm)
+						result, err = executeRead(ctx, graphQLs[i], datasource, qrm.SQLQuery, qrm.SQLArgs, !singleTxn, gqd.TimeOut, func(ctx context.Context, d *module_model.DataSource) (map[string]interface{}, error) {
+							return graphQLs[i].ExecuteQuery(ctx, d, qrm)
+						})
+					}
+					if err == nil && len(sqlObj.masks) > 0 {
+						if gqd.OutputType == eru_writes.OutputTypeCsv || gqd.OutputType == eru_writes.OutputTypeExcel {
+							maskCsvRows(ctx, result[mainAliasNames[i]], sqlObj.masks.flatten())
+						} else {
+							sqlObj.masks.maskRecords(ctx, result[strings.Replace(sqlObj.MainAliasName, ".", "___", 1)], strings.Replace(sqlObj.MainTableName, ".", "___", 1))
+						}
+					}
+					// output is transformed after masking so that transform rules never see unmasked values
+					if err == nil {
+						if gqd.OutputType == eru_writes.OutputTypeCsv || gqd.OutputType == eru_writes.OutputTypeExcel {
+							result[mainAliasNames[i]], err = transformCsvRows(ctx, sqlObj.TransformRule, result[mainAliasNames[i]], gqd.FinalVariables)
+						} else {
+							err = transformRecords(ctx, sqlObj.TransformRule, sqlObj.transforms, result[strings.Replace(sqlObj.MainAliasName, ".", "___", 1)], gqd.FinalVariables)
+						}
+					}
+					if err == nil && sqlObj.ReturnCursor && (sqlObj.After != nil || sqlObj.Before != nil || sqlObj.WithCount) {
+						var totalCount interface{}
+						if sqlObj.WithCount {
+							totalCount, err = gqd.getTotalCount(ctx, graphQLs[i], datasource, !singleTxn, sqlObj)
+						}
+						if err == nil {
+							err = sqlObj.setPageInfo(ctx, result, totalCount)
+						}
 					}
 					if err != nil {
 						logs.WithContext(ctx).Error(err.Error())
 						errMsg = err.Error()
 						errFound = true
+					} else if cacheKey != "" {
+						putCachedResult(ctx, projectId, cacheScope, cacheConfig, cacheKey, sqlObj.getQueryTables(projectId, datasource), []map[string]interface{}{result}, cacheGen)
 					}
 				}
 
 				if result != nil {
 					res = append(res, result)
 				}
+				if errFound && queryErr == nil {
+					queryErr = errors.New(errMsg)
+				}
 
 			case "mutation":
+				if isMongo {
+					var resObj map[string]interface{}
+					var mainAliasName, returnAlias string
+					mctx, cancel, timeOutErr := withQueryTimeOut(ctx, datasource, gqd.TimeOut)
+					resObj, queryObj, mainAliasName, returnAlias, err = gqd.executeMongoMutation(mctx, v.(*ast.Field), projectId, dbAlias, datasource, mongoMaker, s, singleTxn)
+					if gqd.ExecuteFlag {
+						if tempStr := strings.SplitN(v.(*ast.Field).Name.Value, "_", 2); len(tempStr) > 1 {
+							mutatedTables[dbAlias] = append(mutatedTables[dbAlias], strings.Replace(tempStr[1], "___", ".", -1))
+						}
+					}
+					err = timeOutErr(err)
+					cancel()
+					if err != nil {
+						errFound = true
+					}
+					mainAliasNames = append(mainAliasNames, mainAliasName)
+					returnAliasStrings = append(returnAliasStrings, returnAlias)
+					res = append(res, resObj)
+					break
+				}
 				sqlObj := SQLObjectM{}
 				field := v.(*ast.Field)
 				tempStr := strings.SplitN(field.Name.Value, "_", 2)
 				sqlObj.QueryType = tempStr[0]
 				sqlObj.MainTableName = strings.Replace(tempStr[1], "___", ".", -1)
-				if sqlObj.OverwriteDoc == nil {
-					sqlObj.OverwriteDoc = make(map[string]map[string]interface{})
+				if sqlObj.TransformRules == nil {
+					sqlObj.TransformRules = make(map[string]module_model.TransformRule)
 				}
-				sqlObj.OverwriteDoc[sqlObj.MainTableName], err = gqd.setOverwriteDoc(ctx, projectId, dbAlias, sqlObj.MainTableName, s, op.Operation, sqlObj.QueryType)
+				sqlObj.TransformRules[sqlObj.MainTableName], err = gqd.getTransformRule(ctx, projectId, dbAlias, sqlObj.MainTableName, s, op.Operation, sqlObj.QueryType)
 				if err != nil {
 					errMsg = err.Error()
 					logs.WithContext(ctx).Error(err.Error())
@@ -238,10 +372,19 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 				sqlObj.openTxn = openTxn
 				sqlObj.closeTxn = closeTxn
 				sqlObj.QueryObject = gqd.QueryObject
-				err = sqlObj.ProcessMutationGraphQL(ctx, v, gqd.FinalVariables, datasource)
+				if auditErr := sqlObj.initAudit(ctx, datasource, gqd.FinalVariables); auditErr != nil {
+					errMsg = auditErr.Error()
+					errFound = true
+				}
+				err = sqlObj.ProcessMutationGraphQL(ctx, v, gqd.FinalVariables, datasource, graphQLs[i])
 				if err != nil {
 					logs.WithContext(ctx).Error(err.Error())
 				}
+				sqlObj.setAuditReturnFields()
+				if auditErr := sqlObj.makeAuditBeforeQuery(ctx); auditErr != nil {
+					errMsg = auditErr.Error()
+					errFound = true
+				}
 				//TODO connection close on this error - to handle the same
 				mainAliasNames = append(mainAliasNames, sqlObj.MainAliasName)
 				//TODO to loop on MutationRecords and pass query
@@ -259,13 +402,24 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 					mrm.IsNested = sqlObj.NestedDoc
 					mrm.QueryType = sqlObj.QueryType
 					mrm.DBQuery = sqlObj.DBQuery
+					mrm.DBQueryArgs = sqlObj.DBQueryArgs
 					mrm.PreparedQuery = sqlObj.PreparedQuery
-					results, err = graphQLs[i].ExecuteMutationQuery(ctx, datasource, graphQLs[i], mrm)
+					mrm.Audit = sqlObj.audit
+					mctx, cancel, timeOutErr := withQueryTimeOut(ctx, datasource, gqd.TimeOut)
+					results, err = graphQLs[i].ExecuteMutationQuery(mctx, datasource, graphQLs[i], mrm)
+					mutatedTables[dbAlias] = append(mutatedTables[dbAlias], getMutationTables(sqlObj.MainTableName, sqlObj.MutationRecords)...)
+					err = timeOutErr(err)
+					cancel()
 					if err != nil {
 						errFound = true
 						logs.WithContext(ctx).Error(err.Error())
 						errMsg = err.Error()
 						// no need to return here - error is returned as part of result - if asked in the query.
+					} else if singleTxn {
+						// audit records are posted to sink only after all mutations of single txn are committed
+						pendingAudits = append(pendingAudits, sqlObj.audit)
+					} else {
+						postAuditRecords(ctx, []*module_model.MutationAudit{sqlObj.audit})
 					}
 				} else if errFound {
 					rollBackErr := graphQLs[i].RollbackQuery(ctx)
@@ -306,10 +460,30 @@ func (gqd *GraphQLData) Execute(ctx context.Context, projectId string, datasourc
 			}
 			return res, queryObjs, errors.New("ERROR")
 		}
+		postAuditRecords(ctx, pendingAudits)
+		if queryErr != nil {
+			err = queryErr
+		}
 	}
 	return res, queryObjs, err
 }
 
+// getTotalCount executes count query of the sql object and returns count of rows matching the query across all pages
+func (gqd *GraphQLData) getTotalCount(ctx context.Context, sqlMaker ds.SqlMakerI, datasource *module_model.DataSource, readOnly bool, sqlObj SQLObjectQ) (totalCount interface{}, err error) {
+	logs.WithContext(ctx).Debug("getTotalCount - Start")
+	countResult, err := executeRead(ctx, sqlMaker, datasource, sqlObj.CountQuery, sqlObj.CountQueryArgs, readOnly, gqd.TimeOut, func(ctx context.Context, d *module_model.DataSource) (map[string]interface{}, error) {
+		return sqlMaker.ExecutePreparedQuery(ctx, sqlObj.CountQuery, d, sqlObj.CountQueryArgs...)
+	})
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	if rows, ok := countResult["Results"].([]map[string]interface{}); ok && len(rows) > 0 {
+		totalCount = rows[0][countColAlias]
+	}
+	return totalCount, nil
+}
+
 // parseAstValue returns an interface that can be casted to string
 func ParseAstValue(ctx context.Context, value ast.Value, vars map[string]interface{}) (interface{}, error) {
 	logs.WithContext(ctx).Debug("ParseAstValue - Start")
@@ -539,39 +713,28 @@ func adjustObjectKey(key string) string {
 	return key
 }
 
-func (gqd *GraphQLData) setOverwriteDoc(ctx context.Context, projectId string, dbAlias string, tableName string, s module_store.ModuleStoreI, op string, queryType string) (overwriteDoc map[string]interface{}, err error) {
-	logs.WithContext(ctx).Debug("setOverwriteDoc - Start")
+// getTransformRule returns transform output rule of the table for query and transform input rule for mutation if it applies on the query type
+func (gqd *GraphQLData) getTransformRule(ctx context.Context, projectId string, dbAlias string, tableName string, s module_store.ModuleStoreI, op string, queryType string) (transformRule module_model.TransformRule, err error) {
+	logs.WithContext(ctx).Debug("getTransformRule - Start")
 	tr, err := s.GetTableTransformation(ctx, projectId, dbAlias, tableName)
 	if err != nil {
 		err = errors.New(fmt.Sprint("error from GetTableTransformation = ", err.Error()))
 		logs.WithContext(ctx).Error(err.Error())
-		return nil, err
+		return transformRule, err
 	}
-	overwriteDoc = make(map[string]interface{})
 	if op == "query" {
-		overwriteDoc, err = gqd.ProcessTransformRule(ctx, tr.TransformOutput)
+		return tr.TransformOutput, nil
 	} else if op == "mutation" {
-		processTransformRule := false
 		for _, v := range tr.TransformInput.ApplyOn {
 			if v == queryType {
-				processTransformRule = true
-				break
+				return tr.TransformInput, nil
 			}
 		}
-		if processTransformRule {
-			overwriteDoc, err = gqd.ProcessTransformRule(ctx, tr.TransformInput)
-		}
-	} else {
-		err = errors.New(fmt.Sprint("Invalid Operation : ", op))
-		logs.WithContext(ctx).Error(err.Error())
-	}
-
-	if err != nil {
-		err = errors.New(fmt.Sprint("TransformRule failed : ", err.Error()))
-		logs.WithContext(ctx).Error(err.Error())
-		return nil, err
+		return transformRule, nil
 	}
-	return
+	err = errors.New(fmt.Sprint("Invalid Operation : ", op))
+	logs.WithContext(ctx).Error(err.Error())
+	return transformRule, err
 }
 
 func getTableSecurityRule(ctx context.Context, projectId string, dbAlias string, tableName string, s module_store.ModuleStoreI, op string, vars map[string]interface{}) (ruleOutput string, err error) {
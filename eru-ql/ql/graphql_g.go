@@ -13,7 +13,6 @@ import (
 	"github.com/graphql-go/graphql/language/kinds"
 	"reflect"
 	"sort"
-	"strconv"
 	"strings"
 )
 
@@ -36,9 +35,22 @@ type SQLObjectQ struct {
 	queryLevel      int
 	querySubLevel   []int
 	DBQuery         string
-	OverwriteDoc    map[string]map[string]interface{} `json:"-"`
-	SecurityClause  map[string]string                 `json:"-"`
-	WithQuery       string                            `json:"-"`
+	DBQueryArgs     []interface{}
+	TransformRule   module_model.TransformRule `json:"-"`
+	SecurityClause  map[string]string          `json:"-"`
+	WithQuery       string                     `json:"-"`
+	WithQueryArgs   []interface{}              `json:"-"`
+	After           interface{}                `json:"-"`
+	Before          interface{}                `json:"-"`
+	WithCount       bool                       `json:"-"`
+	ReturnCursor    bool                       `json:"-"`
+	CountQuery      string                     `json:"-"`
+	CountQueryArgs  []interface{}              `json:"-"`
+	cursorColCount  int
+	pkCols          []string
+	masks           columnMasks
+	transforms      tableTransforms
+	rowLimit        int
 }
 
 type SQLCols struct {
@@ -102,23 +114,71 @@ func (sqlObj *SQLObjectQ) ProcessGraphQL(ctx context.Context, sel ast.Selection,
 			}
 			//v, e := ParseAstValue(ff.Value, vars)
 			sqlObj.Skip = v.(int)
+		case "after", "before": // blank cursor fetches the first page
+			if v == nil {
+				v = ""
+			}
+			if reflect.TypeOf(v).Kind() != reflect.String {
+				err = errors.New(fmt.Sprint("Non String value received - ", ff.Name.Value, " clause need cursor string value"))
+				logs.WithContext(ctx).Error(err.Error())
+				return err
+			}
+			if ff.Name.Value == "after" {
+				sqlObj.After = v
+			} else {
+				sqlObj.Before = v
+			}
 		default:
 		}
 	}
+	if sqlObj.After != nil && sqlObj.Before != nil {
+		err = errors.New("after and before clauses cannot be used together")
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	for _, d := range field.Directives[1:] {
+		if d.Name.Value == "withCount" {
+			sqlObj.WithCount = true
+		}
+	}
 	sqlCols := SQLCols{}
 	if field.SelectionSet == nil {
 		var tmpSelSet []ast.Selection
 		sqlCols, _ = sqlObj.processColumnList(ctx, tmpSelSet, sqlObj.MainTableName, vars, 0, 0, datasource, s)
 		sqlCols.ColWithAlias = append(sqlCols.ColWithAlias, " * ")
+		if tableCols, ok := datasource.SchemaTables[sqlObj.MainTableName]; ok {
+			for colName := range tableCols {
+				sqlObj.addColumnMask(ctx, datasource, sqlObj.MainTableName, "", colName, strings.Replace(sqlObj.MainTableName, ".", "___", 1), colName)
+			}
+		}
 	} else {
 		sqlCols, _ = sqlObj.processColumnList(ctx, field.SelectionSet.Selections, sqlObj.MainTableName, vars, 0, 0, datasource, s)
 	}
 	sqlObj.Columns = sqlCols
+	sqlObj.rowLimit = datasource.DbConfig.OtherDbConfig.DefaultLimit()
+	sqlObj.pkCols = primaryKeyCols(datasource, sqlObj.MainTableName)
 	err = sqlObj.MakeQuery(ctx, sqlMaker, withColAlias)
 	logs.WithContext(ctx).Info(fmt.Sprint("query  : ", sqlObj.DBQuery))
 	return err
 }
 
+// checkQueryGuards returns error if the query nests or joins more tables or asks for more rows than allowed for the datasource
+func (sqlObj *SQLObjectQ) checkQueryGuards(ctx context.Context, datasource *module_model.DataSource) (err error) {
+	logs.WithContext(ctx).Debug("checkQueryGuards - Start")
+	odc := datasource.DbConfig.OtherDbConfig
+	if odc.MaxDepth > 0 && sqlObj.queryLevel > odc.MaxDepth {
+		err = errors.New(fmt.Sprint("query nests tables ", sqlObj.queryLevel, " levels deep which is more than max depth ", odc.MaxDepth, " allowed for datasource ", datasource.DbAlias))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	if odc.MaxJoins > 0 && len(sqlObj.JoinClause) > odc.MaxJoins {
+		err = errors.New(fmt.Sprint("query joins ", len(sqlObj.JoinClause), " tables which is more than max joins ", odc.MaxJoins, " allowed for datasource ", datasource.DbAlias))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	return checkLimit(ctx, datasource, sqlObj.Limit)
+}
+
 func (sqlObj *SQLObjectQ) processColumnList(ctx context.Context, sel []ast.Selection, tableName string, vars map[string]interface{}, level int, sublevel int, datasource *module_model.DataSource, s module_store.ModuleStoreI) (sqlCols SQLCols, err string) {
 	logs.WithContext(ctx).Debug("processColumnList - Start")
 	if sqlObj.queryLevel < level {
@@ -147,6 +207,7 @@ func (sqlObj *SQLObjectQ) processColumnList(ctx context.Context, sel []ast.Selec
 	for _, va := range sel {
 		joinFound := false
 		colProcessed := false
+		isCalc := false
 		field := va.(*ast.Field)
 		temp1 := strings.Split(field.Name.Value, "___")
 		var temp2 []string
@@ -235,6 +296,7 @@ func (sqlObj *SQLObjectQ) processColumnList(ctx context.Context, sel []ast.Selec
 				}
 				//TODO to exit if error
 				//val = fmt.Sprint("'", v.(string), "'") //TODO to handle float value as variable value
+				isCalc = true
 				actualType := reflect.TypeOf(v).String()
 				if actualType == "string" {
 					//val = fmt.Sprint("'", v.(string), "'") //TODO commented this as formulas stopped working
@@ -253,6 +315,12 @@ func (sqlObj *SQLObjectQ) processColumnList(ctx context.Context, sel []ast.Selec
 			//var tc string
 			tiq.Nested = true
 			sqlObj.tables[level][sublevel] = tiq
+			if sqlObj.transforms == nil {
+				sqlObj.transforms = make(tableTransforms)
+			}
+			if e := sqlObj.transforms.addTransform(ctx, s, sqlObj.ProjectId, datasource.DbAlias, colTableName, strings.Replace(colTableName, ".", "___", 1)); e != nil {
+				return SQLCols{}, e.Error()
+			}
 			sqlChildCols := SQLCols{}
 			sqlChildCols, err = sqlObj.processColumnList(ctx, field.SelectionSet.Selections, colTableName, vars, level+1, mySublevel, datasource, s)
 			sqlCols.ColNames = append(sqlCols.ColNames, sqlChildCols.ColNames...)
@@ -273,6 +341,13 @@ func (sqlObj *SQLObjectQ) processColumnList(ctx context.Context, sel []ast.Selec
 			d := field.Directives[0] // do not support multiple directives for fields - thus picking up first one - rest if provided will be ignored
 			switch d.Name.Value {
 			case "sum", "count", "avg", "max", "min":
+				if d.Name.Value == "max" || d.Name.Value == "min" {
+					if isCalc {
+						sqlObj.addCalcColumnMask(ctx, datasource, tableName, colTableName, val, tiq.Name, cName)
+					} else {
+						sqlObj.addColumnMask(ctx, datasource, tableName, colTableName, colName, tiq.Name, cName)
+					}
+				}
 				//tempArray[i] = fmt.Sprint(d.Name.Value, "(", val, ") ", alias)
 				sqlCols.ColWithAlias = append(sqlCols.ColWithAlias, fmt.Sprint(d.Name.Value, "(", val, ") ", alias))
 				sqlCols.ColNames = append(sqlCols.ColNames, cName)
@@ -295,6 +370,11 @@ func (sqlObj *SQLObjectQ) processColumnList(ctx context.Context, sel []ast.Selec
 			sqlCols.ColWithAlias = append(sqlCols.ColWithAlias, fmt.Sprint(val, alias))
 			sqlCols.GroupClause = append(sqlCols.GroupClause, val)
 			sqlCols.ColNames = append(sqlCols.ColNames, cName)
+			if isCalc {
+				sqlObj.addCalcColumnMask(ctx, datasource, tableName, colTableName, val, tiq.Name, cName)
+			} else {
+				sqlObj.addColumnMask(ctx, datasource, tableName, colTableName, colName, tiq.Name, cName)
+			}
 		}
 		if !joinFound && colTableName != "" && colTableName != sqlObj.MainTableName {
 			logs.WithContext(ctx).Info(fmt.Sprint("fetch joins for tables ", tableName, " and ", colTableName))
@@ -334,17 +414,76 @@ func (sqlObj *SQLObjectQ) processColumnList(ctx context.Context, sel []ast.Selec
 	return sqlCols, err
 }
 
-func processWhereClause(ctx context.Context, val interface{}, parentKey string, mainTableName string, isJoinClause bool) (whereClause string, err string) { //, gqr *graphQLRead
+// addColumnMask adds masking of the column, if any, against its name in result
+func (sqlObj *SQLObjectQ) addColumnMask(ctx context.Context, datasource *module_model.DataSource, tableName string, colTableName string, colName string, resultTable string, resultCol string) {
+	if datasource == nil || colName == "" {
+		return
+	}
+	if colTableName != "" {
+		tableName = colTableName
+	}
+	if sqlObj.masks == nil {
+		sqlObj.masks = make(columnMasks)
+	}
+	sqlObj.masks.addMask(ctx, datasource, tableName, colName, resultTable, resultCol, sqlObj.FinalVariables)
+}
+
+// addCalcColumnMask masks calculated column in result if its expression refers a masked column - with masking of that column.
+// unqualified columns of the expression are looked up in the table of the field and the main table.
+func (sqlObj *SQLObjectQ) addCalcColumnMask(ctx context.Context, datasource *module_model.DataSource, tableName string, colTableName string, calc string, resultTable string, resultCol string) {
+	for _, word := range sqlQualifiedWordRegex.FindAllString(calc, -1) {
+		if i := strings.LastIndex(word, "."); i >= 0 {
+			sqlObj.addColumnMask(ctx, datasource, word[:i], "", word[i+1:], resultTable, resultCol)
+			continue
+		}
+		for _, tn := range []string{sqlObj.MainTableName, tableName, colTableName} {
+			sqlObj.addColumnMask(ctx, datasource, tn, "", word, resultTable, resultCol)
+		}
+	}
+}
+
+// processWhereClause returns where clause with a placeholder for every value received from user - values are bound to qa
+// column references (FIELD_ prefix and, in join clause, values with a dot) are added to the clause only if they are valid column names
+func processWhereClause(ctx context.Context, val interface{}, parentKey string, mainTableName string, isJoinClause bool, qa *queryArgs) (whereClause string, err string) { //, gqr *graphQLRead
 	logs.WithContext(ctx).Debug("processWhereClause - Start")
 
 	if val != nil {
+		constKey := ""
+		isConstKey := false
 		if strings.HasPrefix(parentKey, "CONST_") {
-			parentKey = fmt.Sprint("'", strings.Replace(parentKey, "CONST_", "", 1), "'")
+			constKey = strings.Replace(parentKey, "CONST_", "", 1)
+			isConstKey = true
 		} else if strings.HasPrefix(parentKey, "FIELD_") {
 			parentKey = fmt.Sprint(strings.Replace(parentKey, "FIELD_", "", 1))
 		} else if !(strings.Contains(parentKey, ".")) {
 			parentKey = fmt.Sprint(mainTableName, ".", parentKey)
 		}
+		// const key is bound each time it is used as placeholders are positional for some databases
+		key := func() (string, string) {
+			if isConstKey {
+				return qa.bind(ctx, constKey), ""
+			}
+			if idErr := checkSqlIdentifier(ctx, parentKey); idErr != nil {
+				return "", idErr.Error()
+			}
+			return parentKey, ""
+		}
+		// value returns column reference as is and placeholder for everything else
+		value := func(v interface{}) (string, string) {
+			if str, ok := v.(string); ok {
+				if strings.HasPrefix(str, "FIELD_") {
+					col := strings.Replace(str, "FIELD_", "", 1)
+					if idErr := checkSqlIdentifier(ctx, col); idErr != nil {
+						return "", idErr.Error()
+					}
+					return col, ""
+				}
+				if isJoinClause && strings.Contains(str, ".") && sqlIdentifierRegex.MatchString(str) {
+					return str, ""
+				}
+			}
+			return qa.bind(ctx, v), ""
+		}
 		switch reflect.TypeOf(val).Kind() {
 		case reflect.Map:
 			var tempArray []string
@@ -352,13 +491,6 @@ func processWhereClause(ctx context.Context, val interface{}, parentKey string,
 			for _, v := range reflect.ValueOf(val).MapKeys() {
 				newVal := reflect.ValueOf(val).MapIndex(v).Interface()
 				if newVal != nil {
-					var valPrefix, valSuffix = "", ""
-					if reflect.TypeOf(newVal).Kind().String() == "string" {
-						if !strings.Contains(newVal.(string), ".") {
-							valPrefix = "'"
-							valSuffix = "'"
-						}
-					}
 					if v.String() == "$or" || v.String() == "or" {
 						if reflect.TypeOf(newVal).Kind().String() != "slice" {
 							errStr := "Error : or clause has single element"
@@ -368,7 +500,7 @@ func processWhereClause(ctx context.Context, val interface{}, parentKey string,
 						s := reflect.ValueOf(newVal)
 						innerTempArray := make([]string, s.Len())
 						for ii := 0; ii < s.Len(); ii++ {
-							innerTempArray[ii], err = processWhereClause(ctx, s.Index(ii).Interface(), v.String(), mainTableName, isJoinClause)
+							innerTempArray[ii], err = processWhereClause(ctx, s.Index(ii).Interface(), v.String(), mainTableName, isJoinClause, qa)
 							if err != "" {
 								return "", err
 							}
@@ -402,63 +534,65 @@ func processWhereClause(ctx context.Context, val interface{}, parentKey string,
 						}
 						switch v.String() {
 						case "$gte", "$lte", "$gt", "$lt", "$eq", "$ne":
-							valTmp := reflect.ValueOf(newVal).String()
-							if strings.HasPrefix(valTmp, "FIELD_") {
-								valTmp = strings.Replace(valTmp, "FIELD_", "", -1)
-								valPrefix = ""
-								valSuffix = ""
+							k, kErr := key()
+							if kErr != "" {
+								return "", kErr
 							}
-							tempArray = append(tempArray, fmt.Sprint(parentKey, op, valPrefix, valTmp, valSuffix))
+							vs, vErr := value(newVal)
+							if vErr != "" {
+								return "", vErr
+							}
+							tempArray = append(tempArray, fmt.Sprint(k, op, vs))
 						case "$like":
-							tempArray = append(tempArray, fmt.Sprint(parentKey, op, valPrefix, "%", reflect.ValueOf(newVal), "%", valSuffix))
+							k, kErr := key()
+							if kErr != "" {
+								return "", kErr
+							}
+							tempArray = append(tempArray, fmt.Sprint(k, op, qa.bind(ctx, fmt.Sprint("%", newVal, "%"))))
 						case "$btw":
 							btwClause, ok := reflect.ValueOf(newVal).Interface().(map[string]interface{})
 							if !ok {
-								logs.WithContext(ctx).Warn("between clause is not a map")
+								errStr := "Error : between clause is not a map"
+								logs.WithContext(ctx).Error(errStr)
+								return "", errStr
 							}
-							preFix := "'"
-							//checking only from value to determine with values recevied are int/float to avoid adding single quote in sql
-							_, Interr := strconv.Atoi(btwClause["from"].(string))
-							if Interr == nil {
-								preFix = ""
+							k, kErr := key()
+							if kErr != "" {
+								return "", kErr
 							}
-							if _, flErr := strconv.ParseFloat(btwClause["from"].(string), 64); flErr == nil {
-								preFix = ""
-							}
-							btwClauseStr := fmt.Sprint(preFix, btwClause["from"], preFix, " and ", preFix, btwClause["to"], preFix)
-							tempArray = append(tempArray, fmt.Sprint(parentKey, op, btwClauseStr))
+							from := qa.bind(ctx, btwClause["from"])
+							to := qa.bind(ctx, btwClause["to"])
+							tempArray = append(tempArray, fmt.Sprint(k, op, from, " and ", to))
 						case "$null":
+							k, kErr := key()
+							if kErr != "" {
+								return "", kErr
+							}
 							nullValue := fmt.Sprint(reflect.ValueOf(newVal))
 							if nullValue == "true" {
-								tempArray = append(tempArray, fmt.Sprint(parentKey, " IS NULL "))
+								tempArray = append(tempArray, fmt.Sprint(k, " IS NULL "))
 							} else {
-								tempArray = append(tempArray, fmt.Sprint(parentKey, " IS NOT NULL "))
+								tempArray = append(tempArray, fmt.Sprint(k, " IS NOT NULL "))
 							}
 						case "$in", "$nin": //TODO to pass json variable aaray and check if the replaced array is passed as single string or string of values to sql
 							switch reflect.TypeOf(newVal).Kind() {
-							case reflect.String:
-								s := reflect.ValueOf(newVal)
-								if strings.HasPrefix(s.String(), "$") {
-
-								}
 							case reflect.Slice:
 								s := reflect.ValueOf(newVal)
+								k, kErr := key()
+								if kErr != "" {
+									return "", kErr
+								}
 								temp := make([]string, s.Len())
 								for i := 0; i < s.Len(); i++ {
-									ss := s.Index(i).Interface()
-									if reflect.TypeOf(ss).Kind().String() == "string" {
-										temp[i] = fmt.Sprint("'", ss, "'")
-									} else {
-										temp[i] = fmt.Sprint(ss)
-									}
+									temp[i] = qa.bind(ctx, s.Index(i).Interface())
 								}
-								tempArray = append(tempArray, fmt.Sprint(parentKey, op, "(", strings.Join(temp, " , "), ")"))
+								tempArray = append(tempArray, fmt.Sprint(k, op, "(", strings.Join(temp, " , "), ")"))
 							default:
 								logs.WithContext(ctx).Warn(fmt.Sprint("skipping $in and $nin clause as it needs array as a value but recevied ", newVal))
 							}
 						default:
 							str := ""
-							str, err = processWhereClause(ctx, newVal, eru_utils.ReplaceUnderscoresWithDots(v.String()), mainTableName, isJoinClause)
+							str, err = processWhereClause(ctx, newVal, eru_utils.ReplaceUnderscoresWithDots(v.String()), mainTableName, isJoinClause, qa)
 							if str == "" {
 								logs.WithContext(ctx).Warn(fmt.Sprint("skipping whereclause for ", newVal, " as there is no value provided by user  : ", str))
 							} else {
@@ -478,23 +612,16 @@ func processWhereClause(ctx context.Context, val interface{}, parentKey string,
 				return "", ""
 			}
 
-		case reflect.String:
-			var newVal, valPrefix, valSuffix = "", "", ""
-			//TODO due to below statement - 2022-07-27T18:30:00.000Z date in filter is failing if passed in this format
-			//parse for date
-			if !strings.Contains(reflect.ValueOf(val).String(), ".") || !isJoinClause {
-				valPrefix = "'"
-				valSuffix = "'"
-			}
-			newVal = reflect.ValueOf(val).String()
-			if strings.HasPrefix(newVal, "FIELD_") {
-				valPrefix = ""
-				valSuffix = ""
-				newVal = strings.Replace(newVal, "FIELD_", "", -1)
-			}
-			return fmt.Sprint(parentKey, " = ", valPrefix, newVal, valSuffix), ""
-		case reflect.Int, reflect.Float32, reflect.Float64:
-			return fmt.Sprint(parentKey, " = ", reflect.ValueOf(val)), ""
+		case reflect.String, reflect.Int, reflect.Float32, reflect.Float64:
+			k, kErr := key()
+			if kErr != "" {
+				return "", kErr
+			}
+			vs, vErr := value(val)
+			if vErr != "" {
+				return "", vErr
+			}
+			return fmt.Sprint(k, " = ", vs), ""
 		default:
 			return "", ""
 		}
@@ -502,7 +629,7 @@ func processWhereClause(ctx context.Context, val interface{}, parentKey string,
 	return "", ""
 }
 
-func (sqlObj *SQLObjectQ) processSortClause(ctx context.Context, val interface{}) (sortClause string) {
+func (sqlObj *SQLObjectQ) processSortClause(ctx context.Context, val interface{}) (sortClause string, err error) {
 	logs.WithContext(ctx).Debug("processSortClause - Start")
 	if val != nil {
 		isDesc := ""
@@ -527,14 +654,16 @@ func (sqlObj *SQLObjectQ) processSortClause(ctx context.Context, val interface{}
 						isDesc = " desc"
 						ss = strings.Replace(ss, "-", "", 1)
 					}
-					if strings.Contains(ss, ".") {
-						temp[i] = ss + isDesc
-					} else {
-						temp[i] = fmt.Sprintf("%s%s%s%s", sqlObj.MainTableName, ".", ss, isDesc)
+					if !strings.Contains(ss, ".") {
+						ss = fmt.Sprintf("%s%s%s", sqlObj.MainTableName, ".", ss)
 					}
+					if err = checkSqlIdentifier(ctx, ss); err != nil {
+						return "", err
+					}
+					temp[i] = ss + isDesc
 				}
 			}
-			return fmt.Sprint(" order by ", strings.Join(temp, " , "))
+			return fmt.Sprint(" order by ", strings.Join(temp, " , ")), nil
 		case reflect.String:
 			s := fmt.Sprintf("%s", reflect.ValueOf(val))
 			if strings.HasPrefix(s, "-") {
@@ -542,44 +671,51 @@ func (sqlObj *SQLObjectQ) processSortClause(ctx context.Context, val interface{}
 				s = strings.Replace(s, "-", "", 1)
 			}
 			if strings.Contains(eru_utils.ReplaceUnderscoresWithDots(s), ".") {
-				return fmt.Sprint(" order by ", eru_utils.ReplaceUnderscoresWithDots(s), isDesc)
+				s = eru_utils.ReplaceUnderscoresWithDots(s)
 			} else {
-				return fmt.Sprint(" order by ", sqlObj.MainTableName, ".", s, isDesc)
+				s = fmt.Sprint(sqlObj.MainTableName, ".", s)
+			}
+			if err = checkSqlIdentifier(ctx, s); err != nil {
+				return "", err
 			}
+			return fmt.Sprint(" order by ", s, isDesc), nil
 		case reflect.Int:
 			s := reflect.ValueOf(val).Int()
 			if s < 0 {
 				isDesc = " desc"
 				s = s * -1
 			}
-			return fmt.Sprint(" order by ", s, isDesc)
+			return fmt.Sprint(" order by ", s, isDesc), nil
 		case reflect.Float64:
 			s := reflect.ValueOf(val).Float()
 			if s < 0 {
 				isDesc = " desc"
 				s = s * -1
 			}
-			return fmt.Sprint(" order by ", s, isDesc)
+			return fmt.Sprint(" order by ", s, isDesc), nil
 		default:
 		}
 	}
-	return ""
+	return "", nil
 }
-func (sqlObj *SQLObjectQ) processJoins(ctx context.Context, val []*OrderedMap) (strJoinClause string) {
+func (sqlObj *SQLObjectQ) processJoins(ctx context.Context, val []*OrderedMap, qa *queryArgs) (strJoinClause string, err error) {
 	logs.WithContext(ctx).Debug("processJoins - Start")
 
 	sort.Sort(MapSorter(val))
 
 	for _, obj := range val {
 		for tableName, v := range obj.Obj {
+			if err = checkSqlIdentifier(ctx, tableName); err != nil {
+				return "", err
+			}
 			joinType := "LEFT" //default join value TODO schema joins has an option to define join type
 			onClause := ""
 			switch reflect.TypeOf(v).Kind() {
 			case reflect.Map:
 				for _, vv := range reflect.ValueOf(v).MapKeys() { //TODO remove reflect usage
 					if vv.String() == "joinType" {
-						jt, err := reflect.ValueOf(v).MapIndex(vv).Interface().(string)
-						if !err {
+						jt, ok := reflect.ValueOf(v).MapIndex(vv).Interface().(string)
+						if !ok {
 							logs.WithContext(ctx).Warn("joinType value is not a string")
 						}
 						switch jt {
@@ -589,7 +725,10 @@ func (sqlObj *SQLObjectQ) processJoins(ctx context.Context, val []*OrderedMap) (
 							logs.WithContext(ctx).Warn("valid values for joinType are LEFT RIGHT and INNER ")
 						}
 					} else if vv.String() == "on" {
-						oc, _ := processWhereClause(ctx, reflect.ValueOf(v).MapIndex(vv).Interface(), "", sqlObj.MainTableName, true)
+						oc, ocErr := processWhereClause(ctx, reflect.ValueOf(v).MapIndex(vv).Interface(), "", sqlObj.MainTableName, true, qa)
+						if ocErr != "" {
+							return "", errors.New(ocErr)
+						}
 						onClause = oc
 					}
 				}
@@ -599,7 +738,7 @@ func (sqlObj *SQLObjectQ) processJoins(ctx context.Context, val []*OrderedMap) (
 			}
 		}
 	}
-	return strJoinClause
+	return strJoinClause, nil
 }
 
 func (sqlObj *SQLObjectQ) MakeQuery(ctx context.Context, sqlMaker ds.SqlMakerI, withColAlias bool) (err error) {
@@ -612,10 +751,16 @@ func (sqlObj *SQLObjectQ) MakeQuery(ctx context.Context, sqlMaker ds.SqlMakerI,
 	} else {
 		strColums = strings.Join(sqlObj.Columns.ColNames, " , ")
 	}
-	strJoinClause := sqlObj.processJoins(ctx, sqlObj.JoinClause)
-	strWhereClause, e := processWhereClause(ctx, sqlObj.WhereClause, "", sqlObj.MainTableName, false)
+	// args are bound in the order their placeholders appear in query - with query, then joins and then where clause
+	qa := &queryArgs{sqlMaker: sqlMaker}
+	qa.values = append(qa.values, sqlObj.WithQueryArgs...)
+	strJoinClause, err := sqlObj.processJoins(ctx, sqlObj.JoinClause, qa)
+	if err != nil {
+		return err
+	}
+	strWhereClause, e := processWhereClause(ctx, sqlObj.WhereClause, "", sqlObj.MainTableName, false, qa)
 	if e != "" {
-		err = errors.New(e)
+		return errors.New(e)
 	}
 
 	strAnd := ""
@@ -637,10 +782,7 @@ func (sqlObj *SQLObjectQ) MakeQuery(ctx context.Context, sqlMaker ds.SqlMakerI,
 		strWhereClause = fmt.Sprint(" where ", strWhereClause)
 	}
 
-	strSortClause := sqlObj.processSortClause(ctx, sqlObj.SortClause)
-	if sqlObj.HasAggregate && len(sqlObj.Columns.GroupClause) > 0 {
-		strGroupClause = fmt.Sprint(" group by ", strings.Join(sqlObj.Columns.GroupClause, " , "))
-	}
+	groupClause := append([]string{}, sqlObj.Columns.GroupClause...)
 	if sqlObj.DistinctResults {
 		strDistinct = " distinct "
 	}
@@ -650,8 +792,71 @@ func (sqlObj *SQLObjectQ) MakeQuery(ctx context.Context, sqlMaker ds.SqlMakerI,
 	if sqlObj.WithQuery != "" {
 		fromTable = fmt.Sprint("( ", sqlObj.WithQuery, " ) ", sqlObj.MainTableName)
 	}
+	if sqlObj.WithCount {
+		// count is made before adding cursor clause so that it is the count of rows across all pages
+		if sqlObj.HasAggregate && len(groupClause) > 0 {
+			strGroupClause = fmt.Sprint(" group by ", strings.Join(groupClause, " , "))
+		}
+		sqlObj.CountQuery = fmt.Sprint("select count(*) as ", countColAlias, " from ( select ", strDistinct, strColums, " from ", fromTable, " ", strJoinClause, " ", strWhereClause, " ", strGroupClause, " ) q")
+		sqlObj.CountQueryArgs = append([]interface{}{}, qa.values...)
+	}
+
+	strSortClause := ""
+	if sqlObj.After != nil || sqlObj.Before != nil {
+		if sqlObj.SortClause == nil {
+			err = errors.New("sort clause is required for cursor pagination")
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+		if sqlObj.DistinctResults {
+			// cursor needs values of sort and primary key columns which change the distinct rows if added to the select
+			err = errors.New("cursor pagination is not supported with distinct results")
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+		sortCols, sortErr := sqlObj.getSortCols(ctx, sqlObj.SortClause)
+		if sortErr != nil {
+			return sortErr
+		}
+		before := sqlObj.Before != nil
+		cursor := fmt.Sprint(sqlObj.After)
+		if before {
+			cursor = fmt.Sprint(sqlObj.Before)
+		}
+		if cursor != "" {
+			cursorValues, cursorErr := decodeCursor(ctx, cursor, len(sortCols))
+			if cursorErr != nil {
+				return cursorErr
+			}
+			cursorClause := makeCursorClause(ctx, sortCols, cursorValues, before, qa)
+			if strWhereClause != "" {
+				strWhereClause = fmt.Sprint(strWhereClause, " and ", cursorClause)
+			} else {
+				strWhereClause = fmt.Sprint(" where ", cursorClause)
+			}
+		}
+		strSortClause = makeCursorSortClause(sortCols, before)
+		if sqlObj.ReturnCursor {
+			// sort column values are fetched with hidden aliases to make cursor of each row
+			for i, sc := range sortCols {
+				strColums = fmt.Sprint(strColums, " , ", sc.col, " \"L0~~0**", cursorColPrefix, i, "\" ")
+				groupClause = append(groupClause, sc.col)
+			}
+			sqlObj.cursorColCount = len(sortCols)
+		}
+	} else {
+		strSortClause, err = sqlObj.processSortClause(ctx, sqlObj.SortClause)
+		if err != nil {
+			return err
+		}
+	}
+	strGroupClause = ""
+	if sqlObj.HasAggregate && len(groupClause) > 0 {
+		strGroupClause = fmt.Sprint(" group by ", strings.Join(groupClause, " , "))
+	}
 	sqlObj.DBQuery = fmt.Sprint(withClause, "select ", strDistinct, strColums, " from ", fromTable, " ", strJoinClause, " ", strWhereClause, " ", strGroupClause, strSortClause)
 
-	sqlObj.DBQuery = sqlMaker.AddLimitSkipClause(ctx, sqlObj.DBQuery, sqlObj.Limit, sqlObj.Skip, 1000)
+	sqlObj.DBQuery = sqlMaker.AddLimitSkipClause(ctx, sqlObj.DBQuery, sqlObj.Limit, sqlObj.Skip, sqlObj.rowLimit)
+	sqlObj.DBQueryArgs = qa.values
 	return err
 }
@@ -0,0 +1,131 @@
+package ql
+
+import (
+	"context"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logs.LogInit("eru-ql-test")
+	os.Exit(m.Run())
+}
+
+func TestProcessWhereClause(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		where    interface{}
+		join     bool
+		wantSql  string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{"value is bound", map[string]interface{}{"name": "x' or '1'='1"}, false, "( t.name = $1 )", []interface{}{"x' or '1'='1"}, false},
+		{"operator", map[string]interface{}{"age": map[string]interface{}{"$gt": 10}}, false, "( ( t.age > $1 ) )", []interface{}{10}, false},
+		{"dotted value is bound outside join", map[string]interface{}{"email": "a@b.com"}, false, "( t.email = $1 )", []interface{}{"a@b.com"}, false},
+		{"field reference", map[string]interface{}{"a": map[string]interface{}{"$eq": "FIELD_t.b"}}, false, "( ( t.a = t.b ) )", nil, false},
+		{"injected field reference", map[string]interface{}{"a": map[string]interface{}{"$eq": "FIELD_1=1 or t.b"}}, false, "", nil, true},
+		{"join column reference", map[string]interface{}{"t2.id": "t.id"}, true, "( t2.id = t.id )", nil, false},
+		{"join dotted value which is not a column is bound", map[string]interface{}{"t2.d": "2022-07-27T18:30:00.000Z"}, true, "( t2.d = $1 )", []interface{}{"2022-07-27T18:30:00.000Z"}, false},
+		{"join injected value is bound", map[string]interface{}{"t2.id": "t.id or 1=1"}, true, "( t2.id = $1 )", []interface{}{"t.id or 1=1"}, false},
+		{"injected key", map[string]interface{}{"1=1 or name": "x"}, false, "", nil, true},
+		{"injected key with operator", map[string]interface{}{"name;drop table t": map[string]interface{}{"$null": true}}, false, "", nil, true},
+		{"const key is bound", map[string]interface{}{"CONST_x": "x"}, false, "( $1 = $2 )", []interface{}{"x", "x"}, false},
+		{"in", map[string]interface{}{"id": map[string]interface{}{"$in": []interface{}{1, "2"}}}, false, "( ( t.id in ($1 , $2) ) )", []interface{}{1, "2"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qa := &queryArgs{sqlMaker: new(ds.PostgresSqlMaker)}
+			got, err := processWhereClause(ctx, tt.where, "", "t", tt.join, qa)
+			if (err != "") != tt.wantErr {
+				t.Fatalf("error = %s, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if strings.Join(strings.Fields(got), " ") != tt.wantSql {
+				t.Errorf("where clause = %s, want %s", got, tt.wantSql)
+			}
+			if len(qa.values) != len(tt.wantArgs) || (len(qa.values) > 0 && !reflect.DeepEqual(qa.values, tt.wantArgs)) {
+				t.Errorf("args = %v, want %v", qa.values, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestMakeQueryIdentifiers(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		sort    interface{}
+		join    []*OrderedMap
+		wantErr bool
+	}{
+		{"valid sort", []interface{}{"-name", "t2.id"}, nil, false},
+		{"sort by position", []interface{}{1, -2}, nil, false},
+		{"injected sort", "name; drop table t", nil, true},
+		{"injected sort in list", []interface{}{"name", "(select 1)"}, nil, true},
+		{"valid join", nil, []*OrderedMap{{Obj: map[string]interface{}{"t2": map[string]interface{}{"on": map[string]interface{}{"t2.id": "t.id"}}}}}, false},
+		{"injected join table", nil, []*OrderedMap{{Obj: map[string]interface{}{"t2 on 1=1 --": map[string]interface{}{}}}}, true},
+		{"injected join on key", nil, []*OrderedMap{{Obj: map[string]interface{}{"t2": map[string]interface{}{"on": map[string]interface{}{"1=1 or t2.id": "t.id"}}}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlObj := SQLObjectQ{MainTableName: "t", SortClause: tt.sort, JoinClause: tt.join}
+			sqlObj.Columns.ColNames = []string{"t.name"}
+			err := sqlObj.MakeQuery(ctx, new(ds.PostgresSqlMaker), false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MakeQuery() error = %v, wantErr %v : %s", err, tt.wantErr, sqlObj.DBQuery)
+			}
+		})
+	}
+}
+
+func TestMakeMutationQueryWhereClause(t *testing.T) {
+	ctx := context.Background()
+	sqlObj := SQLObjectM{MainTableName: "t", QueryType: "delete", sqlMaker: new(ds.PostgresSqlMaker)}
+	sqlObj.WhereClause = map[string]interface{}{"id": 1}
+	if err := sqlObj.MakeMutationQuery(ctx, nil, "t"); err != nil || !strings.Contains(sqlObj.DBQuery, "where") {
+		t.Fatalf("expected delete with where clause : %v %s", err, sqlObj.DBQuery)
+	}
+	// delete is never made without the where clause it was asked with
+	sqlObj.WhereClause = map[string]interface{}{"1=1 or id": 1}
+	if err := sqlObj.MakeMutationQuery(ctx, nil, "t"); err == nil {
+		t.Fatal("invalid where clause is expected to fail mutation")
+	}
+}
+
+func TestMakeUpsertQueryUpsertCols(t *testing.T) {
+	ctx := context.Background()
+	datasource := &module_model.DataSource{SchemaTables: map[string]map[string]module_model.TableColsMetaData{
+		"t": {"id": {ColName: "id", PrimaryKey: true}, "code": {ColName: "code"}, "qty": {ColName: "qty"}},
+	}}
+	tests := []struct {
+		name       string
+		upsertCols []string
+		want       string
+		wantErr    bool
+	}{
+		{"all inserted columns except conflict columns", nil, "do update set code = excluded.code , qty = excluded.qty", false},
+		{"upsert columns of table", []string{"qty"}, "do update set qty = excluded.qty", false},
+		{"upsert column not in table", []string{"qty = 0, code"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlObj := SQLObjectM{MainTableName: "t", QueryType: "upsert", UpsertCols: tt.upsertCols, sqlMaker: new(ds.PostgresSqlMaker), datasource: datasource}
+			query, err := sqlObj.makeUpsertQuery(ctx, &module_model.MutationRecord{Cols: "id,code,qty", ColsPlaceholder: "($1,$2,$3)"}, "t")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(query, tt.want) {
+				t.Errorf("query %s does not contain %s", query, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/ds"
 	"github.com/eru-tech/eru/eru-ql/module_model"
 	"github.com/graphql-go/graphql/language/ast"
 	"reflect"
@@ -21,6 +22,7 @@ type SQLObjectM struct {
 	MutationReturn      module_model.MutationReturn
 	MutationSelectQuery string
 	MutationSelectCols  string
+	MutationSelectArgs  []interface{}
 	NestedDoc           bool
 	SingleTxn           bool
 	openTxn             bool
@@ -29,6 +31,7 @@ type SQLObjectM struct {
 	WhereClause interface{}
 	QueryObject map[string]QueryObject
 	DBQuery     string
+	DBQueryArgs []interface{}
 	//SortClause      interface{}
 	//JoinClause      map[string]interface{}
 	//DistinctResults bool
@@ -40,13 +43,24 @@ type SQLObjectM struct {
 	//tableNames      map[string]string
 	//queryLevel      int
 	//querySubLevel   []int
-	PreparedQuery bool
-	OverwriteDoc  map[string]map[string]interface{} `json:"-"`
+	PreparedQuery  bool
+	TransformRules map[string]module_model.TransformRule `json:"-"`
+	// ConflictTarget is the primary key or unique constraint name checked by upsert - primary key is used if blank
+	ConflictTarget string
+	// UpsertCols are the columns updated by upsert on conflict - all inserted columns except conflict columns if blank
+	UpsertCols     []string
+	sqlMaker       ds.SqlMakerI
+	datasource     *module_model.DataSource
+	finalVariables map[string]interface{}
+	audit          *module_model.MutationAudit
 }
 
-func (sqlObj *SQLObjectM) ProcessMutationGraphQL(ctx context.Context, sel ast.Selection, vars map[string]interface{}, datasource *module_model.DataSource) (err error) {
+func (sqlObj *SQLObjectM) ProcessMutationGraphQL(ctx context.Context, sel ast.Selection, vars map[string]interface{}, datasource *module_model.DataSource, sqlMaker ds.SqlMakerI) (err error) {
 	//myself.CheckMe()
 	logs.WithContext(ctx).Debug("ProcessMutationGraphQL - Start")
+	sqlObj.sqlMaker = sqlMaker
+	sqlObj.datasource = datasource
+	sqlObj.finalVariables = vars
 	field := sel.(*ast.Field)
 	docsFound := false
 	if field.Alias != nil {
@@ -60,7 +74,10 @@ func (sqlObj *SQLObjectM) ProcessMutationGraphQL(ctx context.Context, sel ast.Se
 	// in case of SingleTxn, same sqlObj object is used for all records in loop
 	sqlObj.MutationSelectQuery = ""
 	sqlObj.MutationSelectCols = ""
+	sqlObj.MutationSelectArgs = nil
 	sqlObj.MutationRecords = nil
+	sqlObj.ConflictTarget = ""
+	sqlObj.UpsertCols = nil
 
 	sqlObj.MutationReturn.ReturnError = false  //default to false
 	sqlObj.MutationReturn.ReturnDoc = false    //default to false
@@ -122,8 +139,12 @@ func (sqlObj *SQLObjectM) ProcessMutationGraphQL(ctx context.Context, sel ast.Se
 			}
 			sqlObj.MutationSelectQuery = sqlObj.QueryObject[varValue.(string)].Query
 			sqlObj.MutationSelectCols = sqlObj.QueryObject[varValue.(string)].Cols
+			sqlObj.MutationSelectArgs = sqlObj.QueryObject[varValue.(string)].Args
 			sqlObj.QueryType = "insertselect"
-			sqlObj.MakeMutationQuery(ctx, nil, sqlObj.MainTableName)
+			err = sqlObj.MakeMutationQuery(ctx, nil, sqlObj.MainTableName)
+			if err != nil {
+				return err
+			}
 			logs.WithContext(ctx).Info(sqlObj.DBQuery)
 			logs.WithContext(ctx).Info(fmt.Sprint("sqlObj.PreparedQuery = ", sqlObj.PreparedQuery))
 
@@ -150,6 +171,28 @@ func (sqlObj *SQLObjectM) ProcessMutationGraphQL(ctx context.Context, sel ast.Se
 			//wc, _ := sqlObj.processWhereClause(v, "", false)
 			//sqlObj.WhereClause = fmt.Sprint(" where ", wc)
 			sqlObj.WhereClause = v
+		case "conflict":
+			varValue, err := ParseAstValue(ctx, ff.Value, vars)
+			if err != nil {
+				return err
+			}
+			v, ok := varValue.(string)
+			if !ok {
+				return errors.New("error while parsing value of 'conflict' - constraint name is expected")
+			}
+			sqlObj.ConflictTarget = v
+		case "update":
+			varValue, err := ParseAstValue(ctx, ff.Value, vars)
+			if err != nil {
+				return err
+			}
+			cols, ok := varValue.([]interface{})
+			if !ok {
+				return errors.New("error while parsing value of 'update' - array of columns is expected")
+			}
+			for _, c := range cols {
+				sqlObj.UpsertCols = append(sqlObj.UpsertCols, fmt.Sprint(c))
+			}
 		default:
 			//do nothing
 		}
@@ -165,7 +208,10 @@ func (sqlObj *SQLObjectM) ProcessMutationGraphQL(ctx context.Context, sel ast.Se
 	}
 	if sqlObj.QueryType == "delete" || sqlObj.PreparedQuery {
 		sqlObj.MutationRecords = make([]module_model.MutationRecord, 1) // dummy record added so that it enters for loop in ExecuteMutationQuery function
-		sqlObj.MakeMutationQuery(ctx, &sqlObj.MutationRecords[0], sqlObj.MainTableName)
+		err = sqlObj.MakeMutationQuery(ctx, &sqlObj.MutationRecords[0], sqlObj.MainTableName)
+		if err != nil {
+			return err
+		}
 	} else if !docsFound {
 		logs.WithContext(ctx).Warn("docs not found")
 		return errors.New("missing 'docs' keyword - document to mutate not found") //TODO this error is not returned in graphql error
@@ -192,11 +238,14 @@ func (sqlObj *SQLObjectM) processMutationDoc(ctx context.Context, d interface{},
 
 		insertDoc, err := doc.(map[string]interface{})
 		if !err {
-			return nil, errors.New(fmt.Sprintf("error while parsing document at index ", i))
+			return nil, errors.New(fmt.Sprint("error while parsing document at index ", i))
 		}
 
-		for k, v := range sqlObj.OverwriteDoc[parentTableName] {
-			insertDoc[k] = v
+		insertDoc, e = transformDoc(ctx, sqlObj.TransformRules[parentTableName], insertDoc, sqlObj.finalVariables)
+		if e != nil {
+			e = errors.New(fmt.Sprint("TransformRule failed : ", e.Error()))
+			logs.WithContext(ctx).Error(e.Error())
+			return nil, e
 		}
 		var jsonFields []string
 
@@ -320,43 +369,57 @@ func (sqlObj *SQLObjectM) processMutationDoc(ctx context.Context, d interface{},
 			valuesIfNotNested = append(valuesIfNotNested, nonNestedValue)
 		}
 		mr[i].NonNestedValues = valuesIfNotNested
-		sqlObj.MakeMutationQuery(ctx, &mr[i], parentTableName)
+		e = sqlObj.MakeMutationQuery(ctx, &mr[i], parentTableName)
+		if e != nil {
+			return nil, e
+		}
 	}
 	if !sqlObj.NestedDoc && len(docs) > 0 {
 		mr[0].Cols = mr[0].NonNestedCols
-		sqlObj.MakeMutationQuery(ctx, &mr[0], parentTableName)
+		e = sqlObj.MakeMutationQuery(ctx, &mr[0], parentTableName)
+		if e != nil {
+			return nil, e
+		}
 	}
 	return mr, nil
 }
 
-func (sqlObj *SQLObjectM) MakeMutationQuery(ctx context.Context, doc *module_model.MutationRecord, tableName string) {
+func (sqlObj *SQLObjectM) MakeMutationQuery(ctx context.Context, doc *module_model.MutationRecord, tableName string) (err error) {
 	logs.WithContext(ctx).Debug("MakeMutationQuery - Start")
 	returningStr := ""
-	strWhereClause, e := processWhereClause(ctx, sqlObj.WhereClause, "", sqlObj.MainTableName, false)
+	outputStr := ""
+	qa := &queryArgs{sqlMaker: sqlObj.sqlMaker}
+	if doc != nil && sqlObj.QueryType == "update" {
+		// where clause placeholders are numbered after the updated columns
+		qa.offset = len(doc.Values)
+	}
+	strWhereClause, e := processWhereClause(ctx, sqlObj.WhereClause, "", sqlObj.MainTableName, false, qa)
 	if e != "" {
+		// mutation is never run without the where clause it was asked with
 		logs.WithContext(ctx).Error(e)
-		//TODO to return errors to main result
-		//err := errors.New(e)
+		return errors.New(e)
 	}
 	if strWhereClause != "" {
 		strWhereClause = fmt.Sprint(" where ", strWhereClause)
 	}
 	//if sqlObj.MutationReturn.ReturnDoc { ### commented the conditional check to add returning clause - not we will always add
 	//TODO to bring back conditional check
-	if sqlObj.MutationReturn.ReturnDoc {
-		returningStr = fmt.Sprint(" RETURNING ", sqlObj.MutationReturn.ReturnFields)
+	if returnDoc, returnFields := sqlObj.returning(tableName); returnDoc {
+		outputStr, returningStr = sqlObj.sqlMaker.MakeReturningClause(ctx, sqlObj.QueryType, returnFields)
 	}
 	//}
 	query := ""
 	if sqlObj.PreparedQuery {
 		query = fmt.Sprint(sqlObj.QueryObject[sqlObj.MainTableName].Query, " ", returningStr)
 		sqlObj.DBQuery = query
+		sqlObj.DBQueryArgs = sqlObj.QueryObject[sqlObj.MainTableName].Args
 		return
 	}
 	switch sqlObj.QueryType {
 	case "insertselect":
-		query = fmt.Sprint("insert into ", tableName, " ( ", sqlObj.MutationSelectCols, ") ", sqlObj.MutationSelectQuery, returningStr)
+		query = fmt.Sprint("insert into ", tableName, " ( ", sqlObj.MutationSelectCols, ") ", outputStr, sqlObj.MutationSelectQuery, returningStr)
 		sqlObj.DBQuery = query
+		sqlObj.DBQueryArgs = sqlObj.MutationSelectArgs
 	case "insert":
 
 		/*for _, tjColVal := range tj.Table1Cols { //TODO test parent-child insertion extensively.
@@ -379,16 +442,75 @@ func (sqlObj *SQLObjectM) MakeMutationQuery(ctx context.Context, doc *module_mod
 		*/
 
 		query = fmt.Sprint("insert into ", tableName, " (", doc.Cols,
-			") values ", doc.ColsPlaceholder, returningStr)
+			") ", outputStr, " values ", doc.ColsPlaceholder, returningStr)
 		doc.DBQuery = query
 	case "update":
 		query = fmt.Sprint("update ", tableName, " set ", doc.UpdatedCols,
-			" ", strWhereClause, " ", returningStr)
+			" ", outputStr, " ", strWhereClause, " ", returningStr)
+		doc.DBQuery = query
+		doc.WhereValues = qa.values
+	case "upsert":
+		query, err = sqlObj.makeUpsertQuery(ctx, doc, tableName)
+		if err != nil {
+			return err
+		}
 		doc.DBQuery = query
 	case "delete":
-		query = fmt.Sprint("delete from ", tableName, " ", strWhereClause, " ", returningStr)
+		query = fmt.Sprint("delete from ", tableName, " ", outputStr, " ", strWhereClause, " ", returningStr)
 		sqlObj.DBQuery = query
+		sqlObj.DBQueryArgs = qa.values
 	default:
 		//do nothing
 	}
+	return
+}
+
+// makeUpsertQuery returns insert query which updates the existing row on conflict of primary key or unique constraint
+// conflict target and columns to update received in mutation are applied only to main table - nested tables use primary key
+func (sqlObj *SQLObjectM) makeUpsertQuery(ctx context.Context, doc *module_model.MutationRecord, tableName string) (query string, err error) {
+	logs.WithContext(ctx).Debug("makeUpsertQuery - Start")
+	constraintName := ""
+	if tableName == sqlObj.MainTableName {
+		constraintName = sqlObj.ConflictTarget
+	}
+	conflictCols, err := sqlObj.datasource.GetConflictCols(ctx, tableName, constraintName)
+	if err != nil {
+		return "", err
+	}
+	cols := strings.Split(doc.Cols, ",")
+	var updateCols []string
+	if tableName == sqlObj.MainTableName && len(sqlObj.UpsertCols) > 0 {
+		// upsert columns are received in the mutation and only columns of the table are allowed in the query
+		tableCols := sqlObj.datasource.SchemaTables[tableName]
+		for _, c := range sqlObj.UpsertCols {
+			if _, ok := tableCols[c]; !ok {
+				err = errors.New(fmt.Sprint("upsert column ", c, " not found in table ", tableName))
+				logs.WithContext(ctx).Error(err.Error())
+				return "", err
+			}
+		}
+		updateCols = sqlObj.UpsertCols
+	} else {
+		for _, c := range cols {
+			isConflictCol := false
+			for _, cc := range conflictCols {
+				if c == cc {
+					isConflictCol = true
+					break
+				}
+			}
+			if !isConflictCol {
+				updateCols = append(updateCols, c)
+			}
+		}
+	}
+	returnFields := ""
+	if returnDoc, fields := sqlObj.returning(tableName); returnDoc {
+		returnFields = fields
+	}
+	query, err = sqlObj.sqlMaker.MakeUpsertSQL(ctx, tableName, cols, doc.ColsPlaceholder, conflictCols, updateCols, returnFields)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	return
 }
@@ -0,0 +1,1142 @@
+package ql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/eru-tech/eru/eru-read-write/eru_writes"
+	"github.com/eru-tech/eru/eru-security-rule/security_rule"
+	"github.com/eru-tech/eru/eru-utils"
+	"github.com/graphql-go/graphql/language/ast"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mongoObject holds what is common to translate graphql query and mutation to mongo operations
+type mongoObject struct {
+	ProjectId      string
+	DbAlias        string
+	FinalVariables map[string]interface{} `json:"-"`
+	datasource     *module_model.DataSource
+	store          module_store.ModuleStoreI
+}
+
+// MongoObjectQ is the mongo counterpart of SQLObjectQ - graphql query is translated to aggregation pipeline on collection of the main table
+type MongoObjectQ struct {
+	mongoObject
+	MainTableName string
+	MainAliasName string
+	Pipeline      []bson.D
+	ColNames      []string
+	masks         columnMasks
+	transforms    tableTransforms
+}
+
+// MongoObjectM is the mongo counterpart of SQLObjectM - docs are mutated as documents and nested objects are stored within the document
+type MongoObjectM struct {
+	mongoObject
+	MainTableName  string
+	MainAliasName  string
+	QueryType      string
+	MutationReturn module_model.MutationReturn
+	Docs           []map[string]interface{}
+	WhereClause    interface{}
+	TransformRule  module_model.TransformRule `json:"-"`
+	ConflictTarget string
+	UpsertCols     []string
+}
+
+// executeMongoQuery executes query of a graphql selection on mongo datasource and returns result in same format as sql datasources
+func (gqd *GraphQLData) executeMongoQuery(ctx context.Context, field *ast.Field, projectId string, dbAlias string, datasource *module_model.DataSource, mongoMaker *ds.MongoSqlMaker, s module_store.ModuleStoreI) (result map[string]interface{}, queryObj QueryObject, mainAliasName string, err error) {
+	logs.WithContext(ctx).Debug("executeMongoQuery - Start")
+	mo := MongoObjectQ{mongoObject: mongoObject{ProjectId: projectId, DbAlias: dbAlias, FinalVariables: gqd.FinalVariables, datasource: datasource, store: s}}
+	mainAliasName = field.Name.Value
+	if field.Alias != nil {
+		mainAliasName = field.Alias.Value
+	}
+	mo.MainAliasName = mainAliasName
+	err = mo.ProcessGraphQL(ctx, field)
+	if err != nil {
+		return nil, queryObj, mainAliasName, err
+	}
+	queryObj.Query, err = mo.pipelineJson(ctx)
+	if err != nil {
+		return nil, queryObj, mainAliasName, err
+	}
+	queryObj.Cols = strings.Join(mo.ColNames, " , ")
+	if !gqd.ExecuteFlag {
+		return nil, queryObj, mainAliasName, nil
+	}
+	tr, err := gqd.getTransformRule(ctx, projectId, dbAlias, mo.MainTableName, s, "query", module_model.QUERY_TYPE_SELECT)
+	if err != nil {
+		return nil, queryObj, mainAliasName, err
+	}
+	qctx, cancel, timeOutErr := withQueryTimeOut(ctx, datasource, gqd.TimeOut)
+	docs, err := mongoMaker.Aggregate(qctx, datasource, mo.MainTableName, mo.Pipeline)
+	err = timeOutErr(err)
+	cancel()
+	if err != nil {
+		return nil, queryObj, mainAliasName, err
+	}
+	result = make(map[string]interface{})
+	if gqd.OutputType == eru_writes.OutputTypeCsv || gqd.OutputType == eru_writes.OutputTypeExcel {
+		rows, csvErr := mongoCsvRows(ctx, docs, mo.ColNames)
+		if csvErr != nil {
+			return nil, queryObj, mainAliasName, csvErr
+		}
+		maskCsvRows(ctx, rows, mo.masks.flatten())
+		result[mainAliasName], err = transformCsvRows(ctx, tr, rows, gqd.FinalVariables)
+		if err != nil {
+			return nil, queryObj, mainAliasName, err
+		}
+		return result, queryObj, mainAliasName, nil
+	}
+	records := make([]interface{}, len(docs))
+	for i, d := range docs {
+		records[i] = d
+	}
+	mo.masks.maskRecords(ctx, records, strings.Replace(mo.MainTableName, ".", "___", 1))
+	if err = transformRecords(ctx, tr, mo.transforms, records, gqd.FinalVariables); err != nil {
+		return nil, queryObj, mainAliasName, err
+	}
+	result[strings.Replace(mainAliasName, ".", "___", 1)] = records
+	return result, queryObj, mainAliasName, nil
+}
+
+// executeMongoMutation executes mutation of a graphql selection on mongo datasource and returns result in same format as sql datasources.
+// documents are not mutated in a transaction thus singleTxn is not supported.
+func (gqd *GraphQLData) executeMongoMutation(ctx context.Context, field *ast.Field, projectId string, dbAlias string, datasource *module_model.DataSource, mongoMaker *ds.MongoSqlMaker, s module_store.ModuleStoreI, singleTxn bool) (resObj map[string]interface{}, queryObj QueryObject, mainAliasName string, returnAlias string, err error) {
+	logs.WithContext(ctx).Debug("executeMongoMutation - Start")
+	mo := MongoObjectM{mongoObject: mongoObject{ProjectId: projectId, DbAlias: dbAlias, FinalVariables: gqd.FinalVariables, datasource: datasource, store: s}}
+	tempStr := strings.SplitN(field.Name.Value, "_", 2)
+	mo.QueryType = tempStr[0]
+	if len(tempStr) > 1 {
+		mo.MainTableName = strings.Replace(tempStr[1], "___", ".", -1)
+	}
+	if singleTxn {
+		err = errors.New("singleTxn is not supported for mongo datasource")
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	if err == nil {
+		mo.TransformRule, err = gqd.getTransformRule(ctx, projectId, dbAlias, mo.MainTableName, s, "mutation", mo.QueryType)
+	}
+	if err == nil {
+		err = mo.ProcessMutationGraphQL(ctx, field)
+	}
+	var results []map[string]interface{}
+	if err == nil {
+		queryObj.Query, err = mo.mutationJson(ctx)
+	}
+	if err == nil && gqd.ExecuteFlag {
+		results, err = mo.Execute(ctx, mongoMaker)
+	}
+	errMsg := "-"
+	if err != nil {
+		errMsg = err.Error()
+	}
+	returnResult := make(map[string]interface{})
+	if mo.MutationReturn.ReturnError {
+		returnResult[mo.MutationReturn.ReturnErrorAlias] = errMsg
+	}
+	if mo.MutationReturn.ReturnDoc {
+		returnResult[mo.MutationReturn.ReturnDocAlias] = results
+	}
+	resObj = map[string]interface{}{mo.MainAliasName: returnResult}
+	return resObj, queryObj, mo.MainAliasName, mo.MutationReturn.ReturnDocAlias, err
+}
+
+func (mo *MongoObjectQ) ProcessGraphQL(ctx context.Context, field *ast.Field) (err error) {
+	logs.WithContext(ctx).Debug("ProcessGraphQL of mongo - Start")
+	mo.MainTableName = strings.Replace(field.Name.Value, "___", ".", -1)
+	if mo.MainAliasName == "" {
+		mo.MainAliasName = mo.MainTableName
+	}
+	var where, sortVal interface{}
+	limit := 0
+	skip := 0
+	distinct := false
+	for _, ff := range field.Arguments {
+		v, e := ParseAstValue(ctx, ff.Value, mo.FinalVariables)
+		if e != nil {
+			logs.WithContext(ctx).Error(e.Error())
+			return e
+		}
+		switch ff.Name.Value {
+		case "where":
+			where = v
+		case "sort":
+			sortVal = v
+		case "distinct":
+			b, ok := v.(bool)
+			if !ok {
+				err = errors.New("Non Boolean value received - distinct clause need boolean value")
+				logs.WithContext(ctx).Error(err.Error())
+				return err
+			}
+			distinct = b
+		case "limit", "skip":
+			if f, ok := v.(float64); ok {
+				v = int(f)
+			}
+			n, ok := v.(int)
+			if !ok {
+				err = errors.New(fmt.Sprint("Non Integer value received - ", ff.Name.Value, " clause need integer value"))
+				logs.WithContext(ctx).Error(err.Error())
+				return err
+			}
+			if ff.Name.Value == "limit" {
+				limit = n
+			} else {
+				skip = n
+			}
+		case "after", "before":
+			err = errors.New("cursor pagination is not supported for mongo datasource - use limit and skip")
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		default:
+		}
+	}
+	for _, d := range field.Directives[1:] {
+		if d.Name.Value == "withCount" {
+			err = errors.New("withCount is not supported for mongo datasource")
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+	}
+
+	filter, err := mo.mongoFilter(ctx, where, mo.MainTableName)
+	if err != nil {
+		return err
+	}
+	securityFilter, err := mo.mongoSecurityFilter(ctx, mo.MainTableName)
+	if err != nil {
+		return err
+	}
+	var matchStages []bson.D
+	if match := combineMongoFilters(filter, securityFilter); match != nil {
+		matchStages = append(matchStages, bson.D{{Key: "$match", Value: match}})
+	}
+	var selections []ast.Selection
+	if field.SelectionSet != nil {
+		selections = field.SelectionSet.Selections
+	}
+	fieldStages, colNames, err := mo.processFields(ctx, selections, mo.MainTableName, strings.Replace(mo.MainTableName, ".", "___", 1))
+	if err != nil {
+		return err
+	}
+	mo.ColNames = colNames
+
+	// sort refers to fields of the collection if applied before projection and to the result columns otherwise
+	sortTable := mo.MainTableName
+	if distinct {
+		sortTable = ""
+	}
+	sortStage, err := mongoSort(ctx, sortVal, sortTable)
+	if err != nil {
+		return err
+	}
+	var pageStages []bson.D
+	if len(sortStage) > 0 {
+		pageStages = append(pageStages, bson.D{{Key: "$sort", Value: sortStage}})
+	}
+	if skip > 0 {
+		pageStages = append(pageStages, bson.D{{Key: "$skip", Value: skip}})
+	}
+	if err = checkLimit(ctx, mo.datasource, limit); err != nil {
+		return err
+	}
+	if limit <= 0 {
+		limit = mo.datasource.DbConfig.OtherDbConfig.DefaultLimit()
+	}
+	pageStages = append(pageStages, bson.D{{Key: "$limit", Value: limit}})
+
+	mo.Pipeline = append(mo.Pipeline, matchStages...)
+	if distinct {
+		mo.Pipeline = append(mo.Pipeline, fieldStages...)
+		mo.Pipeline = append(mo.Pipeline, bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$$ROOT"}}}}, bson.D{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: "$_id"}}}})
+		mo.Pipeline = append(mo.Pipeline, pageStages...)
+	} else {
+		// documents are paged before lookups so that only documents of the page are joined
+		mo.Pipeline = append(mo.Pipeline, pageStages...)
+		mo.Pipeline = append(mo.Pipeline, fieldStages...)
+	}
+	logs.WithContext(ctx).Info(fmt.Sprint("mongo pipeline  : ", mo.Pipeline))
+	return nil
+}
+
+// processFields returns $lookup stages of nested tables followed by $project stage of selected fields of the table.
+// no stage is returned if no field is selected so that complete documents are returned.
+func (mo *MongoObjectQ) processFields(ctx context.Context, sel []ast.Selection, tableName string, resultTable string) (stages []bson.D, colNames []string, err error) {
+	logs.WithContext(ctx).Debug("processFields - Start")
+	if mo.masks == nil {
+		mo.masks = make(columnMasks)
+	}
+	if len(sel) == 0 {
+		for colName := range mo.datasource.SchemaTables[tableName] {
+			mo.masks.addMask(ctx, mo.datasource, tableName, colName, resultTable, colName, mo.FinalVariables)
+		}
+		return nil, nil, nil
+	}
+	project := bson.D{}
+	idProjected := false
+	for _, s := range sel {
+		field := s.(*ast.Field)
+		alias := field.Name.Value
+		if field.Alias != nil {
+			alias = field.Alias.Value
+		}
+		for _, a := range field.Arguments {
+			if a.Name.Value == "join" || a.Name.Value == "calc" {
+				err = errors.New(fmt.Sprint(a.Name.Value, " is not supported for mongo datasource"))
+				logs.WithContext(ctx).Error(err.Error())
+				return nil, nil, err
+			}
+		}
+		if len(field.Directives) > 0 {
+			err = errors.New(fmt.Sprint("@", field.Directives[0].Name.Value, " is not supported for mongo datasource"))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, nil, err
+		}
+		if field.SelectionSet != nil {
+			childTableName := strings.Replace(field.Name.Value, "___", ".", -1)
+			lookup, e := mo.makeLookup(ctx, tableName, childTableName, alias, field.SelectionSet.Selections)
+			if e != nil {
+				return nil, nil, e
+			}
+			stages = append(stages, lookup)
+			project = append(project, bson.E{Key: alias, Value: 1})
+		} else {
+			path := mongoFieldPath(tableName, eru_utils.ReplaceUnderscoresWithDots(field.Name.Value))
+			project = append(project, bson.E{Key: alias, Value: bson.D{{Key: "$ifNull", Value: bson.A{"$" + path, nil}}}})
+			mo.masks.addMask(ctx, mo.datasource, tableName, path, resultTable, alias, mo.FinalVariables)
+		}
+		if alias == "_id" {
+			idProjected = true
+		}
+		colNames = append(colNames, alias)
+	}
+	if !idProjected {
+		project = append(bson.D{{Key: "_id", Value: 0}}, project...)
+	}
+	stages = append(stages, bson.D{{Key: "$project", Value: project}})
+	return stages, colNames, nil
+}
+
+// makeLookup returns $lookup stage which fetches documents of child table matching the table joins as array of the alias
+func (mo *MongoObjectQ) makeLookup(ctx context.Context, tableName string, childTableName string, alias string, sel []ast.Selection) (lookup bson.D, err error) {
+	logs.WithContext(ctx).Debug("makeLookup - Start")
+	tj, err := mo.datasource.GetTableJoins(ctx, tableName, childTableName, nil)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	if tj.Table1Name != tableName || len(tj.Table1Cols) == 0 || len(tj.Table1Cols) != len(tj.Table2Cols) {
+		err = errors.New(fmt.Sprint("table joins not found for ", tableName, " and ", childTableName))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	dbName, _ := ds.SplitMongoTableName(tableName, mo.datasource.DbConfig.DefaultDB)
+	childDbName, childCollName := ds.SplitMongoTableName(childTableName, mo.datasource.DbConfig.DefaultDB)
+	if dbName != childDbName {
+		err = errors.New(fmt.Sprint("joins across databases are not supported for mongo datasource : ", tableName, " and ", childTableName))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	let := bson.D{}
+	conditions := bson.A{}
+	for i, c := range tj.Table1Cols {
+		v := fmt.Sprint("v", i)
+		let = append(let, bson.E{Key: v, Value: "$" + c})
+		conditions = append(conditions, bson.D{{Key: "$eq", Value: bson.A{"$" + tj.Table2Cols[i], "$$" + v}}})
+	}
+	pipeline := []bson.D{{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: conditions}}}}}}}
+	securityFilter, err := mo.mongoSecurityFilter(ctx, childTableName)
+	if err != nil {
+		return nil, err
+	}
+	if securityFilter != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: securityFilter}})
+	}
+	if mo.transforms == nil {
+		mo.transforms = make(tableTransforms)
+	}
+	if err = mo.transforms.addTransform(ctx, mo.store, mo.ProjectId, mo.DbAlias, childTableName, alias); err != nil {
+		return nil, err
+	}
+	stages, _, err := mo.processFields(ctx, sel, childTableName, alias)
+	if err != nil {
+		return nil, err
+	}
+	pipeline = append(pipeline, stages...)
+	return bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: childCollName}, {Key: "let", Value: let}, {Key: "pipeline", Value: pipeline}, {Key: "as", Value: alias}}}}, nil
+}
+
+// pipelineJson returns the aggregate command as extended json - it is returned as query when query is not executed
+func (mo *MongoObjectQ) pipelineJson(ctx context.Context) (string, error) {
+	_, collName := ds.SplitMongoTableName(mo.MainTableName, mo.datasource.DbConfig.DefaultDB)
+	b, err := bson.MarshalExtJSON(bson.D{{Key: "aggregate", Value: collName}, {Key: "pipeline", Value: mo.Pipeline}}, false, false)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (mo *MongoObjectM) ProcessMutationGraphQL(ctx context.Context, field *ast.Field) (err error) {
+	logs.WithContext(ctx).Debug("ProcessMutationGraphQL of mongo - Start")
+	mo.MainAliasName = field.Name.Value
+	if field.Alias != nil {
+		mo.MainAliasName = field.Alias.Value
+	}
+	switch mo.QueryType {
+	case module_model.QUERY_TYPE_INSERT, module_model.QUERY_TYPE_UPDATE, module_model.QUERY_TYPE_UPSERT, module_model.QUERY_TYPE_DELETE:
+	default:
+		err = errors.New(fmt.Sprint("Invalid mutation ", field.Name.Value, " - mutation name should start with insert_, update_, upsert_ or delete_"))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	mo.MutationReturn.ReturnFields = " * "
+	if field.SelectionSet != nil {
+		for _, ss := range field.SelectionSet.Selections {
+			f := ss.(*ast.Field)
+			alias := f.Name.Value
+			if f.Alias != nil {
+				alias = f.Alias.Value
+			}
+			switch f.Name.Value {
+			case "error":
+				mo.MutationReturn.ReturnError = true
+				mo.MutationReturn.ReturnErrorAlias = alias
+			case "returning":
+				mo.MutationReturn.ReturnDoc = true
+				mo.MutationReturn.ReturnDocAlias = alias
+				if f.SelectionSet != nil {
+					var retFields []string
+					for _, rf := range f.SelectionSet.Selections {
+						retFields = append(retFields, rf.(*ast.Field).Name.Value)
+					}
+					mo.MutationReturn.ReturnFields = strings.Join(retFields, ",")
+				}
+			default:
+			}
+		}
+	}
+	docsFound := false
+	for _, ff := range field.Arguments {
+		v, e := ParseAstValue(ctx, ff.Value, mo.FinalVariables)
+		if e != nil {
+			logs.WithContext(ctx).Error(e.Error())
+			return e
+		}
+		switch ff.Name.Value {
+		case "docs":
+			docsFound = true
+			err = mo.processMutationDocs(ctx, v)
+			if err != nil {
+				return err
+			}
+		case "where":
+			mo.WhereClause = v
+		case "conflict":
+			c, ok := v.(string)
+			if !ok {
+				return errors.New("error while parsing value of 'conflict' - constraint name is expected")
+			}
+			mo.ConflictTarget = c
+		case "update":
+			cols, ok := v.([]interface{})
+			if !ok {
+				return errors.New("error while parsing value of 'update' - array of columns is expected")
+			}
+			for _, c := range cols {
+				mo.UpsertCols = append(mo.UpsertCols, fmt.Sprint(c))
+			}
+		case "query":
+			err = errors.New("insert with query is not supported for mongo datasource")
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		default:
+			//do nothing
+		}
+	}
+	if !docsFound && mo.QueryType != module_model.QUERY_TYPE_DELETE {
+		err = errors.New("missing 'docs' keyword - document to mutate not found")
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// processMutationDocs sets documents to mutate with values of transform input rule overwritten and values converted to bson types of the fields
+func (mo *MongoObjectM) processMutationDocs(ctx context.Context, d interface{}) (err error) {
+	logs.WithContext(ctx).Debug("processMutationDocs - Start")
+	docs, isArray := d.([]interface{})
+	if !isArray {
+		dd, ok := d.(map[string]interface{}) // checking if docs is a single document without array
+		if !ok {
+			return errors.New("error while parsing value of 'docs'")
+		}
+		docs = append(docs, dd)
+	} else if mo.QueryType == module_model.QUERY_TYPE_UPDATE {
+		return errors.New("value of 'docs' cannot be an array")
+	}
+	for i, doc := range docs {
+		dd, ok := doc.(map[string]interface{})
+		if !ok {
+			return errors.New(fmt.Sprint("error while parsing document at index ", i))
+		}
+		dd, err = transformDoc(ctx, mo.TransformRule, dd, mo.FinalVariables)
+		if err != nil {
+			err = errors.New(fmt.Sprint("TransformRule failed : ", err.Error()))
+			logs.WithContext(ctx).Error(err.Error())
+			return err
+		}
+		for k, v := range dd {
+			dd[k] = mo.mongoValue(mo.MainTableName, k, v)
+		}
+		mo.Docs = append(mo.Docs, dd)
+	}
+	return nil
+}
+
+// Execute mutates documents and returns mutated documents with returning fields - deleted documents are returned as they were before delete
+func (mo *MongoObjectM) Execute(ctx context.Context, mongoMaker *ds.MongoSqlMaker) (results []map[string]interface{}, err error) {
+	logs.WithContext(ctx).Debug("Execute of mongo mutation - Start")
+	projection := mo.returnProjection()
+	switch mo.QueryType {
+	case module_model.QUERY_TYPE_INSERT:
+		docs := make([]interface{}, len(mo.Docs))
+		for i, d := range mo.Docs {
+			docs[i] = d
+		}
+		ids, e := mongoMaker.InsertDocs(ctx, mo.datasource, mo.MainTableName, docs)
+		if e != nil || !mo.MutationReturn.ReturnDoc {
+			return nil, e
+		}
+		return mo.findByIds(ctx, mongoMaker, ids, projection)
+	case module_model.QUERY_TYPE_UPDATE:
+		filter, e := mo.mongoFilter(ctx, mo.WhereClause, mo.MainTableName)
+		if e != nil {
+			return nil, e
+		}
+		// documents are updated with the filter so that update is a single write - ids of documents to return are fetched before it
+		var ids []interface{}
+		if mo.MutationReturn.ReturnDoc {
+			ids, e = mongoMaker.FindIds(ctx, mo.datasource, mo.MainTableName, mongoFilterOrAll(filter))
+			if e != nil {
+				return nil, e
+			}
+		}
+		_, e = mongoMaker.UpdateDocs(ctx, mo.datasource, mo.MainTableName, mongoFilterOrAll(filter), mongoUpdate(mo.Docs[0]))
+		if e != nil || !mo.MutationReturn.ReturnDoc {
+			return nil, e
+		}
+		return mo.findByIds(ctx, mongoMaker, ids, projection)
+	case module_model.QUERY_TYPE_UPSERT:
+		conflictCols, e := mo.datasource.GetConflictCols(ctx, mo.MainTableName, mo.ConflictTarget)
+		if e != nil {
+			if mo.ConflictTarget != "" {
+				return nil, e
+			}
+			conflictCols = []string{"_id"}
+		}
+		for _, d := range mo.Docs {
+			doc, e := mongoMaker.UpsertDoc(ctx, mo.datasource, mo.MainTableName, mo.conflictFilter(d, conflictCols), mo.upsertUpdate(d, conflictCols), projection)
+			if e != nil {
+				return nil, e
+			}
+			if mo.MutationReturn.ReturnDoc {
+				results = append(results, doc)
+			}
+		}
+		return results, nil
+	case module_model.QUERY_TYPE_DELETE:
+		filter, e := mo.mongoFilter(ctx, mo.WhereClause, mo.MainTableName)
+		if e != nil {
+			return nil, e
+		}
+		if mo.MutationReturn.ReturnDoc {
+			results, e = mongoMaker.Find(ctx, mo.datasource, mo.MainTableName, mongoFilterOrAll(filter), projection)
+			if e != nil {
+				return nil, e
+			}
+		}
+		_, e = mongoMaker.DeleteDocs(ctx, mo.datasource, mo.MainTableName, mongoFilterOrAll(filter))
+		if e != nil {
+			return nil, e
+		}
+		return results, nil
+	default:
+		return nil, errors.New(fmt.Sprint("Invalid mutation type ", mo.QueryType))
+	}
+}
+
+// mongoUpdate returns update which sets fields of the doc - pipeline update is returned if a value refers to other field with FIELD_ prefix
+func mongoUpdate(doc map[string]interface{}) interface{} {
+	set := bson.D{}
+	isPipeline := false
+	for _, k := range sortedMapKeys(doc) {
+		if str, ok := doc[k].(string); ok && strings.HasPrefix(str, "FIELD_") {
+			isPipeline = true
+			break
+		}
+	}
+	for _, k := range sortedMapKeys(doc) {
+		v := doc[k]
+		if !isPipeline {
+			set = append(set, bson.E{Key: k, Value: v})
+		} else if str, ok := v.(string); ok && strings.HasPrefix(str, "FIELD_") {
+			set = append(set, bson.E{Key: k, Value: "$" + strings.Replace(str, "FIELD_", "", 1)})
+		} else {
+			// values are literals in pipeline so that strings starting with $ are not read as field
+			set = append(set, bson.E{Key: k, Value: bson.D{{Key: "$literal", Value: v}}})
+		}
+	}
+	if isPipeline {
+		return []bson.D{{{Key: "$set", Value: set}}}
+	}
+	return bson.D{{Key: "$set", Value: set}}
+}
+
+// returnProjection returns projection of returning fields - nil returns all fields
+func (mo *MongoObjectM) returnProjection() bson.D {
+	if strings.TrimSpace(mo.MutationReturn.ReturnFields) == "*" {
+		return nil
+	}
+	projection := bson.D{}
+	idFound := false
+	for _, f := range strings.Split(mo.MutationReturn.ReturnFields, ",") {
+		projection = append(projection, bson.E{Key: f, Value: 1})
+		idFound = idFound || f == "_id"
+	}
+	if !idFound {
+		projection = append(bson.D{{Key: "_id", Value: 0}}, projection...)
+	}
+	return projection
+}
+
+// findByIds returns documents of the ids in same order as ids
+func (mo *MongoObjectM) findByIds(ctx context.Context, mongoMaker *ds.MongoSqlMaker, ids []interface{}, projection bson.D) (results []map[string]interface{}, err error) {
+	logs.WithContext(ctx).Debug("findByIds - Start")
+	// _id is fetched to order the documents and removed later if not asked
+	idProjection := projection
+	idRemoved := false
+	if projection != nil {
+		idProjection = bson.D{}
+		for _, p := range projection {
+			if p.Key == "_id" {
+				idRemoved = p.Value == 0
+				continue
+			}
+			idProjection = append(idProjection, p)
+		}
+		idProjection = append(idProjection, bson.E{Key: "_id", Value: 1})
+	}
+	docs, err := mongoMaker.Find(ctx, mo.datasource, mo.MainTableName, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}, idProjection)
+	if err != nil {
+		return nil, err
+	}
+	docsById := make(map[string]map[string]interface{}, len(docs))
+	for _, d := range docs {
+		docsById[fmt.Sprint(d["_id"])] = d
+		if idRemoved {
+			delete(d, "_id")
+		}
+	}
+	results = make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		if d, ok := docsById[fmt.Sprint(ds.NormalizeMongoValue(id))]; ok {
+			results = append(results, d)
+		}
+	}
+	return results, nil
+}
+
+// conflictFilter returns filter on conflict columns of the document - new _id is set if document has no _id so that it is inserted
+func (mo *MongoObjectM) conflictFilter(doc map[string]interface{}, conflictCols []string) bson.D {
+	filter := bson.D{}
+	for _, c := range conflictCols {
+		v, ok := doc[c]
+		if !ok && c == "_id" {
+			v = primitive.NewObjectID()
+		}
+		filter = append(filter, bson.E{Key: c, Value: v})
+	}
+	return filter
+}
+
+// upsertUpdate returns update which sets the update columns on conflict and other columns only if document is inserted
+func (mo *MongoObjectM) upsertUpdate(doc map[string]interface{}, conflictCols []string) bson.D {
+	isConflictCol := make(map[string]bool)
+	for _, c := range conflictCols {
+		isConflictCol[c] = true
+	}
+	isUpdateCol := make(map[string]bool)
+	for _, c := range mo.UpsertCols {
+		isUpdateCol[c] = true
+	}
+	set := bson.D{}
+	setOnInsert := bson.D{}
+	for _, k := range sortedMapKeys(doc) {
+		if isConflictCol[k] {
+			continue
+		}
+		if len(mo.UpsertCols) == 0 || isUpdateCol[k] {
+			set = append(set, bson.E{Key: k, Value: doc[k]})
+		} else {
+			setOnInsert = append(setOnInsert, bson.E{Key: k, Value: doc[k]})
+		}
+	}
+	update := bson.D{}
+	if len(set) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: set})
+	}
+	if len(setOnInsert) > 0 {
+		update = append(update, bson.E{Key: "$setOnInsert", Value: setOnInsert})
+	}
+	if len(update) == 0 {
+		// document has only conflict columns - nothing to update if it exists
+		update = append(update, bson.E{Key: "$setOnInsert", Value: bson.D{}})
+	}
+	return update
+}
+
+// mutationJson returns the mutation as extended json - it is returned as query when mutation is not executed
+func (mo *MongoObjectM) mutationJson(ctx context.Context) (string, error) {
+	_, collName := ds.SplitMongoTableName(mo.MainTableName, mo.datasource.DbConfig.DefaultDB)
+	cmd := bson.D{{Key: mo.QueryType, Value: collName}}
+	if mo.QueryType == module_model.QUERY_TYPE_UPDATE || mo.QueryType == module_model.QUERY_TYPE_DELETE {
+		filter, err := mo.mongoFilter(ctx, mo.WhereClause, mo.MainTableName)
+		if err != nil {
+			return "", err
+		}
+		cmd = append(cmd, bson.E{Key: "filter", Value: mongoFilterOrAll(filter)})
+	}
+	if len(mo.Docs) > 0 {
+		cmd = append(cmd, bson.E{Key: "docs", Value: mo.Docs})
+	}
+	b, err := bson.MarshalExtJSON(cmd, false, false)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return "", err
+	}
+	return string(b), nil
+}
+
+// mongoFilter translates where clause to mongo filter - operators and or clause are same as of sql datasources
+func (mo *mongoObject) mongoFilter(ctx context.Context, val interface{}, tableName string) (filter bson.M, err error) {
+	logs.WithContext(ctx).Debug("mongoFilter - Start")
+	if val == nil {
+		return nil, nil
+	}
+	where, ok := val.(map[string]interface{})
+	if !ok {
+		err = errors.New("Error : where clause is not an object")
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	var filters []bson.M
+	for _, k := range sortedMapKeys(where) {
+		v := where[k]
+		if v == nil {
+			continue
+		}
+		if k == "$or" || k == "or" {
+			orClause, isArray := v.([]interface{})
+			if !isArray {
+				err = errors.New("Error : or clause has single element")
+				logs.WithContext(ctx).Error(err.Error())
+				return nil, err
+			}
+			orFilters := bson.A{}
+			for _, o := range orClause {
+				f, e := mo.mongoFilter(ctx, o, tableName)
+				if e != nil {
+					return nil, e
+				}
+				orFilters = append(orFilters, mongoFilterOrAll(f))
+			}
+			filters = append(filters, bson.M{"$or": orFilters})
+			continue
+		}
+		f, e := mo.fieldFilter(ctx, mongoFieldPath(tableName, eru_utils.ReplaceUnderscoresWithDots(k)), v, tableName)
+		if e != nil {
+			return nil, e
+		}
+		filters = append(filters, f)
+	}
+	return combineMongoFilters(filters...), nil
+}
+
+// fieldFilter returns filter of the operators applied to the field - value which is not an object is compared for equality
+func (mo *mongoObject) fieldFilter(ctx context.Context, path string, val interface{}, tableName string) (filter bson.M, err error) {
+	ops, isMap := val.(map[string]interface{})
+	if !isMap {
+		return mo.compareFilter(path, "$eq", val, tableName), nil
+	}
+	var filters []bson.M
+	for _, op := range sortedMapKeys(ops) {
+		v := ops[op]
+		if v == nil {
+			continue
+		}
+		switch op {
+		case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte":
+			filters = append(filters, mo.compareFilter(path, op, v, tableName))
+		case "$in", "$nin":
+			if reflect.TypeOf(v).Kind() != reflect.Slice {
+				logs.WithContext(ctx).Warn(fmt.Sprint("skipping $in and $nin clause as it needs array as a value but recevied ", v))
+				continue
+			}
+			filters = append(filters, bson.M{path: bson.M{op: mo.mongoValue(tableName, path, v)}})
+		case "$like":
+			filters = append(filters, bson.M{path: bson.M{"$regex": likeToRegex(fmt.Sprint(v))}})
+		case "$btw":
+			btw, ok := v.(map[string]interface{})
+			if !ok {
+				err = errors.New("Error : between clause is not a map")
+				logs.WithContext(ctx).Error(err.Error())
+				return nil, err
+			}
+			filters = append(filters, bson.M{path: bson.M{"$gte": mo.mongoValue(tableName, path, btw["from"]), "$lte": mo.mongoValue(tableName, path, btw["to"])}})
+		case "$null":
+			if fmt.Sprint(v) == "true" {
+				filters = append(filters, bson.M{path: nil})
+			} else {
+				filters = append(filters, bson.M{path: bson.M{"$ne": nil}})
+			}
+		default:
+			// nested object refers to field of sub document
+			f, e := mo.fieldFilter(ctx, fmt.Sprint(path, ".", eru_utils.ReplaceUnderscoresWithDots(op)), v, tableName)
+			if e != nil {
+				return nil, e
+			}
+			filters = append(filters, f)
+		}
+	}
+	return combineMongoFilters(filters...), nil
+}
+
+// compareFilter returns comparison of the field with value - value with FIELD_ prefix is compared with the field it refers to
+func (mo *mongoObject) compareFilter(path string, op string, v interface{}, tableName string) bson.M {
+	if str, ok := v.(string); ok && strings.HasPrefix(str, "FIELD_") {
+		otherPath := mongoFieldPath(tableName, strings.Replace(str, "FIELD_", "", 1))
+		return bson.M{"$expr": bson.M{op: bson.A{"$" + path, "$" + otherPath}}}
+	}
+	return bson.M{path: bson.M{op: mo.mongoValue(tableName, path, v)}}
+}
+
+// mongoValue converts value received in json to bson type of the field - hex string is converted to object id and date string to date
+func (mo *mongoObject) mongoValue(tableName string, path string, v interface{}) interface{} {
+	dataType := mo.datasource.SchemaTables[tableName][path].DataType
+	if path == "_id" && dataType == "" {
+		dataType = "objectId"
+	}
+	switch val := v.(type) {
+	case []interface{}:
+		values := make([]interface{}, len(val))
+		for i, av := range val {
+			values[i] = mo.mongoValue(tableName, path, av)
+		}
+		return values
+	case string:
+		switch dataType {
+		case "objectId":
+			if oid, err := primitive.ObjectIDFromHex(val); err == nil {
+				return oid
+			}
+		case "date":
+			for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+				if t, err := time.Parse(layout, val); err == nil {
+					return t
+				}
+			}
+		}
+	}
+	return v
+}
+
+// mongoSecurityFilter returns filter of the select security rule of the table.
+// condition on a field and a variable is added to the filter and condition on variables alone is evaluated to match all or no documents.
+func (mo *mongoObject) mongoSecurityFilter(ctx context.Context, tableName string) (filter bson.M, err error) {
+	logs.WithContext(ctx).Debug("mongoSecurityFilter - Start")
+	sr, err := mo.store.GetTableSecurityRule(ctx, mo.ProjectId, mo.DbAlias, tableName)
+	if err != nil {
+		err = errors.New(fmt.Sprint("error from getTableSecurityRule = ", err.Error()))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	switch sr.Select.RuleType {
+	case module_model.RULETYPE_NONE:
+		err = errors.New("SecurityRule failed : Security Rule Set to NONE")
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	case module_model.RULETYPE_CUSTOM:
+		if len(sr.Select.CustomRule.AND) > 0 {
+			filter, err = mo.mongoRuleFilter(ctx, sr.Select.CustomRule.AND, "$and", tableName)
+		} else if len(sr.Select.CustomRule.OR) > 0 {
+			filter, err = mo.mongoRuleFilter(ctx, sr.Select.CustomRule.OR, "$or", tableName)
+		}
+		if err != nil {
+			err = errors.New(fmt.Sprint("SecurityRule failed : ", err.Error()))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+	default:
+		//do nothing
+	}
+	return filter, nil
+}
+
+func (mo *mongoObject) mongoRuleFilter(ctx context.Context, rules []security_rule.CustomRuleDetails, conditionType string, tableName string) (filter bson.M, err error) {
+	conditions := bson.A{}
+	for _, r := range rules {
+		var f bson.M
+		if len(r.AND) > 0 {
+			f, err = mo.mongoRuleFilter(ctx, r.AND, "$and", tableName)
+		} else if len(r.OR) > 0 {
+			f, err = mo.mongoRuleFilter(ctx, r.OR, "$or", tableName)
+		} else {
+			f, err = mo.mongoRuleCondition(ctx, r, tableName)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			conditions = append(conditions, f)
+		}
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+	return bson.M{conditionType: conditions}, nil
+}
+
+// mongoRuleCondition returns filter of the rule condition - Variable1 is the field unless it is a variable
+func (mo *mongoObject) mongoRuleCondition(ctx context.Context, cd security_rule.CustomRuleDetails, tableName string) (filter bson.M, err error) {
+	if security_rule.IsVariable(cd.Variable1) {
+		ok, e := security_rule.CustomRule{AND: []security_rule.CustomRuleDetails{cd}}.Evaluate(ctx, mo.FinalVariables)
+		if e != nil {
+			return nil, e
+		}
+		if ok {
+			return bson.M{}, nil
+		}
+		return bson.M{"$expr": false}, nil
+	}
+	path := mongoFieldPath(tableName, cd.Variable1)
+	str, err := security_rule.VariableValue(ctx, cd.Variable2, mo.FinalVariables)
+	if err != nil {
+		return nil, err
+	}
+	value := func(s string) interface{} {
+		return mo.mongoValue(tableName, path, ruleValue(cd.DataType, s))
+	}
+	switch cd.Operator {
+	case "eq", "ne", "gt", "gte", "lt", "lte":
+		return bson.M{path: bson.M{"$" + cd.Operator: value(str)}}, nil
+	case "in", "nin":
+		values := bson.A{}
+		for _, s := range strings.Split(str, ",") {
+			values = append(values, value(strings.Trim(strings.TrimSpace(s), "'\"")))
+		}
+		return bson.M{path: bson.M{"$" + cd.Operator: values}}, nil
+	case "btw":
+		btw := strings.Split(str, ",")
+		if len(btw) != 2 {
+			err = errors.New(fmt.Sprint("btw operator needs two comma separated values but received ", str))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+		return bson.M{path: bson.M{"$gte": value(strings.TrimSpace(btw[0])), "$lte": value(strings.TrimSpace(btw[1]))}}, nil
+	case "like":
+		return bson.M{path: bson.M{"$regex": likeToRegex(str)}}, nil
+	case "eq_null":
+		return bson.M{path: nil}, nil
+	case "neq_null":
+		return bson.M{path: bson.M{"$ne": nil}}, nil
+	default:
+		err = errors.New(fmt.Sprint("operator ", cd.Operator, " not supported"))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+}
+
+// likeToRegex returns anchored regex matching same values as the sql like pattern - % matches any characters and _ matches one character
+// unless escaped with a backslash, other characters are matched as is
+func likeToRegex(pattern string) string {
+	var sb strings.Builder
+	// s flag lets . match new line as % does
+	sb.WriteString("(?s)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			sb.WriteString(".*")
+		case r == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if escaped {
+		sb.WriteString(regexp.QuoteMeta("\\"))
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
+
+// ruleValue returns value of security rule as string if data type is string else as number or boolean it holds
+func ruleValue(dataType string, s string) interface{} {
+	if dataType == "string" {
+		return s
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return s
+}
+
+// mongoSort returns sort of the fields - field prefixed with - is sorted in descending order
+func mongoSort(ctx context.Context, val interface{}, tableName string) (sortStage bson.D, err error) {
+	if val == nil {
+		return nil, nil
+	}
+	var fields []interface{}
+	if arr, ok := val.([]interface{}); ok {
+		fields = arr
+	} else {
+		fields = []interface{}{val}
+	}
+	for _, f := range fields {
+		s, ok := f.(string)
+		if !ok {
+			err = errors.New(fmt.Sprint("sort by column position is not supported for mongo datasource : ", f))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+		order := 1
+		if strings.HasPrefix(s, "-") {
+			order = -1
+			s = strings.Replace(s, "-", "", 1)
+		}
+		sortStage = append(sortStage, bson.E{Key: mongoFieldPath(tableName, eru_utils.ReplaceUnderscoresWithDots(s)), Value: order})
+	}
+	return sortStage, nil
+}
+
+// mongoFieldPath returns path of the field in documents of the table - table name prefix is removed and rest is the path of sub document fields
+func mongoFieldPath(tableName string, name string) string {
+	if tableName == "" {
+		return name
+	}
+	_, collName := ds.SplitMongoTableName(tableName, "")
+	for _, prefix := range []string{tableName + ".", collName + "."} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return name
+}
+
+// combineMongoFilters returns filters joined by $and - nil is returned if there is no filter
+func combineMongoFilters(filters ...bson.M) bson.M {
+	var nonEmpty bson.A
+	for _, f := range filters {
+		if len(f) > 0 {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return nil
+	case 1:
+		return nonEmpty[0].(bson.M)
+	default:
+		return bson.M{"$and": nonEmpty}
+	}
+}
+
+func mongoFilterOrAll(filter bson.M) bson.M {
+	if filter == nil {
+		return bson.M{}
+	}
+	return filter
+}
+
+// mongoCsvRows returns documents as rows with column names as first row - nested documents are written as json
+func mongoCsvRows(ctx context.Context, docs []map[string]interface{}, colNames []string) (rows [][]interface{}, err error) {
+	if len(colNames) == 0 {
+		colNames = docKeys(docs)
+	}
+	header := make([]interface{}, len(colNames))
+	for i, c := range colNames {
+		header[i] = c
+	}
+	rows = append(rows, header)
+	for _, d := range docs {
+		row := make([]interface{}, len(colNames))
+		for i, c := range colNames {
+			v := d[c]
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				b, e := json.Marshal(v)
+				if e != nil {
+					logs.WithContext(ctx).Error(e.Error())
+					return nil, e
+				}
+				v = string(b)
+			}
+			row[i] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// docKeys returns keys of all documents in alphabetic order with _id first
+func docKeys(docs []map[string]interface{}) (keys []string) {
+	found := make(map[string]bool)
+	for _, d := range docs {
+		for k := range d {
+			if !found[k] {
+				found[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == "_id" || keys[j] == "_id" {
+			return keys[i] == "_id"
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func sortedMapKeys(m map[string]interface{}) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
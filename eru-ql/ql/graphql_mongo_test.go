@@ -0,0 +1,168 @@
+package ql
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/eru-tech/eru/eru-security-rule/security_rule"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func newMongoTestObject(t *testing.T, securityRules map[string]module_model.SecurityRules) mongoObject {
+	datasource := &module_model.DataSource{DbAlias: "mdb", DbName: "mongo", SchemaTables: map[string]map[string]module_model.TableColsMetaData{
+		"shop.orders": {"_id": {ColName: "_id", DataType: "objectId"}, "status": {ColName: "status", DataType: "string"}},
+		"shop.items":  {"order_id": {ColName: "order_id", DataType: "objectId"}, "qty": {ColName: "qty", DataType: "int"}},
+	}, TableJoins: map[string]*module_model.TableJoins{
+		"shop.orders___shop.items": {Table1Name: "shop.orders", Table1Cols: []string{"_id"}, Table2Name: "shop.items", Table2Cols: []string{"order_id"}},
+	}, SchemaTablesSecurity: securityRules}
+	s := &module_store.ModuleFileStore{}
+	s.Projects = map[string]*module_model.Project{"p": {ProjectId: "p", DataSources: map[string]*module_model.DataSource{"mdb": datasource}}}
+	return mongoObject{ProjectId: "p", DbAlias: "mdb", datasource: datasource, store: s, FinalVariables: map[string]interface{}{}}
+}
+
+func parseTestField(t *testing.T, query string) *ast.Field {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query)})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc.Definitions[0].(*ast.OperationDefinition).SelectionSet.Selections[0].(*ast.Field)
+}
+
+func TestMongoFilter(t *testing.T) {
+	ctx := context.Background()
+	mo := newMongoTestObject(t, nil)
+	tests := []struct {
+		name  string
+		where map[string]interface{}
+		want  bson.M
+	}{
+		{"equality", map[string]interface{}{"status": "new"}, bson.M{"status": bson.M{"$eq": "new"}}},
+		{"operators", map[string]interface{}{"qty": map[string]interface{}{"$gte": 1, "$lt": 5}}, bson.M{"$and": bson.A{bson.M{"qty": bson.M{"$gte": 1}}, bson.M{"qty": bson.M{"$lt": 5}}}}},
+		{"like", map[string]interface{}{"status": map[string]interface{}{"$like": "n_w%"}}, bson.M{"status": bson.M{"$regex": "(?s)^n.w.*$"}}},
+		{"null", map[string]interface{}{"status": map[string]interface{}{"$null": true}}, bson.M{"status": nil}},
+		{"or", map[string]interface{}{"or": []interface{}{map[string]interface{}{"status": "a"}, map[string]interface{}{"status": "b"}}}, bson.M{"$or": bson.A{bson.M{"status": bson.M{"$eq": "a"}}, bson.M{"status": bson.M{"$eq": "b"}}}}},
+		{"field reference", map[string]interface{}{"qty": map[string]interface{}{"$gt": "FIELD_min_qty"}}, bson.M{"$expr": bson.M{"$gt": bson.A{"$qty", "$min_qty"}}}},
+		{"in", map[string]interface{}{"status": map[string]interface{}{"$in": []interface{}{"a", "b"}}}, bson.M{"status": bson.M{"$in": []interface{}{"a", "b"}}}},
+		{"between", map[string]interface{}{"qty": map[string]interface{}{"$btw": map[string]interface{}{"from": 1, "to": 3}}}, bson.M{"qty": bson.M{"$gte": 1, "$lte": 3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mo.mongoFilter(ctx, tt.where, "shop.items")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLikeToRegex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"ab%", "abc", true},
+		{"ab%", "xabc", false},
+		{"a_c", "abc", true},
+		{"a_c", "abbc", false},
+		{"a.c", "abc", false},
+		{"%", "a\nb", true},
+		{`100\%`, "100%", true},
+		{`100\%`, "1000", false},
+	}
+	for _, tt := range tests {
+		if got := regexp.MustCompile(likeToRegex(tt.pattern)).MatchString(tt.value); got != tt.want {
+			t.Errorf("%q like %q = %v, want %v", tt.value, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestMongoSecurityFilter(t *testing.T) {
+	ctx := context.Background()
+	customRule := func(cds ...security_rule.CustomRuleDetails) module_model.SecurityRules {
+		return module_model.SecurityRules{Select: security_rule.SecurityRule{RuleType: module_model.RULETYPE_CUSTOM, CustomRule: security_rule.CustomRule{AND: cds}}}
+	}
+	tests := []struct {
+		name    string
+		rules   module_model.SecurityRules
+		want    bson.M
+		wantErr bool
+	}{
+		{"always", module_model.SecurityRules{Select: security_rule.SecurityRule{RuleType: module_model.RULETYPE_ALWAYS}}, nil, false},
+		{"none", module_model.SecurityRules{Select: security_rule.SecurityRule{RuleType: module_model.RULETYPE_NONE}}, nil, true},
+		{"field condition", customRule(security_rule.CustomRuleDetails{Variable1: "status", Variable2: "open", Operator: "eq", DataType: "string"}), bson.M{"$and": bson.A{bson.M{"status": bson.M{"$eq": "open"}}}}, false},
+		{"like condition", customRule(security_rule.CustomRuleDetails{Variable1: "status", Variable2: "op%", Operator: "like", DataType: "string"}), bson.M{"$and": bson.A{bson.M{"status": bson.M{"$regex": "(?s)^op.*$"}}}}, false},
+		{"in condition", customRule(security_rule.CustomRuleDetails{Variable1: "status", Variable2: "'a', 'b'", Operator: "in", DataType: "string"}), bson.M{"$and": bson.A{bson.M{"status": bson.M{"$in": bson.A{"a", "b"}}}}}, false},
+		{"unsupported operator", customRule(security_rule.CustomRuleDetails{Variable1: "status", Variable2: "a", Operator: "regex", DataType: "string"}), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mo := newMongoTestObject(t, map[string]module_model.SecurityRules{"shop.orders": tt.rules})
+			got, err := mo.mongoSecurityFilter(ctx, "shop.orders")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoProcessGraphQLLookup(t *testing.T) {
+	ctx := context.Background()
+	itemRule := module_model.SecurityRules{Select: security_rule.SecurityRule{RuleType: module_model.RULETYPE_CUSTOM, CustomRule: security_rule.CustomRule{AND: []security_rule.CustomRuleDetails{{Variable1: "qty", Variable2: "0", Operator: "gt", DataType: "number"}}}}}
+	mo := MongoObjectQ{mongoObject: newMongoTestObject(t, map[string]module_model.SecurityRules{"shop.items": itemRule})}
+	field := parseTestField(t, `{ shop___orders(where: {status: "new"}, limit: 5) @mdb { status items: shop___items { qty } } }`)
+	if err := mo.ProcessGraphQL(ctx, field); err != nil {
+		t.Fatal(err)
+	}
+	lookupPipeline := []bson.D{
+		{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$order_id", "$$v0"}}}}}}}}}},
+		{{Key: "$match", Value: bson.M{"$and": bson.A{bson.M{"qty": bson.M{"$gt": 0.0}}}}}},
+		{{Key: "$project", Value: bson.D{{Key: "_id", Value: 0}, {Key: "qty", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$qty", nil}}}}}}},
+	}
+	want := []bson.D{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$eq": "new"}}}},
+		{{Key: "$limit", Value: 5}},
+		{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "items"}, {Key: "let", Value: bson.D{{Key: "v0", Value: "$_id"}}}, {Key: "pipeline", Value: lookupPipeline}, {Key: "as", Value: "items"}}}},
+		{{Key: "$project", Value: bson.D{{Key: "_id", Value: 0}, {Key: "status", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$status", nil}}}}, {Key: "items", Value: 1}}}},
+	}
+	if !reflect.DeepEqual(mo.Pipeline, want) {
+		t.Errorf("pipeline = %v\nwant %v", mo.Pipeline, want)
+	}
+}
+
+func TestMongoUpsert(t *testing.T) {
+	mo := MongoObjectM{UpsertCols: []string{"qty"}}
+	doc := map[string]interface{}{"order_id": "o1", "sku": "s1", "qty": 2, "note": "x"}
+	filter := mo.conflictFilter(doc, []string{"order_id", "sku"})
+	if want := (bson.D{{Key: "order_id", Value: "o1"}, {Key: "sku", Value: "s1"}}); !reflect.DeepEqual(filter, want) {
+		t.Errorf("filter = %v, want %v", filter, want)
+	}
+	update := mo.upsertUpdate(doc, []string{"order_id", "sku"})
+	want := bson.D{{Key: "$set", Value: bson.D{{Key: "qty", Value: 2}}}, {Key: "$setOnInsert", Value: bson.D{{Key: "note", Value: "x"}}}}
+	if !reflect.DeepEqual(update, want) {
+		t.Errorf("update = %v, want %v", update, want)
+	}
+
+	// document without _id gets a new _id so that it is inserted
+	filter = mo.conflictFilter(map[string]interface{}{"qty": 1}, []string{"_id"})
+	if len(filter) != 1 || filter[0].Key != "_id" || filter[0].Value == nil {
+		t.Errorf("filter = %v", filter)
+	}
+	// document with only conflict columns is not changed if it exists
+	mo = MongoObjectM{}
+	if update = mo.upsertUpdate(map[string]interface{}{"_id": 1}, []string{"_id"}); !reflect.DeepEqual(update, bson.D{{Key: "$setOnInsert", Value: bson.D{}}}) {
+		t.Errorf("update = %v", update)
+	}
+}
@@ -0,0 +1,449 @@
+package ql
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	eru_reads "github.com/eru-tech/eru/eru-read-write/eru-reads"
+	server_handlers "github.com/eru-tech/eru/eru-server/server/handlers"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	IMPORT_FILE_CSV  = "csv"
+	IMPORT_FILE_XLSX = "xlsx"
+)
+
+// importRecord is a row of the file with values keyed on file column header
+type importRecord struct {
+	rowNo  int
+	values map[string]string
+}
+
+// FetchImportFile downloads file referred by the import from storage of eru-files
+func FetchImportFile(ctx context.Context, projectId string, ref module_model.FileRef) (fileBytes []byte, err error) {
+	logs.WithContext(ctx).Debug("FetchImportFile - Start")
+	if ref.StorageName == "" || ref.FileName == "" {
+		err = errors.New("storage_name and file_name of file_ref are mandatory")
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]string{"file_name": ref.FileName, "folder_path": ref.FolderPath})
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	url := fmt.Sprint(module_store.Erufilesbaseurl, "/files/", projectId, "/", ref.StorageName, "/download")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if requestId := logs.FieldValue(ctx, server_handlers.RequestIdKey); requestId != "" {
+		req.Header.Set(server_handlers.RequestIdKey, requestId)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		err = errors.New(fmt.Sprint("file download from eru-files failed : ", err.Error()))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	defer resp.Body.Close()
+	fileBytes, err = io.ReadAll(io.LimitReader(resp.Body, module_store.ImportMaxBytes+1))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	if int64(len(fileBytes)) > module_store.ImportMaxBytes {
+		err = errors.New(fmt.Sprint("file is larger than ", module_store.ImportMaxBytes, " bytes"))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		err = errors.New(fmt.Sprint("file download from eru-files failed with status ", resp.StatusCode, " : ", string(fileBytes)))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	return fileBytes, nil
+}
+
+// ImportFile loads rows of csv or excel file into the table.
+// file columns are mapped to table columns and their values are converted to data type of the column.
+// rows are validated with insert security rule of the table and inserted in batches within a single transaction.
+func ImportFile(ctx context.Context, projectId string, dbAlias string, tableName string, fileName string, fileBytes []byte, bi module_model.BulkImport, tokenObj map[string]interface{}, s module_store.ModuleStoreI) (result module_model.BulkImportResult, err error) {
+	logs.WithContext(ctx).Debug("ImportFile - Start")
+	datasource, err := s.GetDataSource(ctx, projectId, dbAlias)
+	if err != nil {
+		return result, err
+	}
+	if datasource.DbType != "sql" {
+		err = errors.New(fmt.Sprint("import is not supported for ", datasource.DbName, " datasource ", dbAlias))
+		logs.WithContext(ctx).Error(err.Error())
+		return result, err
+	}
+	tableCols, ok := datasource.SchemaTables[tableName]
+	if !ok {
+		err = errors.New(fmt.Sprint("Table ", tableName, " not found"))
+		logs.WithContext(ctx).Error(err.Error())
+		return result, err
+	}
+	sr, err := s.GetTableSecurityRule(ctx, projectId, dbAlias, tableName)
+	if err != nil {
+		return result, err
+	}
+	// insert rule is processed as for insert mutations - conditions on columns are then checked for each row with the row as docs
+	if _, err = processSecurityRule(ctx, sr.Insert, map[string]interface{}{module_model.RULEPREFIX_TOKEN: tokenObj}); err != nil {
+		err = errors.New(fmt.Sprint("SecurityRule failed : ", err.Error()))
+		logs.WithContext(ctx).Error(err.Error())
+		return result, err
+	}
+	if datasource.SchemaTablesAudit[tableName].Audits(module_model.QUERY_TYPE_INSERT) {
+		err = errors.New(fmt.Sprint("import into audited table ", tableName, " is not supported - rows are to be inserted with insert mutation"))
+		logs.WithContext(ctx).Error(err.Error())
+		return result, err
+	}
+
+	headers, records, err := readImportFile(ctx, fileName, fileBytes, bi)
+	if err != nil {
+		return result, err
+	}
+	fileCols, cols, err := mapImportColumns(ctx, headers, tableCols, bi.ColumnMap)
+	if err != nil {
+		return result, err
+	}
+	result.TotalRows = len(records)
+
+	// rows are transformed with input rule of the table before their values are coerced, as docs of insert mutations are
+	docs := make([]map[string]interface{}, len(records))
+	for i, rec := range records {
+		docs[i] = make(map[string]interface{}, len(cols))
+		for j, c := range cols {
+			docs[i][c] = rec.values[fileCols[j]]
+		}
+	}
+	tr, err := getImportTransformRule(ctx, projectId, dbAlias, tableName, s)
+	if err != nil {
+		return result, err
+	}
+	if hasTransformRules(tr) {
+		trVars := map[string]interface{}{module_model.RULEPREFIX_TOKEN: tokenObj}
+		for i := range docs {
+			if docs[i], err = transformDoc(ctx, tr, docs[i], trVars); err != nil {
+				err = errors.New(fmt.Sprint("TransformRule failed : ", err.Error()))
+				logs.WithContext(ctx).Error(err.Error())
+				return result, err
+			}
+		}
+		if cols, err = transformedImportColumns(ctx, cols, docs, tableCols); err != nil {
+			return result, err
+		}
+	}
+	colFile := make(map[string]string, len(fileCols))
+	for i, fc := range fileCols {
+		colFile[cols[i]] = fc
+	}
+
+	var rows []ds.ImportRow
+	for i, rec := range records {
+		row := ds.ImportRow{RowNo: rec.rowNo, Values: make([]interface{}, len(cols))}
+		doc := make(map[string]interface{}, len(cols))
+		var rowErr *module_model.BulkImportRowError
+		for j, c := range cols {
+			var v interface{}
+			if raw, ok := docs[i][c]; ok && raw != nil {
+				var e error
+				v, e = ds.CoerceImportValue(tableCols[c], fmt.Sprint(raw))
+				if e != nil {
+					errCol := c
+					if fc, ok := colFile[c]; ok {
+						errCol = fc
+					}
+					rowErr = &module_model.BulkImportRowError{RowNo: rec.rowNo, Column: errCol, Error: e.Error()}
+					break
+				}
+			}
+			row.Values[j] = v
+			doc[c] = v
+		}
+		if rowErr == nil && sr.Insert.RuleType == module_model.RULETYPE_CUSTOM {
+			allowed, e := sr.Insert.CustomRule.Evaluate(ctx, map[string]interface{}{module_model.RULEPREFIX_TOKEN: tokenObj, module_model.RULEPREFIX_DOCS: doc})
+			if e != nil || !allowed {
+				rowErr = &module_model.BulkImportRowError{RowNo: rec.rowNo, Error: "insert security rule failed"}
+			}
+		}
+		if rowErr != nil {
+			result.Errors = append(result.Errors, *rowErr)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if bi.StopOnError && len(result.Errors) > 0 {
+		result.FailedRows = len(result.Errors)
+		return result, nil
+	}
+	if len(rows) > 0 {
+		sqlMaker := ds.GetSqlMaker(datasource.DbName)
+		imported, rowErrors, e := ds.ImportRows(ctx, datasource, sqlMaker, tableName, cols, rows, bi.BatchSize, bi.StopOnError)
+		if e != nil {
+			return result, e
+		}
+		result.ImportedRows = imported
+		if imported > 0 {
+			InvalidateCacheTables(ctx, projectId, dbAlias, tableName)
+		}
+		result.Errors = append(result.Errors, rowErrors...)
+		sort.SliceStable(result.Errors, func(i, j int) bool {
+			return result.Errors[i].RowNo < result.Errors[j].RowNo
+		})
+	}
+	result.FailedRows = len(result.Errors)
+	return result, nil
+}
+
+// getImportTransformRule returns input transform rule of the table if it is applied on insert
+func getImportTransformRule(ctx context.Context, projectId string, dbAlias string, tableName string, s module_store.ModuleStoreI) (transformRule module_model.TransformRule, err error) {
+	tr, err := s.GetTableTransformation(ctx, projectId, dbAlias, tableName)
+	if err != nil {
+		err = errors.New(fmt.Sprint("error from GetTableTransformation = ", err.Error()))
+		logs.WithContext(ctx).Error(err.Error())
+		return transformRule, err
+	}
+	for _, v := range tr.TransformInput.ApplyOn {
+		if v == module_model.QUERY_TYPE_INSERT {
+			return tr.TransformInput, nil
+		}
+	}
+	return transformRule, nil
+}
+
+// transformedImportColumns returns table columns to import after rows are transformed - columns removed from all rows are dropped
+// and columns added by the rule are appended after mapped columns. column removed from only some rows is imported as null in them.
+func transformedImportColumns(ctx context.Context, cols []string, docs []map[string]interface{}, tableCols map[string]module_model.TableColsMetaData) (newCols []string, err error) {
+	found := make(map[string]bool)
+	for _, doc := range docs {
+		for k := range doc {
+			found[k] = true
+		}
+	}
+	for _, c := range cols {
+		if found[c] {
+			newCols = append(newCols, c)
+			delete(found, c)
+		}
+	}
+	var addedCols []string
+	for k := range found {
+		if _, ok := tableCols[k]; !ok {
+			err = errors.New(fmt.Sprint("column ", k, " set by transform rule not found in table"))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, err
+		}
+		addedCols = append(addedCols, k)
+	}
+	sort.Strings(addedCols)
+	newCols = append(newCols, addedCols...)
+	if len(newCols) == 0 {
+		err = errors.New("no column is left to import after transform rule")
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	return newCols, nil
+}
+
+// readImportFile returns column headers and non empty rows of the file - type of file is taken from its extension if not set in import
+func readImportFile(ctx context.Context, fileName string, fileBytes []byte, bi module_model.BulkImport) (headers []string, records []importRecord, err error) {
+	logs.WithContext(ctx).Debug("readImportFile - Start")
+	fileType := strings.ToLower(bi.FileType)
+	if fileType == "" {
+		fileType = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
+	}
+	switch fileType {
+	case IMPORT_FILE_CSV:
+		return readImportCsv(ctx, fileBytes, bi.Delimiter)
+	case IMPORT_FILE_XLSX:
+		return readImportExcel(ctx, fileBytes, bi.Sheet)
+	default:
+		err = errors.New(fmt.Sprint("file type '", fileType, "' is not supported - only csv and xlsx files can be imported"))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, nil, err
+	}
+}
+
+func readImportCsv(ctx context.Context, fileBytes []byte, delimiter string) (headers []string, records []importRecord, err error) {
+	logs.WithContext(ctx).Debug("readImportCsv - Start")
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(fileBytes, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	if delimiter != "" {
+		d, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) {
+			err = errors.New(fmt.Sprint("delimiter '", delimiter, "' should be a single character"))
+			logs.WithContext(ctx).Error(err.Error())
+			return nil, nil, err
+		}
+		reader.Comma = d
+	}
+	lines, err := reader.ReadAll()
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, nil, err
+	}
+	if len(lines) == 0 {
+		err = errors.New("file is empty")
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, nil, err
+	}
+	headers = lines[0]
+	for i, line := range lines[1:] {
+		values := make(map[string]string, len(headers))
+		for c, h := range headers {
+			if c < len(line) {
+				values[h] = line[c]
+			}
+		}
+		if rec := (importRecord{rowNo: i + 2, values: values}); !rec.isEmpty() {
+			records = append(records, rec)
+		}
+	}
+	return headers, records, nil
+}
+
+func readImportExcel(ctx context.Context, fileBytes []byte, sheet string) (headers []string, records []importRecord, err error) {
+	logs.WithContext(ctx).Debug("readImportExcel - Start")
+	sheetName := sheet
+	if sheetName == "" {
+		sheetName = "*"
+	}
+	erd := eru_reads.ExcelReadData{Sheets: map[string]eru_reads.FileReadData{sheetName: {HeaderRow: 0, DataStartRow: 2}}}
+	readOutput, err := erd.ReadAsJson(ctx, fileBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(readOutput) > 1 {
+		err = errors.New("excel file has more than one sheet - sheet to import is mandatory")
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, nil, err
+	}
+	var sheetRows []map[string]interface{}
+	for _, v := range readOutput {
+		sheetRows, _ = v.([]map[string]interface{})
+	}
+	if len(sheetRows) == 0 {
+		err = errors.New("file is empty")
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, nil, err
+	}
+	for h := range sheetRows[0] {
+		headers = append(headers, h)
+	}
+	sort.Strings(headers)
+	for i, sr := range sheetRows {
+		values := make(map[string]string, len(sr))
+		for k, v := range sr {
+			values[k] = fmt.Sprint(v)
+		}
+		// data starts from second row of the sheet
+		if rec := (importRecord{rowNo: i + 2, values: values}); !rec.isEmpty() {
+			records = append(records, rec)
+		}
+	}
+	return headers, records, nil
+}
+
+func (rec importRecord) isEmpty() bool {
+	for _, v := range rec.values {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// mapImportColumns returns file column headers and table columns they are imported into in the same order.
+// with column map, only mapped headers are imported - else headers matching table columns ignoring case are imported.
+// a table column can be imported from only one file column.
+func mapImportColumns(ctx context.Context, headers []string, tableCols map[string]module_model.TableColsMetaData, columnMap map[string]string) (fileCols []string, cols []string, err error) {
+	logs.WithContext(ctx).Debug("mapImportColumns - Start")
+	headerFound := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		headerFound[h] = true
+	}
+	mappedFrom := make(map[string]string)
+	mapCol := func(h string, c string) error {
+		if fh, ok := mappedFrom[c]; ok {
+			e := errors.New(fmt.Sprint("column ", c, " is mapped to more than one file column - '", fh, "' and '", h, "'"))
+			logs.WithContext(ctx).Error(e.Error())
+			return e
+		}
+		mappedFrom[c] = h
+		fileCols = append(fileCols, h)
+		cols = append(cols, c)
+		return nil
+	}
+	if len(columnMap) > 0 {
+		for _, h := range headers {
+			c, ok := columnMap[h]
+			if !ok {
+				continue
+			}
+			if _, ok = tableCols[c]; !ok {
+				err = errors.New(fmt.Sprint("column ", c, " mapped to file column '", h, "' not found in table"))
+				logs.WithContext(ctx).Error(err.Error())
+				return nil, nil, err
+			}
+			if err = mapCol(h, c); err != nil {
+				return nil, nil, err
+			}
+		}
+		// unmapped header of a mapped column would be silently ignored
+		for _, h := range headers {
+			if _, ok := columnMap[h]; ok {
+				continue
+			}
+			for c, fh := range mappedFrom {
+				if strings.EqualFold(strings.TrimSpace(h), c) {
+					err = errors.New(fmt.Sprint("column ", c, " is mapped to more than one file column - '", fh, "' and '", h, "'"))
+					logs.WithContext(ctx).Error(err.Error())
+					return nil, nil, err
+				}
+			}
+		}
+		for h := range columnMap {
+			if !headerFound[h] {
+				err = errors.New(fmt.Sprint("mapped column '", h, "' not found in file"))
+				logs.WithContext(ctx).Error(err.Error())
+				return nil, nil, err
+			}
+		}
+	} else {
+		for _, h := range headers {
+			for c := range tableCols {
+				if strings.EqualFold(strings.TrimSpace(h), c) {
+					if err = mapCol(h, c); err != nil {
+						return nil, nil, err
+					}
+					break
+				}
+			}
+		}
+	}
+	if len(cols) == 0 {
+		err = errors.New("no column of the file matches columns of the table")
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, nil, err
+	}
+	return fileCols, cols, nil
+}
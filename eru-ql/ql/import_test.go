@@ -0,0 +1,187 @@
+package ql
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/eru-tech/eru/eru-security-rule/security_rule"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportFile(t *testing.T) {
+	ctx := context.Background()
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbType: "sql", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db")},
+		SchemaTables: map[string]map[string]module_model.TableColsMetaData{"customers": {
+			"id":     {ColName: "id", OwnDataType: "Integer", PrimaryKey: true},
+			"region": {ColName: "region", OwnDataType: "String"},
+		}}}
+	if err := ds.GetSqlMaker("sqlite").CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+	if _, err := datasource.Con.Exec("create table customers (id integer primary key, region text)"); err != nil {
+		t.Fatal(err)
+	}
+	s := &module_store.ModuleFileStore{}
+	s.Projects = map[string]*module_model.Project{"p": {ProjectId: "p", DataSources: map[string]*module_model.DataSource{"db": datasource}}}
+	file := []byte("id,region\n1,east\n2,west\n")
+	token := map[string]interface{}{"region": "east"}
+
+	tests := []struct {
+		name         string
+		rule         security_rule.SecurityRule
+		audit        module_model.AuditConfig
+		wantErr      string
+		wantImported int
+	}{
+		{"rule type none", security_rule.SecurityRule{RuleType: module_model.RULETYPE_NONE}, module_model.AuditConfig{}, "SecurityRule failed : Security Rule Set to NONE", 0},
+		{"audited table", security_rule.SecurityRule{RuleType: module_model.RULETYPE_ALWAYS}, module_model.AuditConfig{Enabled: true}, "import into audited table customers is not supported", 0},
+		{"insert not audited", security_rule.SecurityRule{RuleType: module_model.RULETYPE_ALWAYS}, module_model.AuditConfig{Enabled: true, Operations: []string{"delete"}}, "", 2},
+		{"custom rule on rows", security_rule.SecurityRule{RuleType: module_model.RULETYPE_CUSTOM, CustomRule: security_rule.CustomRule{AND: []security_rule.CustomRuleDetails{
+			{Variable1: "docs.region", Variable2: "token.region", Operator: "eq", DataType: "string"}}}}, module_model.AuditConfig{}, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := datasource.Con.Exec("delete from customers"); err != nil {
+				t.Fatal(err)
+			}
+			datasource.SchemaTablesSecurity = map[string]module_model.SecurityRules{"customers": {Insert: tt.rule}}
+			datasource.SchemaTablesAudit = map[string]module_model.AuditConfig{"customers": tt.audit}
+			result, err := ImportFile(ctx, "p", "db", "customers", "customers.csv", file, module_model.BulkImport{}, token, s)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.ImportedRows != tt.wantImported || result.FailedRows != result.TotalRows-tt.wantImported {
+				t.Errorf("result = %+v, want %d imported rows", result, tt.wantImported)
+			}
+		})
+	}
+}
+
+func TestImportFileTransform(t *testing.T) {
+	ctx := context.Background()
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbType: "sql", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db")},
+		SchemaTables: map[string]map[string]module_model.TableColsMetaData{"customers": {
+			"id":     {ColName: "id", OwnDataType: "Integer", PrimaryKey: true},
+			"region": {ColName: "region", OwnDataType: "String", IsNullable: true},
+			"note":   {ColName: "note", OwnDataType: "String", IsNullable: true},
+		}}}
+	if err := ds.GetSqlMaker("sqlite").CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+	if _, err := datasource.Con.Exec("create table customers (id integer primary key, region text, note text)"); err != nil {
+		t.Fatal(err)
+	}
+	s := &module_store.ModuleFileStore{}
+	s.Projects = map[string]*module_model.Project{"p": {ProjectId: "p", DataSources: map[string]*module_model.DataSource{"db": datasource}}}
+	datasource.SchemaTablesSecurity = map[string]module_model.SecurityRules{"customers": {Insert: security_rule.SecurityRule{RuleType: module_model.RULETYPE_ALWAYS}}}
+	file := []byte("id,note\n1,a\n2,b\n")
+
+	tests := []struct {
+		name    string
+		tr      module_model.TransformRule
+		wantErr bool
+		want    string
+	}{
+		{"rule not applied on insert", module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, ApplyOn: []string{module_model.QUERY_TYPE_UPDATE},
+			Rules: []module_model.TransformRuleDetail{{ForceColumnValues: map[string]string{"region": "token.{{.region}}"}}}}, false, "1::a,2::b"},
+		{"column added and removed", module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, ApplyOn: []string{module_model.QUERY_TYPE_INSERT},
+			Rules: []module_model.TransformRuleDetail{{ForceColumnValues: map[string]string{"region": "token.{{.region}}"}, RemoveColumnValues: []string{"note"}}}}, false, "1:east:,2:east:"},
+		{"column not in table", module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, ApplyOn: []string{module_model.QUERY_TYPE_INSERT},
+			Rules: []module_model.TransformRuleDetail{{ForceColumnValues: map[string]string{"zone": "none.x"}}}}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := datasource.Con.Exec("delete from customers"); err != nil {
+				t.Fatal(err)
+			}
+			datasource.SchemaTablesTransformation = map[string]module_model.TransformRules{"customers": {TransformInput: tt.tr}}
+			result, err := ImportFile(ctx, "p", "db", "customers", "customers.csv", file, module_model.BulkImport{}, map[string]interface{}{"region": "east"}, s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if result.ImportedRows != 2 {
+				t.Fatalf("result = %+v, want 2 imported rows", result)
+			}
+			var got []string
+			if err = datasource.Con.Select(&got, "select id || ':' || coalesce(region, '') || ':' || coalesce(note, '') from customers order by id"); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("rows = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapImportColumns(t *testing.T) {
+	ctx := context.Background()
+	tableCols := map[string]module_model.TableColsMetaData{"id": {ColName: "id"}, "region": {ColName: "region"}}
+	tests := []struct {
+		name      string
+		headers   []string
+		columnMap map[string]string
+		wantCols  []string
+		wantErr   string
+	}{
+		{"headers matched ignoring case", []string{"ID", " Region ", "other"}, nil, []string{"id", "region"}, ""},
+		{"duplicate headers ignoring case", []string{"id", "Region", "region"}, nil, nil, "column region is mapped to more than one file column - 'Region' and 'region'"},
+		{"unmapped header ignored", []string{"code", "area", "other"}, map[string]string{"code": "id", "area": "region"}, []string{"id", "region"}, ""},
+		{"two headers mapped to a column", []string{"code", "area", "zone"}, map[string]string{"code": "id", "area": "region", "zone": "region"}, nil, "column region is mapped to more than one file column"},
+		{"mapped column with matching header", []string{"code", "area", "Region"}, map[string]string{"code": "id", "area": "region"}, nil, "column region is mapped to more than one file column - 'area' and 'Region'"},
+		{"mapped column not in table", []string{"code"}, map[string]string{"code": "zone"}, nil, "column zone mapped to file column 'code' not found in table"},
+		{"mapped header not in file", []string{"code"}, map[string]string{"code": "id", "area": "region"}, nil, "mapped column 'area' not found in file"},
+		{"no matching header", []string{"other"}, nil, nil, "no column of the file matches columns of the table"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cols, err := mapImportColumns(ctx, tt.headers, tableCols, tt.columnMap)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(cols, ",") != strings.Join(tt.wantCols, ",") {
+				t.Errorf("cols = %v, want %v", cols, tt.wantCols)
+			}
+		})
+	}
+}
+
+func TestFetchImportFileLimit(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("id,region\n1,east\n"))
+	}))
+	defer files.Close()
+	baseUrl, maxBytes := module_store.Erufilesbaseurl, module_store.ImportMaxBytes
+	defer func() { module_store.Erufilesbaseurl, module_store.ImportMaxBytes = baseUrl, maxBytes }()
+	module_store.Erufilesbaseurl = files.URL
+	ref := module_model.FileRef{StorageName: "s", FileName: "customers.csv"}
+
+	if fileBytes, err := FetchImportFile(context.Background(), "p", ref); err != nil || len(fileBytes) != 17 {
+		t.Fatalf("file = %q, err %v", fileBytes, err)
+	}
+	module_store.ImportMaxBytes = 10
+	if _, err := FetchImportFile(context.Background(), "p", ref); err == nil {
+		t.Error("error is expected for file larger than the limit")
+	}
+}
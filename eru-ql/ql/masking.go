@@ -0,0 +1,154 @@
+package ql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"strings"
+)
+
+// columnMasks holds masking of the columns to be masked for the token of the request.
+// it is keyed by table name as it appears in the result (schema___table) and then by column name in result.
+type columnMasks map[string]map[string]module_model.ColumnMasking
+
+// addMask adds masking of the table column against the result column name if it applies for the token in vars.
+// errors while evaluating the rule are logged and the column is masked.
+func (cms columnMasks) addMask(ctx context.Context, datasource *module_model.DataSource, tableName string, colName string, resultTable string, resultCol string, vars map[string]interface{}) {
+	cm, ok := datasource.GetColumnMasking(tableName, colName)
+	if !ok {
+		return
+	}
+	applies, err := cm.Applies(ctx, vars)
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("masking rule failed for ", tableName, ".", colName, " - masking value : ", err.Error()))
+	}
+	if !applies {
+		return
+	}
+	if cms[resultTable] == nil {
+		cms[resultTable] = make(map[string]module_model.ColumnMasking)
+	}
+	cms[resultTable][resultCol] = cm
+}
+
+// flatten returns masks of all tables keyed by column name - used for tabular results which have no table names
+func (cms columnMasks) flatten() map[string]module_model.ColumnMasking {
+	flat := make(map[string]module_model.ColumnMasking)
+	for _, cols := range cms {
+		for k, v := range cols {
+			flat[k] = v
+		}
+	}
+	return flat
+}
+
+// maskRecords masks columns of records of the table - nested records of child tables are masked with masks of the child table
+func (cms columnMasks) maskRecords(ctx context.Context, records interface{}, tableName string) {
+	recs, ok := records.([]interface{})
+	if !ok {
+		return
+	}
+	for _, r := range recs {
+		record, rok := r.(map[string]interface{})
+		if !rok {
+			continue
+		}
+		for k, v := range record {
+			if cm, mok := cms[tableName][k]; mok {
+				record[k] = cm.Mask(ctx, v)
+			} else if _, nested := v.([]interface{}); nested {
+				cms.maskRecords(ctx, v, k)
+			}
+		}
+	}
+}
+
+// maskRows masks columns of rows returned as map of column name and value
+func maskRows(ctx context.Context, rows interface{}, masks map[string]module_model.ColumnMasking) {
+	recs, ok := rows.([]map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, record := range recs {
+		for k, cm := range masks {
+			if v, vok := record[k]; vok {
+				record[k] = cm.Mask(ctx, v)
+			}
+		}
+	}
+}
+
+// maskCsvRows masks columns of rows returned for csv and excel output - first row holds the column names
+func maskCsvRows(ctx context.Context, rows interface{}, masks map[string]module_model.ColumnMasking) {
+	recs, ok := rows.([][]interface{})
+	if !ok || len(recs) == 0 {
+		return
+	}
+	for i, h := range recs[0] {
+		cm, mok := masks[fmt.Sprint(h)]
+		if !mok {
+			continue
+		}
+		for _, record := range recs[1:] {
+			if i < len(record) {
+				record[i] = cm.Mask(ctx, record[i])
+			}
+		}
+	}
+}
+
+// getSqlColumnMasks returns masks of columns of the tables referred in the sql query.
+// result columns of a sql query cannot be traced to their table, so columns are matched on name.
+// query is refused if it refers a masked column other than as a plain column of the select list e.g. with an alias, in an expression
+// or in a where clause, as its value would then be returned or revealed under another name.
+func getSqlColumnMasks(ctx context.Context, query string, datasource *module_model.DataSource, vars map[string]interface{}) (map[string]module_model.ColumnMasking, error) {
+	logs.WithContext(ctx).Debug("getSqlColumnMasks - Start")
+	cms := make(columnMasks)
+	for _, tableName := range getSqlTableNames(query, datasource) {
+		for colName := range datasource.SchemaTables[tableName] {
+			cms.addMask(ctx, datasource, tableName, colName, tableName, colName, vars)
+		}
+	}
+	masks := cms.flatten()
+	if len(masks) == 0 {
+		return masks, nil
+	}
+	for _, loc := range sqlWordRegex.FindAllStringIndex(query, -1) {
+		word := query[loc[0]:loc[1]]
+		for colName := range masks {
+			if strings.EqualFold(word, colName) && !isPlainSelectCol(query, loc[0], loc[1]) {
+				err := errors.New(fmt.Sprint("masked column ", colName, " can only be selected as is in query"))
+				logs.WithContext(ctx).Error(err.Error())
+				return nil, err
+			}
+		}
+	}
+	return masks, nil
+}
+
+// isPlainSelectCol returns true if word of the query between start and end is a column of the select list without alias or expression
+// e.g. ssn in select t.ssn , name from t
+func isPlainSelectCol(query string, start int, end int) bool {
+	before := strings.TrimRight(query[:start], "\"`[")
+	for strings.HasSuffix(before, ".") {
+		// column qualified with table name
+		before = strings.TrimRight(before[:len(before)-1], "\"`]")
+		before = strings.TrimRightFunc(before, isSqlWordRune)
+		before = strings.TrimRight(before, "\"`[")
+	}
+	before = strings.ToLower(strings.TrimSpace(before))
+	after := strings.ToLower(strings.TrimSpace(strings.TrimLeft(query[end:], "\"`]")))
+	prevOk := strings.HasSuffix(before, ",") || endsWithSqlWord(before, "select") || endsWithSqlWord(before, "distinct")
+	nextOk := after == "" || strings.HasPrefix(after, ",") || strings.HasPrefix(after, ";") || startsWithSqlWord(after, "from")
+	return prevOk && nextOk
+}
+
+func endsWithSqlWord(str string, word string) bool {
+	return strings.HasSuffix(str, word) && (len(str) == len(word) || !isSqlWordRune(rune(str[len(str)-len(word)-1])))
+}
+
+func startsWithSqlWord(str string, word string) bool {
+	return strings.HasPrefix(str, word) && (len(str) == len(word) || !isSqlWordRune(rune(str[len(word)])))
+}
@@ -0,0 +1,75 @@
+package ql
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"testing"
+)
+
+func newMaskingTestDataSource() *module_model.DataSource {
+	ssnMask := module_model.ColumnMasking{MaskingType: module_model.MASKING_TYPE_FULL}
+	return &module_model.DataSource{SchemaTables: map[string]map[string]module_model.TableColsMetaData{
+		"public.users":      {"id": {ColName: "id"}, "name": {ColName: "name"}, "ssn": {ColName: "ssn", ColumnMasking: ssnMask}},
+		"public.user_roles": {"id": {ColName: "id"}, "secret": {ColName: "secret", ColumnMasking: ssnMask}},
+	}}
+}
+
+func TestGetSqlColumnMasks(t *testing.T) {
+	ctx := context.Background()
+	datasource := newMaskingTestDataSource()
+	tests := []struct {
+		name      string
+		query     string
+		wantMasks []string
+		wantErr   bool
+	}{
+		{"plain column", "select id, ssn from users", []string{"ssn"}, false},
+		{"qualified and quoted column", `select u.name , "u"."ssn" from public.users u`, []string{"ssn"}, false},
+		{"select star", "select * from users", []string{"ssn"}, false},
+		{"table name within another table name", "select id, secret from user_roles", []string{"secret"}, false},
+		{"table not in query", "select ssn from customers", nil, false},
+		{"alias", "select ssn as x from users", nil, true},
+		{"expression", "select upper(ssn) x from users", nil, true},
+		{"where clause", "select id from users where ssn = '1'", nil, true},
+		{"sub query alias", "select * from (select ssn x from users) q", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			masks, err := getSqlColumnMasks(ctx, tt.query, datasource, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(masks) != len(tt.wantMasks) {
+				t.Fatalf("masks = %v, want %v", masks, tt.wantMasks)
+			}
+			for _, m := range tt.wantMasks {
+				if _, ok := masks[m]; !ok {
+					t.Errorf("column %s is not masked", m)
+				}
+			}
+		})
+	}
+}
+
+func TestAddCalcColumnMask(t *testing.T) {
+	ctx := context.Background()
+	datasource := newMaskingTestDataSource()
+	tests := []struct {
+		name     string
+		calc     string
+		wantMask bool
+	}{
+		{"unqualified masked column", "upper(ssn)", true},
+		{"qualified masked column", "concat(public.users.name, public.users.ssn)", true},
+		{"no masked column", "concat(name, id)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlObj := SQLObjectQ{MainTableName: "public.users"}
+			sqlObj.addCalcColumnMask(ctx, datasource, "public.users", "", tt.calc, "public___users", "x")
+			if _, ok := sqlObj.masks["public___users"]["x"]; ok != tt.wantMask {
+				t.Errorf("calc column masked = %v, want %v", ok, tt.wantMask)
+			}
+		})
+	}
+}
@@ -13,17 +13,22 @@ import (
 )
 
 type QLData struct {
-	Query          string                     `json:"query"`
-	Variables      map[string]interface{}     `json:"variables"`
-	FinalVariables map[string]interface{}     `json:"-"`
-	ExecuteFlag    bool                       `json:"-"`
-	SecurityRule   security_rule.SecurityRule `json:"security_rule"`
-	IsPublic       bool                       `json:"is_public"`
-	OutputType     string                     `json:"output_type"`
+	Query          string                      `json:"query"`
+	Variables      map[string]interface{}      `json:"variables"`
+	FinalVariables map[string]interface{}      `json:"-"`
+	ExecuteFlag    bool                        `json:"-"`
+	SecurityRule   security_rule.SecurityRule  `json:"security_rule"`
+	IsPublic       bool                        `json:"is_public"`
+	OutputType     string                      `json:"output_type"`
+	TimeOut        int                         `json:"timeout"`
+	Params         []module_model.MyQueryParam `json:"params"`
+	Cache          module_model.CacheConfig    `json:"cache"`
+	stream         *StreamWriter
 }
 
 type QueryObject struct {
 	Query string
+	Args  []interface{}
 	Cols  string
 	Type  string
 }
@@ -31,7 +36,13 @@ type QueryObject struct {
 type QL interface {
 	Execute(ctx context.Context, projectId string, datasources map[string]*module_model.DataSource, s module_store.ModuleStoreI, outputType string) (res []map[string]interface{}, queryObjs []QueryObject, err error)
 	SetQLData(ctx context.Context, mq module_model.MyQuery, vars map[string]interface{}, executeFlag bool, tokenObj map[string]interface{}, isPublic bool, outputType string)
-	ProcessTransformRule(ctx context.Context, tr module_model.TransformRule) (outputObj map[string]interface{}, err error)
+	ProcessTransformRule(ctx context.Context, tr module_model.TransformRule, doc map[string]interface{}) (outputDoc map[string]interface{}, err error)
+	SetStreamWriter(sw *StreamWriter)
+}
+
+// SetStreamWriter sets writer to stream rows of the query to - result of the query is not returned if it is streamed
+func (qld *QLData) SetStreamWriter(sw *StreamWriter) {
+	qld.stream = sw
 }
 
 func (qld *QLData) SetQLDataCommon(ctx context.Context, mq module_model.MyQuery, vars map[string]interface{}, executeFlag bool, tokenObj map[string]interface{}, isPublic bool, outputType string) (err error) {
@@ -45,6 +56,7 @@ func (qld *QLData) SetQLDataCommon(ctx context.Context, mq module_model.MyQuery,
 	qld.ExecuteFlag = executeFlag
 	qld.IsPublic = isPublic
 	qld.OutputType = outputType
+	qld.TimeOut = mq.TimeOut
 	err = qld.SetFinalVars(ctx, vars)
 	qld.FinalVariables[module_model.RULEPREFIX_TOKEN] = tokenObj
 	return err
@@ -71,30 +83,6 @@ func (qld *QLData) SetFinalVars(ctx context.Context, vars map[string]interface{}
 	qld.FinalVariables = finalVars
 	return nil
 }
-func (qld *QLData) ProcessTransformRule(ctx context.Context, tr module_model.TransformRule) (outputObj map[string]interface{}, err error) {
-	logs.WithContext(ctx).Debug("ProcessTransformRule - Start")
-	if tr.RuleType == module_model.RULETYPE_NONE {
-		outputObj = make(map[string]interface{})
-		return
-	}
-	if tr.RuleType == module_model.RULETYPE_ALWAYS {
-		outputObj = make(map[string]interface{})
-		if len(tr.Rules) > 0 {
-			for k, v := range tr.Rules[0].ForceColumnValues { //todo to remove array and make it single object
-				outputBytes, err := processTemplate(ctx, "xxx", v, qld.FinalVariables, "string", k)
-				if err != nil {
-					return nil, err
-				}
-				if outputBytes != nil {
-					outputObj[k] = string(outputBytes)
-					//skipping to write to overwriteobj if there is no output
-				}
-			}
-		}
-	}
-	return
-}
-
 func processSecurityRule(ctx context.Context, sr security_rule.SecurityRule, vars map[string]interface{}) (outputStr string, err error) {
 	logs.WithContext(ctx).Debug("processSecurityRule - Start")
 	if sr.RuleType == module_model.RULETYPE_NONE {
@@ -143,7 +131,7 @@ func processTemplate(ctx context.Context, templateName string, templateString st
 				return nil, errors.New("error while parsing value of 'docs'")
 			}
 			outputBytes, ptErr := executeTemplate(ctx, templateName, templateStr, dd, outputType)
-			if err != nil {
+			if ptErr != nil {
 				err = ptErr
 				logs.WithContext(ctx).Error(err.Error())
 				return
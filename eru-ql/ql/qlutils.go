@@ -1,5 +1,27 @@
 package ql
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// sqlIdentifierRegex matches table and column names, optionally qualified with table name, which are added to queries as is
+var sqlIdentifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// sqlWordRegex matches words of a sql query - keywords and unqualified, unquoted table and column names
+var sqlWordRegex = regexp.MustCompile(`[A-Za-z0-9_$]+`)
+
+// sqlQualifiedWordRegex matches words of a sql expression along with table name they are qualified with e.g. t.col
+var sqlQualifiedWordRegex = regexp.MustCompile(`[A-Za-z0-9_$.]+`)
+
 func GetQL(queryType string) QL {
 	switch queryType {
 	case "graphql":
@@ -31,3 +53,115 @@ func (a MapSorter) Swap(i, j int) {
 func (a MapSorter) Less(i, j int) bool {
 	return a[i].Level < a[j].Level
 }
+
+// queryArgs collects values bound to the placeholders of the query being built.
+// offset is the count of values already bound before these e.g. updated columns of an update query.
+type queryArgs struct {
+	sqlMaker ds.SqlMakerI
+	offset   int
+	values   []interface{}
+}
+
+// bind adds the value to args and returns the db specific placeholder for it
+func (qa *queryArgs) bind(ctx context.Context, val interface{}) string {
+	qa.values = append(qa.values, val)
+	return qa.sqlMaker.GetPreparedQueryPlaceholder(ctx, 1, qa.offset+len(qa.values), true)
+}
+
+// isSqlWordRune returns true if the rune can be part of a word of sql query
+func isSqlWordRune(r rune) bool {
+	return r == '_' || r == '$' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// sqlWords returns lower cased words of the sql query
+func sqlWords(query string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range sqlWordRegex.FindAllString(query, -1) {
+		words[strings.ToLower(w)] = true
+	}
+	return words
+}
+
+// checkSqlIdentifier returns error if name received in the query cannot be added to sql as a table or column name
+func checkSqlIdentifier(ctx context.Context, name string) (err error) {
+	if !sqlIdentifierRegex.MatchString(name) {
+		err = errors.New(fmt.Sprint("invalid table or column name : ", name))
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	return err
+}
+
+// executeRead executes the read query on a healthy read replica of the datasource or on primary if readOnly is false
+// query is executed again on primary if it fails on a replica which is found unhealthy on recheck
+// query is not executed if its cost is more than max cost of the datasource and it is cancelled after its timeout
+func executeRead(ctx context.Context, sqlMaker ds.SqlMakerI, datasource *module_model.DataSource, query string, args []interface{}, readOnly bool, timeOut int, exec func(ctx context.Context, datasource *module_model.DataSource) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	logs.WithContext(ctx).Debug("executeRead - Start")
+	qctx, cancel, timeOutErr := withQueryTimeOut(ctx, datasource, timeOut)
+	defer cancel()
+	guardedExec := func(d *module_model.DataSource) (map[string]interface{}, error) {
+		if err := checkQueryCost(qctx, sqlMaker, d, query, args); err != nil {
+			return nil, err
+		}
+		result, err := exec(qctx, d)
+		return result, timeOutErr(err)
+	}
+	if !readOnly {
+		return guardedExec(datasource)
+	}
+	readDs := datasource.ReadDataSource(ctx)
+	result, err := guardedExec(readDs)
+	if err != nil && qctx.Err() == nil && readDs != datasource && !datasource.RecheckReplica(ctx, readDs.Con) {
+		logs.WithContext(ctx).Warn(fmt.Sprint("read query failed on unhealthy replica of ", datasource.DbAlias, " - executing it on primary"))
+		return guardedExec(datasource)
+	}
+	return result, err
+}
+
+// withQueryTimeOut returns context which is cancelled after timeOut seconds of my query or else after query timeout of the datasource
+// returned func replaces error of a query cancelled on timeout with a descriptive error
+func withQueryTimeOut(ctx context.Context, datasource *module_model.DataSource, timeOut int) (context.Context, context.CancelFunc, func(err error) error) {
+	if timeOut <= 0 {
+		timeOut = datasource.DbConfig.OtherDbConfig.QueryTimeOut
+	}
+	if timeOut <= 0 {
+		qctx, cancel := context.WithCancel(ctx)
+		return qctx, cancel, func(err error) error { return err }
+	}
+	qctx, cancel := context.WithTimeout(ctx, time.Duration(timeOut)*time.Second)
+	return qctx, cancel, func(err error) error {
+		if err != nil && errors.Is(qctx.Err(), context.DeadlineExceeded) {
+			err = errors.New(fmt.Sprint("query on datasource ", datasource.DbAlias, " cancelled as it did not complete in ", timeOut, " seconds"))
+			logs.WithContext(ctx).Error(err.Error())
+		}
+		return err
+	}
+}
+
+// checkQueryCost returns error if cost of the query estimated by the db is more than max cost of the datasource
+// check is skipped if the db cannot estimate cost of the query and for queries which change data as they are not explained
+func checkQueryCost(ctx context.Context, sqlMaker ds.SqlMakerI, datasource *module_model.DataSource, query string, args []interface{}) (err error) {
+	maxCost := datasource.DbConfig.OtherDbConfig.MaxCost
+	if maxCost <= 0 || query == "" || !module_store.IsSqlSelect(query) {
+		return nil
+	}
+	cost, costErr := sqlMaker.GetQueryCost(ctx, datasource, query, args...)
+	if costErr != nil {
+		logs.WithContext(ctx).Warn(fmt.Sprint("cost of query on datasource ", datasource.DbAlias, " not checked : ", costErr.Error()))
+		return nil
+	}
+	if cost > maxCost {
+		err = errors.New(fmt.Sprint("estimated cost ", cost, " of query is more than max cost ", maxCost, " allowed for datasource ", datasource.DbAlias))
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	return err
+}
+
+// checkLimit returns error if limit asked by the query is more than max rows allowed for the datasource
+func checkLimit(ctx context.Context, datasource *module_model.DataSource, limit int) (err error) {
+	maxRows := datasource.DbConfig.OtherDbConfig.MaxRows
+	if maxRows > 0 && limit > maxRows {
+		err = errors.New(fmt.Sprint("limit ", limit, " of query is more than max rows ", maxRows, " allowed for datasource ", datasource.DbAlias))
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	return err
+}
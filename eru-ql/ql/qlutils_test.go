@@ -0,0 +1,100 @@
+package ql
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// costSqlMaker returns same cost for every query and records queries it is asked to explain
+type costSqlMaker struct {
+	ds.SqliteSqlMaker
+	cost      float64
+	explained []string
+}
+
+func (cm *costSqlMaker) GetQueryCost(ctx context.Context, datasource *module_model.DataSource, query string, args ...interface{}) (float64, error) {
+	cm.explained = append(cm.explained, query)
+	return cm.cost, nil
+}
+
+func TestCheckQueryCost(t *testing.T) {
+	ctx := context.Background()
+	datasource := &module_model.DataSource{DbAlias: "db", DbConfig: module_model.DbConfig{OtherDbConfig: module_model.OtherDbConfig{MaxCost: 100}}}
+	tests := []struct {
+		name      string
+		query     string
+		wantErr   bool
+		explained bool
+	}{
+		{"select", "select * from t", true, true},
+		{"with select", "with a as (select 1) select * from a", true, true},
+		{"insert", "insert into t values (1)", false, false},
+		{"update", "UPDATE t set a = 1", false, false},
+		{"delete", "delete from t", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &costSqlMaker{cost: 500}
+			err := checkQueryCost(ctx, cm, datasource, tt.query, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (len(cm.explained) > 0) != tt.explained {
+				t.Errorf("explained = %v, want %v", cm.explained, tt.explained)
+			}
+		})
+	}
+}
+
+func TestExecuteQueryGuards(t *testing.T) {
+	ctx := context.Background()
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db"), DefaultSchema: "main",
+		OtherDbConfig: module_model.OtherDbConfig{MaxRows: 5}}}
+	if err := ds.GetSqlMaker("sqlite").CreateConn(ctx, datasource); err != nil {
+		t.Fatal(err)
+	}
+	defer datasource.Con.Close()
+	if _, err := datasource.Con.Exec("create table items (id integer primary key, name varchar(10)); insert into items (id, name) values (1, 'a')"); err != nil {
+		t.Fatal(err)
+	}
+	datasource.SchemaTables = map[string]map[string]module_model.TableColsMetaData{"main.items": {
+		"id": {TblName: "items", ColName: "id", OwnDataType: "Integer", PrimaryKey: true}, "name": {TblName: "items", ColName: "name", OwnDataType: "Varchar"},
+	}}
+	datasources := map[string]*module_model.DataSource{"db": datasource}
+	s := &module_store.ModuleFileStore{}
+	s.Projects = map[string]*module_model.Project{"p": {ProjectId: "p", DataSources: datasources}}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+		wantRes int
+	}{
+		{"within guards", `{ main___items(limit: 5) @db { id name } }`, "", 1},
+		{"limit above max rows", `{ main___items(limit: 10) @db { id name } }`, "max rows", 0},
+		{"query after failed query is not executed", `{ a: main___items(limit: 10) @db { id } b: main___items(limit: 1) @db { id } }`, "max rows", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gqd := GraphQLData{}
+			gqd.Query = tt.query
+			gqd.FinalVariables = map[string]interface{}{module_model.RULEPREFIX_TOKEN: map[string]interface{}{}}
+			gqd.ExecuteFlag = true
+			res, _, err := gqd.Execute(ctx, "p", datasources, s, "")
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			if len(res) != tt.wantRes {
+				t.Errorf("results = %v, want %d", res, tt.wantRes)
+			}
+		})
+	}
+}
@@ -0,0 +1,645 @@
+package ql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/eru-tech/eru/eru-security-rule/security_rule"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// graphql schema is generated from SchemaTables of datasources - every table is a type named as schema___table with its columns as fields
+// and active table joins as nested list fields. It is used to print SDL, to answer introspection queries and to validate queries.
+
+// schemaCache holds graphql schema of each project used to validate queries - projectId is the key.
+// generation of the project is incremented on invalidation so that schema built before invalidation is not cached.
+var schemaCache = struct {
+	sync.Mutex
+	schemas     map[string]*graphql.Schema
+	generations map[string]int
+}{schemas: make(map[string]*graphql.Schema), generations: make(map[string]int)}
+
+var graphQLNameRegex = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+var schemaMutationTypes = []string{"insert", "update", "upsert", "delete"}
+
+var schemaAggregateDirectives = []string{"sum", "count", "avg", "max", "min", "distinctcount"}
+
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Any json value",
+	Serialize:    func(value interface{}) interface{} { return value },
+	ParseValue:   func(value interface{}) interface{} { return value },
+	ParseLiteral: parseJsonLiteral,
+})
+
+var bigIntScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "BigInt",
+	Description: "64 bit integer",
+	Serialize:   func(value interface{}) interface{} { return value },
+	ParseValue:  func(value interface{}) interface{} { return value },
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		if v, ok := valueAST.(*ast.IntValue); ok {
+			if i, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+				return i
+			}
+		}
+		return nil
+	},
+})
+
+var dateScalar = newStringScalar("Date", "Date as yyyy-mm-dd")
+var timeScalar = newStringScalar("Time", "Time of the day")
+var dateTimeScalar = newStringScalar("DateTime", "Date and time")
+
+// newStringScalar returns scalar which is received and returned as string
+func newStringScalar(name string, description string) *graphql.Scalar {
+	return graphql.NewScalar(graphql.ScalarConfig{
+		Name:        name,
+		Description: description,
+		Serialize:   func(value interface{}) interface{} { return value },
+		ParseValue:  func(value interface{}) interface{} { return value },
+		ParseLiteral: func(valueAST ast.Value) interface{} {
+			if v, ok := valueAST.(*ast.StringValue); ok {
+				return v.Value
+			}
+			return nil
+		},
+	})
+}
+
+// parseJsonLiteral returns value of the literal - variables are returned as their names as values of variables are not checked
+func parseJsonLiteral(valueAST ast.Value) interface{} {
+	switch v := valueAST.(type) {
+	case *ast.ObjectValue:
+		o := make(map[string]interface{})
+		for _, f := range v.Fields {
+			o[f.Name.Value] = parseJsonLiteral(f.Value)
+		}
+		return o
+	case *ast.ListValue:
+		a := make([]interface{}, len(v.Values))
+		for i, av := range v.Values {
+			a[i] = parseJsonLiteral(av)
+		}
+		return a
+	case *ast.Variable:
+		return fmt.Sprint("$", v.Name.Value)
+	default:
+		return v.GetValue()
+	}
+}
+
+// GraphQLValidationError is returned when query does not conform to graphql schema of the project
+type GraphQLValidationError struct {
+	Errors []gqlerrors.FormattedError
+}
+
+func (e *GraphQLValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Message
+		for _, l := range fe.Locations {
+			msgs[i] = fmt.Sprint(msgs[i], " (line ", l.Line, ", column ", l.Column, ")")
+		}
+	}
+	return strings.Join(msgs, " ; ")
+}
+
+type schemaBuilder struct {
+	ctx         context.Context
+	tables      map[string]map[string]module_model.TableColsMetaData // keyed on type name i.e. schema___table
+	joins       map[string][]string
+	objects     map[string]*graphql.Object
+	responses   map[string]*graphql.Object
+	filters     map[string]*graphql.InputObject
+	docs        map[string]*graphql.InputObject
+	comparisons map[string]*graphql.InputObject
+	ranges      map[string]*graphql.InputObject
+}
+
+// BuildGraphQLSchema returns graphql schema of tables of all datasources - dbAlias of each datasource is a directive
+// if same table is found in more than one datasource, table of first datasource in order of dbAlias is used
+func BuildGraphQLSchema(ctx context.Context, datasources map[string]*module_model.DataSource) (schema graphql.Schema, err error) {
+	logs.WithContext(ctx).Debug("BuildGraphQLSchema - Start")
+	sb := &schemaBuilder{
+		ctx:         ctx,
+		tables:      make(map[string]map[string]module_model.TableColsMetaData),
+		joins:       make(map[string][]string),
+		objects:     make(map[string]*graphql.Object),
+		responses:   make(map[string]*graphql.Object),
+		filters:     make(map[string]*graphql.InputObject),
+		docs:        make(map[string]*graphql.InputObject),
+		comparisons: make(map[string]*graphql.InputObject),
+		ranges:      make(map[string]*graphql.InputObject),
+	}
+	directives := append([]*graphql.Directive{}, graphql.SpecifiedDirectives...)
+	var dbAliases []string
+	for dbAlias := range datasources {
+		dbAliases = append(dbAliases, dbAlias)
+	}
+	sort.Strings(dbAliases)
+	for _, dbAlias := range dbAliases {
+		if !isGraphQLName(dbAlias) {
+			logs.WithContext(ctx).Warn(fmt.Sprint("skipping datasource ", dbAlias, " from graphql schema as dbAlias is not a valid graphql name"))
+			continue
+		}
+		directives = append(directives, graphql.NewDirective(graphql.DirectiveConfig{
+			Name:        dbAlias,
+			Description: fmt.Sprint("Executes the field on datasource ", dbAlias),
+			Locations:   []string{graphql.DirectiveLocationField},
+		}))
+		sb.addDataSource(datasources[dbAlias])
+	}
+	if len(sb.tables) == 0 {
+		err = errors.New("no tables found to generate graphql schema")
+		logs.WithContext(ctx).Error(err.Error())
+		return schema, err
+	}
+	directives = append(directives, graphql.NewDirective(graphql.DirectiveConfig{
+		Name:        "withCount",
+		Description: "Returns total count of rows across all pages",
+		Locations:   []string{graphql.DirectiveLocationField},
+	}), graphql.NewDirective(graphql.DirectiveConfig{
+		Name:        "singleTxn",
+		Description: "Executes all mutations in a single transaction",
+		Locations:   []string{graphql.DirectiveLocationMutation},
+	}))
+	for _, d := range schemaAggregateDirectives {
+		directives = append(directives, graphql.NewDirective(graphql.DirectiveConfig{
+			Name:        d,
+			Description: fmt.Sprint("Returns ", d, " of the column"),
+			Locations:   []string{graphql.DirectiveLocationField},
+		}))
+	}
+
+	queryFields := graphql.Fields{}
+	mutationFields := graphql.Fields{}
+	for typeName := range sb.tables {
+		queryFields[typeName] = &graphql.Field{
+			Type: graphql.NewList(sb.object(typeName)),
+			Args: graphql.FieldConfigArgument{
+				"where":    &graphql.ArgumentConfig{Type: sb.filter(typeName)},
+				"sort":     &graphql.ArgumentConfig{Type: graphql.NewList(graphql.String)},
+				"distinct": &graphql.ArgumentConfig{Type: graphql.Boolean},
+				"limit":    &graphql.ArgumentConfig{Type: graphql.Int},
+				"skip":     &graphql.ArgumentConfig{Type: graphql.Int},
+				"after":    &graphql.ArgumentConfig{Type: graphql.String},
+				"before":   &graphql.ArgumentConfig{Type: graphql.String},
+			},
+		}
+		for _, mt := range schemaMutationTypes {
+			args := graphql.FieldConfigArgument{
+				"txn": &graphql.ArgumentConfig{Type: graphql.Boolean},
+			}
+			switch mt {
+			case "insert":
+				args["docs"] = &graphql.ArgumentConfig{Type: graphql.NewList(sb.doc(typeName))}
+				args["query"] = &graphql.ArgumentConfig{Type: graphql.String}
+			case "update":
+				args["docs"] = &graphql.ArgumentConfig{Type: sb.doc(typeName)}
+				args["where"] = &graphql.ArgumentConfig{Type: sb.filter(typeName)}
+			case "upsert":
+				args["docs"] = &graphql.ArgumentConfig{Type: graphql.NewList(sb.doc(typeName))}
+				args["conflict"] = &graphql.ArgumentConfig{Type: graphql.String}
+				args["update"] = &graphql.ArgumentConfig{Type: graphql.NewList(graphql.String)}
+			case "delete":
+				args["where"] = &graphql.ArgumentConfig{Type: sb.filter(typeName)}
+			}
+			mutationFields[fmt.Sprint(mt, "_", typeName)] = &graphql.Field{Type: sb.response(typeName), Args: args}
+		}
+	}
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:      graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: queryFields}),
+		Mutation:   graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutationFields}),
+		Directives: directives,
+	})
+}
+
+// addDataSource adds tables of the datasource with columns and joins having valid graphql names
+func (sb *schemaBuilder) addDataSource(datasource *module_model.DataSource) {
+	added := make(map[string]bool)
+	for tableName, cols := range datasource.SchemaTables {
+		typeName := strings.Replace(tableName, ".", "___", 1)
+		if !isGraphQLName(typeName) {
+			logs.WithContext(sb.ctx).Warn(fmt.Sprint("skipping table ", tableName, " from graphql schema as it is not a valid graphql name"))
+			continue
+		}
+		if _, ok := sb.tables[typeName]; ok {
+			logs.WithContext(sb.ctx).Warn(fmt.Sprint("skipping table ", tableName, " of datasource ", datasource.DbAlias, " from graphql schema as it is already added from another datasource"))
+			continue
+		}
+		tableCols := make(map[string]module_model.TableColsMetaData)
+		for colName, col := range cols {
+			if !isGraphQLName(colName) {
+				logs.WithContext(sb.ctx).Warn(fmt.Sprint("skipping column ", colName, " of table ", tableName, " from graphql schema as it is not a valid graphql name"))
+				continue
+			}
+			tableCols[colName] = col
+		}
+		sb.tables[typeName] = tableCols
+		added[typeName] = true
+	}
+	for _, tj := range datasource.TableJoins {
+		if tj == nil || !tj.IsActive {
+			continue
+		}
+		t1 := strings.Replace(tj.Table1Name, ".", "___", 1)
+		t2 := strings.Replace(tj.Table2Name, ".", "___", 1)
+		if added[t1] && added[t2] {
+			sb.joins[t1] = append(sb.joins[t1], t2)
+			sb.joins[t2] = append(sb.joins[t2], t1)
+		}
+	}
+}
+
+// object returns type of the table with columns and joined tables as fields
+func (sb *schemaBuilder) object(typeName string) *graphql.Object {
+	if o, ok := sb.objects[typeName]; ok {
+		return o
+	}
+	sb.objects[typeName] = graphql.NewObject(graphql.ObjectConfig{
+		Name: typeName,
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := graphql.Fields{}
+			for colName, col := range sb.tables[typeName] {
+				fields[colName] = &graphql.Field{
+					Type: graphQLScalar(col.OwnDataType),
+					Args: graphql.FieldConfigArgument{"calc": &graphql.ArgumentConfig{Type: graphql.String}},
+				}
+			}
+			for _, j := range sb.joins[typeName] {
+				if _, ok := fields[j]; !ok {
+					fields[j] = &graphql.Field{
+						Type: graphql.NewList(sb.object(j)),
+						Args: graphql.FieldConfigArgument{"join": &graphql.ArgumentConfig{Type: jsonScalar}},
+					}
+				}
+			}
+			return fields
+		}),
+	})
+	return sb.objects[typeName]
+}
+
+// response returns type of the mutation result of the table
+func (sb *schemaBuilder) response(typeName string) *graphql.Object {
+	if o, ok := sb.responses[typeName]; ok {
+		return o
+	}
+	sb.responses[typeName] = graphql.NewObject(graphql.ObjectConfig{
+		Name: fmt.Sprint(typeName, "_response"),
+		Fields: graphql.Fields{
+			"error":     &graphql.Field{Type: graphql.String},
+			"returning": &graphql.Field{Type: graphql.NewList(sb.object(typeName))},
+		},
+	})
+	return sb.responses[typeName]
+}
+
+// filter returns input type of where clause of the table
+func (sb *schemaBuilder) filter(typeName string) *graphql.InputObject {
+	if f, ok := sb.filters[typeName]; ok {
+		return f
+	}
+	sb.filters[typeName] = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: fmt.Sprint(typeName, "_filter"),
+		Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+			fields := graphql.InputObjectConfigFieldMap{
+				"_or": &graphql.InputObjectFieldConfig{Type: graphql.NewList(sb.filter(typeName))},
+			}
+			for colName, col := range sb.tables[typeName] {
+				fields[colName] = &graphql.InputObjectFieldConfig{Type: sb.comparison(graphQLScalar(col.OwnDataType))}
+			}
+			return fields
+		}),
+	})
+	return sb.filters[typeName]
+}
+
+// comparison returns input type of operators of where clause for columns of the scalar
+func (sb *schemaBuilder) comparison(scalar *graphql.Scalar) *graphql.InputObject {
+	if c, ok := sb.comparisons[scalar.Name()]; ok {
+		return c
+	}
+	fields := graphql.InputObjectConfigFieldMap{
+		"_in":   &graphql.InputObjectFieldConfig{Type: graphql.NewList(scalar)},
+		"_nin":  &graphql.InputObjectFieldConfig{Type: graphql.NewList(scalar)},
+		"_like": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"_null": &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		"_btw":  &graphql.InputObjectFieldConfig{Type: sb.between(scalar)},
+	}
+	for _, op := range []string{"_eq", "_ne", "_gt", "_gte", "_lt", "_lte"} {
+		fields[op] = &graphql.InputObjectFieldConfig{Type: scalar}
+	}
+	sb.comparisons[scalar.Name()] = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:   fmt.Sprint(scalar.Name(), "_comparison"),
+		Fields: fields,
+	})
+	return sb.comparisons[scalar.Name()]
+}
+
+// between returns input type of from and to values of between operator
+func (sb *schemaBuilder) between(scalar *graphql.Scalar) *graphql.InputObject {
+	if r, ok := sb.ranges[scalar.Name()]; ok {
+		return r
+	}
+	sb.ranges[scalar.Name()] = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: fmt.Sprint(scalar.Name(), "_range"),
+		Fields: graphql.InputObjectConfigFieldMap{
+			"from": &graphql.InputObjectFieldConfig{Type: scalar},
+			"to":   &graphql.InputObjectFieldConfig{Type: scalar},
+		},
+	})
+	return sb.ranges[scalar.Name()]
+}
+
+// doc returns input type of document to mutate - documents of joined tables can be nested
+func (sb *schemaBuilder) doc(typeName string) *graphql.InputObject {
+	if d, ok := sb.docs[typeName]; ok {
+		return d
+	}
+	sb.docs[typeName] = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: fmt.Sprint(typeName, "_doc"),
+		Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+			fields := graphql.InputObjectConfigFieldMap{}
+			for colName, col := range sb.tables[typeName] {
+				fields[colName] = &graphql.InputObjectFieldConfig{Type: graphQLScalar(col.OwnDataType)}
+			}
+			for _, j := range sb.joins[typeName] {
+				if _, ok := fields[j]; !ok {
+					fields[j] = &graphql.InputObjectFieldConfig{Type: graphql.NewList(sb.doc(j))}
+				}
+			}
+			return fields
+		}),
+	})
+	return sb.docs[typeName]
+}
+
+// graphQLScalar returns scalar of eru data type of the column
+func graphQLScalar(ownDataType string) *graphql.Scalar {
+	switch ownDataType {
+	case "Integer", "SmallInteger":
+		return graphql.Int
+	case "BigInteger":
+		return bigIntScalar
+	case "Float", "Decimal":
+		return graphql.Float
+	case "Boolean":
+		return graphql.Boolean
+	case "Date":
+		return dateScalar
+	case "Time", "TimeWithZone":
+		return timeScalar
+	case "DateTime", "DateTimeWithZone":
+		return dateTimeScalar
+	case "JSON":
+		return jsonScalar
+	default:
+		return graphql.String
+	}
+}
+
+func isGraphQLName(name string) bool {
+	return graphQLNameRegex.MatchString(name) && !strings.HasPrefix(name, "__")
+}
+
+// GetGraphQLSchemaSDL returns graphql schema of the datasources in schema definition language
+func GetGraphQLSchemaSDL(ctx context.Context, datasources map[string]*module_model.DataSource) (sdl string, err error) {
+	logs.WithContext(ctx).Debug("GetGraphQLSchemaSDL - Start")
+	schema, err := BuildGraphQLSchema(ctx, datasources)
+	if err != nil {
+		return "", err
+	}
+	return printSchemaSDL(schema), nil
+}
+
+// printSchemaSDL prints directives and types of the schema sorted on name - built in scalars, directives and introspection types are not printed
+func printSchemaSDL(schema graphql.Schema) string {
+	var sdl []string
+	specified := make(map[*graphql.Directive]bool)
+	for _, d := range graphql.SpecifiedDirectives {
+		specified[d] = true
+	}
+	for _, d := range schema.Directives() {
+		if !specified[d] {
+			sdl = append(sdl, fmt.Sprint(printDescription(d.Description), "directive @", d.Name, " on ", strings.Join(d.Locations, " | ")))
+		}
+	}
+	typeMap := schema.TypeMap()
+	var typeNames []string
+	for typeName := range typeMap {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+	for _, typeName := range typeNames {
+		if strings.HasPrefix(typeName, "__") {
+			continue
+		}
+		switch t := typeMap[typeName].(type) {
+		case *graphql.Scalar:
+			if t != graphql.String && t != graphql.Int && t != graphql.Float && t != graphql.Boolean && t != graphql.ID {
+				sdl = append(sdl, fmt.Sprint(printDescription(t.Description()), "scalar ", typeName))
+			}
+		case *graphql.Object:
+			var lines []string
+			for fieldName, f := range t.Fields() {
+				args := make([]string, len(f.Args))
+				for i, a := range f.Args {
+					args[i] = fmt.Sprint(a.Name(), ": ", a.Type.String())
+				}
+				sort.Strings(args)
+				argStr := ""
+				if len(args) > 0 {
+					argStr = fmt.Sprint("(", strings.Join(args, ", "), ")")
+				}
+				lines = append(lines, fmt.Sprint("  ", fieldName, argStr, ": ", f.Type.String()))
+			}
+			sort.Strings(lines)
+			sdl = append(sdl, fmt.Sprint("type ", typeName, " {\n", strings.Join(lines, "\n"), "\n}"))
+		case *graphql.InputObject:
+			var lines []string
+			for fieldName, f := range t.Fields() {
+				lines = append(lines, fmt.Sprint("  ", fieldName, ": ", f.Type.String()))
+			}
+			sort.Strings(lines)
+			sdl = append(sdl, fmt.Sprint("input ", typeName, " {\n", strings.Join(lines, "\n"), "\n}"))
+		default:
+			// no other types are generated
+		}
+	}
+	return fmt.Sprint(strings.Join(sdl, "\n\n"), "\n")
+}
+
+func printDescription(description string) string {
+	if description == "" {
+		return ""
+	}
+	return fmt.Sprint(strconv.Quote(description), "\n")
+}
+
+// validateGraphQL validates the query against graphql schema of the project if validation is enabled in project settings
+func (gqd *GraphQLData) validateGraphQL(ctx context.Context, projectId string, datasources map[string]*module_model.DataSource, s module_store.ModuleStoreI, doc *ast.Document) (err error) {
+	logs.WithContext(ctx).Debug("validateGraphQL - Start")
+	projectSettings, err := s.GetProjectSettingsObject(ctx, projectId)
+	if err != nil {
+		return err
+	}
+	if !projectSettings.ValidateGraphQL {
+		return nil
+	}
+	schema, err := getProjectGraphQLSchema(ctx, projectId, datasources)
+	if err != nil {
+		return err
+	}
+	return ValidateGraphQL(ctx, schema, doc)
+}
+
+// getProjectGraphQLSchema returns cached graphql schema of the project - schema is built and cached if not found
+func getProjectGraphQLSchema(ctx context.Context, projectId string, datasources map[string]*module_model.DataSource) (*graphql.Schema, error) {
+	logs.WithContext(ctx).Debug("getProjectGraphQLSchema - Start")
+	schemaCache.Lock()
+	schema, ok := schemaCache.schemas[projectId]
+	generation := schemaCache.generations[projectId]
+	schemaCache.Unlock()
+	if ok {
+		return schema, nil
+	}
+	s, err := BuildGraphQLSchema(ctx, datasources)
+	if err != nil {
+		return nil, err
+	}
+	schemaCache.Lock()
+	if schemaCache.generations[projectId] == generation {
+		schemaCache.schemas[projectId] = &s
+	}
+	schemaCache.Unlock()
+	return &s, nil
+}
+
+// InvalidateGraphQLSchema removes cached graphql schema of the project - it is called when tables, joins or datasources of the project are saved
+func InvalidateGraphQLSchema(ctx context.Context, projectId string) {
+	logs.WithContext(ctx).Debug("InvalidateGraphQLSchema - Start")
+	schemaCache.Lock()
+	defer schemaCache.Unlock()
+	delete(schemaCache.schemas, projectId)
+	schemaCache.generations[projectId]++
+}
+
+// SelectableDataSources returns datasources with only those tables which the caller can select - tables are removed if select
+// security rule is set to none or if a custom rule which checks only variables is not satisfied by the variables of the caller
+func SelectableDataSources(ctx context.Context, projectId string, datasources map[string]*module_model.DataSource, s module_store.ModuleStoreI, vars map[string]interface{}) map[string]*module_model.DataSource {
+	logs.WithContext(ctx).Debug("SelectableDataSources - Start")
+	selectable := make(map[string]*module_model.DataSource, len(datasources))
+	for dbAlias, datasource := range datasources {
+		// only tables and joins are copied as schema is built from them
+		dsCopy := &module_model.DataSource{DbAlias: datasource.DbAlias, DbName: datasource.DbName, TableJoins: datasource.TableJoins, SchemaTables: make(map[string]map[string]module_model.TableColsMetaData)}
+		for tableName, cols := range datasource.SchemaTables {
+			sr, err := s.GetTableSecurityRule(ctx, projectId, dbAlias, tableName)
+			if err != nil || selectRuleDenies(ctx, sr.Select, vars) {
+				continue
+			}
+			dsCopy.SchemaTables[tableName] = cols
+		}
+		selectable[dbAlias] = dsCopy
+	}
+	return selectable
+}
+
+// selectRuleDenies returns true if select on the table is refused irrespective of the rows selected
+func selectRuleDenies(ctx context.Context, sr security_rule.SecurityRule, vars map[string]interface{}) bool {
+	if _, err := processSecurityRule(ctx, sr, vars); err != nil {
+		return true
+	}
+	if sr.RuleType != module_model.RULETYPE_CUSTOM || sr.CustomRule.IsEmpty() || !ruleChecksOnlyVariables(sr.CustomRule.AND) || !ruleChecksOnlyVariables(sr.CustomRule.OR) {
+		return false
+	}
+	ok, err := sr.CustomRule.Evaluate(ctx, vars)
+	return err != nil || !ok
+}
+
+// ruleChecksOnlyVariables returns true if no condition of the rule refers to a column
+func ruleChecksOnlyVariables(rules []security_rule.CustomRuleDetails) bool {
+	for _, r := range rules {
+		if len(r.AND) > 0 || len(r.OR) > 0 {
+			if !ruleChecksOnlyVariables(r.AND) || !ruleChecksOnlyVariables(r.OR) {
+				return false
+			}
+		} else if !security_rule.IsVariable(r.Variable1) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateGraphQL returns GraphQLValidationError with location of each error if the document does not conform to the schema
+func ValidateGraphQL(ctx context.Context, schema *graphql.Schema, doc *ast.Document) error {
+	logs.WithContext(ctx).Debug("ValidateGraphQL - Start")
+	vr := graphql.ValidateDocument(schema, doc, nil)
+	if vr.IsValid {
+		return nil
+	}
+	err := &GraphQLValidationError{Errors: vr.Errors}
+	logs.WithContext(ctx).Error(err.Error())
+	return err
+}
+
+// IsIntrospection returns true if the query only asks for __schema and __type fields
+func (gqd *GraphQLData) IsIntrospection(ctx context.Context) bool {
+	logs.WithContext(ctx).Debug("IsIntrospection - Start")
+	doc, err := gqd.parseGraphQL(ctx)
+	if err != nil {
+		return false
+	}
+	found := false
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if op.Operation != "query" || op.SelectionSet == nil {
+			return false
+		}
+		for _, sel := range op.SelectionSet.Selections {
+			field, ok := sel.(*ast.Field)
+			if !ok {
+				continue
+			}
+			switch field.Name.Value {
+			case "__schema", "__type":
+				found = true
+			case "__typename":
+				// allowed along with introspection fields
+			default:
+				return false
+			}
+		}
+	}
+	return found
+}
+
+// Introspect answers the introspection query from graphql schema of the tables which the caller can select
+func (gqd *GraphQLData) Introspect(ctx context.Context, projectId string, datasources map[string]*module_model.DataSource, s module_store.ModuleStoreI) *graphql.Result {
+	logs.WithContext(ctx).Debug("Introspect - Start")
+	schema, err := BuildGraphQLSchema(ctx, SelectableDataSources(ctx, projectId, datasources, s, gqd.FinalVariables))
+	if err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(err.Error())}}
+	}
+	return graphql.Do(graphql.Params{
+		Schema:         schema,
+		RequestString:  gqd.Query,
+		VariableValues: gqd.Variables,
+		Context:        ctx,
+	})
+}
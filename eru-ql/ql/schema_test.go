@@ -0,0 +1,120 @@
+package ql
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/eru-tech/eru/eru-security-rule/security_rule"
+	"strings"
+	"testing"
+)
+
+func newSchemaTestStore(securityRules map[string]module_model.SecurityRules) (*module_store.ModuleFileStore, map[string]*module_model.DataSource) {
+	cols := map[string]module_model.TableColsMetaData{"id": {ColName: "id", OwnDataType: "Integer"}, "owner": {ColName: "owner", OwnDataType: "Varchar"}}
+	datasource := &module_model.DataSource{DbAlias: "db", DbName: "postgres", SchemaTables: map[string]map[string]module_model.TableColsMetaData{
+		"public.orders": cols, "public.salaries": cols, "public.notes": cols, "public.secrets": cols,
+	}, SchemaTablesSecurity: securityRules}
+	datasources := map[string]*module_model.DataSource{"db": datasource}
+	s := &module_store.ModuleFileStore{}
+	s.Projects = map[string]*module_model.Project{"p": {ProjectId: "p", DataSources: datasources, ProjectSettings: module_model.ProjectSettings{ValidateGraphQL: true}}}
+	return s, datasources
+}
+
+func selectRule(ruleType string, rules ...security_rule.CustomRuleDetails) module_model.SecurityRules {
+	return module_model.SecurityRules{Select: security_rule.SecurityRule{RuleType: ruleType, CustomRule: security_rule.CustomRule{AND: rules}}}
+}
+
+func TestSelectableDataSources(t *testing.T) {
+	ctx := context.Background()
+	s, datasources := newSchemaTestStore(map[string]module_model.SecurityRules{
+		"public.orders":   selectRule(module_model.RULETYPE_ALWAYS),
+		"public.salaries": selectRule(module_model.RULETYPE_CUSTOM, security_rule.CustomRuleDetails{Variable1: "token.role", Variable2: "hr", Operator: "eq", DataType: "string"}),
+		"public.notes":    selectRule(module_model.RULETYPE_CUSTOM, security_rule.CustomRuleDetails{Variable1: "owner", Variable2: "token.user", Operator: "eq", DataType: "string"}),
+		"public.secrets":  selectRule(module_model.RULETYPE_NONE),
+	})
+	tests := []struct {
+		name  string
+		token map[string]interface{}
+		want  []string
+	}{
+		{"rule on token is satisfied", map[string]interface{}{"role": "hr", "user": "u1"}, []string{"public.notes", "public.orders", "public.salaries"}},
+		{"rule on token is not satisfied", map[string]interface{}{"role": "sales", "user": "u1"}, []string{"public.notes", "public.orders"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]interface{}{module_model.RULEPREFIX_TOKEN: tt.token}
+			tables := SelectableDataSources(ctx, "p", datasources, s, vars)["db"].SchemaTables
+			var got []string
+			for _, tableName := range []string{"public.notes", "public.orders", "public.salaries", "public.secrets"} {
+				if _, ok := tables[tableName]; ok {
+					got = append(got, tableName)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("tables = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if len(datasources["db"].SchemaTables) != 4 {
+		t.Error("tables of the datasource are not expected to be removed")
+	}
+}
+
+func TestIntrospectHidesDeniedTables(t *testing.T) {
+	ctx := context.Background()
+	s, datasources := newSchemaTestStore(map[string]module_model.SecurityRules{
+		"public.orders":   selectRule(module_model.RULETYPE_ALWAYS),
+		"public.salaries": selectRule(module_model.RULETYPE_NONE),
+		"public.notes":    selectRule(module_model.RULETYPE_NONE),
+		"public.secrets":  selectRule(module_model.RULETYPE_NONE),
+	})
+	gqd := GraphQLData{}
+	gqd.Query = `{ __schema { queryType { fields { name } } } }`
+	gqd.FinalVariables = map[string]interface{}{module_model.RULEPREFIX_TOKEN: map[string]interface{}{}}
+	if !gqd.IsIntrospection(ctx) {
+		t.Fatal("query is expected to be introspection")
+	}
+	result := gqd.Introspect(ctx, "p", datasources, s)
+	if result.HasErrors() {
+		t.Fatal(result.Errors)
+	}
+	b, _ := json.Marshal(result.Data)
+	if !strings.Contains(string(b), `"public___orders"`) || strings.Contains(string(b), "public___secrets") || strings.Contains(string(b), "public___salaries") {
+		t.Errorf("unexpected introspection result : %s", b)
+	}
+}
+
+func TestValidateGraphQLSchemaCache(t *testing.T) {
+	ctx := context.Background()
+	s, datasources := newSchemaTestStore(nil)
+	InvalidateGraphQLSchema(ctx, "p")
+	validate := func(query string) error {
+		gqd := GraphQLData{}
+		gqd.Query = query
+		doc, err := gqd.parseGraphQL(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gqd.validateGraphQL(ctx, "p", datasources, s, doc)
+	}
+	if err := validate(`{ public___orders @db { id } }`); err != nil {
+		t.Fatal(err)
+	}
+	schemaCache.Lock()
+	cached := schemaCache.schemas["p"]
+	schemaCache.Unlock()
+	if cached == nil {
+		t.Fatal("schema is expected to be cached after validation")
+	}
+
+	// table added without invalidation is not found in cached schema
+	datasources["db"].SchemaTables["public.items"] = map[string]module_model.TableColsMetaData{"id": {ColName: "id", OwnDataType: "Integer"}}
+	if err := validate(`{ public___items @db { id } }`); err == nil {
+		t.Fatal("cached schema is expected to be used")
+	}
+	InvalidateGraphQLSchema(ctx, "p")
+	if err := validate(`{ public___items @db { id } }`); err != nil {
+		t.Fatalf("schema is expected to be rebuilt after invalidation : %v", err)
+	}
+}
@@ -15,8 +15,9 @@ import (
 
 type SQLData struct {
 	QLData
-	DBAlias string `json:"dbalias"`
-	Cols    string `json:"cols"`
+	DBAlias   string `json:"dbalias"`
+	Cols      string `json:"cols"`
+	ReadWrite string `json:"-"`
 }
 
 func (sqd *SQLData) SetQLData(ctx context.Context, mq module_model.MyQuery, vars map[string]interface{}, executeFlag bool, tokenObj map[string]interface{}, isPublic bool, outputType string) {
@@ -26,6 +27,7 @@ func (sqd *SQLData) SetQLData(ctx context.Context, mq module_model.MyQuery, vars
 	//sqd.Variables=mq.Vars
 	sqd.DBAlias = mq.DBAlias
 	sqd.Cols = mq.Cols
+	sqd.ReadWrite = mq.ReadWrite
 	//sqd.SetFinalVars(vars)
 }
 func (sqd *SQLData) Execute(ctx context.Context, projectId string, datasources map[string]*module_model.DataSource, s module_store.ModuleStoreI, outputType string) (res []map[string]interface{}, queryObjs []QueryObject, err error) {
@@ -36,6 +38,14 @@ func (sqd *SQLData) Execute(ctx context.Context, projectId string, datasources m
 	}
 	var result map[string]interface{}
 	sr := ds.GetSqlMaker(datasource.DbName)
+	// masks are evaluated before variables are replaced with their string values below as rules need token claims
+	var masks map[string]module_model.ColumnMasking
+	if sqd.ExecuteFlag {
+		masks, err = getSqlColumnMasks(ctx, sqd.Query, datasource, sqd.FinalVariables)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 	for k, v := range sqd.FinalVariables {
 		var str string
 		switch tp := v.(type) {
@@ -97,17 +107,33 @@ func (sqd *SQLData) Execute(ctx context.Context, projectId string, datasources m
 	queryObj := QueryObject{}
 	queryObj.Query = sqd.Query
 	queryObj.Cols = sqd.Cols
+	if sqd.ExecuteFlag && !module_store.IsSqlSelect(sqd.Query) {
+		// cached results of tables changed by the query are invalidated after it is executed
+		defer InvalidateCacheTables(ctx, projectId, sqd.DBAlias, getSqlTableNames(sqd.Query, datasource)...)
+	}
 	if sqd.ExecuteFlag {
-		if sqd.OutputType == eru_writes.OutputTypeCsv || sqd.OutputType == eru_writes.OutputTypeExcel {
-			result, err = sr.ExecuteQueryForCsv(ctx, sqd.Query, datasource, "Results")
+		// only select queries are routed to read replicas
+		readOnly := module_store.IsSqlSelect(sqd.Query)
+		if sqd.stream != nil {
+			err = executeStream(ctx, sr, datasource, sqd.Query, nil, readOnly, sqd.TimeOut, sqd.stream, sqd.stream.rowFunc(ctx, masks, module_model.TransformRule{}, sqd.FinalVariables))
+		} else if sqd.OutputType == eru_writes.OutputTypeCsv || sqd.OutputType == eru_writes.OutputTypeExcel {
+			result, err = executeRead(ctx, sr, datasource, sqd.Query, nil, readOnly, sqd.TimeOut, func(ctx context.Context, d *module_model.DataSource) (map[string]interface{}, error) {
+				return sr.ExecuteQueryForCsv(ctx, sqd.Query, d, "Results")
+			})
 			if err != nil {
 				logs.WithContext(ctx).Error(err.Error())
+			} else {
+				maskCsvRows(ctx, result["Results"], masks)
 			}
 			res = append(res, result)
 		} else {
-			result, err = sr.ExecutePreparedQuery(ctx, sqd.Query, datasource)
+			result, err = executeRead(ctx, sr, datasource, sqd.Query, nil, readOnly, sqd.TimeOut, func(ctx context.Context, d *module_model.DataSource) (map[string]interface{}, error) {
+				return sr.ExecutePreparedQuery(ctx, sqd.Query, d)
+			})
 			if err != nil {
 				logs.WithContext(ctx).Error(err.Error())
+			} else {
+				maskRows(ctx, result["Results"], masks)
 			}
 
 			res = append(res, result)
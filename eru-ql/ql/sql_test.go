@@ -0,0 +1,64 @@
+package ql
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/jmoiron/sqlx"
+	"path/filepath"
+	"testing"
+)
+
+func TestMyQueryReadReplicaRouting(t *testing.T) {
+	ctx := context.Background()
+	newDataSource := func(name string) *module_model.DataSource {
+		d := &module_model.DataSource{DbAlias: "db", DbName: "sqlite", DbType: "sql", DbConfig: module_model.DbConfig{DefaultDB: filepath.Join(t.TempDir(), "eru.db"), DefaultSchema: "main"}}
+		if err := ds.GetSqlMaker("sqlite").CreateConn(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { d.Con.Close() })
+		if _, err := d.Con.Exec("create table items (id integer primary key, name varchar(10)); insert into items (id, name) values (1, ?)", name); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	datasource := newDataSource("primary")
+	replica := newDataSource("replica")
+	datasource.Replicas = &module_model.Replicas{Cons: []*module_model.ReplicaCon{module_model.NewReplicaCon("r1", func(ctx context.Context) (*sqlx.DB, error) {
+		return replica.Con, nil
+	})}}
+	datasources := map[string]*module_model.DataSource{"db": datasource}
+	s := &module_store.ModuleFileStore{}
+	s.Projects = map[string]*module_model.Project{"p": {ProjectId: "p", DataSources: datasources}}
+
+	execute := func(query string, readWrite string) string {
+		sqd := SQLData{}
+		sqd.SetQLData(ctx, module_model.MyQuery{Query: query, QueryType: "sql", DBAlias: "db", ReadWrite: readWrite}, map[string]interface{}{}, true, map[string]interface{}{}, false, "")
+		res, _, err := sqd.Execute(ctx, "p", datasources, s, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, _ := res[0]["Results"].([]map[string]interface{})
+		if len(rows) == 0 {
+			return ""
+		}
+		name, _ := rows[0]["name"].(string)
+		return name
+	}
+	if name := execute("select name from items", module_store.Q_SELECT); name != "replica" {
+		t.Errorf("select query is expected to be read from replica : %q", name)
+	}
+	// query is routed on its own statements and not on read write of my query saved earlier
+	execute("update items set name = 'changed' where id = 1", module_store.Q_SELECT)
+	var primaryName, replicaName string
+	if err := datasource.Con.Get(&primaryName, "select name from items where id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.Con.Get(&replicaName, "select name from items where id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if primaryName != "changed" || replicaName != "replica" {
+		t.Errorf("update is expected to be executed on primary : primary %q, replica %q", primaryName, replicaName)
+	}
+}
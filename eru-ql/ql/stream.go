@@ -0,0 +1,223 @@
+package ql
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/ds"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"net/http"
+	"sort"
+)
+
+const (
+	streamBufferSize = 32 * 1024
+	streamFlushRows  = 1000
+)
+
+// IsStreamOutput returns true if rows of the query are to be streamed in the output type
+func IsStreamOutput(outputType string) bool {
+	return outputType == module_model.OUTPUT_TYPE_CSV_STREAM || outputType == module_model.OUTPUT_TYPE_NDJSON
+}
+
+// StreamWriter writes rows of a query to the response as csv or newline delimited json as soon as they are read.
+// response is started with the first row so that errors before it are returned as usual error response.
+type StreamWriter struct {
+	w          http.ResponseWriter
+	outputType string
+	buf        *bufio.Writer
+	csvWriter  *csv.Writer
+	queryCols  []string // columns of the query - written as header of csv with no rows
+	started    bool
+	rowCount   int
+}
+
+func NewStreamWriter(w http.ResponseWriter, outputType string) *StreamWriter {
+	return &StreamWriter{w: w, outputType: outputType}
+}
+
+// Started returns true if response is started - error can no longer be returned as error response
+func (sw *StreamWriter) Started() bool {
+	return sw.started
+}
+
+func (sw *StreamWriter) start(cols []string) (err error) {
+	sw.started = true
+	sw.buf = bufio.NewWriterSize(sw.w, streamBufferSize)
+	if sw.outputType == module_model.OUTPUT_TYPE_NDJSON {
+		sw.w.Header().Set("Content-Type", "application/x-ndjson")
+		sw.w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	sw.w.Header().Set("Content-Type", "text/csv")
+	sw.w.Header().Set("Content-Disposition", "attachment; filename=query.csv")
+	sw.w.WriteHeader(http.StatusOK)
+	sw.csvWriter = csv.NewWriter(sw.buf)
+	return sw.csvWriter.Write(cols)
+}
+
+// writeRow writes values of the row in order of columns of the first row
+func (sw *StreamWriter) writeRow(ctx context.Context, cols []string, values []interface{}) (err error) {
+	if !sw.started {
+		if err = sw.start(cols); err != nil {
+			return err
+		}
+	}
+	if sw.outputType == module_model.OUTPUT_TYPE_NDJSON {
+		doc := make(map[string]interface{}, len(cols))
+		for i, c := range cols {
+			doc[c] = values[i]
+		}
+		docBytes, e := json.Marshal(doc)
+		if e != nil {
+			return e
+		}
+		if _, err = sw.buf.Write(append(docBytes, '\n')); err != nil {
+			return err
+		}
+	} else {
+		record := make([]string, len(values))
+		for i, v := range values {
+			if v != nil {
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err = sw.csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	sw.rowCount++
+	if sw.rowCount%streamFlushRows == 0 {
+		return sw.flush()
+	}
+	return nil
+}
+
+func (sw *StreamWriter) flush() (err error) {
+	if sw.csvWriter != nil {
+		sw.csvWriter.Flush()
+		if err = sw.csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+	if err = sw.buf.Flush(); err != nil {
+		return err
+	}
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Finish completes the streamed response and returns false if nothing is written so that error is returned as usual error response.
+// error after response is started is written as last line of newline delimited json, while csv response is aborted to
+// let the client know that the file is incomplete.
+func (sw *StreamWriter) Finish(ctx context.Context, err error) (done bool) {
+	logs.WithContext(ctx).Debug("Finish - Start")
+	if !sw.started {
+		if err != nil {
+			return false
+		}
+		if startErr := sw.start(sw.queryCols); startErr != nil {
+			logs.WithContext(ctx).Error(startErr.Error())
+		}
+	}
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("streamed output stopped after ", sw.rowCount, " rows : ", err.Error()))
+		if sw.outputType != module_model.OUTPUT_TYPE_NDJSON {
+			panic(http.ErrAbortHandler)
+		}
+		errBytes, _ := json.Marshal(map[string]string{"error": err.Error()})
+		_, _ = sw.buf.Write(append(errBytes, '\n'))
+	}
+	if flushErr := sw.flush(); flushErr != nil {
+		logs.WithContext(ctx).Error(flushErr.Error())
+	}
+	return true
+}
+
+// rowFunc returns func which masks and transforms the row before writing it to the stream.
+// with transform rules, columns of the first transformed row are the columns of the output.
+func (sw *StreamWriter) rowFunc(ctx context.Context, masks map[string]module_model.ColumnMasking, tr module_model.TransformRule, vars map[string]interface{}) ds.StreamRowFunc {
+	var transformedCols []string
+	return func(cols []string, values []interface{}) (err error) {
+		for i, c := range cols {
+			if cm, ok := masks[c]; ok {
+				values[i] = cm.Mask(ctx, values[i])
+			}
+		}
+		if !hasTransformRules(tr) {
+			return sw.writeRow(ctx, cols, values)
+		}
+		doc := make(map[string]interface{}, len(cols))
+		for i, c := range cols {
+			doc[c] = values[i]
+		}
+		if doc, err = transformDoc(ctx, tr, doc, vars); err != nil {
+			return err
+		}
+		if transformedCols == nil {
+			transformedCols = transformedHeader(cols, doc)
+		}
+		transformedValues := make([]interface{}, len(transformedCols))
+		for i, c := range transformedCols {
+			transformedValues[i] = doc[c]
+		}
+		return sw.writeRow(ctx, transformedCols, transformedValues)
+	}
+}
+
+// transformedHeader returns columns of the query found in transformed document followed by columns added by transform rules
+func transformedHeader(cols []string, doc map[string]interface{}) (header []string) {
+	found := make(map[string]bool, len(doc))
+	for k := range doc {
+		found[k] = true
+	}
+	for _, c := range cols {
+		if found[c] {
+			header = append(header, c)
+			delete(found, c)
+		}
+	}
+	var added []string
+	for k := range found {
+		added = append(added, k)
+	}
+	sort.Strings(added)
+	return append(header, added...)
+}
+
+// executeStream executes read query streaming its rows to the stream writer with timeout and cost guards of the datasource.
+// query failed on an unhealthy replica is executed on primary only if no row is streamed yet.
+func executeStream(ctx context.Context, sqlMaker ds.SqlMakerI, datasource *module_model.DataSource, query string, args []interface{}, readOnly bool, timeOut int, sw *StreamWriter, onRow ds.StreamRowFunc) (err error) {
+	logs.WithContext(ctx).Debug("executeStream - Start")
+	if sw == nil {
+		err = errors.New("stream writer not set for streamed output")
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	qctx, cancel, timeOutErr := withQueryTimeOut(ctx, datasource, timeOut)
+	defer cancel()
+	guardedExec := func(d *module_model.DataSource) error {
+		if e := checkQueryCost(qctx, sqlMaker, d, query, args); e != nil {
+			return e
+		}
+		cols, _, e := sqlMaker.ExecuteQueryStream(qctx, query, d, sw.outputType != module_model.OUTPUT_TYPE_NDJSON, onRow, args...)
+		sw.queryCols = cols
+		return timeOutErr(e)
+	}
+	if !readOnly {
+		return guardedExec(datasource)
+	}
+	readDs := datasource.ReadDataSource(ctx)
+	err = guardedExec(readDs)
+	if err != nil && !sw.Started() && qctx.Err() == nil && readDs != datasource && !datasource.RecheckReplica(ctx, readDs.Con) {
+		logs.WithContext(ctx).Warn(fmt.Sprint("read query failed on unhealthy replica of ", datasource.DbAlias, " - executing it on primary"))
+		return guardedExec(datasource)
+	}
+	return err
+}
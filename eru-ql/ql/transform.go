@@ -0,0 +1,216 @@
+package ql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"sort"
+	"strings"
+)
+
+// ProcessTransformRule applies the transform rule on the document and returns it - document is transformed in place
+func (qld *QLData) ProcessTransformRule(ctx context.Context, tr module_model.TransformRule, doc map[string]interface{}) (outputDoc map[string]interface{}, err error) {
+	logs.WithContext(ctx).Debug("ProcessTransformRule - Start")
+	return transformDoc(ctx, tr, doc, qld.FinalVariables)
+}
+
+// transformDoc applies rules of the transform rule on the document in order of their rank.
+// with custom rule type, a rule is applied only if its custom rule is satisfied - fields of the document are referred as docs.<field> in custom rule.
+// each rule sets force column values and fields returned by complex script on the document and then removes remove column values from it.
+func transformDoc(ctx context.Context, tr module_model.TransformRule, doc map[string]interface{}, vars map[string]interface{}) (map[string]interface{}, error) {
+	if !hasTransformRules(tr) || doc == nil {
+		return doc, nil
+	}
+	docVars := make(map[string]interface{}, len(vars)+1)
+	for k, v := range vars {
+		docVars[k] = v
+	}
+	docVars[module_model.RULEPREFIX_DOCS] = doc
+	for _, rule := range rankTransformRules(tr.Rules) {
+		if tr.RuleType == module_model.RULETYPE_CUSTOM {
+			apply, err := rule.CustomRule.Evaluate(ctx, docVars)
+			if err != nil {
+				logs.WithContext(ctx).Error(err.Error())
+				return nil, err
+			}
+			if !apply {
+				continue
+			}
+		}
+		for k, v := range rule.ForceColumnValues {
+			// value of a docs template is set on the document by processTemplate itself
+			outputBytes, err := processTemplate(ctx, "forcecolumnvalue", v, docVars, "string", k)
+			if err != nil {
+				return nil, err
+			}
+			if outputBytes != nil {
+				doc[k] = string(outputBytes)
+			}
+		}
+		if strings.TrimSpace(rule.ComplexScript) != "" {
+			scriptDoc, err := executeComplexScript(ctx, rule.ComplexScript, docVars)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range scriptDoc {
+				doc[k] = v
+			}
+		}
+		for _, k := range rule.RemoveColumnValues {
+			delete(doc, k)
+		}
+	}
+	return doc, nil
+}
+
+// hasTransformRules returns true if the transform rule has rules to apply - rules are not applied if rule type is none
+func hasTransformRules(tr module_model.TransformRule) bool {
+	return (tr.RuleType == module_model.RULETYPE_ALWAYS || tr.RuleType == module_model.RULETYPE_CUSTOM) && len(tr.Rules) > 0
+}
+
+// rankTransformRules returns copy of the rules sorted on rule rank - rules of same rank are kept in the order they are saved
+func rankTransformRules(rules []module_model.TransformRuleDetail) []module_model.TransformRuleDetail {
+	ranked := make([]module_model.TransformRuleDetail, len(rules))
+	copy(ranked, rules)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].RuleRank < ranked[j].RuleRank
+	})
+	return ranked
+}
+
+// executeComplexScript executes go template of the complex script with the document as docs - template is expected to return a json object
+func executeComplexScript(ctx context.Context, script string, vars map[string]interface{}) (scriptDoc map[string]interface{}, err error) {
+	logs.WithContext(ctx).Debug("executeComplexScript - Start")
+	outputBytes, err := executeTemplate(ctx, "complexscript", script, vars, "json")
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(outputBytes, &scriptDoc); err != nil {
+		err = errors.New(fmt.Sprint("complex script is expected to return a json object : ", err.Error()))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+	return scriptDoc, nil
+}
+
+// tableTransforms holds transform output rules of the joined tables of query result keyed by name of their nested records
+type tableTransforms map[string]module_model.TransformRule
+
+// addTransform adds transform output rule of the table against name of its nested records if the rule has rules to apply
+func (tts tableTransforms) addTransform(ctx context.Context, s module_store.ModuleStoreI, projectId string, dbAlias string, tableName string, resultTable string) (err error) {
+	trs, err := s.GetTableTransformation(ctx, projectId, dbAlias, tableName)
+	if err != nil {
+		err = errors.New(fmt.Sprint("error from GetTableTransformation = ", err.Error()))
+		logs.WithContext(ctx).Error(err.Error())
+		return err
+	}
+	if hasTransformRules(trs.TransformOutput) {
+		tts[resultTable] = trs.TransformOutput
+	}
+	return nil
+}
+
+// transformRecords applies the transform output rule on records of query result.
+// nested records of joined tables are transformed with rule of the joined table before the record itself is transformed.
+func transformRecords(ctx context.Context, tr module_model.TransformRule, nested tableTransforms, records interface{}, vars map[string]interface{}) (err error) {
+	logs.WithContext(ctx).Debug("transformRecords - Start")
+	recs, ok := records.([]interface{})
+	if !ok {
+		return nil
+	}
+	for _, rec := range recs {
+		doc, dok := rec.(map[string]interface{})
+		if !dok {
+			continue
+		}
+		if len(nested) > 0 {
+			for k, v := range doc {
+				if _, isNested := v.([]interface{}); isNested {
+					if err = transformRecords(ctx, nested[k], nested, v, vars); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if _, err = transformDoc(ctx, tr, doc, vars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transformCsvRows applies the transform output rule on rows returned for csv and excel output - first row holds the column names.
+// columns removed by the rule are dropped from all rows and columns added by the rule are appended after existing columns.
+func transformCsvRows(ctx context.Context, tr module_model.TransformRule, rows interface{}, vars map[string]interface{}) (transformedRows interface{}, err error) {
+	logs.WithContext(ctx).Debug("transformCsvRows - Start")
+	recs, ok := rows.([][]interface{})
+	if !ok || len(recs) < 2 || !hasTransformRules(tr) {
+		return rows, nil
+	}
+	var header []string
+	for _, h := range recs[0] {
+		header = append(header, fmt.Sprint(h))
+	}
+	docs := make([]map[string]interface{}, len(recs)-1)
+	found := make(map[string]bool)
+	for i, record := range recs[1:] {
+		doc := make(map[string]interface{})
+		for c, h := range header {
+			if c < len(record) {
+				doc[h] = record[c]
+			}
+		}
+		if docs[i], err = transformDoc(ctx, tr, doc, vars); err != nil {
+			return nil, err
+		}
+		for k := range docs[i] {
+			found[k] = true
+		}
+	}
+	var newHeader []string
+	for _, h := range header {
+		if found[h] {
+			newHeader = append(newHeader, h)
+			delete(found, h)
+		}
+	}
+	var addedCols []string
+	for k := range found {
+		addedCols = append(addedCols, k)
+	}
+	sort.Strings(addedCols)
+	newHeader = append(newHeader, addedCols...)
+
+	headerRow := make([]interface{}, len(newHeader))
+	for c, h := range newHeader {
+		headerRow[c] = h
+	}
+	newRecs := [][]interface{}{headerRow}
+	for _, doc := range docs {
+		record := make([]interface{}, len(newHeader))
+		for c, h := range newHeader {
+			record[c] = csvValue(doc[h])
+		}
+		newRecs = append(newRecs, record)
+	}
+	return newRecs, nil
+}
+
+// csvValue returns value as string as all values of csv rows are strings
+func csvValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case map[string]interface{}, []interface{}:
+		valBytes, _ := json.Marshal(val)
+		return string(valBytes)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,111 @@
+package ql
+
+import (
+	"context"
+	"github.com/eru-tech/eru/eru-ql/module_model"
+	"github.com/eru-tech/eru/eru-ql/module_store"
+	"github.com/eru-tech/eru/eru-security-rule/security_rule"
+	"reflect"
+	"testing"
+)
+
+func TestTransformDoc(t *testing.T) {
+	ctx := context.Background()
+	openRule := security_rule.CustomRule{AND: []security_rule.CustomRuleDetails{{Variable1: "docs.status", Variable2: "open", Operator: "eq", DataType: "string"}}}
+	tests := []struct {
+		name string
+		tr   module_model.TransformRule
+		doc  map[string]interface{}
+		want map[string]interface{}
+	}{
+		{"rule type none", module_model.TransformRule{RuleType: module_model.RULETYPE_NONE, Rules: []module_model.TransformRuleDetail{{RemoveColumnValues: []string{"name"}}}},
+			map[string]interface{}{"name": "ann"}, map[string]interface{}{"name": "ann"}},
+		{"rules applied in rank order", module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, Rules: []module_model.TransformRuleDetail{
+			{ForceColumnValues: map[string]string{"label": "none.late"}, RuleRank: 2},
+			{ForceColumnValues: map[string]string{"label": "none.early"}, RuleRank: 1},
+			{ForceColumnValues: map[string]string{"stage": "none.second"}, RuleRank: 1},
+		}}, map[string]interface{}{"name": "ann"}, map[string]interface{}{"name": "ann", "label": "late", "stage": "second"}},
+		{"docs template", module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, Rules: []module_model.TransformRuleDetail{{ForceColumnValues: map[string]string{"greeting": "docs.hi {{.name}}"}}}},
+			map[string]interface{}{"name": "ann"}, map[string]interface{}{"name": "ann", "greeting": "hi ann"}},
+		{"field removed", module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, Rules: []module_model.TransformRuleDetail{{RemoveColumnValues: []string{"salary", "missing"}}}},
+			map[string]interface{}{"name": "ann", "salary": 10}, map[string]interface{}{"name": "ann"}},
+		{"removed after force values of rule", module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, Rules: []module_model.TransformRuleDetail{{ForceColumnValues: map[string]string{"salary": "none.0"}, RemoveColumnValues: []string{"salary"}}}},
+			map[string]interface{}{"salary": 10}, map[string]interface{}{}},
+		{"custom rule satisfied", module_model.TransformRule{RuleType: module_model.RULETYPE_CUSTOM, Rules: []module_model.TransformRuleDetail{{CustomRule: openRule, RemoveColumnValues: []string{"note"}}}},
+			map[string]interface{}{"status": "open", "note": "x"}, map[string]interface{}{"status": "open"}},
+		{"custom rule not satisfied", module_model.TransformRule{RuleType: module_model.RULETYPE_CUSTOM, Rules: []module_model.TransformRuleDetail{{CustomRule: openRule, RemoveColumnValues: []string{"note"}}}},
+			map[string]interface{}{"status": "closed", "note": "x"}, map[string]interface{}{"status": "closed", "note": "x"}},
+		{"complex script", module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, Rules: []module_model.TransformRuleDetail{{ComplexScript: `{"upper": "{{ .docs.name }}-x"}`}}},
+			map[string]interface{}{"name": "ann"}, map[string]interface{}{"name": "ann", "upper": "ann-x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := transformDoc(ctx, tt.tr, tt.doc, map[string]interface{}{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("doc = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformRecordsOfJoinedTables(t *testing.T) {
+	ctx := context.Background()
+	removeCol := func(col string) module_model.TransformRule {
+		return module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, Rules: []module_model.TransformRuleDetail{{RemoveColumnValues: []string{col}}}}
+	}
+	datasource := &module_model.DataSource{DbAlias: "db", SchemaTables: map[string]map[string]module_model.TableColsMetaData{"public.orders": {}, "public.items": {}, "public.notes": {}},
+		SchemaTablesTransformation: map[string]module_model.TransformRules{"public.items": {TransformOutput: removeCol("cost")}}}
+	s := &module_store.ModuleFileStore{}
+	s.Projects = map[string]*module_model.Project{"p": {ProjectId: "p", DataSources: map[string]*module_model.DataSource{"db": datasource}}}
+	nested := make(tableTransforms)
+	for _, tn := range []string{"public.items", "public.notes"} {
+		if err := nested.addTransform(ctx, s, "p", "db", tn, tn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := nested["public.notes"]; ok || len(nested) != 1 {
+		t.Fatalf("only tables with transform rules are expected to be added : %v", nested)
+	}
+	if err := nested.addTransform(ctx, s, "p", "db", "public.missing", "public.missing"); err == nil {
+		t.Error("error is expected for table not in the datasource")
+	}
+
+	records := []interface{}{map[string]interface{}{"id": 1, "secret": "s", "public.items": []interface{}{
+		map[string]interface{}{"sku": "a", "cost": 5},
+		map[string]interface{}{"sku": "b", "cost": 6, "public.notes": []interface{}{map[string]interface{}{"cost": 1}}},
+	}}}
+	if err := transformRecords(ctx, removeCol("secret"), nested, records, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{map[string]interface{}{"id": 1, "public.items": []interface{}{
+		map[string]interface{}{"sku": "a"},
+		map[string]interface{}{"sku": "b", "public.notes": []interface{}{map[string]interface{}{"cost": 1}}},
+	}}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+}
+
+func TestTransformCsvRows(t *testing.T) {
+	ctx := context.Background()
+	tr := module_model.TransformRule{RuleType: module_model.RULETYPE_ALWAYS, Rules: []module_model.TransformRuleDetail{
+		{ForceColumnValues: map[string]string{"zone": "none.east", "band": "docs.{{.grade}}1"}, RemoveColumnValues: []string{"salary"}},
+	}}
+	rows := [][]interface{}{{"name", "salary", "grade"}, {"ann", "10", "a"}, {"bob", "20", "b"}}
+	got, err := transformCsvRows(ctx, tr, rows, map[string]interface{}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{{"name", "grade", "band", "zone"}, {"ann", "a", "a1", "east"}, {"bob", "b", "b1", "east"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+	// rows with only header are not transformed
+	header := [][]interface{}{{"name", "salary"}}
+	if got, err = transformCsvRows(ctx, tr, header, map[string]interface{}{}); err != nil || !reflect.DeepEqual(got, header) {
+		t.Errorf("rows = %v, err %v", got, err)
+	}
+}
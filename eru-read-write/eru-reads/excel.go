@@ -54,6 +54,14 @@ func (erd *ExcelReadData) ReadAsJson(ctx context.Context, readData []byte) (read
 		_ = rows
 		_ = sheetObj
 		logs.WithContext(ctx).Info(sheetName)
+		if len(rows) <= sheetObj.HeaderRow {
+			// sheet has no header row to read
+			if readOutput == nil {
+				readOutput = make(map[string]interface{})
+			}
+			readOutput[sheetName] = sheetData
+			continue
+		}
 
 		var cols []int
 		var colHeaders []string
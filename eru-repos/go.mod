@@ -3,17 +3,27 @@ module github.com/eru-tech/eru/eru-repos
 go 1.20
 
 require (
-github.com/eru-tech/eru/eru-logs v0.0.0-00010101000000-000000000000
-github.com/eru-tech/eru/eru-utils v0.0.0-00010101000000-000000000000
+	github.com/eru-tech/eru/eru-logs v0.0.0-00010101000000-000000000000
+	github.com/eru-tech/eru/eru-utils v0.0.0-00010101000000-000000000000
 )
 
 require (
+	github.com/felixge/httpsnoop v1.0.2 // indirect
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/jmoiron/sqlx v1.3.4 // indirect
+	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.25.0 // indirect
+	go.opentelemetry.io/otel v1.14.0 // indirect
+	go.opentelemetry.io/otel/internal/metric v0.24.0 // indirect
+	go.opentelemetry.io/otel/metric v0.24.0 // indirect
+	go.opentelemetry.io/otel/trace v1.14.0 // indirect
 	go.uber.org/atomic v1.10.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.24.0 // indirect
 )
 
 replace (
-github.com/eru-tech/eru/eru-logs => ../eru-logs
-github.com/eru-tech/eru/eru-utils => ../eru-utils
+	github.com/eru-tech/eru/eru-logs => ../eru-logs
+	github.com/eru-tech/eru/eru-utils => ../eru-utils
 )
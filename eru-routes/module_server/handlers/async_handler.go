@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-routes/module_store"
+	"github.com/eru-tech/eru/eru-routes/routes"
+	server_handlers "github.com/eru-tech/eru/eru-server/server/handlers"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+func AsyncJobStatusHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("AsyncJobStatusHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		jobId := vars["jobid"]
+
+		if routes.AsyncQueue == nil {
+			server_handlers.FormatResponse(w, http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "async queue is not configured"})
+			return
+		}
+		job, err := routes.AsyncQueue.GetJob(r.Context(), jobId)
+		// job of another project or caller is reported as not found so that job ids cannot be probed
+		if err == nil && (job.ProjectId != projectId || job.Owner != routes.AsyncJobOwner(r.Context(), r.Header.Get("claims"))) {
+			err = errors.New(fmt.Sprint("Async job ", jobId, " not found"))
+			logs.WithContext(r.Context()).Error(err.Error())
+		}
+		if err != nil {
+			server_handlers.FormatResponse(w, http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, http.StatusOK)
+		_ = json.NewEncoder(w).Encode(job.GetStatus())
+	}
+}
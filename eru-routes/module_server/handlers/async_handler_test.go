@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-routes/routes"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logs.LogInit("eru-routes-handlers-test")
+	os.Exit(m.Run())
+}
+
+func TestAsyncJobStatusHandler(t *testing.T) {
+	queue, err := routes.NewAsyncFileQueue(context.Background(), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	asyncQueue := routes.AsyncQueue
+	defer func() { routes.AsyncQueue = asyncQueue }()
+	routes.AsyncQueue = queue
+
+	owner := `{"sub":"u1","exp":1}`
+	ctx := routes.WithAsyncJobClaims(context.WithValue(context.Background(), "project_id", "p"), owner)
+	jobId, err := routes.EnqueueAsync(ctx, "r", routes.AsyncRetry{}, httptest.NewRequest(http.MethodPost, "/x", strings.NewReader("{}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		project    string
+		claims     string
+		wantStatus int
+	}{
+		{"same caller", "p", owner, http.StatusOK},
+		{"same caller with refreshed token", "p", `{"sub":"u1","exp":2}`, http.StatusOK},
+		{"different caller", "p", `{"sub":"u2","exp":1}`, http.StatusNotFound},
+		{"caller without claims", "p", "", http.StatusNotFound},
+		{"different project", "q", owner, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, fmt.Sprint("/", tt.project, "/async/", jobId), nil)
+			if tt.claims != "" {
+				r.Header.Set("claims", tt.claims)
+			}
+			r = mux.SetURLVars(r, map[string]string{"project": tt.project, "jobid": jobId})
+			w := httptest.NewRecorder()
+			AsyncJobStatusHandler(nil)(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d : %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(w.Body.String(), jobId) {
+				t.Errorf("job status is expected in response : %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
 	"github.com/eru-tech/eru/eru-routes/module_store"
+	"github.com/eru-tech/eru/eru-routes/routes"
 	server_handlers "github.com/eru-tech/eru/eru-server/server/handlers"
 	"github.com/gorilla/mux"
 	"io"
@@ -25,6 +26,8 @@ func FuncHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 		host, url := extractHostUrl(r)
 		vars := mux.Vars(r)
 		projectId := vars["project"]
+		ctx = context.WithValue(ctx, "project_id", projectId)
+		ctx = routes.WithAsyncJobClaims(ctx, r.Header.Get("claims"))
 		funcName := vars["funcname"]
 
 		// Lookup a routes in a function based on host and url
@@ -4,12 +4,17 @@ import (
 	"context"
 	//"bytes"
 	"encoding/json"
+	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
 	"github.com/eru-tech/eru/eru-routes/module_store"
+	"github.com/eru-tech/eru/eru-routes/routes"
 	server_handlers "github.com/eru-tech/eru/eru-server/server/handlers"
+	utils "github.com/eru-tech/eru/eru-utils"
 	"github.com/gorilla/mux"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func RouteForwardHandler(s module_store.ModuleStoreI) http.HandlerFunc {
@@ -48,6 +53,8 @@ func RouteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 		host, url := extractHostUrl(r)
 		vars := mux.Vars(r)
 		projectId := vars["project"]
+		ctx = context.WithValue(ctx, "project_id", projectId)
+		ctx = routes.WithAsyncJobClaims(ctx, r.Header.Get("claims"))
 		routeName := vars["routename"]
 
 		// Lookup a route based on host and url
@@ -58,6 +65,44 @@ func RouteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 			return
 		}
 
+		if route.RateLimit.IsSet() {
+			// claims header is set by gateway after verifying the token - claims sent by the client are dropped by gateway
+			rlResult := utils.CheckRateLimit(ctx, r, fmt.Sprint(projectId, "/", routeName), route.RateLimit, utils.ParseClaims(ctx, r.Header.Get("claims")))
+			utils.SetRateLimitHeaders(w.Header(), rlResult)
+			if !rlResult.Allowed {
+				server_handlers.FormatResponse(w, http.StatusTooManyRequests)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded"})
+				return
+			}
+		}
+
+		// key is computed before execute as route execution transforms headers and params of the request
+		cacheKey, cacheable := route.CacheKey(ctx, projectId, r)
+		cacheable = cacheable && routes.RouteCache != nil
+		if cacheable {
+			if routes.IsCacheBypassed(r) {
+				w.Header().Set(routes.CacheStatusHeader, routes.CacheStatusBypass)
+			} else if cr, ok := routes.RouteCache.Get(ctx, cacheKey); ok {
+				logs.WithContext(ctx).Info(fmt.Sprint("cache hit for route ", routeName))
+				for k, v := range cr.Header {
+					w.Header().Del(k)
+					for _, h := range v {
+						w.Header().Add(k, h)
+					}
+				}
+				w.Header().Set(routes.CacheStatusHeader, routes.CacheStatusHit)
+				w.Header().Set("Age", strconv.Itoa(int(time.Since(cr.CreateDate).Seconds())))
+				w.WriteHeader(cr.StatusCode)
+				_, err = w.Write(cr.Body)
+				if err != nil {
+					logs.WithContext(ctx).Error(err.Error())
+				}
+				return
+			} else {
+				w.Header().Set(routes.CacheStatusHeader, routes.CacheStatusMiss)
+			}
+		}
+
 		/*
 			if route.Authorizer != "" {
 
@@ -102,9 +147,13 @@ func RouteHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 				_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 				return
 			}
+			if cacheable && w.Header().Get(routes.CacheStatusHeader) == routes.CacheStatusMiss {
+				route.CacheResponse(ctx, cacheKey, projectId, response)
+			}
 			for k, v := range response.Header {
+				w.Header().Del(k)
 				for _, h := range v {
-					w.Header().Set(k, h)
+					w.Header().Add(k, h)
 				}
 			}
 			w.WriteHeader(response.StatusCode)
@@ -253,6 +253,34 @@ func RouteRemoveHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 	}
 }
 
+func RouteCachePurgeHandler(s module_store.ModuleStoreI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logs.WithContext(r.Context()).Debug("RouteCachePurgeHandler - Start")
+		vars := mux.Vars(r)
+		projectId := vars["project"]
+		routeName := vars["routename"]
+
+		if _, err := s.GetProjectConfig(r.Context(), projectId); err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		if routes.RouteCache == nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": "route cache is not configured"})
+			return
+		}
+		purged, err := routes.RouteCache.Purge(r.Context(), projectId, routeName)
+		if err != nil {
+			server_handlers.FormatResponse(w, 400)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": err.Error()})
+			return
+		}
+		server_handlers.FormatResponse(w, 200)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"msg": fmt.Sprint(purged, " cached responses purged"), "purged": purged})
+	}
+}
+
 func FuncSaveHandler(s module_store.ModuleStoreI) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logs.WithContext(r.Context()).Debug("FuncSaveHandler - Start")
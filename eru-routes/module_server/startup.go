@@ -7,9 +7,13 @@ import (
 	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
 	"github.com/eru-tech/eru/eru-routes/module_store"
+	"github.com/eru-tech/eru/eru-routes/routes"
+	utils "github.com/eru-tech/eru/eru-utils"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const StoreTableName = "eruroutes_config"
@@ -81,5 +85,73 @@ func StartUp() (module_store.ModuleStoreI, error) {
 		logs.WithContext(context.Background()).Error(err.Error())
 	}
 	//s.Store = myStore
+	if err == nil {
+		//async routes fail on enqueue till the queue is fixed - rest of the routes are still served
+		if asyncErr := startAsyncWorkers(storeType, myStore); asyncErr != nil {
+			logs.WithContext(context.Background()).Error(fmt.Sprint("async workers not started : ", asyncErr.Error()))
+		}
+	}
+	if err == nil {
+		err = setRouteCache(myStore)
+	}
+	if err == nil {
+		err = utils.SetRateLimiter(context.Background())
+	}
 	return myStore, err
 }
+
+func setRouteCache(myStore module_store.ModuleStoreI) (err error) {
+	ctx := context.Background()
+	routeCacheType := strings.ToUpper(os.Getenv("ROUTE_CACHE"))
+	if routeCacheType == "" {
+		routeCacheType = "MEMORY"
+		logs.WithContext(ctx).Info("'ROUTE_CACHE' environment variable not found - setting default value as MEMORY")
+	}
+	maxEntries, meErr := strconv.Atoi(os.Getenv("ROUTE_CACHE_MAX_ENTRIES"))
+	if meErr != nil {
+		maxEntries = 0
+	}
+	routes.RouteCache, err = routes.NewRouteCache(ctx, routeCacheType, myStore, maxEntries)
+	return
+}
+
+func startAsyncWorkers(storeType string, myStore module_store.ModuleStoreI) (err error) {
+	ctx := context.Background()
+	asyncQueueType := strings.ToUpper(os.Getenv("ASYNC_QUEUE"))
+	if asyncQueueType == "" {
+		asyncQueueType = "FILE"
+		if storeType == "POSTGRES" {
+			asyncQueueType = "POSTGRES"
+		}
+		logs.WithContext(ctx).Info(fmt.Sprint("'ASYNC_QUEUE' environment variable not found - setting default value as ", asyncQueueType))
+	}
+	asyncQueuePath := os.Getenv("ASYNC_QUEUE_PATH")
+	if asyncQueuePath == "" {
+		wd, wdErr := os.Getwd()
+		if wdErr != nil {
+			logs.WithContext(ctx).Error(wdErr.Error())
+		}
+		asyncQueuePath = filepath.Join(wd, "asyncqueue")
+		logs.WithContext(ctx).Info(fmt.Sprint("'ASYNC_QUEUE_PATH' environment variable not found - setting default value as ", asyncQueuePath))
+	}
+	asyncThreads, atErr := strconv.Atoi(os.Getenv("ASYNC_THREADS"))
+	if atErr != nil || asyncThreads <= 0 {
+		asyncThreads = 3
+		logs.WithContext(ctx).Info("'ASYNC_THREADS' environment variable not found or non numeric - setting default value as 3")
+	}
+	retentionHours, rhErr := strconv.Atoi(os.Getenv("ASYNC_JOB_RETENTION_HOURS"))
+	if rhErr == nil && retentionHours > 0 {
+		routes.AsyncJobRetention = time.Duration(retentionHours) * time.Hour
+	}
+	asyncQueueKey := os.Getenv("ASYNC_QUEUE_KEY")
+	if asyncQueueKey == "" {
+		logs.WithContext(ctx).Info("'ASYNC_QUEUE_KEY' environment variable not found - credential headers will be dropped from async jobs")
+	}
+	routes.SetAsyncCredentialsKey(asyncQueueKey)
+	asyncQueue, err := routes.NewAsyncQueue(ctx, asyncQueueType, myStore, asyncQueuePath)
+	if err != nil {
+		return
+	}
+	routes.StartAsyncWorkers(ctx, asyncQueue, asyncThreads, time.Second)
+	return
+}
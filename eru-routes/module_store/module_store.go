@@ -194,6 +194,8 @@ func (ms *ModuleStore) SaveRoute(ctx context.Context, routeObj routes.Route, pro
 		logs.WithContext(ctx).Error(err.Error())
 		return err
 	}
+	// health checks are started again with saved target hosts on next request of the route
+	routes.StopHealthChecks(ctx, projectId, routeObj.RouteName)
 	if persist == true {
 		return realStore.SaveStore(ctx, "", realStore)
 	}
@@ -205,6 +207,7 @@ func (ms *ModuleStore) RemoveRoute(ctx context.Context, routeName string, projec
 	if prg, ok := ms.Projects[projectId]; ok {
 		if _, ok := prg.Routes[routeName]; ok {
 			delete(prg.Routes, routeName)
+			routes.StopHealthChecks(ctx, projectId, routeName)
 			logs.WithContext(ctx).Info("SaveStore called from RemoveRoute")
 			return realStore.SaveStore(ctx, "", realStore)
 		} else {
@@ -357,6 +360,12 @@ func (ms *ModuleStore) LoadRoutesForFunction(ctx context.Context, funcStep *rout
 				return
 			}
 		}
+		//policy set on func step overrides the policy of the route for this func step only
+		if funcStep.Policy.IsSet() {
+			r.Policy = funcStep.Policy
+			//failures under the overridden policy are tracked apart from the circuit of the route
+			r.CircuitName = fmt.Sprint(r.RouteName, "/funcstep/", funcStep.GetRouteName())
+		}
 		funcStep.Route = r
 
 	}
@@ -0,0 +1,455 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	utils "github.com/eru-tech/eru/eru-utils"
+	"github.com/google/uuid"
+	"io"
+	"math"
+	"net/http"
+	"time"
+)
+
+const (
+	AsyncJobStatusPending = "PENDING"
+	AsyncJobStatusRunning = "RUNNING"
+	AsyncJobStatusSuccess = "SUCCESS"
+	AsyncJobStatusFailed  = "FAILED"
+	AsyncJobIdHeader      = "X-Eru-Async-Job-Id"
+	asyncJobIdKey         = "job_id"
+	asyncJobClaimsKey     = "claims"
+)
+
+// AsyncQueue is set at startup and holds async jobs of routes and func steps till they are executed by async workers
+var AsyncQueue AsyncQueueI
+
+// AsyncJobTimeout is the maximum time given to a single attempt of an async job
+var AsyncJobTimeout = 60 * time.Second
+
+// AsyncJobLease is the time after which a running job, whose worker has died, is picked up again
+var AsyncJobLease = 5 * time.Minute
+
+// AsyncJobRetention is the time for which completed and failed jobs are kept in the queue before they are purged
+var AsyncJobRetention = 7 * 24 * time.Hour
+
+// asyncJobPurgeInterval is how often the queue is purged of jobs older than AsyncJobRetention
+var asyncJobPurgeInterval = time.Hour
+
+// asyncCredentialsKey encrypts credential headers of async jobs before they are saved in the queue.
+// Credential headers are dropped from async jobs if no key is set.
+var asyncCredentialsKey []byte
+
+// asyncCredentialHeaders are never saved in plain text in the async queue
+var asyncCredentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+const defaultAsyncBackoffSeconds = 5
+
+type AsyncQueueI interface {
+	Enqueue(ctx context.Context, job *AsyncJob) error
+	Dequeue(ctx context.Context, limit int) ([]*AsyncJob, error)
+	UpdateJob(ctx context.Context, job *AsyncJob) error
+	GetJob(ctx context.Context, jobId string) (*AsyncJob, error)
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+type AsyncRetry struct {
+	MaxRetries     int
+	BackoffSeconds int
+}
+
+type AsyncRequest struct {
+	Method      string
+	Url         string
+	Host        string
+	Header      http.Header
+	Credentials []byte //credential headers encrypted with asyncCredentialsKey
+	Body        []byte
+}
+
+type AsyncResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+type AsyncJob struct {
+	JobId      string
+	ProjectId  string
+	Owner      string //identity of the caller who queued the job - only the same caller can read its status
+	RouteName  string
+	Status     string
+	Attempts   int
+	Retry      AsyncRetry
+	NextRunAt  time.Time
+	Request    AsyncRequest
+	Response   *AsyncResponse
+	Error      string
+	CreateDate time.Time
+	UpdateDate time.Time
+}
+
+// AsyncJobStatus is the view of an async job returned to clients - request is left out as it can carry tokens
+type AsyncJobStatus struct {
+	JobId      string      `json:"job_id"`
+	RouteName  string      `json:"route_name"`
+	Status     string      `json:"status"`
+	Attempts   int         `json:"attempts"`
+	NextRunAt  *time.Time  `json:"next_run_at,omitempty"`
+	StatusCode int         `json:"status_code,omitempty"`
+	Response   interface{} `json:"response,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	CreateDate time.Time   `json:"create_date"`
+	UpdateDate time.Time   `json:"update_date"`
+}
+
+func (job *AsyncJob) GetStatus() (jobStatus AsyncJobStatus) {
+	jobStatus.JobId = job.JobId
+	jobStatus.RouteName = job.RouteName
+	jobStatus.Status = job.Status
+	jobStatus.Attempts = job.Attempts
+	jobStatus.Error = job.Error
+	jobStatus.CreateDate = job.CreateDate
+	jobStatus.UpdateDate = job.UpdateDate
+	if job.Status == AsyncJobStatusPending {
+		nextRunAt := job.NextRunAt
+		jobStatus.NextRunAt = &nextRunAt
+	}
+	if job.Response != nil {
+		jobStatus.StatusCode = job.Response.StatusCode
+		var body interface{}
+		if err := json.Unmarshal(job.Response.Body, &body); err == nil {
+			jobStatus.Response = body
+		} else {
+			jobStatus.Response = string(job.Response.Body)
+		}
+	}
+	return
+}
+
+func newAsyncJob(ctx context.Context, routeName string, retry AsyncRetry, request *http.Request) (job *AsyncJob, err error) {
+	logs.WithContext(ctx).Debug("newAsyncJob - Start")
+	jobId := uuid.New()
+	var body []byte
+	if request.Body != nil {
+		body, err = io.ReadAll(request.Body)
+		if err != nil {
+			logs.WithContext(ctx).Error(fmt.Sprint("error in io.ReadAll(request.Body) : ", err.Error()))
+			return
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	header, credentials, err := sealAsyncCredentials(ctx, request.Header)
+	if err != nil {
+		return
+	}
+	projectId, _ := ctx.Value("project_id").(string)
+	claims, _ := ctx.Value(asyncJobClaimsKey).(string)
+	now := time.Now()
+	job = &AsyncJob{
+		JobId:     jobId.String(),
+		ProjectId: projectId,
+		Owner:     AsyncJobOwner(ctx, claims),
+		RouteName: routeName,
+		Status:    AsyncJobStatusPending,
+		Retry:     retry,
+		NextRunAt: now,
+		Request: AsyncRequest{
+			Method: request.Method,
+			Url:    request.URL.String(),
+			Host:   request.Host,
+			Header:      header,
+			Credentials: credentials,
+			Body:        body,
+		},
+		CreateDate: now,
+		UpdateDate: now,
+	}
+	return
+}
+
+// AsyncJobOwner returns identity of the caller from claims header set by gateway - sub claim if present else hash of all claims
+func AsyncJobOwner(ctx context.Context, claimsStr string) string {
+	if claimsStr == "" {
+		return ""
+	}
+	if claims, ok := utils.ParseClaims(ctx, claimsStr).(map[string]interface{}); ok {
+		if sub, ok := claims["sub"].(string); ok && sub != "" {
+			return fmt.Sprint("sub:", sub)
+		}
+	}
+	h := sha256.Sum256([]byte(claimsStr))
+	return fmt.Sprint("claims:", hex.EncodeToString(h[:]))
+}
+
+// WithAsyncJobClaims keeps claims header of the incoming request in ctx as owner of async jobs queued while executing it
+func WithAsyncJobClaims(ctx context.Context, claimsStr string) context.Context {
+	return context.WithValue(ctx, asyncJobClaimsKey, claimsStr)
+}
+
+// SetAsyncCredentialsKey sets the key used to encrypt credential headers of async jobs - blank key drops credential headers from async jobs
+func SetAsyncCredentialsKey(key string) {
+	if key == "" {
+		asyncCredentialsKey = nil
+		return
+	}
+	k := sha256.Sum256([]byte(key))
+	asyncCredentialsKey = k[:]
+}
+
+// sealAsyncCredentials returns a copy of header without credential headers and the credential headers encrypted with asyncCredentialsKey
+func sealAsyncCredentials(ctx context.Context, header http.Header) (plainHeader http.Header, sealed []byte, err error) {
+	plainHeader = header.Clone()
+	if plainHeader == nil {
+		plainHeader = make(http.Header)
+	}
+	credentials := make(http.Header)
+	for _, h := range asyncCredentialHeaders {
+		if v := plainHeader.Values(h); len(v) > 0 {
+			credentials[http.CanonicalHeaderKey(h)] = v
+			plainHeader.Del(h)
+		}
+	}
+	if len(credentials) == 0 {
+		return
+	}
+	if asyncCredentialsKey == nil {
+		logs.WithContext(ctx).Warn("ASYNC_QUEUE_KEY not set - credential headers are dropped from async job")
+		return
+	}
+	credBytes, err := json.Marshal(credentials)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	gcm, err := asyncCredentialsCipher(ctx)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	sealed = gcm.Seal(nonce, nonce, credBytes, nil)
+	return
+}
+
+// openAsyncCredentials decrypts credential headers sealed by sealAsyncCredentials
+func openAsyncCredentials(ctx context.Context, sealed []byte) (credentials http.Header, err error) {
+	if asyncCredentialsKey == nil {
+		err = errors.New("ASYNC_QUEUE_KEY not set - cannot decrypt credential headers of async job")
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	gcm, err := asyncCredentialsCipher(ctx)
+	if err != nil {
+		return
+	}
+	if len(sealed) < gcm.NonceSize() {
+		err = errors.New("invalid credential headers of async job")
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	credBytes, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("failed to decrypt credential headers of async job : ", err.Error()))
+		return
+	}
+	err = json.Unmarshal(credBytes, &credentials)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	return
+}
+
+func asyncCredentialsCipher(ctx context.Context) (gcm cipher.AEAD, err error) {
+	block, err := aes.NewCipher(asyncCredentialsKey)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	gcm, err = cipher.NewGCM(block)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	return
+}
+
+// EnqueueAsync saves the transformed request as an async job to be executed by async workers
+func EnqueueAsync(ctx context.Context, routeName string, retry AsyncRetry, request *http.Request) (jobId string, err error) {
+	logs.WithContext(ctx).Debug("EnqueueAsync - Start")
+	if AsyncQueue == nil {
+		err = errors.New("async queue is not configured")
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	job, err := newAsyncJob(ctx, routeName, retry, request)
+	if err != nil {
+		return
+	}
+	err = AsyncQueue.Enqueue(ctx, job)
+	if err != nil {
+		return
+	}
+	logs.WithContext(ctx).Info(fmt.Sprint("async job ", job.JobId, " queued for route ", routeName))
+	return job.JobId, nil
+}
+
+// addAsyncJobId adds job id to async message if it is a json object and does not already carry it
+func addAsyncJobId(body string, jobId string) string {
+	if body == "" || body == "{}" {
+		b, _ := json.Marshal(map[string]string{asyncJobIdKey: jobId})
+		return string(b)
+	}
+	msg := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(body), &msg); err != nil {
+		return body
+	}
+	if _, ok := msg[asyncJobIdKey]; ok {
+		return body
+	}
+	msg[asyncJobIdKey] = jobId
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
+
+func isAsyncJobFailure(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusTooManyRequests
+}
+
+func (retry AsyncRetry) backoff(attempts int) time.Duration {
+	backoffSeconds := retry.BackoffSeconds
+	if backoffSeconds <= 0 {
+		backoffSeconds = defaultAsyncBackoffSeconds
+	}
+	return time.Duration(float64(backoffSeconds)*math.Pow(2, float64(attempts-1))) * time.Second
+}
+
+func runAsyncJob(ctx context.Context, job *AsyncJob) {
+	logs.WithContext(ctx).Info(fmt.Sprint("runAsyncJob - Start : ", job.JobId))
+	job.Attempts++
+	job.Error = ""
+	actx, cancel := context.WithTimeout(ctx, AsyncJobTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(actx, job.Request.Method, job.Request.Url, bytes.NewReader(job.Request.Body))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		job.Status = AsyncJobStatusFailed
+		job.Error = err.Error()
+		return
+	}
+	request.Header = job.Request.Header.Clone()
+	if len(job.Request.Credentials) > 0 {
+		credentials, cErr := openAsyncCredentials(ctx, job.Request.Credentials)
+		if cErr != nil {
+			job.Status = AsyncJobStatusFailed
+			job.Error = cErr.Error()
+			return
+		}
+		for k, v := range credentials {
+			request.Header[k] = v
+		}
+	}
+	request.Host = job.Request.Host
+	request.ContentLength = int64(len(job.Request.Body))
+
+	response, err := utils.ExecuteHttp(actx, request)
+	if err == nil {
+		body, readErr := io.ReadAll(response.Body)
+		response.Body.Close()
+		if readErr != nil {
+			err = readErr
+			logs.WithContext(ctx).Error(fmt.Sprint("error in io.ReadAll(response.Body) : ", err.Error()))
+		} else {
+			job.Response = &AsyncResponse{StatusCode: response.StatusCode, Header: response.Header, Body: body}
+		}
+	}
+	if !isAsyncJobFailure(response, err) {
+		job.Status = AsyncJobStatusSuccess
+		return
+	}
+	if err != nil {
+		job.Error = err.Error()
+	} else {
+		job.Error = fmt.Sprint("target host returned status ", response.StatusCode)
+	}
+	if job.Attempts > job.Retry.MaxRetries {
+		logs.WithContext(ctx).Error(fmt.Sprint("async job ", job.JobId, " failed after ", job.Attempts, " attempts : ", job.Error))
+		job.Status = AsyncJobStatusFailed
+		return
+	}
+	job.Status = AsyncJobStatusPending
+	job.NextRunAt = time.Now().Add(job.Retry.backoff(job.Attempts))
+	logs.WithContext(ctx).Info(fmt.Sprint("async job ", job.JobId, " will be retried at ", job.NextRunAt, " : ", job.Error))
+}
+
+func asyncWorker(ctx context.Context, queue AsyncQueueI, jobs chan *AsyncJob) {
+	for job := range jobs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logs.WithContext(ctx).Error(fmt.Sprint("goroutine panicked in asyncWorker: ", r))
+				}
+			}()
+			runAsyncJob(ctx, job)
+			job.UpdateDate = time.Now()
+			if err := queue.UpdateJob(ctx, job); err != nil {
+				logs.WithContext(ctx).Error(fmt.Sprint("failed to update async job ", job.JobId, " : ", err.Error()))
+			}
+		}()
+	}
+}
+
+// StartAsyncWorkers sets the async queue and starts a pool of workers polling it for due jobs
+func StartAsyncWorkers(ctx context.Context, queue AsyncQueueI, noOfWorkers int, pollInterval time.Duration) {
+	logs.WithContext(ctx).Info(fmt.Sprint("starting ", noOfWorkers, " async workers"))
+	AsyncQueue = queue
+	jobs := make(chan *AsyncJob, noOfWorkers)
+	for i := 0; i < noOfWorkers; i++ {
+		go asyncWorker(ctx, queue, jobs)
+	}
+	go func() {
+		var lastPurge time.Time
+		for {
+			if time.Since(lastPurge) > asyncJobPurgeInterval {
+				lastPurge = time.Now()
+				if purged, err := queue.Purge(ctx, lastPurge.Add(-AsyncJobRetention)); err != nil {
+					logs.WithContext(ctx).Error(fmt.Sprint("async queue purge failed : ", err.Error()))
+				} else if purged > 0 {
+					logs.WithContext(ctx).Info(fmt.Sprint("purged ", purged, " completed async jobs"))
+				}
+			}
+			limit := cap(jobs) - len(jobs)
+			if limit <= 0 {
+				time.Sleep(pollInterval)
+				continue
+			}
+			claimed, err := queue.Dequeue(ctx, limit)
+			if err != nil {
+				logs.WithContext(ctx).Error(fmt.Sprint("async queue dequeue failed : ", err.Error()))
+			}
+			for _, job := range claimed {
+				jobs <- job
+			}
+			if len(claimed) == 0 {
+				time.Sleep(pollInterval)
+			}
+		}
+	}()
+}
@@ -0,0 +1,324 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-store/store"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const AsyncJobsTableName = "eruroutes_async_jobs"
+
+// AsyncFileQueue keeps one json file per async job in a local folder - meant for standalone setups
+// status and run times of all jobs are indexed in memory so that polling does not read job files
+type AsyncFileQueue struct {
+	dir  string
+	mu   sync.Mutex
+	jobs map[string]AsyncJob
+}
+
+func NewAsyncFileQueue(ctx context.Context, dir string) (q *AsyncFileQueue, err error) {
+	logs.WithContext(ctx).Debug("NewAsyncFileQueue - Start")
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	q = &AsyncFileQueue{dir: dir, jobs: make(map[string]AsyncJob)}
+	for _, p := range paths {
+		job, rErr := q.readJob(ctx, p)
+		if rErr != nil {
+			continue
+		}
+		q.indexJob(job)
+	}
+	return q, nil
+}
+
+func (q *AsyncFileQueue) indexJob(job *AsyncJob) {
+	q.jobs[job.JobId] = AsyncJob{JobId: job.JobId, Status: job.Status, NextRunAt: job.NextRunAt, UpdateDate: job.UpdateDate}
+}
+
+func (q *AsyncFileQueue) jobPath(jobId string) string {
+	return filepath.Join(q.dir, fmt.Sprint(jobId, ".json"))
+}
+
+func (q *AsyncFileQueue) writeJob(ctx context.Context, job *AsyncJob) (err error) {
+	jobBytes, err := json.Marshal(job)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	//writing to a temp file first so that a crash never leaves a partially written job
+	tmpPath := fmt.Sprint(q.jobPath(job.JobId), ".tmp")
+	err = os.WriteFile(tmpPath, jobBytes, 0644)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	err = os.Rename(tmpPath, q.jobPath(job.JobId))
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	q.indexJob(job)
+	return
+}
+
+func (q *AsyncFileQueue) readJob(ctx context.Context, path string) (job *AsyncJob, err error) {
+	jobBytes, err := os.ReadFile(path)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	job = &AsyncJob{}
+	err = json.Unmarshal(jobBytes, job)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+	}
+	return
+}
+
+func (q *AsyncFileQueue) Enqueue(ctx context.Context, job *AsyncJob) error {
+	logs.WithContext(ctx).Debug("AsyncFileQueue Enqueue - Start")
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.writeJob(ctx, job)
+}
+
+func (q *AsyncFileQueue) Dequeue(ctx context.Context, limit int) (jobs []*AsyncJob, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	var due []AsyncJob
+	for _, j := range q.jobs {
+		if isAsyncJobDue(&j, now) {
+			due = append(due, j)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(due[j].NextRunAt)
+	})
+	for _, j := range due {
+		if len(jobs) >= limit {
+			break
+		}
+		job, rErr := q.readJob(ctx, q.jobPath(j.JobId))
+		if rErr != nil {
+			continue
+		}
+		job.Status = AsyncJobStatusRunning
+		job.UpdateDate = now
+		if wErr := q.writeJob(ctx, job); wErr != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return
+}
+
+func (q *AsyncFileQueue) UpdateJob(ctx context.Context, job *AsyncJob) error {
+	logs.WithContext(ctx).Debug("AsyncFileQueue UpdateJob - Start")
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.writeJob(ctx, job)
+}
+
+func (q *AsyncFileQueue) GetJob(ctx context.Context, jobId string) (job *AsyncJob, err error) {
+	logs.WithContext(ctx).Debug("AsyncFileQueue GetJob - Start")
+	//job id is always a uuid - parsing it also guards against path traversal
+	if _, err = uuid.Parse(jobId); err != nil {
+		err = errors.New(fmt.Sprint("Async job ", jobId, " not found"))
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.jobs[jobId]; !ok {
+		err = errors.New(fmt.Sprint("Async job ", jobId, " not found"))
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	return q.readJob(ctx, q.jobPath(jobId))
+}
+
+// Purge removes completed and failed jobs last updated before the given time
+func (q *AsyncFileQueue) Purge(ctx context.Context, before time.Time) (purged int, err error) {
+	logs.WithContext(ctx).Debug("AsyncFileQueue Purge - Start")
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for jobId, j := range q.jobs {
+		if !isAsyncJobDone(&j) || !j.UpdateDate.Before(before) {
+			continue
+		}
+		if rErr := os.Remove(q.jobPath(jobId)); rErr != nil && !os.IsNotExist(rErr) {
+			logs.WithContext(ctx).Error(rErr.Error())
+			err = rErr
+			continue
+		}
+		delete(q.jobs, jobId)
+		purged++
+	}
+	return
+}
+
+func isAsyncJobDone(job *AsyncJob) bool {
+	return job.Status == AsyncJobStatusSuccess || job.Status == AsyncJobStatusFailed
+}
+
+func isAsyncJobDue(job *AsyncJob, now time.Time) bool {
+	switch job.Status {
+	case AsyncJobStatusPending:
+		return !job.NextRunAt.After(now)
+	case AsyncJobStatusRunning:
+		return job.UpdateDate.Add(AsyncJobLease).Before(now)
+	default:
+		return false
+	}
+}
+
+// AsyncDbQueue keeps async jobs in a table of the postgres store so that jobs survive restarts and are shared across replicas
+type AsyncDbQueue struct {
+	s store.StoreI
+}
+
+func NewAsyncDbQueue(ctx context.Context, s store.StoreI) (q *AsyncDbQueue, err error) {
+	logs.WithContext(ctx).Debug("NewAsyncDbQueue - Start")
+	q = &AsyncDbQueue{s: s}
+	query := store.Queries{Query: fmt.Sprint("create table if not exists ", AsyncJobsTableName, " (job_id varchar(64) primary key, status varchar(20) not null, next_run_at timestamp with time zone not null, job jsonb not null, create_date timestamp with time zone default current_timestamp, update_date timestamp with time zone default current_timestamp)")}
+	_, err = s.ExecuteDbSave(ctx, []store.Queries{query})
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("failed to create async jobs table : ", err.Error()))
+		return nil, err
+	}
+	return
+}
+
+func (q *AsyncDbQueue) Enqueue(ctx context.Context, job *AsyncJob) (err error) {
+	logs.WithContext(ctx).Debug("AsyncDbQueue Enqueue - Start")
+	jobBytes, err := json.Marshal(job)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	query := store.Queries{Query: fmt.Sprint("insert into ", AsyncJobsTableName, " (job_id, status, next_run_at, job) values ($1, $2, $3, $4)"), Vals: []interface{}{job.JobId, job.Status, job.NextRunAt, string(jobBytes)}}
+	_, err = q.s.ExecuteDbSave(ctx, []store.Queries{query})
+	return
+}
+
+func (q *AsyncDbQueue) Dequeue(ctx context.Context, limit int) (jobs []*AsyncJob, err error) {
+	leaseSeconds := int(AsyncJobLease.Seconds())
+	//skip locked lets multiple replicas poll the same table without picking the same job
+	query := store.Queries{Query: fmt.Sprint("update ", AsyncJobsTableName, " set status = $1, update_date = current_timestamp where job_id in (select job_id from ", AsyncJobsTableName,
+		" where (status = $2 and next_run_at <= current_timestamp) or (status = $1 and update_date < current_timestamp - make_interval(secs => $3)) order by next_run_at limit $4 for update skip locked) returning job"),
+		Vals: []interface{}{AsyncJobStatusRunning, AsyncJobStatusPending, leaseSeconds, limit}}
+	output, err := q.s.ExecuteDbSave(ctx, []store.Queries{query})
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	for _, o := range output {
+		for _, row := range o {
+			job, jErr := decodeAsyncJob(ctx, row["job"])
+			if jErr != nil {
+				continue
+			}
+			job.Status = AsyncJobStatusRunning
+			job.UpdateDate = now
+			jobs = append(jobs, job)
+		}
+	}
+	return
+}
+
+func (q *AsyncDbQueue) UpdateJob(ctx context.Context, job *AsyncJob) (err error) {
+	logs.WithContext(ctx).Debug("AsyncDbQueue UpdateJob - Start")
+	jobBytes, err := json.Marshal(job)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	query := store.Queries{Query: fmt.Sprint("update ", AsyncJobsTableName, " set status = $2, next_run_at = $3, job = $4, update_date = current_timestamp where job_id = $1"), Vals: []interface{}{job.JobId, job.Status, job.NextRunAt, string(jobBytes)}}
+	_, err = q.s.ExecuteDbSave(ctx, []store.Queries{query})
+	return
+}
+
+func (q *AsyncDbQueue) GetJob(ctx context.Context, jobId string) (job *AsyncJob, err error) {
+	logs.WithContext(ctx).Debug("AsyncDbQueue GetJob - Start")
+	query := store.Queries{Query: fmt.Sprint("select job from ", AsyncJobsTableName, " where job_id = $1"), Vals: []interface{}{jobId}}
+	output, err := q.s.ExecuteDbFetch(ctx, query)
+	if err != nil {
+		return
+	}
+	if len(output) == 0 {
+		err = errors.New(fmt.Sprint("Async job ", jobId, " not found"))
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	return decodeAsyncJob(ctx, output[0]["job"])
+}
+
+// Purge removes completed and failed jobs last updated before the given time
+func (q *AsyncDbQueue) Purge(ctx context.Context, before time.Time) (purged int, err error) {
+	logs.WithContext(ctx).Debug("AsyncDbQueue Purge - Start")
+	query := store.Queries{Query: fmt.Sprint("delete from ", AsyncJobsTableName, " where status in ($1, $2) and update_date < $3 returning job_id"), Vals: []interface{}{AsyncJobStatusSuccess, AsyncJobStatusFailed, before}}
+	output, err := q.s.ExecuteDbSave(ctx, []store.Queries{query})
+	if err != nil {
+		return
+	}
+	for _, o := range output {
+		purged = purged + len(o)
+	}
+	return
+}
+
+func decodeAsyncJob(ctx context.Context, jobData interface{}) (job *AsyncJob, err error) {
+	var jobBytes []byte
+	switch v := jobData.(type) {
+	case []byte:
+		jobBytes = v
+	case string:
+		jobBytes = []byte(v)
+	default:
+		jobBytes, err = json.Marshal(v)
+		if err != nil {
+			logs.WithContext(ctx).Error(err.Error())
+			return
+		}
+	}
+	job = &AsyncJob{}
+	err = json.Unmarshal(jobBytes, job)
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("async job unmarshal failed : ", err.Error()))
+		return nil, err
+	}
+	return
+}
+
+// NewAsyncQueue returns the queue for the given queue type - POSTGRES needs a postgres store, FILE saves jobs under dir
+func NewAsyncQueue(ctx context.Context, queueType string, s store.StoreI, dir string) (AsyncQueueI, error) {
+	switch strings.ToUpper(queueType) {
+	case "POSTGRES":
+		return NewAsyncDbQueue(ctx, s)
+	case "FILE":
+		return NewAsyncFileQueue(ctx, dir)
+	default:
+		err := errors.New(fmt.Sprint("Invalid ASYNC_QUEUE ", queueType))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+}
@@ -0,0 +1,181 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAsyncJob(t *testing.T, ctx context.Context, nextRunAt time.Time) *AsyncJob {
+	job, err := newAsyncJob(ctx, "r", AsyncRetry{}, httptest.NewRequest("POST", "/x", strings.NewReader("{}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.NextRunAt = nextRunAt
+	return job
+}
+
+func TestAsyncFileQueueDequeue(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "project_id", "p")
+	q, err := NewAsyncFileQueue(ctx, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	later := newTestAsyncJob(t, ctx, now.Add(-time.Second))
+	first := newTestAsyncJob(t, ctx, now.Add(-time.Minute))
+	future := newTestAsyncJob(t, ctx, now.Add(time.Hour))
+	for _, job := range []*AsyncJob{later, first, future} {
+		if err = q.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jobs, err := q.Dequeue(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].JobId != first.JobId || jobs[0].Status != AsyncJobStatusRunning {
+		t.Fatalf("expected oldest due job to be claimed : %+v", jobs)
+	}
+	jobs, _ = q.Dequeue(ctx, 10)
+	if len(jobs) != 1 || jobs[0].JobId != later.JobId {
+		t.Fatalf("expected only remaining due job to be claimed : %+v", jobs)
+	}
+	if jobs, _ = q.Dequeue(ctx, 10); len(jobs) != 0 {
+		t.Fatalf("running and future jobs are not expected to be claimed : %+v", jobs)
+	}
+
+	// running job of a dead worker is claimed again after lease
+	job, _ := q.GetJob(ctx, first.JobId)
+	job.UpdateDate = now.Add(-AsyncJobLease - time.Second)
+	_ = q.UpdateJob(ctx, job)
+	if jobs, _ = q.Dequeue(ctx, 10); len(jobs) != 1 || jobs[0].JobId != first.JobId {
+		t.Fatalf("expected job with expired lease to be claimed again : %+v", jobs)
+	}
+
+	// index is rebuilt from disk on restart
+	q2, err := NewAsyncFileQueue(ctx, q.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q2.jobs) != 3 {
+		t.Fatalf("expected 3 jobs after reload, got %d", len(q2.jobs))
+	}
+	if _, err = q2.GetJob(ctx, future.JobId); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = q2.GetJob(ctx, "../../etc/passwd"); err == nil {
+		t.Fatal("invalid job id is expected to fail")
+	}
+}
+
+func TestAsyncFileQueuePurge(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "project_id", "p")
+	q, err := NewAsyncFileQueue(ctx, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	tests := []struct {
+		status     string
+		updateDate time.Time
+		wantPurged bool
+	}{
+		{AsyncJobStatusSuccess, old, true},
+		{AsyncJobStatusFailed, old, true},
+		{AsyncJobStatusSuccess, time.Now(), false},
+		{AsyncJobStatusPending, old, false},
+		{AsyncJobStatusRunning, old, false},
+	}
+	var jobs []*AsyncJob
+	for _, tt := range tests {
+		job := newTestAsyncJob(t, ctx, time.Now())
+		job.Status = tt.status
+		job.UpdateDate = tt.updateDate
+		if err = q.Enqueue(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, job)
+	}
+	purged, err := q.Purge(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Errorf("expected 2 jobs to be purged, got %d", purged)
+	}
+	for i, tt := range tests {
+		_, statErr := os.Stat(q.jobPath(jobs[i].JobId))
+		if tt.wantPurged != os.IsNotExist(statErr) {
+			t.Errorf("%s job updated at %v : purged = %v, want %v", tt.status, tt.updateDate, os.IsNotExist(statErr), tt.wantPurged)
+		}
+	}
+}
+
+func TestAsyncJobCredentials(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "project_id", "p")
+	defer SetAsyncCredentialsKey("")
+	var gotAuth, gotCookie, gotOther string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotCookie, gotOther = r.Header.Get("Authorization"), r.Header.Get("Cookie"), r.Header.Get("X-Other")
+	}))
+	defer server.Close()
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest("POST", server.URL, strings.NewReader("{}"))
+		r.Header.Set("Authorization", "Bearer secret-token")
+		r.Header.Set("Cookie", "session=secret-session")
+		r.Header.Set("X-Other", "v")
+		return r
+	}
+
+	// credentials are encrypted with the key and sent again to the target host
+	SetAsyncCredentialsKey("k")
+	q, err := NewAsyncFileQueue(ctx, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newAsyncJob(ctx, "r", AsyncRetry{}, newRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = q.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	jobBytes, _ := os.ReadFile(filepath.Join(q.dir, job.JobId+".json"))
+	if strings.Contains(string(jobBytes), "secret") {
+		t.Fatalf("credential headers are saved in plain text : %s", jobBytes)
+	}
+	runAsyncJob(ctx, job)
+	if job.Status != AsyncJobStatusSuccess || gotAuth != "Bearer secret-token" || gotCookie != "session=secret-session" || gotOther != "v" {
+		t.Fatalf("expected credential headers to reach target host : %s %s %s %s", job.Status, gotAuth, gotCookie, gotOther)
+	}
+
+	// job sealed with another key fails instead of calling target without credentials
+	SetAsyncCredentialsKey("other")
+	job.Status = AsyncJobStatusPending
+	runAsyncJob(ctx, job)
+	if job.Status != AsyncJobStatusFailed {
+		t.Fatalf("expected job to fail when credentials cannot be decrypted : %s", job.Status)
+	}
+
+	// credentials are dropped without key
+	SetAsyncCredentialsKey("")
+	job, err = newAsyncJob(ctx, "r", AsyncRetry{}, newRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Request.Credentials != nil || job.Request.Header.Get("Authorization") != "" || job.Request.Header.Get("Cookie") != "" {
+		t.Fatalf("credential headers are expected to be dropped : %+v", job.Request)
+	}
+	gotAuth, gotCookie = "", ""
+	runAsyncJob(ctx, job)
+	if gotAuth != "" || gotCookie != "" || gotOther != "v" {
+		t.Fatalf("unexpected headers at target host : %s %s %s", gotAuth, gotCookie, gotOther)
+	}
+}
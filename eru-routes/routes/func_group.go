@@ -59,6 +59,7 @@ type FuncStep struct {
 	TransformResponse    string
 	IsPublic             bool
 	RemoveParams         RemoveParams
+	Policy               RoutePolicy
 	FuncSteps            map[string]*FuncStep
 }
 
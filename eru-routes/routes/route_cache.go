@@ -0,0 +1,337 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"github.com/eru-tech/eru/eru-store/store"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CacheStatusHeader      = "X-Eru-Cache"
+	CacheStatusHit         = "HIT"
+	CacheStatusMiss        = "MISS"
+	CacheStatusBypass      = "BYPASS"
+	RouteCacheTableName    = "eruroutes_route_cache"
+	defaultCacheTTL        = 60
+	defaultCacheMaxEntries = 10000
+)
+
+// RouteCache is set at startup and holds responses of routes which have EnableCache set
+var RouteCache RouteCacheI
+
+type RouteCacheI interface {
+	Get(ctx context.Context, key string) (*CachedResponse, bool)
+	Set(ctx context.Context, key string, projectId string, routeName string, cr *CachedResponse) error
+	Purge(ctx context.Context, projectId string, routeName string) (int64, error)
+}
+
+type CacheConfig struct {
+	TTLSeconds int
+	Methods    []string
+	KeyHeaders []string
+	KeyParams  []string
+	Shared     bool //response is same for all users - claims and Authorization header are left out of the key
+}
+
+// userKeyHeaders identify the user of the request and are part of the cache key unless the route cache is shared
+var userKeyHeaders = []string{"claims", "Authorization"}
+
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	CreateDate time.Time
+	ExpiresAt  time.Time
+}
+
+func (cc CacheConfig) ttl() time.Duration {
+	if cc.TTLSeconds <= 0 {
+		return defaultCacheTTL * time.Second
+	}
+	return time.Duration(cc.TTLSeconds) * time.Second
+}
+
+func (cc CacheConfig) isCacheableMethod(method string) bool {
+	if len(cc.Methods) == 0 {
+		return method == http.MethodGet || method == http.MethodHead
+	}
+	for _, m := range cc.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheKey returns the cache key of the incoming request and false if request is not cacheable for the route.
+// Claims, Authorization and Origin headers are always part of the key, unless the route cache is Shared which leaves out claims
+// and Authorization, along with headers and params listed in CacheConfig.
+func (route *Route) CacheKey(ctx context.Context, projectId string, r *http.Request) (string, bool) {
+	logs.WithContext(ctx).Debug("CacheKey - Start")
+	if !route.EnableCache || route.Async || !route.CacheConfig.isCacheableMethod(r.Method) {
+		return "", false
+	}
+	var keyParts []string
+	keyParts = append(keyParts, projectId, route.RouteName, r.Method, r.URL.Path)
+	//cors headers of the cached response are replayed as is, so responses to different origins are never shared
+	keyHeaders := []string{"Origin"}
+	if !route.CacheConfig.Shared {
+		keyHeaders = append(keyHeaders, userKeyHeaders...)
+	}
+	for _, h := range append(keyHeaders, route.CacheConfig.KeyHeaders...) {
+		keyParts = append(keyParts, fmt.Sprint(strings.ToLower(h), "=", strings.Join(r.Header.Values(h), ",")))
+	}
+	reqParams := r.URL.Query()
+	if len(route.CacheConfig.KeyParams) == 0 {
+		//url.Values.Encode sorts on key so that order of params in url does not change the key
+		keyParts = append(keyParts, reqParams.Encode())
+	} else {
+		keyParams := append([]string{}, route.CacheConfig.KeyParams...)
+		sort.Strings(keyParams)
+		for _, p := range keyParams {
+			keyParts = append(keyParts, fmt.Sprint(p, "=", strings.Join(reqParams[p], ",")))
+		}
+	}
+	hash := sha256.Sum256([]byte(strings.Join(keyParts, "|")))
+	return hex.EncodeToString(hash[:]), true
+}
+
+func cacheControlDirectives(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, cc := range header.Values("Cache-Control") {
+		for _, d := range strings.Split(cc, ",") {
+			kv := strings.SplitN(strings.TrimSpace(d), "=", 2)
+			if kv[0] == "" {
+				continue
+			}
+			if len(kv) == 2 {
+				directives[strings.ToLower(kv[0])] = strings.Trim(kv[1], "\"")
+			} else {
+				directives[strings.ToLower(kv[0])] = ""
+			}
+		}
+	}
+	return directives
+}
+
+// IsCacheBypassed returns true if client asked for a fresh response using Cache-Control no-cache or no-store
+func IsCacheBypassed(r *http.Request) bool {
+	directives := cacheControlDirectives(r.Header)
+	_, noCache := directives["no-cache"]
+	_, noStore := directives["no-store"]
+	return noCache || noStore || r.Header.Get("Pragma") == "no-cache"
+}
+
+// cacheTTL returns ttl for the response - a max-age or s-maxage lower than route ttl wins and
+// no-store, no-cache or private responses are not cached at all
+func (route *Route) cacheTTL(response *http.Response) time.Duration {
+	directives := cacheControlDirectives(response.Header)
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return 0
+		}
+	}
+	ttl := route.CacheConfig.ttl()
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[d]; ok {
+			if secs, err := strconv.Atoi(v); err == nil {
+				if time.Duration(secs)*time.Second < ttl {
+					ttl = time.Duration(secs) * time.Second
+				}
+				break
+			}
+		}
+	}
+	return ttl
+}
+
+// CacheResponse saves the response in route cache if it is cacheable and replaces the consumed response body
+func (route *Route) CacheResponse(ctx context.Context, key string, projectId string, response *http.Response) {
+	logs.WithContext(ctx).Debug("CacheResponse - Start")
+	if RouteCache == nil || route.Async || response.StatusCode != http.StatusOK || response.Header.Get("Set-Cookie") != "" {
+		return
+	}
+	ttl := route.cacheTTL(response)
+	if ttl <= 0 {
+		return
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("error in io.ReadAll(response.Body) : ", err.Error()))
+		return
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	now := time.Now()
+	cr := &CachedResponse{StatusCode: response.StatusCode, Header: response.Header.Clone(), Body: body, CreateDate: now, ExpiresAt: now.Add(ttl)}
+	err = RouteCache.Set(ctx, key, projectId, route.RouteName, cr)
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("failed to cache response of route ", route.RouteName, " : ", err.Error()))
+	}
+}
+
+type memoryCacheEntry struct {
+	projectId string
+	routeName string
+	response  *CachedResponse
+}
+
+// MemoryRouteCache keeps responses in memory of this server - each replica has its own cache
+type MemoryRouteCache struct {
+	mu         sync.Mutex
+	entries    map[string]*memoryCacheEntry
+	maxEntries int
+}
+
+func NewMemoryRouteCache(maxEntries int) *MemoryRouteCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	return &MemoryRouteCache{entries: make(map[string]*memoryCacheEntry), maxEntries: maxEntries}
+}
+
+func (mc *MemoryRouteCache) Get(ctx context.Context, key string) (*CachedResponse, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	e, ok := mc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.response.ExpiresAt) {
+		delete(mc.entries, key)
+		return nil, false
+	}
+	return e.response, true
+}
+
+func (mc *MemoryRouteCache) Set(ctx context.Context, key string, projectId string, routeName string, cr *CachedResponse) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if _, ok := mc.entries[key]; !ok && len(mc.entries) >= mc.maxEntries {
+		mc.evict()
+	}
+	mc.entries[key] = &memoryCacheEntry{projectId: projectId, routeName: routeName, response: cr}
+	return nil
+}
+
+// evict removes expired entries and, if cache is still full, the entry closest to expiry
+func (mc *MemoryRouteCache) evict() {
+	now := time.Now()
+	oldestKey := ""
+	var oldestExpiry time.Time
+	for k, e := range mc.entries {
+		if now.After(e.response.ExpiresAt) {
+			delete(mc.entries, k)
+			continue
+		}
+		if oldestKey == "" || e.response.ExpiresAt.Before(oldestExpiry) {
+			oldestKey = k
+			oldestExpiry = e.response.ExpiresAt
+		}
+	}
+	if len(mc.entries) >= mc.maxEntries && oldestKey != "" {
+		delete(mc.entries, oldestKey)
+	}
+}
+
+func (mc *MemoryRouteCache) Purge(ctx context.Context, projectId string, routeName string) (int64, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	var purged int64
+	for k, e := range mc.entries {
+		if e.projectId == projectId && (routeName == "" || e.routeName == routeName) {
+			delete(mc.entries, k)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// DbRouteCache keeps responses in a table of the postgres store so that cache is shared across replicas
+type DbRouteCache struct {
+	s store.StoreI
+}
+
+func NewDbRouteCache(ctx context.Context, s store.StoreI) (dc *DbRouteCache, err error) {
+	logs.WithContext(ctx).Debug("NewDbRouteCache - Start")
+	dc = &DbRouteCache{s: s}
+	query := store.Queries{Query: fmt.Sprint("create table if not exists ", RouteCacheTableName, " (cache_key varchar(64) primary key, project_id varchar(200) not null, route_name varchar(200) not null, response jsonb not null, expires_at timestamp with time zone not null)")}
+	_, err = s.ExecuteDbSave(ctx, []store.Queries{query})
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("failed to create route cache table : ", err.Error()))
+		return nil, err
+	}
+	return
+}
+
+func (dc *DbRouteCache) Get(ctx context.Context, key string) (*CachedResponse, bool) {
+	query := store.Queries{Query: fmt.Sprint("select response from ", RouteCacheTableName, " where cache_key = $1 and expires_at > current_timestamp"), Vals: []interface{}{key}}
+	output, err := dc.s.ExecuteDbFetch(ctx, query)
+	if err != nil || len(output) == 0 {
+		return nil, false
+	}
+	crBytes, err := json.Marshal(output[0]["response"])
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, false
+	}
+	cr := &CachedResponse{}
+	if err = json.Unmarshal(crBytes, cr); err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, false
+	}
+	return cr, true
+}
+
+func (dc *DbRouteCache) Set(ctx context.Context, key string, projectId string, routeName string, cr *CachedResponse) (err error) {
+	crBytes, err := json.Marshal(cr)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	queries := []store.Queries{
+		{Query: fmt.Sprint("delete from ", RouteCacheTableName, " where expires_at <= current_timestamp")},
+		{Query: fmt.Sprint("insert into ", RouteCacheTableName, " (cache_key, project_id, route_name, response, expires_at) values ($1, $2, $3, $4, $5) on conflict (cache_key) do update set response = excluded.response, expires_at = excluded.expires_at"),
+			Vals: []interface{}{key, projectId, routeName, string(crBytes), cr.ExpiresAt}},
+	}
+	_, err = dc.s.ExecuteDbSave(ctx, queries)
+	return
+}
+
+func (dc *DbRouteCache) Purge(ctx context.Context, projectId string, routeName string) (purged int64, err error) {
+	query := store.Queries{Query: fmt.Sprint("delete from ", RouteCacheTableName, " where project_id = $1 and ($2 = '' or route_name = $2) returning cache_key"), Vals: []interface{}{projectId, routeName}}
+	output, err := dc.s.ExecuteDbSave(ctx, []store.Queries{query})
+	if err != nil {
+		return
+	}
+	for _, o := range output {
+		purged = purged + int64(len(o))
+	}
+	return
+}
+
+// NewRouteCache returns the cache for the given cache type - POSTGRES needs a postgres store
+func NewRouteCache(ctx context.Context, cacheType string, s store.StoreI, maxEntries int) (RouteCacheI, error) {
+	switch strings.ToUpper(cacheType) {
+	case "POSTGRES":
+		return NewDbRouteCache(ctx, s)
+	case "MEMORY":
+		return NewMemoryRouteCache(maxEntries), nil
+	default:
+		err := errors.New(fmt.Sprint("Invalid ROUTE_CACHE ", cacheType))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+}
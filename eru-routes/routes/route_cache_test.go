@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouteCacheKey(t *testing.T) {
+	ctx := context.Background()
+	base := func() *http.Request {
+		r := httptest.NewRequest("GET", "/r/x?a=1&b=2", nil)
+		r.Header.Set("Authorization", "Bearer u1")
+		r.Header.Set("claims", `{"sub":"u1"}`)
+		r.Header.Set("Origin", "https://a.com")
+		return r
+	}
+	route := Route{RouteName: "r", EnableCache: true}
+	shared := Route{RouteName: "r", EnableCache: true, CacheConfig: CacheConfig{Shared: true}}
+	tests := []struct {
+		name     string
+		route    Route
+		change   func(r *http.Request)
+		wantSame bool
+	}{
+		{"same request", route, func(r *http.Request) {}, true},
+		{"param order", route, func(r *http.Request) { r.URL.RawQuery = "b=2&a=1" }, true},
+		{"other param value", route, func(r *http.Request) { r.URL.RawQuery = "a=1&b=3" }, false},
+		{"other user token", route, func(r *http.Request) { r.Header.Set("Authorization", "Bearer u2") }, false},
+		{"other user claims", route, func(r *http.Request) { r.Header.Set("claims", `{"sub":"u2"}`) }, false},
+		{"other origin", route, func(r *http.Request) { r.Header.Set("Origin", "https://b.com") }, false},
+		{"shared cache ignores token", shared, func(r *http.Request) { r.Header.Set("Authorization", "Bearer u2") }, true},
+		{"shared cache ignores claims", shared, func(r *http.Request) { r.Header.Set("claims", `{"sub":"u2"}`) }, true},
+		{"shared cache keeps origin", shared, func(r *http.Request) { r.Header.Set("Origin", "https://b.com") }, false},
+		{"unlisted header", route, func(r *http.Request) { r.Header.Set("X-Other", "1") }, true},
+		{"listed header", Route{RouteName: "r", EnableCache: true, CacheConfig: CacheConfig{KeyHeaders: []string{"X-Other"}}}, func(r *http.Request) { r.Header.Set("X-Other", "1") }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k1, ok1 := tt.route.CacheKey(ctx, "p", base())
+			r := base()
+			tt.change(r)
+			k2, ok2 := tt.route.CacheKey(ctx, "p", r)
+			if !ok1 || !ok2 {
+				t.Fatal("request is expected to be cacheable")
+			}
+			if (k1 == k2) != tt.wantSame {
+				t.Errorf("same key = %v, want %v", k1 == k2, tt.wantSame)
+			}
+		})
+	}
+
+	notCacheable := []struct {
+		name  string
+		route Route
+		r     *http.Request
+	}{
+		{"cache disabled", Route{RouteName: "r"}, base()},
+		{"async route", Route{RouteName: "r", EnableCache: true, Async: true}, base()},
+		{"post", route, httptest.NewRequest("POST", "/r/x", nil)},
+	}
+	for _, tt := range notCacheable {
+		if _, ok := tt.route.CacheKey(ctx, "p", tt.r); ok {
+			t.Errorf("%s is not expected to be cacheable", tt.name)
+		}
+	}
+}
+
+func TestRouteCacheResponse(t *testing.T) {
+	ctx := context.Background()
+	defer func() { RouteCache = nil }()
+	newResponse := func(status int, header http.Header) *http.Response {
+		return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader("body"))}
+	}
+	tests := []struct {
+		name      string
+		route     Route
+		response  *http.Response
+		wantTTL   time.Duration
+		wantCache bool
+	}{
+		{"default ttl", Route{RouteName: "r"}, newResponse(200, http.Header{}), defaultCacheTTL * time.Second, true},
+		{"lower max-age wins", Route{RouteName: "r"}, newResponse(200, http.Header{"Cache-Control": {"max-age=10"}}), 10 * time.Second, true},
+		{"no-store", Route{RouteName: "r"}, newResponse(200, http.Header{"Cache-Control": {"no-store"}}), 0, false},
+		{"error status", Route{RouteName: "r"}, newResponse(500, http.Header{}), 0, false},
+		{"set cookie", Route{RouteName: "r"}, newResponse(200, http.Header{"Set-Cookie": {"a=b"}}), 0, false},
+		{"async route", Route{RouteName: "r", Async: true}, newResponse(202, http.Header{}), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := NewMemoryRouteCache(10)
+			RouteCache = mc
+			tt.route.CacheResponse(ctx, "k", "p", tt.response)
+			cr, ok := mc.Get(ctx, "k")
+			if ok != tt.wantCache {
+				t.Fatalf("cached = %v, want %v", ok, tt.wantCache)
+			}
+			if body, _ := io.ReadAll(tt.response.Body); string(body) != "body" {
+				t.Errorf("response body is expected to be readable after caching : %s", body)
+			}
+			if ok {
+				if ttl := cr.ExpiresAt.Sub(cr.CreateDate); ttl != tt.wantTTL {
+					t.Errorf("ttl = %v, want %v", ttl, tt.wantTTL)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRouteCacheEvictAndPurge(t *testing.T) {
+	ctx := context.Background()
+	mc := NewMemoryRouteCache(2)
+	now := time.Now()
+	_ = mc.Set(ctx, "k1", "p", "r1", &CachedResponse{ExpiresAt: now.Add(time.Minute)})
+	_ = mc.Set(ctx, "k2", "p", "r2", &CachedResponse{ExpiresAt: now.Add(time.Hour)})
+	_ = mc.Set(ctx, "k3", "p", "r2", &CachedResponse{ExpiresAt: now.Add(time.Hour)})
+	if _, ok := mc.Get(ctx, "k1"); ok {
+		t.Error("entry closest to expiry is expected to be evicted")
+	}
+	if purged, _ := mc.Purge(ctx, "p", "r2"); purged != 2 {
+		t.Errorf("expected 2 entries of route to be purged, got %d", purged)
+	}
+	_ = mc.Set(ctx, "k4", "p", "r1", &CachedResponse{ExpiresAt: now.Add(-time.Second)})
+	if _, ok := mc.Get(ctx, "k4"); ok {
+		t.Error("expired entry is not expected to be returned")
+	}
+}
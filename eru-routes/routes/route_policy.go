@@ -0,0 +1,270 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryBackoffMs     = 100
+	maxRetryBackoffShift      = 10 //backoff stops doubling after 10 attempts
+	defaultCircuitOpenSeconds = 30
+	defaultFallbackStatusCode = http.StatusServiceUnavailable
+	circuitStateClosed        = "CLOSED"
+	circuitStateOpen          = "OPEN"
+	circuitStateHalfOpen      = "HALF_OPEN"
+	CircuitStatusHeader       = "X-Eru-Circuit"
+)
+
+type RoutePolicy struct {
+	TimeoutSeconds   int
+	Retries          int
+	RetryBackoffMs   int
+	RetryStatusCodes []int
+	CircuitBreaker   CircuitBreaker
+}
+
+type CircuitBreaker struct {
+	FailureThreshold   int
+	OpenSeconds        int
+	FallbackStatusCode int
+	FallbackResponse   string
+}
+
+type circuit struct {
+	state    string
+	failures int
+	openedAt time.Time
+	trialRun bool
+}
+
+type circuitRegistry struct {
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+var circuits = &circuitRegistry{circuits: make(map[string]*circuit)}
+
+// IsSet returns true if any policy attribute is configured
+func (p RoutePolicy) IsSet() bool {
+	return p.TimeoutSeconds > 0 || p.Retries > 0 || p.CircuitBreaker.FailureThreshold > 0
+}
+
+func (p RoutePolicy) isRetryStatus(statusCode int) bool {
+	if len(p.RetryStatusCodes) == 0 {
+		return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+	}
+	for _, s := range p.RetryStatusCodes {
+		if s == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RoutePolicy) retryBackoff(attempt int) time.Duration {
+	backoffMs := p.RetryBackoffMs
+	if backoffMs <= 0 {
+		backoffMs = defaultRetryBackoffMs
+	}
+	if attempt > maxRetryBackoffShift {
+		attempt = maxRetryBackoffShift
+	}
+	return time.Duration(backoffMs) * time.Millisecond << uint(attempt)
+}
+
+func (cb CircuitBreaker) openDuration() time.Duration {
+	if cb.OpenSeconds <= 0 {
+		return defaultCircuitOpenSeconds * time.Second
+	}
+	return time.Duration(cb.OpenSeconds) * time.Second
+}
+
+// allow returns false while circuit is open. Once open duration elapses, a single trial request is let through.
+func (cr *circuitRegistry) allow(ctx context.Context, key string, cb CircuitBreaker) bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	c, ok := cr.circuits[key]
+	if !ok {
+		return true
+	}
+	switch c.state {
+	case circuitStateOpen:
+		if time.Since(c.openedAt) < cb.openDuration() {
+			return false
+		}
+		logs.WithContext(ctx).Info(fmt.Sprint("circuit ", key, " is half open - allowing trial request"))
+		c.state = circuitStateHalfOpen
+		c.trialRun = true
+		return true
+	case circuitStateHalfOpen:
+		if c.trialRun {
+			return false
+		}
+		c.trialRun = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (cr *circuitRegistry) record(ctx context.Context, key string, cb CircuitBreaker, failed bool) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	c, ok := cr.circuits[key]
+	if !ok {
+		c = &circuit{state: circuitStateClosed}
+		cr.circuits[key] = c
+	}
+	if !failed {
+		if c.state != circuitStateClosed {
+			logs.WithContext(ctx).Info(fmt.Sprint("circuit ", key, " closed"))
+		}
+		c.state = circuitStateClosed
+		c.failures = 0
+		c.trialRun = false
+		return
+	}
+	c.failures++
+	if c.state == circuitStateHalfOpen || c.failures >= cb.FailureThreshold {
+		if c.state != circuitStateOpen {
+			logs.WithContext(ctx).Warn(fmt.Sprint("circuit ", key, " opened after ", c.failures, " consecutive failures"))
+		}
+		c.state = circuitStateOpen
+		c.openedAt = time.Now()
+		c.trialRun = false
+	}
+}
+
+func (route *Route) circuitKey(ctx context.Context) string {
+	projectId, _ := ctx.Value("project_id").(string)
+	if route.CircuitName != "" {
+		return fmt.Sprint(projectId, "/", route.CircuitName)
+	}
+	return fmt.Sprint(projectId, "/", route.RouteName)
+}
+
+func (route *Route) fallbackResponse(ctx context.Context, request *http.Request) *http.Response {
+	logs.WithContext(ctx).Debug("fallbackResponse - Start")
+	cb := route.Policy.CircuitBreaker
+	statusCode := cb.FallbackStatusCode
+	if statusCode == 0 {
+		statusCode = defaultFallbackStatusCode
+	}
+	body := cb.FallbackResponse
+	if body == "" {
+		body = fmt.Sprint(`{"error":"circuit open for route `, route.RouteName, `"}`)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", applicationjson)
+	header.Set(CircuitStatusHeader, circuitStateOpen)
+	return &http.Response{
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Body:          io.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+		Header:        header,
+	}
+}
+
+// executeWithPolicy applies timeout, retries with exponential backoff and circuit breaker of the route
+// around executeWithFailover which takes care of moving to next target host
+func (route *Route) executeWithPolicy(ctx context.Context, request *http.Request) (response *http.Response, err error) {
+	logs.WithContext(ctx).Debug("executeWithPolicy - Start")
+	policy := route.Policy
+	if !policy.IsSet() {
+		return route.executeWithFailover(ctx, request)
+	}
+
+	//body is read before the circuit lets the request through so that a bad request never holds the trial of a half open circuit
+	var body []byte
+	if request.Body != nil {
+		body, err = io.ReadAll(request.Body)
+		if err != nil {
+			logs.WithContext(ctx).Error(fmt.Sprint("error in io.ReadAll(request.Body) : ", err.Error()))
+			return
+		}
+	}
+
+	cKey := route.circuitKey(ctx)
+	cbEnabled := policy.CircuitBreaker.FailureThreshold > 0
+	if cbEnabled {
+		if !circuits.allow(ctx, cKey, policy.CircuitBreaker) {
+			logs.WithContext(ctx).Warn(fmt.Sprint("circuit open for route ", route.RouteName, " - returning fallback response"))
+			return route.fallbackResponse(ctx, request), nil
+		}
+		defer func() {
+			circuits.record(ctx, cKey, policy.CircuitBreaker, err != nil || response == nil || response.StatusCode >= http.StatusInternalServerError)
+		}()
+	}
+
+	for attempt := 0; attempt <= policy.Retries; attempt++ {
+		if attempt > 0 {
+			backoff := policy.retryBackoff(attempt - 1)
+			logs.WithContext(ctx).Info(fmt.Sprint("retrying route ", route.RouteName, " attempt ", attempt, " after ", backoff))
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				err = ctx.Err()
+				logs.WithContext(ctx).Error(err.Error())
+				return
+			}
+		}
+		if request.Body != nil {
+			request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		response, err = route.executeAttempt(ctx, request, policy)
+		if err == nil && !policy.isRetryStatus(response.StatusCode) {
+			break
+		}
+		if err != nil {
+			logs.WithContext(ctx).Error(fmt.Sprint("route ", route.RouteName, " attempt ", attempt, " failed : ", err.Error()))
+		} else {
+			logs.WithContext(ctx).Warn(fmt.Sprint("route ", route.RouteName, " attempt ", attempt, " returned status ", response.StatusCode))
+			if attempt < policy.Retries {
+				//response of the last attempt is returned as is - earlier ones are discarded to release the connection
+				_, _ = io.Copy(io.Discard, response.Body)
+				response.Body.Close()
+			}
+		}
+	}
+	return
+}
+
+// executeAttempt runs a single attempt within the timeout of the policy. Response body is read before the timeout
+// context is cancelled as it cannot be read afterwards.
+func (route *Route) executeAttempt(ctx context.Context, request *http.Request, policy RoutePolicy) (response *http.Response, err error) {
+	if policy.TimeoutSeconds <= 0 {
+		return route.executeWithFailover(ctx, request)
+	}
+	actx, cancel := context.WithTimeout(ctx, time.Duration(policy.TimeoutSeconds)*time.Second)
+	defer cancel()
+	attemptRequest := request.WithContext(actx)
+	response, err = route.executeWithFailover(actx, attemptRequest)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = errors.New(fmt.Sprint("route ", route.RouteName, " timed out after ", policy.TimeoutSeconds, " seconds"))
+			logs.WithContext(ctx).Error(err.Error())
+		}
+		return
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("error in io.ReadAll(response.Body) : ", err.Error()))
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	response.Request = request
+	return
+}
@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newPolicyTestServer(statuses ...int) (*httptest.Server, *int64) {
+	var hits int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&hits, 1)
+		status := statuses[len(statuses)-1]
+		if int(n) <= len(statuses) {
+			status = statuses[n-1]
+		}
+		w.WriteHeader(status)
+	}))
+	return server, &hits
+}
+
+func resetCircuits() {
+	circuits = &circuitRegistry{circuits: make(map[string]*circuit)}
+}
+
+func TestExecuteWithPolicyRetries(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "project_id", "p")
+	tests := []struct {
+		name       string
+		policy     RoutePolicy
+		statuses   []int
+		wantStatus int
+		wantHits   int64
+	}{
+		{"retry on 503", RoutePolicy{Retries: 2, RetryBackoffMs: 1}, []int{503, 200}, 200, 2},
+		{"retries exhausted", RoutePolicy{Retries: 2, RetryBackoffMs: 1}, []int{503}, 503, 3},
+		{"500 is not retried by default", RoutePolicy{Retries: 2, RetryBackoffMs: 1}, []int{500, 200}, 500, 1},
+		{"configured retry status", RoutePolicy{Retries: 2, RetryBackoffMs: 1, RetryStatusCodes: []int{500}}, []int{500, 200}, 200, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetCircuits()
+			server, hits := newPolicyTestServer(tt.statuses...)
+			defer server.Close()
+			route := Route{RouteName: "r", Policy: tt.policy}
+			request, _ := http.NewRequest("POST", server.URL, strings.NewReader("{}"))
+			response, err := route.executeWithPolicy(ctx, request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.StatusCode != tt.wantStatus || atomic.LoadInt64(hits) != tt.wantHits {
+				t.Errorf("status %d after %d hits, want %d after %d hits", response.StatusCode, atomic.LoadInt64(hits), tt.wantStatus, tt.wantHits)
+			}
+		})
+	}
+}
+
+func TestExecuteWithPolicyCircuitBreaker(t *testing.T) {
+	resetCircuits()
+	ctx := context.WithValue(context.Background(), "project_id", "p")
+	server, hits := newPolicyTestServer(500)
+	defer server.Close()
+	route := Route{RouteName: "r", Policy: RoutePolicy{CircuitBreaker: CircuitBreaker{FailureThreshold: 2, FallbackStatusCode: 503}}}
+	execute := func(ctx context.Context, route Route) *http.Response {
+		request, _ := http.NewRequest("GET", server.URL, nil)
+		response, _ := route.executeWithPolicy(ctx, request)
+		return response
+	}
+	execute(ctx, route)
+	execute(ctx, route)
+	response := execute(ctx, route)
+	if response.StatusCode != 503 || response.Header.Get(CircuitStatusHeader) != circuitStateOpen || atomic.LoadInt64(hits) != 2 {
+		t.Fatalf("expected fallback response from open circuit : %d %d", response.StatusCode, atomic.LoadInt64(hits))
+	}
+
+	// func step overriding the policy has a circuit of its own
+	funcStepRoute := route
+	funcStepRoute.CircuitName = "r/funcstep/s"
+	if response = execute(ctx, funcStepRoute); response.Header.Get(CircuitStatusHeader) == circuitStateOpen {
+		t.Fatal("circuit of func step is not expected to be opened by failures of the route")
+	}
+
+	// trial request cancelled during backoff is recorded so that circuit does not stay half open
+	circuits.circuits["p/r"].openedAt = time.Now().Add(-time.Hour)
+	route.Policy.Retries = 1
+	route.Policy.RetryBackoffMs = 10000
+	route.Policy.RetryStatusCodes = []int{500}
+	cctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	execute(cctx, route)
+	c := circuits.circuits["p/r"]
+	if c.state != circuitStateOpen || c.trialRun {
+		t.Fatalf("circuit is expected to be open again after failed trial : %+v", c)
+	}
+
+	// successful trial closes the circuit
+	c.openedAt = time.Now().Add(-time.Hour)
+	okServer, _ := newPolicyTestServer(200)
+	defer okServer.Close()
+	request, _ := http.NewRequest("GET", okServer.URL, nil)
+	if response, _ = route.executeWithPolicy(ctx, request); response.StatusCode != 200 || c.state != circuitStateClosed {
+		t.Fatalf("circuit is expected to close after successful trial : %d %s", response.StatusCode, c.state)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		policy  RoutePolicy
+		attempt int
+		want    time.Duration
+	}{
+		{RoutePolicy{}, 0, defaultRetryBackoffMs * time.Millisecond},
+		{RoutePolicy{RetryBackoffMs: 10}, 2, 40 * time.Millisecond},
+		{RoutePolicy{RetryBackoffMs: 10}, maxRetryBackoffShift, 10240 * time.Millisecond},
+		{RoutePolicy{RetryBackoffMs: 10}, 100, 10240 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.retryBackoff(tt.attempt); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -691,7 +691,7 @@ func stripSingleElement(obj interface{}) interface{} {
 func protect(f func()) {
 	defer func() {
 		if err := recover(); err != nil {
-			logs.Logger.Panic(fmt.Sprint("Recovered: %v", err))
+			logs.Logger.Panic(fmt.Sprint("Recovered: ", err))
 		}
 	}()
 
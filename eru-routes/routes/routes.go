@@ -62,6 +62,7 @@ type Route struct {
 	AllowedMethods       []string
 	RequiredHeaders      []Headers
 	EnableCache          bool
+	CacheConfig          CacheConfig
 	RequestHeaders       []Headers
 	QueryParams          []Headers
 	FormData             []Headers
@@ -80,6 +81,11 @@ type Route struct {
 	FinalRedirectUrl     string `json:"-"`
 	RedirectScheme       string
 	RedirectParams       []Headers
+	HealthCheck          HealthCheck
+	AsyncRetry           AsyncRetry
+	Policy               RoutePolicy
+	CircuitName          string `json:"-"` //circuit of a func step which overrides policy of the route - route name is used if blank
+	RateLimit            utils.RateLimit
 }
 
 type RemoveParams struct {
@@ -249,13 +255,16 @@ func (route *Route) Validate(ctx context.Context, host string, url string, metho
 
 func (route *Route) getTargetHost(ctx context.Context) (targetHost TargetHost, err error) {
 	logs.WithContext(ctx).Debug("getTargetHost - Start")
-	//TODO Random selection of target based on allocation
-	if len(route.TargetHosts) > 0 {
+	if len(route.TargetHosts) == 0 {
+		err = errors.New(fmt.Sprint("No Target Host defined for this route :", route.RouteName))
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	if len(route.TargetHosts) == 1 {
 		return route.TargetHosts[0], err
 	}
-	err = errors.New(fmt.Sprint("No Target Host defined for this route :", route.RouteName))
-	logs.WithContext(ctx).Error(err.Error())
-	return
+	route.startHealthChecks(ctx)
+	return route.selectTargetHost(ctx, nil)
 }
 
 func (route *Route) Execute(ctx context.Context, request *http.Request, url string, async bool, asyncMsg string, trReqVars *TemplateVars, loopThread int) (response *http.Response, trResVar *TemplateVars, resErr error) {
@@ -408,23 +417,18 @@ func (route *Route) RunRoute(ctx context.Context, req *http.Request, url string,
 
 	if request.Host != "" {
 		if route.Async || async {
-			//creating a new context with 1ms to give sufficient time to execute the http request and
-			// timeout without waiting for the response
-
-			ctxAsync, cancel := context.WithTimeout(ctx, 1*time.Millisecond)
-			defer cancel()
-			request = request.WithContext(ctxAsync)
-			_, err = utils.ExecuteHttp(ctx, request)
+			//request is saved in async queue with its transformed body, headers and target so that it is executed
+			//by async workers with retries even if this server restarts before the target host is reached
+			jobId, enqErr := EnqueueAsync(ctx, route.RouteName, route.AsyncRetry, request)
+			if enqErr != nil {
+				err = enqErr
+				return
+			}
 
 			respHeader := http.Header{}
 			respHeader.Set("Content-Type", applicationjson)
+			respHeader.Set(AsyncJobIdHeader, jobId)
 
-			if errors.Is(err, context.DeadlineExceeded) {
-				//ignoring DeadlineExceeded error as we know it will timeout
-				err = nil
-			} else {
-				return
-			}
 			body := "{}"
 
 			if asyncMsg != "" {
@@ -439,6 +443,7 @@ func (route *Route) RunRoute(ctx context.Context, req *http.Request, url string,
 				}
 				body = string(output)
 			}
+			body = addAsyncJobId(body, jobId)
 
 			response = &http.Response{
 				Status:     "200 OK",
@@ -458,7 +463,7 @@ func (route *Route) RunRoute(ctx context.Context, req *http.Request, url string,
 			logs.Logger.Info(request.URL.String())
 			logs.Logger.Info(request.Header.Get("content-type"))
 			logs.Logger.Info(request.Method)
-			response, err = utils.ExecuteHttp(ctx, request)
+			response, err = route.executeWithPolicy(ctx, request)
 			if err != nil {
 				return
 			}
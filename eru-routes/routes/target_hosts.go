@@ -0,0 +1,418 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	utils "github.com/eru-tech/eru/eru-utils"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval  = 10
+	defaultHealthCheckTimeout   = 5
+	defaultHealthyThreshold     = 2
+	defaultUnhealthyThreshold   = 3
+	defaultUnhealthyCooldown    = 30
+	defaultHealthCheckMethod    = http.MethodGet
+	defaultHealthCheckStatusMin = 200
+	defaultHealthCheckStatusMax = 399
+)
+
+type HealthCheck struct {
+	Path               string
+	Method             string
+	IntervalSeconds    int
+	TimeoutSeconds     int
+	HealthyThreshold   int
+	UnhealthyThreshold int
+	CooldownSeconds    int
+	ExpectedStatus     []int
+	DisableFailover    bool
+	FailoverAllMethods bool // fail over non-idempotent requests on 502/503/504 response as well - target may have processed them
+}
+
+type targetHealth struct {
+	healthy   bool
+	failures  int
+	successes int
+	retryAt   time.Time
+}
+
+// healthChecker is the set of active health checks of a route - signature changes when target hosts or health check of the route change
+type healthChecker struct {
+	signature string
+	cancel    context.CancelFunc
+}
+
+type targetHealthRegistry struct {
+	mu       sync.Mutex
+	targets  map[string]*targetHealth
+	checkers map[string]*healthChecker //projectId/routeName is the key
+}
+
+var targetRegistry = &targetHealthRegistry{
+	targets:  make(map[string]*targetHealth),
+	checkers: make(map[string]*healthChecker),
+}
+
+func (targetHost TargetHost) key() string {
+	hostPort := targetHost.Host
+	if targetHost.Port != "" {
+		hostPort = fmt.Sprint(hostPort, ":", targetHost.Port)
+	}
+	return fmt.Sprint(targetHost.Scheme, "://", hostPort)
+}
+
+func (hc HealthCheck) interval() time.Duration {
+	if hc.IntervalSeconds <= 0 {
+		return defaultHealthCheckInterval * time.Second
+	}
+	return time.Duration(hc.IntervalSeconds) * time.Second
+}
+
+func (hc HealthCheck) timeout() time.Duration {
+	if hc.TimeoutSeconds <= 0 {
+		return defaultHealthCheckTimeout * time.Second
+	}
+	return time.Duration(hc.TimeoutSeconds) * time.Second
+}
+
+func (hc HealthCheck) cooldown() time.Duration {
+	if hc.CooldownSeconds <= 0 {
+		return defaultUnhealthyCooldown * time.Second
+	}
+	return time.Duration(hc.CooldownSeconds) * time.Second
+}
+
+func (hc HealthCheck) healthyThreshold() int {
+	if hc.HealthyThreshold <= 0 {
+		return defaultHealthyThreshold
+	}
+	return hc.HealthyThreshold
+}
+
+func (hc HealthCheck) unhealthyThreshold() int {
+	if hc.UnhealthyThreshold <= 0 {
+		return defaultUnhealthyThreshold
+	}
+	return hc.UnhealthyThreshold
+}
+
+func (hc HealthCheck) isExpectedStatus(statusCode int) bool {
+	if len(hc.ExpectedStatus) == 0 {
+		return statusCode >= defaultHealthCheckStatusMin && statusCode <= defaultHealthCheckStatusMax
+	}
+	for _, s := range hc.ExpectedStatus {
+		if s == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+func (tr *targetHealthRegistry) get(key string) *targetHealth {
+	th, ok := tr.targets[key]
+	if !ok {
+		th = &targetHealth{healthy: true}
+		tr.targets[key] = th
+	}
+	return th
+}
+
+// isAvailable returns true for a healthy target or an unhealthy target whose cooldown has elapsed, so that live traffic can probe it again
+func (tr *targetHealthRegistry) isAvailable(key string) bool {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	th := tr.get(key)
+	return th.healthy || time.Now().After(th.retryAt)
+}
+
+func (tr *targetHealthRegistry) recordSuccess(ctx context.Context, key string, hc HealthCheck) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	th := tr.get(key)
+	th.failures = 0
+	th.successes++
+	if !th.healthy && th.successes >= hc.healthyThreshold() {
+		th.healthy = true
+		logs.WithContext(ctx).Info(fmt.Sprint("target host ", key, " marked healthy"))
+	}
+}
+
+func (tr *targetHealthRegistry) recordFailure(ctx context.Context, key string, hc HealthCheck) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	th := tr.get(key)
+	th.successes = 0
+	th.failures++
+	if th.healthy && th.failures >= hc.unhealthyThreshold() {
+		th.healthy = false
+		logs.WithContext(ctx).Warn(fmt.Sprint("target host ", key, " marked unhealthy after ", th.failures, " consecutive failures"))
+	}
+	if !th.healthy {
+		th.retryAt = time.Now().Add(hc.cooldown())
+	}
+}
+
+// startChecker returns context for health checks of the route and false if health checks with same signature are already running.
+// health checks started with an earlier signature of the route are stopped.
+func (tr *targetHealthRegistry) startChecker(routeKey string, signature string) (context.Context, bool) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if hc, ok := tr.checkers[routeKey]; ok {
+		if hc.signature == signature {
+			return nil, false
+		}
+		hc.cancel()
+	}
+	checkCtx, cancel := context.WithCancel(context.Background())
+	tr.checkers[routeKey] = &healthChecker{signature: signature, cancel: cancel}
+	return checkCtx, true
+}
+
+func (tr *targetHealthRegistry) stopChecker(routeKey string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if hc, ok := tr.checkers[routeKey]; ok {
+		hc.cancel()
+		delete(tr.checkers, routeKey)
+	}
+}
+
+// StopHealthChecks stops active health checks of the route - called when the route is saved or removed
+func StopHealthChecks(ctx context.Context, projectId string, routeName string) {
+	logs.WithContext(ctx).Debug("StopHealthChecks - Start")
+	targetRegistry.stopChecker(fmt.Sprint(projectId, "/", routeName))
+}
+
+// healthCheckSignature returns target hosts and health check of the route as string to detect change in them
+func (route *Route) healthCheckSignature() string {
+	sigBytes, _ := json.Marshal(struct {
+		TargetHosts []TargetHost
+		HealthCheck HealthCheck
+	}{route.TargetHosts, route.HealthCheck})
+	return string(sigBytes)
+}
+
+func (route *Route) startHealthChecks(ctx context.Context) {
+	projectId, _ := ctx.Value("project_id").(string)
+	routeKey := fmt.Sprint(projectId, "/", route.RouteName)
+	if route.HealthCheck.Path == "" {
+		targetRegistry.stopChecker(routeKey)
+		return
+	}
+	checkCtx, started := targetRegistry.startChecker(routeKey, route.healthCheckSignature())
+	if !started {
+		return
+	}
+	for _, th := range route.TargetHosts {
+		logs.WithContext(ctx).Info(fmt.Sprint("starting health check for ", th.key(), route.HealthCheck.Path, " of route ", routeKey))
+		go runHealthCheck(checkCtx, th, route.HealthCheck)
+	}
+}
+
+// runHealthCheck checks the target host every interval till ctx is cancelled
+func runHealthCheck(ctx context.Context, targetHost TargetHost, hc HealthCheck) {
+	defer func() {
+		if r := recover(); r != nil {
+			logs.WithContext(ctx).Error(fmt.Sprint("goroutine panicked in runHealthCheck: ", r))
+		}
+	}()
+	method := hc.Method
+	if method == "" {
+		method = defaultHealthCheckMethod
+	}
+	client := http.Client{
+		Timeout: hc.timeout(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	checkUrl := fmt.Sprint(targetHost.key(), hc.Path)
+	ticker := time.NewTicker(hc.interval())
+	defer ticker.Stop()
+	for {
+		checkTarget(ctx, client, method, checkUrl, targetHost.key(), hc)
+		select {
+		case <-ctx.Done():
+			logs.WithContext(ctx).Info(fmt.Sprint("health check stopped for ", checkUrl))
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func checkTarget(ctx context.Context, client http.Client, method string, checkUrl string, key string, hc HealthCheck) {
+	req, err := http.NewRequestWithContext(ctx, method, checkUrl, nil)
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("health check request creation failed for ", checkUrl, " : ", err.Error()))
+		return
+	}
+	resp, err := client.Do(req)
+	if ctx.Err() != nil {
+		// health check stopped - result of the cancelled request is not recorded
+		if err == nil {
+			resp.Body.Close()
+		}
+		return
+	}
+	if err != nil {
+		logs.WithContext(ctx).Warn(fmt.Sprint("health check failed for ", key, " : ", err.Error()))
+		targetRegistry.recordFailure(ctx, key, hc)
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if hc.isExpectedStatus(resp.StatusCode) {
+		targetRegistry.recordSuccess(ctx, key, hc)
+	} else {
+		logs.WithContext(ctx).Warn(fmt.Sprint("health check for ", key, " returned status ", resp.StatusCode))
+		targetRegistry.recordFailure(ctx, key, hc)
+	}
+}
+
+// selectTargetHost picks a target at random weighted on Allocation, skipping excluded and unhealthy targets.
+// If every target is unhealthy, it falls back to all targets not excluded rather than failing the request.
+func (route *Route) selectTargetHost(ctx context.Context, exclude map[string]bool) (targetHost TargetHost, err error) {
+	logs.WithContext(ctx).Debug("selectTargetHost - Start")
+	var candidates []TargetHost
+	var fallback []TargetHost
+	for _, th := range route.TargetHosts {
+		if exclude[th.key()] {
+			continue
+		}
+		fallback = append(fallback, th)
+		if targetRegistry.isAvailable(th.key()) {
+			candidates = append(candidates, th)
+		}
+	}
+	if len(candidates) == 0 {
+		if len(fallback) == 0 {
+			err = errors.New(fmt.Sprint("No Target Host available for this route :", route.RouteName))
+			logs.WithContext(ctx).Error(err.Error())
+			return
+		}
+		logs.WithContext(ctx).Warn(fmt.Sprint("all target hosts are unhealthy for route ", route.RouteName, " - ignoring health status"))
+		candidates = fallback
+	}
+	var totalAllocation int64
+	for _, th := range candidates {
+		if th.Allocation > 0 {
+			totalAllocation = totalAllocation + th.Allocation
+		}
+	}
+	if totalAllocation == 0 {
+		return candidates[rand.Intn(len(candidates))], nil
+	}
+	pick := rand.Int63n(totalAllocation)
+	for _, th := range candidates {
+		if th.Allocation <= 0 {
+			continue
+		}
+		if pick < th.Allocation {
+			return th, nil
+		}
+		pick = pick - th.Allocation
+	}
+	return candidates[len(candidates)-1], nil
+}
+
+func isTargetFailure(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch response.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func isIdempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// executeWithFailover sends the request to the target host already set by transformRequest and, on a connection error,
+// retries it on the next available target host till all target hosts are tried
+// a 502/503/504 response is retried only for idempotent methods unless the route opts in with FailoverAllMethods
+func (route *Route) executeWithFailover(ctx context.Context, request *http.Request) (response *http.Response, err error) {
+	logs.WithContext(ctx).Debug("executeWithFailover - Start")
+	currentKey := fmt.Sprint(request.URL.Scheme, "://", request.URL.Host)
+	if len(route.TargetHosts) < 2 || route.HealthCheck.DisableFailover {
+		response, err = utils.ExecuteHttp(ctx, request)
+		route.recordTargetResult(ctx, currentKey, response, err)
+		return
+	}
+
+	var body []byte
+	if request.Body != nil {
+		body, err = io.ReadAll(request.Body)
+		if err != nil {
+			logs.WithContext(ctx).Error(fmt.Sprint("error in io.ReadAll(request.Body) : ", err.Error()))
+			return
+		}
+	}
+	tried := make(map[string]bool)
+	for {
+		tried[currentKey] = true
+		if request.Body != nil {
+			request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		response, err = utils.ExecuteHttp(ctx, request)
+		route.recordTargetResult(ctx, currentKey, response, err)
+		if !isTargetFailure(response, err) {
+			return
+		}
+		if err == nil && !isIdempotentMethod(request.Method) && !route.HealthCheck.FailoverAllMethods {
+			//target may have processed the request - returning the response as is
+			return
+		}
+		nextHost, nhErr := route.selectTargetHost(ctx, tried)
+		if nhErr != nil {
+			//no more target hosts to try - returning last response or error as is
+			return
+		}
+		if err != nil {
+			logs.WithContext(ctx).Warn(fmt.Sprint("target host ", currentKey, " failed with error : ", err.Error(), " - retrying on ", nextHost.key()))
+		} else {
+			logs.WithContext(ctx).Warn(fmt.Sprint("target host ", currentKey, " returned status ", response.StatusCode, " - retrying on ", nextHost.key()))
+			_, _ = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+		err = nil
+		port := ""
+		if nextHost.Port != "" {
+			port = fmt.Sprint(":", nextHost.Port)
+		}
+		request.Host = nextHost.Host
+		request.URL.Host = fmt.Sprint(nextHost.Host, port)
+		request.URL.Scheme = nextHost.Scheme
+		if nextHost.Method != "" {
+			request.Method = nextHost.Method
+		}
+		currentKey = nextHost.key()
+	}
+}
+
+func (route *Route) recordTargetResult(ctx context.Context, key string, response *http.Response, err error) {
+	if isTargetFailure(response, err) {
+		targetRegistry.recordFailure(ctx, key, route.HealthCheck)
+	} else {
+		targetRegistry.recordSuccess(ctx, key, route.HealthCheck)
+	}
+}
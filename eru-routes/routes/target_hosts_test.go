@@ -0,0 +1,194 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logs.LogInit("eru-routes-test")
+	os.Exit(m.Run())
+}
+
+func resetTargetRegistry() {
+	targetRegistry = &targetHealthRegistry{targets: make(map[string]*targetHealth), checkers: make(map[string]*healthChecker)}
+}
+
+func TestSelectTargetHost(t *testing.T) {
+	ctx := context.Background()
+	a := TargetHost{Host: "a", Scheme: "http", Allocation: 80}
+	b := TargetHost{Host: "b", Scheme: "http", Allocation: 20}
+	c := TargetHost{Host: "c", Scheme: "http"}
+	tests := []struct {
+		name      string
+		hosts     []TargetHost
+		exclude   map[string]bool
+		unhealthy []TargetHost
+		wantShare map[string]float64 // expected share of picks of each host
+		wantErr   bool
+	}{
+		{"weighted on allocation", []TargetHost{a, b}, nil, nil, map[string]float64{"a": 0.8, "b": 0.2}, false},
+		{"host without allocation is not picked if others have allocation", []TargetHost{a, c}, nil, nil, map[string]float64{"a": 1}, false},
+		{"equal share without allocations", []TargetHost{{Host: "c", Scheme: "http"}, {Host: "d", Scheme: "http"}}, nil, nil, map[string]float64{"c": 0.5, "d": 0.5}, false},
+		{"excluded host is skipped", []TargetHost{a, b}, map[string]bool{a.key(): true}, nil, map[string]float64{"b": 1}, false},
+		{"unhealthy host is skipped", []TargetHost{a, b}, nil, []TargetHost{a}, map[string]float64{"b": 1}, false},
+		{"all unhealthy falls back to all hosts", []TargetHost{a, b}, nil, []TargetHost{a, b}, map[string]float64{"a": 0.8, "b": 0.2}, false},
+		{"all excluded", []TargetHost{a, b}, map[string]bool{a.key(): true, b.key(): true}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTargetRegistry()
+			hc := HealthCheck{UnhealthyThreshold: 1, CooldownSeconds: 60}
+			for _, th := range tt.unhealthy {
+				targetRegistry.recordFailure(ctx, th.key(), hc)
+			}
+			route := Route{RouteName: "r", TargetHosts: tt.hosts}
+			picks := make(map[string]int)
+			const n = 5000
+			for i := 0; i < n; i++ {
+				th, err := route.selectTargetHost(ctx, tt.exclude)
+				if tt.wantErr {
+					if err == nil {
+						t.Fatal("expected error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				picks[th.Host]++
+			}
+			for host := range picks {
+				if _, ok := tt.wantShare[host]; !ok {
+					t.Errorf("host %s is not expected to be picked", host)
+				}
+			}
+			for host, share := range tt.wantShare {
+				got := float64(picks[host]) / n
+				if got < share-0.05 || got > share+0.05 {
+					t.Errorf("host %s picked %.2f of requests, want %.2f", host, got, share)
+				}
+			}
+		})
+	}
+}
+
+func TestTargetHealthThresholds(t *testing.T) {
+	ctx := context.Background()
+	resetTargetRegistry()
+	hc := HealthCheck{UnhealthyThreshold: 2, HealthyThreshold: 2, CooldownSeconds: 60}
+	key := "http://a"
+	targetRegistry.recordFailure(ctx, key, hc)
+	if !targetRegistry.isAvailable(key) {
+		t.Fatal("target is expected to be available before unhealthy threshold")
+	}
+	targetRegistry.recordFailure(ctx, key, hc)
+	if targetRegistry.isAvailable(key) {
+		t.Fatal("target is expected to be unavailable after unhealthy threshold")
+	}
+	targetRegistry.recordSuccess(ctx, key, hc)
+	if targetRegistry.isAvailable(key) {
+		t.Fatal("target is expected to be unavailable before healthy threshold")
+	}
+	targetRegistry.recordSuccess(ctx, key, hc)
+	if !targetRegistry.isAvailable(key) {
+		t.Fatal("target is expected to be available after healthy threshold")
+	}
+}
+
+func TestHealthChecksStopOnRouteChange(t *testing.T) {
+	resetTargetRegistry()
+	var hits int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+	}))
+	defer server.Close()
+	hostPort := strings.TrimPrefix(server.URL, "http://")
+	th := TargetHost{Host: strings.Split(hostPort, ":")[0], Port: strings.Split(hostPort, ":")[1], Scheme: "http"}
+	ctx := context.WithValue(context.Background(), "project_id", "p")
+	route := Route{RouteName: "r", TargetHosts: []TargetHost{th, {Host: "localhost", Port: "1", Scheme: "http"}}, HealthCheck: HealthCheck{Path: "/health", IntervalSeconds: 1}}
+	route.startHealthChecks(ctx)
+	first := targetRegistry.checkers["p/r"]
+	time.Sleep(200 * time.Millisecond)
+	if atomic.LoadInt64(&hits) == 0 {
+		t.Fatal("health check is expected to have run")
+	}
+	route.startHealthChecks(ctx)
+	if targetRegistry.checkers["p/r"] != first {
+		t.Fatal("health checks are not expected to restart for same route config")
+	}
+
+	// changed target hosts stop earlier health checks
+	route.TargetHosts = route.TargetHosts[1:]
+	route.startHealthChecks(ctx)
+	if targetRegistry.checkers["p/r"] == first {
+		t.Fatal("health checks are expected to restart for changed target hosts")
+	}
+	StopHealthChecks(ctx, "p", "r")
+	if _, ok := targetRegistry.checkers["p/r"]; ok {
+		t.Fatal("health checks are expected to be removed")
+	}
+	stoppedAt := atomic.LoadInt64(&hits)
+	time.Sleep(1500 * time.Millisecond)
+	if atomic.LoadInt64(&hits) != stoppedAt {
+		t.Errorf("stopped health check is still running")
+	}
+}
+
+func TestExecuteWithFailover(t *testing.T) {
+	ctx := context.Background()
+	newTarget := func(status int, hits *int64) (TargetHost, func()) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(hits, 1)
+			w.WriteHeader(status)
+		}))
+		hostPort := strings.Split(strings.TrimPrefix(server.URL, "http://"), ":")
+		return TargetHost{Host: hostPort[0], Port: hostPort[1], Scheme: "http"}, server.Close
+	}
+	tests := []struct {
+		name         string
+		method       string
+		healthCheck  HealthCheck
+		wantStatus   int
+		wantFailover bool
+	}{
+		{"idempotent method", http.MethodGet, HealthCheck{}, http.StatusOK, true},
+		{"non-idempotent method", http.MethodPost, HealthCheck{}, http.StatusServiceUnavailable, false},
+		{"non-idempotent method with opt in", http.MethodPost, HealthCheck{FailoverAllMethods: true}, http.StatusOK, true},
+		{"failover disabled", http.MethodGet, HealthCheck{DisableFailover: true}, http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTargetRegistry()
+			var downHits, upHits int64
+			down, closeDown := newTarget(http.StatusServiceUnavailable, &downHits)
+			defer closeDown()
+			up, closeUp := newTarget(http.StatusOK, &upHits)
+			defer closeUp()
+			up.Allocation = 0
+			down.Allocation = 100
+			route := Route{RouteName: "r", TargetHosts: []TargetHost{down, up}, HealthCheck: tt.healthCheck}
+			request := httptest.NewRequest(tt.method, fmt.Sprint("http://", down.Host, ":", down.Port, "/"), strings.NewReader("{}"))
+			request.RequestURI = ""
+			response, err := route.executeWithFailover(ctx, request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+			if gotFailover := atomic.LoadInt64(&upHits) == 1; gotFailover != tt.wantFailover {
+				t.Errorf("failover = %v, want %v", gotFailover, tt.wantFailover)
+			}
+		})
+	}
+}
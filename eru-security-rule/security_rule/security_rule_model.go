@@ -7,6 +7,7 @@ import (
 	"fmt"
 	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
 	"github.com/eru-tech/eru/eru-templates/gotemplate"
+	"strconv"
 	"strings"
 )
 
@@ -136,6 +137,176 @@ func stringifyRule(ctx context.Context, cd CustomRuleDetails, conditionType stri
 	return fmt.Sprint(cd.Variable1, op, cd.Variable2), nil
 }
 
+// Evaluate returns true if variables satisfy the rule - it is used where rule is checked in code instead of being added to sql.
+// rule without any condition is always satisfied.
+func (cr CustomRule) Evaluate(ctx context.Context, vars map[string]interface{}) (bool, error) {
+	logs.WithContext(ctx).Debug("Evaluate - Start")
+	if len(cr.AND) > 0 {
+		return evaluateRuleClause(ctx, cr.AND, "and", vars)
+	}
+	if len(cr.OR) > 0 {
+		return evaluateRuleClause(ctx, cr.OR, "or", vars)
+	}
+	return true, nil
+}
+
+// IsEmpty returns true if rule has no condition
+func (cr CustomRule) IsEmpty() bool {
+	return len(cr.AND) == 0 && len(cr.OR) == 0
+}
+
+func evaluateRuleClause(ctx context.Context, rules []CustomRuleDetails, conditionType string, vars map[string]interface{}) (result bool, err error) {
+	logs.WithContext(ctx).Debug("evaluateRuleClause - Start")
+	for _, v := range rules {
+		if len(v.AND) > 0 {
+			result, err = evaluateRuleClause(ctx, v.AND, "and", vars)
+		} else if len(v.OR) > 0 {
+			result, err = evaluateRuleClause(ctx, v.OR, "or", vars)
+		} else {
+			result, err = evaluateRule(ctx, v, vars)
+		}
+		if err != nil {
+			return false, err
+		}
+		if conditionType == "or" && result {
+			return true, nil
+		}
+		if conditionType == "and" && !result {
+			return false, nil
+		}
+	}
+	return conditionType == "and", nil
+}
+
+func evaluateRule(ctx context.Context, cd CustomRuleDetails, vars map[string]interface{}) (result bool, err error) {
+	logs.WithContext(ctx).Debug("evaluateRule - Start")
+	var1, err := ruleVariableValue(ctx, cd.Variable1, vars)
+	if err != nil {
+		return false, err
+	}
+	var2, err := ruleVariableValue(ctx, cd.Variable2, vars)
+	if err != nil {
+		return false, err
+	}
+	switch cd.Operator {
+	case "eq":
+		return compareRuleValues(var1, var2) == 0, nil
+	case "ne":
+		return compareRuleValues(var1, var2) != 0, nil
+	case "gt":
+		return compareRuleValues(var1, var2) > 0, nil
+	case "gte":
+		return compareRuleValues(var1, var2) >= 0, nil
+	case "lt":
+		return compareRuleValues(var1, var2) < 0, nil
+	case "lte":
+		return compareRuleValues(var1, var2) <= 0, nil
+	case "in", "nin":
+		found := false
+		for _, v := range splitRuleValues(var2) {
+			if compareRuleValues(var1, v) == 0 {
+				found = true
+				break
+			}
+		}
+		return found == (cd.Operator == "in"), nil
+	case "btw":
+		// variable2 holds the range as from,to
+		btw := splitRuleValues(var2)
+		if len(btw) != 2 {
+			err = errors.New(fmt.Sprint("btw operator needs two comma separated values but received ", var2))
+			logs.WithContext(ctx).Error(err.Error())
+			return false, err
+		}
+		return compareRuleValues(var1, btw[0]) >= 0 && compareRuleValues(var1, btw[1]) <= 0, nil
+	case "like":
+		return strings.Contains(var1, var2), nil
+	case "eq_null":
+		return var1 == "", nil
+	case "neq_null":
+		return var1 != "", nil
+	default:
+		err = errors.New(fmt.Sprint("operator ", cd.Operator, " not supported"))
+		logs.WithContext(ctx).Error(err.Error())
+		return false, err
+	}
+}
+
+// ruleVariableValue returns value of the variable from vars - variable without a prefix is a constant and returned as is
+// variable prefixed with docs refers to field of the document in vars e.g. docs.status - missing field is returned as blank
+func ruleVariableValue(ctx context.Context, variable string, vars map[string]interface{}) (string, error) {
+	if ruleValue := strings.SplitN(variable, ".", 2); len(ruleValue) > 1 && ruleValue[0] == "docs" {
+		if doc, ok := vars["docs"].(map[string]interface{}); ok {
+			return docFieldValue(doc, ruleValue[1]), nil
+		}
+	}
+	valueBytes, err := processTemplate(ctx, "customrule", variable, vars, "string")
+	if err != nil {
+		if err.Error() == "no variable prefix found" {
+			return variable, nil
+		}
+		return "", err
+	}
+	value := string(valueBytes)
+	if value == "<no value>" {
+		value = ""
+	}
+	return value, nil
+}
+
+// docFieldValue returns value of the field of the document as string - path of a nested field is separated by dot
+func docFieldValue(doc map[string]interface{}, path string) string {
+	var value interface{} = doc
+	for _, k := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[k]
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		valueBytes, _ := json.Marshal(v)
+		return string(valueBytes)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// IsVariable returns true if the rule variable refers to a value in vars e.g. token.user_id - other values are constants or column names
+func IsVariable(variable string) bool {
+	return strings.SplitN(variable, ".", 2)[0] == "token"
+}
+
+// VariableValue returns value of the rule variable from vars - variable without a prefix is returned as is
+func VariableValue(ctx context.Context, variable string, vars map[string]interface{}) (string, error) {
+	return ruleVariableValue(ctx, variable, vars)
+}
+
+// compareRuleValues compares values as numbers if both are numbers else as strings
+func compareRuleValues(var1 string, var2 string) int {
+	f1, err1 := strconv.ParseFloat(var1, 64)
+	f2, err2 := strconv.ParseFloat(var2, 64)
+	if err1 == nil && err2 == nil {
+		if f1 < f2 {
+			return -1
+		} else if f1 > f2 {
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(var1, var2)
+}
+
+func splitRuleValues(str string) (values []string) {
+	for _, v := range strings.Split(str, ",") {
+		values = append(values, strings.Trim(strings.TrimSpace(v), "'\""))
+	}
+	return values
+}
+
 func processTemplate(ctx context.Context, templateName string, templateString string, vars map[string]interface{}, outputType string) (output []byte, err error) {
 	logs.WithContext(ctx).Debug("processTemplate - Start")
 	logs.WithContext(ctx).Info(fmt.Sprint("templateString = ", templateString))
@@ -4,6 +4,7 @@ go 1.20
 
 require (
 	github.com/eru-tech/eru/eru-logs v0.0.0-00010101000000-000000000000
+	github.com/eru-tech/eru/eru-repos v0.0.0-00010101000000-000000000000
 	github.com/eru-tech/eru/eru-store v0.0.0-00010101000000-000000000000
 	github.com/google/uuid v1.3.0
 	github.com/gorilla/mux v1.8.0
@@ -27,6 +28,6 @@ require (
 
 replace (
 	github.com/eru-tech/eru/eru-logs => ../eru-logs
-	github.com/eru-tech/eru/eru-store => ../eru-store
 	github.com/eru-tech/eru/eru-repos => ../eru-repos
+	github.com/eru-tech/eru/eru-store => ../eru-store
 )
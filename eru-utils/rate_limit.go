@@ -0,0 +1,319 @@
+package eru_utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	models "github.com/eru-tech/eru/eru-models"
+	"github.com/jmoiron/sqlx"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitKeyTypeIP       = "IP"
+	RateLimitKeyTypeHeader   = "HEADER"
+	RateLimitKeyTypeClaim    = "CLAIM"
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	RateLimitResetHeader     = "X-RateLimit-Reset"
+	RateLimitTableName       = "eru_rate_limits"
+	rateLimitSweepInterval   = time.Minute
+)
+
+// RateLimiter is used by gateway and routes to enforce rate limits - startup replaces it with a shared limiter if configured
+var RateLimiter RateLimiterI = NewMemoryRateLimiter()
+
+type RateLimiterI interface {
+	Allow(ctx context.Context, bucketKey string, rl RateLimit) (RateLimitResult, error)
+}
+
+// RateLimit is a token bucket which refills Requests tokens every PeriodSeconds and holds at most Burst tokens.
+// Each client, identified by KeyType and Key, gets its own bucket.
+type RateLimit struct {
+	Requests      int64
+	PeriodSeconds int64
+	Burst         int64
+	KeyType       string
+	Key           string
+}
+
+type RateLimitResult struct {
+	Allowed           bool
+	Limit             int64
+	Remaining         int64
+	ResetSeconds      int64
+	RetryAfterSeconds int64
+}
+
+// IsSet returns true if rate limit is configured
+func (rl RateLimit) IsSet() bool {
+	return rl.Requests > 0
+}
+
+func (rl RateLimit) capacity() float64 {
+	if rl.Burst > 0 {
+		return float64(rl.Burst)
+	}
+	return float64(rl.Requests)
+}
+
+// rate returns tokens added per second
+func (rl RateLimit) rate() float64 {
+	period := rl.PeriodSeconds
+	if period <= 0 {
+		period = 1
+	}
+	return float64(rl.Requests) / float64(period)
+}
+
+func (rl RateLimit) result(tokens float64, allowed bool) (res RateLimitResult) {
+	res.Allowed = allowed
+	res.Limit = int64(rl.capacity())
+	res.Remaining = int64(math.Max(0, math.Floor(tokens)))
+	res.ResetSeconds = int64(math.Ceil((rl.capacity() - tokens) / rl.rate()))
+	if !allowed {
+		res.RetryAfterSeconds = int64(math.Ceil((1 - tokens) / rl.rate()))
+	}
+	return
+}
+
+// ClientKey returns the value which identifies the client of the request for the rate limit.
+// claims are the verified claims of the token of the request - nil if request is not authorized with a token.
+// Requests without the configured header or claim fall back to client ip so that they share no bucket with other clients.
+func (rl RateLimit) ClientKey(ctx context.Context, r *http.Request, claims interface{}) string {
+	switch strings.ToUpper(rl.KeyType) {
+	case RateLimitKeyTypeHeader:
+		if hv := r.Header.Get(rl.Key); hv != "" {
+			return fmt.Sprint("header:", hv)
+		}
+	case RateLimitKeyTypeClaim:
+		if cv := claimValue(claims, rl.Key); cv != "" {
+			return fmt.Sprint("claim:", cv)
+		}
+	default:
+		//do nothing - ip is used as key
+	}
+	return fmt.Sprint("ip:", ClientIP(r))
+}
+
+// ClientIP returns the ip of the remote address of the request without port
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// ParseClaims returns claims from claims header set by gateway after verifying the token - nil if header is not set or is invalid
+func ParseClaims(ctx context.Context, claimsStr string) (claims interface{}) {
+	if claimsStr == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(claimsStr), &claims); err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("failed to parse claims header : ", err.Error()))
+		return nil
+	}
+	return claims
+}
+
+// claimValue returns value of the claim from verified claims - nested claims are referred with dot e.g. tenant.id
+func claimValue(claims interface{}, claimKey string) string {
+	if claims == nil || claimKey == "" {
+		return ""
+	}
+	for _, k := range strings.Split(claimKey, ".") {
+		claimsMap, ok := claims.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		claims = claimsMap[k]
+	}
+	if claims == nil {
+		return ""
+	}
+	return fmt.Sprint(claims)
+}
+
+// SetRateLimitHeaders adds X-RateLimit-* headers, and Retry-After if request was throttled, to the response
+func SetRateLimitHeaders(header http.Header, res RateLimitResult) {
+	header.Set(RateLimitLimitHeader, strconv.FormatInt(res.Limit, 10))
+	header.Set(RateLimitRemainingHeader, strconv.FormatInt(res.Remaining, 10))
+	header.Set(RateLimitResetHeader, strconv.FormatInt(res.ResetSeconds, 10))
+	if !res.Allowed {
+		header.Set("Retry-After", strconv.FormatInt(res.RetryAfterSeconds, 10))
+	}
+}
+
+// CheckRateLimit takes a token from the bucket of the client of the request within the given scope.
+// Limiter errors are logged and the request is allowed as throttling must not take down the api.
+func CheckRateLimit(ctx context.Context, r *http.Request, scope string, rl RateLimit, claims interface{}) RateLimitResult {
+	logs.WithContext(ctx).Debug("CheckRateLimit - Start")
+	bucketKey := fmt.Sprint(scope, "|", rl.ClientKey(ctx, r, claims))
+	res, err := RateLimiter.Allow(ctx, bucketKey, rl)
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("rate limiter failed - allowing request : ", err.Error()))
+		return RateLimitResult{Allowed: true, Limit: int64(rl.capacity()), Remaining: int64(rl.capacity())}
+	}
+	if !res.Allowed {
+		logs.WithContext(ctx).Warn(fmt.Sprint("rate limit exceeded for ", bucketKey))
+	}
+	return res
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	fullAt    time.Time
+}
+
+// MemoryRateLimiter keeps token buckets in memory of this server - each replica enforces the limit on its own
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: make(map[string]*tokenBucket), lastSweep: time.Now()}
+}
+
+func (ml *MemoryRateLimiter) Allow(ctx context.Context, bucketKey string, rl RateLimit) (RateLimitResult, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	now := time.Now()
+	if now.Sub(ml.lastSweep) > rateLimitSweepInterval {
+		ml.sweep(now)
+	}
+	b, ok := ml.buckets[bucketKey]
+	if !ok {
+		b = &tokenBucket{tokens: rl.capacity(), updatedAt: now}
+		ml.buckets[bucketKey] = b
+	}
+	b.tokens = math.Min(rl.capacity(), b.tokens+now.Sub(b.updatedAt).Seconds()*rl.rate())
+	b.updatedAt = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.fullAt = now.Add(time.Duration((rl.capacity() - b.tokens) / rl.rate() * float64(time.Second)))
+	return rl.result(b.tokens, allowed), nil
+}
+
+// sweep removes buckets which are full again as they are same as a new bucket
+func (ml *MemoryRateLimiter) sweep(now time.Time) {
+	for k, b := range ml.buckets {
+		if now.After(b.fullAt) {
+			delete(ml.buckets, k)
+		}
+	}
+	ml.lastSweep = now
+}
+
+// DbRateLimiter keeps token buckets in a postgres table so that all replicas enforce the same limit
+type DbRateLimiter struct {
+	db        *sqlx.DB
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewDbRateLimiter(ctx context.Context, dbType string, dbString string) (dl *DbRateLimiter, err error) {
+	logs.WithContext(ctx).Debug("NewDbRateLimiter - Start")
+	if dbString == "" {
+		err = errors.New("db connection string not provided for rate limiter")
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	db, err := sqlx.Open(strings.ToLower(dbType), dbString)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	dl = &DbRateLimiter{db: db, lastSweep: time.Now()}
+	query := &models.Queries{Query: fmt.Sprint("create table if not exists ", RateLimitTableName, " (bucket_key varchar(500) primary key, tokens double precision not null, allowed boolean not null, updated_at timestamp with time zone not null)")}
+	_, err = ExecuteDbSave(ctx, db, []*models.Queries{query})
+	if err != nil {
+		logs.WithContext(ctx).Error(fmt.Sprint("failed to create rate limit table : ", err.Error()))
+		return nil, err
+	}
+	return
+}
+
+func (dl *DbRateLimiter) Allow(ctx context.Context, bucketKey string, rl RateLimit) (res RateLimitResult, err error) {
+	//refill and take happen in a single upsert so that the row lock serializes concurrent requests across replicas
+	refilled := "least($2::double precision, t.tokens + extract(epoch from (clock_timestamp() - t.updated_at)) * $3::double precision)"
+	query := &models.Queries{Query: fmt.Sprint("insert into ", RateLimitTableName, " as t (bucket_key, tokens, allowed, updated_at) values ($1, $2::double precision - 1, true, clock_timestamp())",
+		" on conflict (bucket_key) do update set tokens = case when ", refilled, " >= 1 then ", refilled, " - 1 else ", refilled, " end,",
+		" allowed = ", refilled, " >= 1, updated_at = clock_timestamp() returning tokens, allowed"),
+		Vals: []interface{}{bucketKey, rl.capacity(), rl.rate()}}
+	queries := []*models.Queries{query}
+	if dl.sweepDue() {
+		queries = append(queries, &models.Queries{Query: fmt.Sprint("delete from ", RateLimitTableName, " where updated_at < clock_timestamp() - interval '1 day'")})
+	}
+	output, err := ExecuteDbSave(ctx, dl.db, queries)
+	if err != nil {
+		return
+	}
+	if len(output) == 0 || len(output[0]) == 0 {
+		err = errors.New(fmt.Sprint("no token bucket returned for ", bucketKey))
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
+	tokens, _ := output[0][0]["tokens"].(float64)
+	allowed, _ := output[0][0]["allowed"].(bool)
+	return rl.result(tokens, allowed), nil
+}
+
+func (dl *DbRateLimiter) sweepDue() bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if time.Since(dl.lastSweep) < rateLimitSweepInterval {
+		return false
+	}
+	dl.lastSweep = time.Now()
+	return true
+}
+
+// SetRateLimiter replaces RateLimiter with the limiter set in RATE_LIMITER environment variable - MEMORY is used if not set.
+// POSTGRES limiter uses RATE_LIMITER_DB_PATH, or STORE_DB_PATH if it is not set.
+func SetRateLimiter(ctx context.Context) (err error) {
+	logs.WithContext(ctx).Debug("SetRateLimiter - Start")
+	rateLimiterType := strings.ToUpper(os.Getenv("RATE_LIMITER"))
+	if rateLimiterType == "" {
+		rateLimiterType = "MEMORY"
+		logs.WithContext(ctx).Info("'RATE_LIMITER' environment variable not found - setting default value as MEMORY")
+	}
+	rateLimiterDbPath := os.Getenv("RATE_LIMITER_DB_PATH")
+	if rateLimiterDbPath == "" {
+		rateLimiterDbPath = os.Getenv("STORE_DB_PATH")
+	}
+	rateLimiter, err := NewRateLimiter(ctx, rateLimiterType, rateLimiterDbPath)
+	if err != nil {
+		return
+	}
+	RateLimiter = rateLimiter
+	return
+}
+
+// NewRateLimiter returns the limiter for the given type - POSTGRES shares buckets across replicas using the given db
+func NewRateLimiter(ctx context.Context, limiterType string, dbString string) (RateLimiterI, error) {
+	switch strings.ToUpper(limiterType) {
+	case "POSTGRES":
+		return NewDbRateLimiter(ctx, limiterType, dbString)
+	case "MEMORY":
+		return NewMemoryRateLimiter(), nil
+	default:
+		err := errors.New(fmt.Sprint("Invalid RATE_LIMITER ", limiterType))
+		logs.WithContext(ctx).Error(err.Error())
+		return nil, err
+	}
+}
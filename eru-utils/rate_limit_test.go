@@ -0,0 +1,82 @@
+package eru_utils
+
+import (
+	"context"
+	logs "github.com/eru-tech/eru/eru-logs/eru-logs"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	logs.LogInit("eru-utils-test")
+	os.Exit(m.Run())
+}
+
+func TestRateLimitClientKey(t *testing.T) {
+	ctx := context.Background()
+	claims := map[string]interface{}{"sub": "u1", "tenant": map[string]interface{}{"id": "t1"}}
+	tests := []struct {
+		name    string
+		rl      RateLimit
+		header  map[string]string
+		claims  interface{}
+		wantKey string
+	}{
+		{"ip by default", RateLimit{}, nil, claims, "ip:10.0.0.1"},
+		{"header", RateLimit{KeyType: RateLimitKeyTypeHeader, Key: "X-Api-Key"}, map[string]string{"X-Api-Key": "k1"}, nil, "header:k1"},
+		{"missing header falls back to ip", RateLimit{KeyType: RateLimitKeyTypeHeader, Key: "X-Api-Key"}, nil, nil, "ip:10.0.0.1"},
+		{"claim", RateLimit{KeyType: "claim", Key: "sub"}, nil, claims, "claim:u1"},
+		{"nested claim", RateLimit{KeyType: RateLimitKeyTypeClaim, Key: "tenant.id"}, nil, claims, "claim:t1"},
+		{"missing claim falls back to ip", RateLimit{KeyType: RateLimitKeyTypeClaim, Key: "email"}, nil, claims, "ip:10.0.0.1"},
+		// claims header sent by the client is never used as key
+		{"claims header without verified claims", RateLimit{KeyType: RateLimitKeyTypeClaim, Key: "sub"}, map[string]string{"claims": `{"sub":"spoofed"}`}, nil, "ip:10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "10.0.0.1:5000"
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if got := tt.rl.ClientKey(ctx, r, tt.claims); got != tt.wantKey {
+				t.Errorf("ClientKey() = %s, want %s", got, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestParseClaims(t *testing.T) {
+	ctx := context.Background()
+	if c := ParseClaims(ctx, ""); c != nil {
+		t.Errorf("blank header is expected to return nil claims : %v", c)
+	}
+	if c := ParseClaims(ctx, "not json"); c != nil {
+		t.Errorf("invalid header is expected to return nil claims : %v", c)
+	}
+	if v := claimValue(ParseClaims(ctx, `{"sub":"u1"}`), "sub"); v != "u1" {
+		t.Errorf("unexpected claim value %s", v)
+	}
+}
+
+func TestMemoryRateLimiterAllow(t *testing.T) {
+	ctx := context.Background()
+	ml := NewMemoryRateLimiter()
+	rl := RateLimit{Requests: 1, PeriodSeconds: 3600, Burst: 2}
+	for i, want := range []bool{true, true, false} {
+		res, err := ml.Allow(ctx, "scope|ip:10.0.0.1", rl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != want {
+			t.Errorf("request %d allowed = %v, want %v", i, res.Allowed, want)
+		}
+		if !res.Allowed && res.RetryAfterSeconds <= 0 {
+			t.Errorf("throttled request is expected to have retry after : %+v", res)
+		}
+	}
+	// bucket of another client is not affected
+	if res, _ := ml.Allow(ctx, "scope|ip:10.0.0.2", rl); !res.Allowed || res.Remaining != 1 {
+		t.Errorf("new client is expected to get full bucket : %+v", res)
+	}
+}
@@ -198,6 +198,10 @@ func ExecuteHttp(ctx context.Context, req *http.Request) (resp *http.Response, e
 		logs.WithContext(ctx).Info(c.String())
 	}
 	resp, err = HTTPClientTransporter(http.DefaultTransport).RoundTrip(req)
+	if err != nil {
+		logs.WithContext(ctx).Error(err.Error())
+		return
+	}
 
 	allowedOriginsI := ctx.Value("allowed_origins")
 	originI := ctx.Value("origin")